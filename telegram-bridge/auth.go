@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/telegram/auth"
+	"github.com/gotd/td/telegram/auth/qrlogin"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+	"golang.org/x/term"
+)
+
+// qrAuth logs in by QR code. Login tokens expire after about 30 seconds, so
+// the code is regenerated and shown again until the token is accepted from a
+// logged-in device, which Telegram reports through loggedIn. A login that
+// needs another DC is migrated by the client. The attempt is abandoned once
+// timeout passes. The code image is written to pngPath; password is the
+// configured 2FA cloud password, if any.
+func qrAuth(ctx context.Context, client *telegram.Client, loggedIn qrlogin.LoggedIn, pngPath, password string, timeout time.Duration) error {
+	slog.Info("Not authorized. Please scan the QR code below with Telegram mobile app.")
+	slog.Info("Open Telegram app → Settings → Devices → Link Desktop Device")
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	show := func(ctx context.Context, token qrlogin.Token) error {
+		// Without any output there is nothing to scan, so give up
+		outputs, err := renderQR(token.URL(), QROpts{PNGPath: pngPath, Size: 256})
+		if err != nil {
+			return fmt.Errorf("failed to show QR code: %w", err)
+		}
+		slog.Info("QR code rendered", "outputs", strings.Join(outputs, ", "))
+		slog.Info("Waiting for the QR code to be scanned", "refresh_at", token.Expires().Format(time.TimeOnly))
+		return nil
+	}
+	_, err := client.QR().Auth(ctx, loggedIn, show)
+	switch {
+	case tgerr.Is(err, "SESSION_PASSWORD_NEEDED"):
+		if err := passwordAuth(ctx, newClientSignIn(client), password); err != nil {
+			return err
+		}
+	case errors.Is(err, context.DeadlineExceeded):
+		return fmt.Errorf("QR code was not scanned within %s", timeout)
+	case err != nil:
+		return fmt.Errorf("QR login failed: %w", err)
+	}
+
+	slog.Info("Authorization successful")
+	return nil
+}
+
+// signInClient is the part of the client used by interactive sign-in,
+// narrowed so the retry logic can be exercised against a fake
+type signInClient interface {
+	SendCode(ctx context.Context, phone string, options auth.SendCodeOptions) (tg.AuthSentCodeClass, error)
+	ResendCode(ctx context.Context, phone, codeHash string) (tg.AuthSentCodeClass, error)
+	SignIn(ctx context.Context, phone, code, codeHash string) (*tg.AuthAuthorization, error)
+	Password(ctx context.Context, password string) (*tg.AuthAuthorization, error)
+}
+
+// clientSignIn adapts a telegram.Client to signInClient
+type clientSignIn struct {
+	*auth.Client
+	api *tg.Client
+}
+
+func newClientSignIn(client *telegram.Client) clientSignIn {
+	return clientSignIn{Client: client.Auth(), api: client.API()}
+}
+
+// ResendCode asks Telegram to send the login code again, possibly by
+// another method
+func (c clientSignIn) ResendCode(ctx context.Context, phone, codeHash string) (tg.AuthSentCodeClass, error) {
+	return c.api.AuthResendCode(ctx, &tg.AuthResendCodeRequest{
+		PhoneNumber:   phone,
+		PhoneCodeHash: codeHash,
+	})
+}
+
+// Bounds on re-prompting during interactive sign-in
+const (
+	maxPasswordAttempts = 3 // wrong 2FA passwords
+	maxCodeAttempts     = 3 // invalid login codes
+)
+
+// phoneCodeAuth signs in with the login code Telegram sends to phone,
+// reading the code interactively from stdin. An invalid code is re-prompted;
+// an expired one is replaced by resending the code once. password is the
+// configured 2FA cloud password, if any.
+func phoneCodeAuth(ctx context.Context, client signInClient, phone, password string) error {
+	if phone == "" {
+		return errors.New("phone must be set in config.ini when auth_mode = phone")
+	}
+
+	sent, err := client.SendCode(ctx, phone, auth.SendCodeOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to send login code: %w", err)
+	}
+	codeHash, done, err := sentCodeHash(sent)
+	if err != nil || done {
+		return err
+	}
+
+	resent := false
+	for attempt := 1; ; attempt++ {
+		code, err := prompt(ctx, "Enter the login code Telegram sent you: ")
+		if err != nil {
+			return fmt.Errorf("failed to read login code: %w", err)
+		}
+
+		_, err = client.SignIn(ctx, phone, code, codeHash)
+		if tg.IsPhoneCodeInvalid(err) && attempt < maxCodeAttempts {
+			slog.Warn("Invalid login code", "attempt", attempt, "max_attempts", maxCodeAttempts)
+			continue
+		}
+		if tg.IsPhoneCodeExpired(err) && !resent {
+			resent = true
+			slog.Info("Login code expired, sending a new one")
+			sent, err := client.ResendCode(ctx, phone, codeHash)
+			if err != nil {
+				return fmt.Errorf("failed to resend login code: %w", err)
+			}
+			if codeHash, done, err = sentCodeHash(sent); err != nil || done {
+				return err
+			}
+			continue
+		}
+
+		if errors.Is(err, auth.ErrPasswordAuthNeeded) {
+			err = passwordAuth(ctx, client, password)
+		}
+		var signUp *auth.SignUpRequired
+		if errors.As(err, &signUp) {
+			return fmt.Errorf("no Telegram account is registered for %s; sign up with an official app first", phone)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to sign in: %w", err)
+		}
+
+		slog.Info("Authorization successful")
+		return nil
+	}
+}
+
+// sentCodeHash returns the phone code hash of a send/resend code response,
+// or done if Telegram authorized the session without a code
+func sentCodeHash(sent tg.AuthSentCodeClass) (hash string, done bool, err error) {
+	switch s := sent.(type) {
+	case *tg.AuthSentCode:
+		return s.PhoneCodeHash, false, nil
+	case *tg.AuthSentCodeSuccess:
+		slog.Info("Authorization successful")
+		return "", true, nil
+	default:
+		return "", false, fmt.Errorf("unexpected send code response %T", sent)
+	}
+}
+
+// passwordAuth completes sign-in for accounts with two-step verification.
+// The configured password is tried first; otherwise, or if it is wrong, the
+// password is read from stdin without echo, up to maxPasswordAttempts tries.
+func passwordAuth(ctx context.Context, client signInClient, configured string) error {
+	slog.Info("Two-step verification is enabled for this account")
+	for attempt := 1; attempt <= maxPasswordAttempts; attempt++ {
+		password := configured
+		configured = ""
+		if password == "" {
+			var err error
+			password, err = promptSecret(ctx, "Enter your 2FA cloud password: ")
+			if err != nil {
+				return fmt.Errorf("failed to read 2FA password: %w", err)
+			}
+		}
+
+		_, err := client.Password(ctx, password)
+		if errors.Is(err, auth.ErrPasswordInvalid) {
+			slog.Warn("Invalid 2FA password", "attempt", attempt, "max_attempts", maxPasswordAttempts)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to check 2FA password: %w", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid 2FA password after %d attempts", maxPasswordAttempts)
+}
+
+// stdin is shared by interactive prompts and the MCP server so that buffered
+// input is never lost between them
+var stdin = bufio.NewReader(os.Stdin)
+
+// prompt prints label to stderr and reads a trimmed line from stdin. It
+// returns early with ctx's error once ctx is cancelled, so a signal stops
+// the login.
+func prompt(ctx context.Context, label string) (string, error) {
+	fmt.Fprint(os.Stderr, label)
+	line, err := readCtx(ctx, func() (string, error) {
+		line, err := stdin.ReadString('\n')
+		if err != nil && line == "" {
+			return "", err
+		}
+		return line, nil
+	})
+	return strings.TrimSpace(line), err
+}
+
+// promptSecret prints label to stderr and reads a line from stdin without
+// echoing it when stdin is a terminal. The terminal state is restored if ctx
+// is cancelled while reading.
+func promptSecret(ctx context.Context, label string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return prompt(ctx, label)
+	}
+	state, err := term.GetState(fd)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprint(os.Stderr, label)
+	secret, err := readCtx(ctx, func() (string, error) {
+		secret, err := term.ReadPassword(fd)
+		return string(secret), err
+	})
+	if ctx.Err() != nil {
+		_ = term.Restore(fd, state)
+	}
+	fmt.Fprintln(os.Stderr)
+	return secret, err
+}
+
+// readCtx runs the blocking read in the background and waits for it or for
+// ctx, whichever comes first
+func readCtx(ctx context.Context, read func() (string, error)) (string, error) {
+	type result struct {
+		line string
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		line, err := read()
+		done <- result{line, err}
+	}()
+	select {
+	case r := <-done:
+		return r.line, r.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
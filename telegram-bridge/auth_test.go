@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gotd/td/telegram/auth"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+// fakeSignIn is a signInClient answering sign-in attempts from a script:
+// each SignIn or Password call returns the next error of its list, nil once
+// the list is exhausted
+type fakeSignIn struct {
+	signInErrs   []error
+	passwordErrs []error
+
+	codes     []string // codes passed to SignIn
+	hashes    []string // code hashes passed to SignIn
+	passwords []string
+	resends   int
+}
+
+func (f *fakeSignIn) SendCode(context.Context, string, auth.SendCodeOptions) (tg.AuthSentCodeClass, error) {
+	return &tg.AuthSentCode{PhoneCodeHash: "hash0"}, nil
+}
+
+func (f *fakeSignIn) ResendCode(_ context.Context, _, codeHash string) (tg.AuthSentCodeClass, error) {
+	f.resends++
+	return &tg.AuthSentCode{PhoneCodeHash: "hash1"}, nil
+}
+
+func (f *fakeSignIn) SignIn(_ context.Context, _, code, codeHash string) (*tg.AuthAuthorization, error) {
+	f.codes = append(f.codes, code)
+	f.hashes = append(f.hashes, codeHash)
+	return &tg.AuthAuthorization{}, popErr(&f.signInErrs)
+}
+
+func (f *fakeSignIn) Password(_ context.Context, password string) (*tg.AuthAuthorization, error) {
+	f.passwords = append(f.passwords, password)
+	return &tg.AuthAuthorization{}, popErr(&f.passwordErrs)
+}
+
+func popErr(errs *[]error) error {
+	if len(*errs) == 0 {
+		return nil
+	}
+	err := (*errs)[0]
+	*errs = (*errs)[1:]
+	return err
+}
+
+// withStdin feeds input to the interactive prompts for the rest of the test
+func withStdin(t *testing.T, input string) {
+	saved := stdin
+	stdin = bufio.NewReader(strings.NewReader(input))
+	t.Cleanup(func() { stdin = saved })
+}
+
+func TestPhoneCodeAuth(t *testing.T) {
+	expired := tgerr.New(400, "PHONE_CODE_EXPIRED")
+	invalid := tgerr.New(400, "PHONE_CODE_INVALID")
+
+	tests := []struct {
+		name        string
+		signInErrs  []error
+		input       string
+		wantCodes   []string
+		wantHashes  []string
+		wantResends int
+		wantErr     bool
+	}{
+		{
+			name:       "first code accepted",
+			input:      "11111\n",
+			wantCodes:  []string{"11111"},
+			wantHashes: []string{"hash0"},
+		},
+		{
+			name:        "expired code is resent once",
+			signInErrs:  []error{expired},
+			input:       "11111\n22222\n",
+			wantCodes:   []string{"11111", "22222"},
+			wantHashes:  []string{"hash0", "hash1"},
+			wantResends: 1,
+		},
+		{
+			name:        "second expiry fails",
+			signInErrs:  []error{expired, expired},
+			input:       "11111\n22222\n",
+			wantCodes:   []string{"11111", "22222"},
+			wantHashes:  []string{"hash0", "hash1"},
+			wantResends: 1,
+			wantErr:     true,
+		},
+		{
+			name:       "invalid code reprompts without resending",
+			signInErrs: []error{invalid},
+			input:      "11112\n11111\n",
+			wantCodes:  []string{"11112", "11111"},
+			wantHashes: []string{"hash0", "hash0"},
+		},
+		{
+			name:       "invalid code attempts are bounded",
+			signInErrs: []error{invalid, invalid, invalid},
+			input:      "1\n2\n3\n4\n",
+			wantCodes:  []string{"1", "2", "3"},
+			wantHashes: []string{"hash0", "hash0", "hash0"},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withStdin(t, tt.input)
+			fake := &fakeSignIn{signInErrs: tt.signInErrs}
+
+			err := phoneCodeAuth(context.Background(), fake, "+15550100", "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("phoneCodeAuth() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if strings.Join(fake.codes, ",") != strings.Join(tt.wantCodes, ",") {
+				t.Errorf("codes = %v, want %v", fake.codes, tt.wantCodes)
+			}
+			if strings.Join(fake.hashes, ",") != strings.Join(tt.wantHashes, ",") {
+				t.Errorf("code hashes = %v, want %v", fake.hashes, tt.wantHashes)
+			}
+			if fake.resends != tt.wantResends {
+				t.Errorf("resends = %d, want %d", fake.resends, tt.wantResends)
+			}
+		})
+	}
+}
+
+func TestPasswordAuth(t *testing.T) {
+	tests := []struct {
+		name          string
+		configured    string
+		passwordErrs  []error
+		input         string
+		wantPasswords []string
+		wantErr       bool
+	}{
+		{name: "configured password", configured: "secret", wantPasswords: []string{"secret"}},
+		{name: "prompted password", input: "secret\n", wantPasswords: []string{"secret"}},
+		{
+			name:          "wrong configured password reprompts",
+			configured:    "old",
+			passwordErrs:  []error{auth.ErrPasswordInvalid},
+			input:         "secret\n",
+			wantPasswords: []string{"old", "secret"},
+		},
+		{
+			name:          "three wrong passwords",
+			passwordErrs:  []error{auth.ErrPasswordInvalid, auth.ErrPasswordInvalid, auth.ErrPasswordInvalid},
+			input:         "a\nb\nc\nd\n",
+			wantPasswords: []string{"a", "b", "c"},
+			wantErr:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withStdin(t, tt.input)
+			fake := &fakeSignIn{passwordErrs: tt.passwordErrs}
+
+			err := passwordAuth(context.Background(), fake, tt.configured)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("passwordAuth() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if strings.Join(fake.passwords, ",") != strings.Join(tt.wantPasswords, ",") {
+				t.Errorf("passwords = %v, want %v", fake.passwords, tt.wantPasswords)
+			}
+		})
+	}
+}
+
+func TestPhoneCodeAuthPassword(t *testing.T) {
+	withStdin(t, "11111\n")
+	fake := &fakeSignIn{signInErrs: []error{auth.ErrPasswordAuthNeeded}}
+	if err := phoneCodeAuth(context.Background(), fake, "+15550100", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.passwords) != 1 || fake.passwords[0] != "secret" {
+		t.Errorf("passwords = %v, want the configured one", fake.passwords)
+	}
+}
+
+func TestReadCtxCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	blocked := make(chan struct{})
+	defer close(blocked)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	_, err := readCtx(ctx, func() (string, error) {
+		<-blocked
+		return "", nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("readCtx() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestReadCtxLine(t *testing.T) {
+	line, err := readCtx(context.Background(), func() (string, error) {
+		return "12345", nil
+	})
+	if err != nil || line != "12345" {
+		t.Fatalf("readCtx() = %q, %v", line, err)
+	}
+}
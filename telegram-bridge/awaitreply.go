@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gotd/td/tg"
+)
+
+// Bounds of the send_and_await_reply timeout
+const (
+	defaultReplyTimeout = 60 * time.Second
+	maxReplyTimeout     = 300 * time.Second
+)
+
+// replyBuffer is how many new messages of a chat a waiter holds before
+// dropping further ones
+const replyBuffer = 32
+
+// errReplyNeedsStream is returned by send_and_await_reply when the update
+// stream, which delivers the reply, is off
+var errReplyNeedsStream = errors.New("waiting for a reply needs the update stream; set stream_updates = true under [bridge]")
+
+// replyWaiters hands new messages of a chat to the calls waiting on it
+type replyWaiters struct {
+	mu      sync.Mutex
+	next    int
+	waiters map[int]replyWaiter
+}
+
+type replyWaiter struct {
+	peerID int64
+	ch     chan *tg.Message
+}
+
+func newReplyWaiters() *replyWaiters {
+	return &replyWaiters{waiters: make(map[int]replyWaiter)}
+}
+
+// add starts collecting the new messages of peerID; remove must be called
+// with the returned key once done
+func (w *replyWaiters) add(peerID int64) (int, <-chan *tg.Message) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.next++
+	ch := make(chan *tg.Message, replyBuffer)
+	w.waiters[w.next] = replyWaiter{peerID: peerID, ch: ch}
+	return w.next, ch
+}
+
+func (w *replyWaiters) remove(key int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.waiters, key)
+}
+
+// deliver passes msg to every waiter on its chat without blocking
+func (w *replyWaiters) deliver(msg *tg.Message) {
+	peerID, err := markedPeerID(msg.PeerID)
+	if err != nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, waiter := range w.waiters {
+		if waiter.peerID != peerID {
+			continue
+		}
+		select {
+		case waiter.ch <- msg:
+		default:
+		}
+	}
+}
+
+type sendAndAwaitReplyArgs struct {
+	Peer string `json:"peer"`
+	Text string `json:"text"`
+	// Timeout is how many seconds to wait for the reply
+	Timeout int `json:"timeout"`
+}
+
+type sendAndAwaitReplyResult struct {
+	MessageID int `json:"message_id"`
+	// Reply is the first reply, unset when TimedOut
+	Reply    *messageInfo `json:"reply,omitempty"`
+	TimedOut bool         `json:"timed_out"`
+}
+
+func sendAndAwaitReplyTool(api *tg.Client, peers *peerResolver, stream *updateStream) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args sendAndAwaitReplyArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.Peer) == "" {
+			return nil, invalidParams("peer is required")
+		}
+		if strings.TrimSpace(args.Text) == "" {
+			return nil, invalidParams("text is required")
+		}
+		timeout := defaultReplyTimeout
+		if args.Timeout != 0 {
+			timeout = time.Duration(args.Timeout) * time.Second
+		}
+		if timeout <= 0 || timeout > maxReplyTimeout {
+			return nil, invalidParams("timeout must be between 1 and %d seconds", int(maxReplyTimeout.Seconds()))
+		}
+		if stream == nil {
+			return nil, errReplyNeedsStream
+		}
+		return sendAndAwaitReply(ctx, api, peers, stream.replies, args.Peer, args.Text, timeout)
+	}
+}
+
+// sendAndAwaitReply sends text to peer and waits up to timeout for the
+// first incoming message after it. In groups and channels only a message
+// replying to the sent one counts, so other chatter is ignored. The waiter
+// is in place before sending so a quick reply is not missed.
+func sendAndAwaitReply(ctx context.Context, api *tg.Client, peers *peerResolver, replies *replyWaiters, peer, text string, timeout time.Duration) (sendAndAwaitReplyResult, error) {
+	p, err := peers.resolve(ctx, peer)
+	if err != nil {
+		return sendAndAwaitReplyResult{}, err
+	}
+	key, incoming := replies.add(p.MarkedID())
+	defer replies.remove(key)
+
+	sentID, err := sendText(ctx, api, p.InputPeer(), text, sendOptions{ClearDraft: true})
+	if err != nil {
+		return sendAndAwaitReplyResult{}, err
+	}
+	result := sendAndAwaitReplyResult{MessageID: sentID}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case msg := <-incoming:
+			if !isReplyTo(msg, p, sentID) {
+				continue
+			}
+			info, err := newMessageInfo(msg)
+			if err != nil {
+				return sendAndAwaitReplyResult{}, fmt.Errorf("failed to read reply: %w", err)
+			}
+			info.PeerID = p.MarkedID()
+			result.Reply = &info
+			return result, nil
+		case <-timer.C:
+			result.TimedOut = true
+			return result, nil
+		case <-ctx.Done():
+			return sendAndAwaitReplyResult{}, fmt.Errorf("message %d sent, stopped waiting for a reply: %w", sentID, ctx.Err())
+		}
+	}
+}
+
+// isReplyTo reports whether msg in chat p answers the message sentID
+func isReplyTo(msg *tg.Message, p cachedPeer, sentID int) bool {
+	if msg.Out || msg.ID <= sentID {
+		return false
+	}
+	if p.Type == peerUser {
+		return true
+	}
+	reply, ok := msg.ReplyTo.(*tg.MessageReplyHeader)
+	return ok && reply.ReplyToMsgID == sentID
+}
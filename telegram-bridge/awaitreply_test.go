@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+)
+
+func TestSendAndAwaitReply(t *testing.T) {
+	dispatcher := tg.NewUpdateDispatcher()
+	stream := newUpdateStream(dispatcher, NewMCPServer(&bytes.Buffer{}), messageFilter{})
+	_, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		if _, ok := input.(*tg.MessagesSendMessageRequest); ok {
+			// Updates arriving just after the send: our own echo, a message
+			// from another chat, and then the reply
+			go func() {
+				batch := &tg.Updates{Updates: []tg.UpdateClass{
+					&tg.UpdateNewMessage{Message: &tg.Message{ID: 100, Out: true, PeerID: &tg.PeerUser{UserID: 10}, Message: "ping"}},
+					&tg.UpdateNewMessage{Message: &tg.Message{ID: 101, PeerID: &tg.PeerUser{UserID: 11}, Message: "unrelated"}},
+					&tg.UpdateNewMessage{Message: &tg.Message{ID: 102, PeerID: &tg.PeerUser{UserID: 10}, Message: "pong", Date: 500}},
+				}}
+				if err := dispatcher.Handle(context.Background(), batch); err != nil {
+					t.Error(err)
+				}
+			}()
+			return &tg.UpdateShortSentMessage{ID: 100}, nil
+		}
+		return nil, nil
+	})
+	peers.storeUser(&tg.User{ID: 10, AccessHash: 1})
+
+	result, err := sendAndAwaitReply(context.Background(), api, peers, stream.replies, "10", "ping", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.MessageID != 100 || result.TimedOut || result.Reply == nil {
+		t.Fatalf("result = %+v", result)
+	}
+	if r := result.Reply; r.ID != 102 || r.Text != "pong" || r.SenderID != 10 || r.PeerID != 10 || r.FromMe {
+		t.Errorf("reply = %+v", r)
+	}
+	if len(stream.replies.waiters) != 0 {
+		t.Errorf("%d waiters left registered", len(stream.replies.waiters))
+	}
+}
+
+func TestIsReplyTo(t *testing.T) {
+	group := cachedPeer{Type: peerChannel, ID: 30}
+	replyTo := func(id int) tg.MessageReplyHeaderClass { return &tg.MessageReplyHeader{ReplyToMsgID: id} }
+	tests := []struct {
+		name string
+		msg  *tg.Message
+		peer cachedPeer
+		want bool
+	}{
+		{"private", &tg.Message{ID: 6}, cachedPeer{Type: peerUser, ID: 10}, true},
+		{"own message", &tg.Message{ID: 6, Out: true}, cachedPeer{Type: peerUser, ID: 10}, false},
+		{"older", &tg.Message{ID: 4}, cachedPeer{Type: peerUser, ID: 10}, false},
+		{"group reply", &tg.Message{ID: 6, ReplyTo: replyTo(5)}, group, true},
+		{"group reply to other", &tg.Message{ID: 6, ReplyTo: replyTo(3)}, group, false},
+		{"group chatter", &tg.Message{ID: 6}, group, false},
+	}
+	for _, tt := range tests {
+		if got := isReplyTo(tt.msg, tt.peer, 5); got != tt.want {
+			t.Errorf("%s: isReplyTo = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSendAndAwaitReplyTimeout(t *testing.T) {
+	_, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		if _, ok := input.(*tg.MessagesSendMessageRequest); ok {
+			return &tg.UpdateShortSentMessage{ID: 100}, nil
+		}
+		return nil, nil
+	})
+	peers.storeUser(&tg.User{ID: 10, AccessHash: 1})
+	result, err := sendAndAwaitReply(context.Background(), api, peers, newReplyWaiters(), "10", "ping", 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.TimedOut || result.Reply != nil || result.MessageID != 100 {
+		t.Errorf("result = %+v, want timed out after sending", result)
+	}
+}
+
+func TestSendAndAwaitReplyNeedsStream(t *testing.T) {
+	inv, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		return nil, nil
+	})
+	_, err := sendAndAwaitReplyTool(api, peers, nil)(context.Background(), json.RawMessage(`{"peer":"10","text":"ping"}`))
+	if !errors.Is(err, errReplyNeedsStream) {
+		t.Errorf("err = %v, want %v", err, errReplyNeedsStream)
+	}
+	if n := len(requests[*tg.MessagesSendMessageRequest](inv)); n != 0 {
+		t.Errorf("sent %d messages without the stream", n)
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gotd/td/telegram/query"
+	"github.com/gotd/td/tg"
+)
+
+// AttachBot is a bot installed in the account's attachment or side menu
+type AttachBot struct {
+	BotID     int64  `json:"bot_id"`
+	ShortName string `json:"short_name"`
+	Name      string `json:"name,omitempty"`
+	Username  string `json:"username,omitempty"`
+	// Inactive is set for bots shown in the menu but not yet enabled
+	Inactive         bool `json:"inactive"`
+	ShowInAttachMenu bool `json:"show_in_attach_menu"`
+	ShowInSideMenu   bool `json:"show_in_side_menu"`
+	// PeerTypes are the chats the bot can be opened in: same_bot_pm,
+	// bot_pm, pm, chat and broadcast
+	PeerTypes []string `json:"peer_types"`
+}
+
+func listAttachBotsTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args struct{}
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		return getAttachMenuBots(ctx, api, peers)
+	}
+}
+
+// getAttachMenuBots lists the bots attached to the account's menus; an
+// account without any returns an empty list
+func getAttachMenuBots(ctx context.Context, api *tg.Client, peers *peerResolver) ([]AttachBot, error) {
+	var res tg.AttachMenuBotsClass
+	err := withFloodRetry(ctx, func() (err error) {
+		res, err = api.MessagesGetAttachMenuBots(ctx, 0)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attachment menu bots: %w", err)
+	}
+	bots, ok := res.(*tg.AttachMenuBots)
+	if !ok {
+		// Not modified is only returned for a non-zero hash
+		return nil, fmt.Errorf("unexpected attachment menu response %T", res)
+	}
+	peers.remember(bots.Users, nil)
+	return newAttachBots(bots), nil
+}
+
+func newAttachBots(bots *tg.AttachMenuBots) []AttachBot {
+	users := make(map[int64]*tg.User)
+	for _, u := range bots.Users {
+		if u, ok := u.(*tg.User); ok {
+			users[u.ID] = u
+		}
+	}
+
+	result := make([]AttachBot, 0, len(bots.Bots))
+	for _, b := range bots.Bots {
+		bot := AttachBot{
+			BotID:            b.BotID,
+			ShortName:        b.ShortName,
+			Inactive:         b.Inactive,
+			ShowInAttachMenu: b.ShowInAttachMenu,
+			ShowInSideMenu:   b.ShowInSideMenu,
+			PeerTypes:        make([]string, 0, len(b.PeerTypes)),
+		}
+		if u, ok := users[b.BotID]; ok {
+			bot.Name = strings.TrimSpace(u.FirstName + " " + u.LastName)
+			bot.Username = u.Username
+		}
+		for _, t := range b.PeerTypes {
+			bot.PeerTypes = append(bot.PeerTypes, attachPeerType(t))
+		}
+		result = append(result, bot)
+	}
+	return result
+}
+
+func attachPeerType(t tg.AttachMenuPeerTypeClass) string {
+	switch t.(type) {
+	case *tg.AttachMenuPeerTypeSameBotPM:
+		return "same_bot_pm"
+	case *tg.AttachMenuPeerTypeBotPM:
+		return "bot_pm"
+	case *tg.AttachMenuPeerTypePM:
+		return "pm"
+	case *tg.AttachMenuPeerTypeChat:
+		return "chat"
+	case *tg.AttachMenuPeerTypeBroadcast:
+		return "broadcast"
+	}
+	return t.TypeName()
+}
+
+// BotInfo describes a bot with its profile texts and command list
+type BotInfo struct {
+	BotID    int64  `json:"bot_id"`
+	Name     string `json:"name"`
+	Username string `json:"username,omitempty"`
+	// About is the short description on the bot's profile; Description is
+	// shown in an empty chat with the bot
+	About       string       `json:"about,omitempty"`
+	Description string       `json:"description,omitempty"`
+	Commands    []BotCommand `json:"commands"`
+	// CanEdit is set for bots this account owns
+	CanEdit bool `json:"can_edit"`
+}
+
+// BotCommand is a command a bot lists in its menu
+type BotCommand struct {
+	Command     string `json:"command"`
+	Description string `json:"description"`
+}
+
+type ownedBotsResult struct {
+	Bots []BotInfo `json:"bots"`
+}
+
+func getOwnedBotsTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args struct{}
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		bots, err := getOwnedBots(ctx, api, peers)
+		if err != nil {
+			return nil, err
+		}
+		return ownedBotsResult{Bots: bots}, nil
+	}
+}
+
+// getOwnedBots lists the bots this account can edit. The API has no list of
+// created bots, so they are found among the dialogs; bots you never chatted
+// with are missed. Only the profile fields are filled in, use getBotInfo for
+// the texts and commands.
+func getOwnedBots(ctx context.Context, api *tg.Client, peers *peerResolver) ([]BotInfo, error) {
+	bots := []BotInfo{}
+	seen := make(map[int64]bool)
+	iter := query.GetDialogs(api).BatchSize(100).Iter()
+	for iter.Next(ctx) {
+		users := iter.Value().Entities.Users()
+		peers.rememberEntities(users, nil)
+		for _, u := range users {
+			if u.Bot && u.BotCanEdit && !seen[u.ID] {
+				seen[u.ID] = true
+				bots = append(bots, newBotInfo(u))
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list dialogs: %w", err)
+	}
+	sort.Slice(bots, func(i, j int) bool { return bots[i].BotID < bots[j].BotID })
+	return bots, nil
+}
+
+type botArgs struct {
+	Bot string `json:"bot"`
+}
+
+func getBotInfoTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args botArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.Bot) == "" {
+			return nil, invalidParams("bot is required")
+		}
+		return getBotInfo(ctx, api, peers, args.Bot)
+	}
+}
+
+// getBotInfo returns the profile texts and commands of any bot. The full
+// user carries them for every bot, unlike bots.getBotInfo, which only
+// answers for bots you own and has no commands.
+func getBotInfo(ctx context.Context, api *tg.Client, peers *peerResolver, bot string) (BotInfo, error) {
+	input, err := peers.ResolveUser(ctx, bot)
+	if err != nil {
+		return BotInfo{}, err
+	}
+	var full *tg.UsersUserFull
+	err = withFloodRetry(ctx, func() (err error) {
+		full, err = api.UsersGetFullUser(ctx, input)
+		return err
+	})
+	if err != nil {
+		return BotInfo{}, fmt.Errorf("failed to get bot: %w", err)
+	}
+	peers.remember(full.Users, full.Chats)
+
+	var user *tg.User
+	for _, u := range full.Users {
+		if u, ok := u.(*tg.User); ok && u.ID == input.UserID {
+			user = u
+		}
+	}
+	if user == nil {
+		return BotInfo{}, fmt.Errorf("bot %s missing from full user response", bot)
+	}
+	if !user.Bot {
+		return BotInfo{}, fmt.Errorf("%s is not a bot", bot)
+	}
+	info := newBotInfo(user)
+	info.About = full.FullUser.About
+	if b, ok := full.FullUser.GetBotInfo(); ok {
+		info.Description = b.Description
+		for _, c := range b.Commands {
+			info.Commands = append(info.Commands, BotCommand{Command: c.Command, Description: c.Description})
+		}
+	}
+	return info, nil
+}
+
+func newBotInfo(u *tg.User) BotInfo {
+	return BotInfo{
+		BotID:    u.ID,
+		Name:     strings.TrimSpace(u.FirstName + " " + u.LastName),
+		Username: u.Username,
+		Commands: []BotCommand{},
+		CanEdit:  u.BotCanEdit,
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+)
+
+func TestGetAttachMenuBots(t *testing.T) {
+	res := &tg.AttachMenuBots{
+		Bots: []tg.AttachMenuBot{
+			{
+				BotID:            50,
+				ShortName:        "shop",
+				ShowInAttachMenu: true,
+				PeerTypes:        []tg.AttachMenuPeerTypeClass{&tg.AttachMenuPeerTypePM{}, &tg.AttachMenuPeerTypeChat{}},
+			},
+			{BotID: 51, ShortName: "notes", Inactive: true, ShowInSideMenu: true},
+		},
+		Users: []tg.UserClass{&tg.User{ID: 50, AccessHash: 5, Bot: true, FirstName: "Shop", Username: "shop_bot"}},
+	}
+	_, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		if _, ok := input.(*tg.MessagesGetAttachMenuBotsRequest); ok {
+			return res, nil
+		}
+		return nil, nil
+	})
+
+	bots, err := getAttachMenuBots(context.Background(), api, peers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bots) != 2 {
+		t.Fatalf("got %d bots, want 2", len(bots))
+	}
+	shop, notes := bots[0], bots[1]
+	if shop.BotID != 50 || shop.Name != "Shop" || shop.Username != "shop_bot" || !shop.ShowInAttachMenu ||
+		fmt.Sprint(shop.PeerTypes) != "[pm chat]" {
+		t.Errorf("first bot = %+v", shop)
+	}
+	if notes.ShortName != "notes" || !notes.Inactive || !notes.ShowInSideMenu || notes.Name != "" || notes.PeerTypes == nil {
+		t.Errorf("second bot = %+v", notes)
+	}
+	if _, ok := peers.cached(50); !ok {
+		t.Error("bot user not cached")
+	}
+}
+
+func TestGetAttachMenuBotsEmpty(t *testing.T) {
+	_, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		return &tg.AttachMenuBots{}, nil
+	})
+	bots, err := getAttachMenuBots(context.Background(), api, peers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bots == nil || len(bots) != 0 {
+		t.Errorf("bots = %#v, want an empty list", bots)
+	}
+}
+
+func TestGetBotInfo(t *testing.T) {
+	_, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		if _, ok := input.(*tg.UsersGetFullUserRequest); ok {
+			full := tg.UserFull{ID: 50, About: "Weather on demand"}
+			full.SetBotInfo(tg.BotInfo{
+				Description: "Send /forecast to get started",
+				Commands: []tg.BotCommand{
+					{Command: "forecast", Description: "Forecast for a city"},
+					{Command: "help", Description: "How to use the bot"},
+				},
+			})
+			return &tg.UsersUserFull{
+				FullUser: full,
+				Users:    []tg.UserClass{&tg.User{ID: 50, AccessHash: 5, Bot: true, BotCanEdit: true, FirstName: "Weather", Username: "weatherbot"}},
+			}, nil
+		}
+		return nil, nil
+	})
+	peers.storeUser(&tg.User{ID: 50, AccessHash: 5})
+
+	info, err := getBotInfo(context.Background(), api, peers, "50")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.BotID != 50 || info.Name != "Weather" || info.Username != "weatherbot" || !info.CanEdit ||
+		info.About != "Weather on demand" || info.Description != "Send /forecast to get started" {
+		t.Errorf("info = %+v", info)
+	}
+	if len(info.Commands) != 2 || info.Commands[0] != (BotCommand{Command: "forecast", Description: "Forecast for a city"}) {
+		t.Errorf("commands = %+v", info.Commands)
+	}
+}
+
+func TestGetBotInfoNotBot(t *testing.T) {
+	_, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		if _, ok := input.(*tg.UsersGetFullUserRequest); ok {
+			return &tg.UsersUserFull{FullUser: tg.UserFull{ID: 10}, Users: []tg.UserClass{&tg.User{ID: 10, AccessHash: 1}}}, nil
+		}
+		return nil, nil
+	})
+	peers.storeUser(&tg.User{ID: 10, AccessHash: 1})
+	if _, err := getBotInfo(context.Background(), api, peers, "10"); err == nil || !strings.Contains(err.Error(), "not a bot") {
+		t.Errorf("err = %v, want not a bot", err)
+	}
+}
+
+func TestGetOwnedBots(t *testing.T) {
+	_, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		if _, ok := input.(*tg.MessagesGetDialogsRequest); ok {
+			var dialogs []tg.DialogClass
+			for _, id := range []int64{10, 50, 51} {
+				dialogs = append(dialogs, &tg.Dialog{Peer: &tg.PeerUser{UserID: id}, NotifySettings: tg.PeerNotifySettings{}})
+			}
+			return &tg.MessagesDialogs{
+				Dialogs: dialogs,
+				Users: []tg.UserClass{
+					&tg.User{ID: 10, AccessHash: 1, FirstName: "Alice"},
+					&tg.User{ID: 50, AccessHash: 5, Bot: true, BotCanEdit: true, FirstName: "Mine"},
+					&tg.User{ID: 51, AccessHash: 5, Bot: true, FirstName: "Theirs"},
+				},
+			}, nil
+		}
+		return nil, nil
+	})
+	bots, err := getOwnedBots(context.Background(), api, peers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bots) != 1 || bots[0].BotID != 50 || bots[0].Name != "Mine" || !bots[0].CanEdit {
+		t.Errorf("bots = %+v, want only the owned bot", bots)
+	}
+}
@@ -0,0 +1,733 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gotd/td/tg"
+)
+
+type channelPostArgs struct {
+	Channel string `json:"channel"`
+	PostID  int    `json:"post_id"`
+}
+
+type commentsCountResult struct {
+	PostID   int `json:"post_id"`
+	Comments int `json:"comments"`
+}
+
+func getCommentsCountTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args channelPostArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.Channel) == "" {
+			return nil, invalidParams("channel is required")
+		}
+		if args.PostID <= 0 {
+			return nil, invalidParams("post_id must be positive")
+		}
+
+		count, err := getCommentsCount(ctx, api, peers, args.Channel, args.PostID)
+		if err != nil {
+			return nil, err
+		}
+		return commentsCountResult{PostID: args.PostID, Comments: count}, nil
+	}
+}
+
+// getCommentsCount reports how many comments a channel post has from the
+// post's replies field, without fetching the comments themselves
+func getCommentsCount(ctx context.Context, api *tg.Client, peers *peerResolver, channel string, postID int) (int, error) {
+	if _, err := peers.ResolveChannel(ctx, channel); err != nil {
+		return 0, err
+	}
+	msg, err := getMessage(ctx, api, peers, channel, postID)
+	if err != nil {
+		return 0, err
+	}
+	return commentsCount(msg)
+}
+
+// commentsCount reads the comment count from a channel post. Posts in
+// channels without a discussion group carry no comment replies info.
+func commentsCount(msg *tg.Message) (int, error) {
+	replies, ok := msg.GetReplies()
+	if !ok || !replies.Comments {
+		return 0, errors.New("comments are disabled for this post")
+	}
+	return replies.Replies, nil
+}
+
+type signaturesArgs struct {
+	Channel string `json:"channel"`
+	Enabled *bool  `json:"enabled"`
+}
+
+type signaturesResult struct {
+	Signatures bool `json:"signatures"`
+}
+
+func getSignaturesTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args channelArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.Channel) == "" {
+			return nil, invalidParams("channel is required")
+		}
+
+		enabled, err := getSignatures(ctx, api, peers, args.Channel)
+		if err != nil {
+			return nil, err
+		}
+		return signaturesResult{Signatures: enabled}, nil
+	}
+}
+
+func toggleSignaturesTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args signaturesArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.Channel) == "" {
+			return nil, invalidParams("channel is required")
+		}
+		if args.Enabled == nil {
+			return nil, invalidParams("enabled is required")
+		}
+
+		if err := toggleSignatures(ctx, api, peers, args.Channel, *args.Enabled); err != nil {
+			return nil, err
+		}
+		return signaturesResult{Signatures: *args.Enabled}, nil
+	}
+}
+
+type channelArgs struct {
+	Channel string `json:"channel"`
+}
+
+// getSignatures reports whether posts in a channel are signed with their
+// author's name
+func getSignatures(ctx context.Context, api *tg.Client, peers *peerResolver, channel string) (bool, error) {
+	_, c, err := getFullChannel(ctx, api, peers, channel)
+	if err != nil {
+		return false, err
+	}
+	return c.Signatures, nil
+}
+
+// toggleSignatures turns author signatures on channel posts on or off. Only
+// the creator or an admin allowed to change channel info may do so.
+func toggleSignatures(ctx context.Context, api *tg.Client, peers *peerResolver, channel string, enabled bool) error {
+	input, c, err := getFullChannel(ctx, api, peers, channel)
+	if err != nil {
+		return err
+	}
+	if !c.Broadcast {
+		return fmt.Errorf("%s is a supergroup; signatures only apply to channels", channel)
+	}
+	if !canChangeInfo(c) {
+		return fmt.Errorf("admin rights to change channel info are required to toggle signatures in %s", channel)
+	}
+
+	err = withFloodRetry(ctx, func() error {
+		_, err := api.ChannelsToggleSignatures(ctx, &tg.ChannelsToggleSignaturesRequest{
+			Channel: input,
+			Enabled: enabled,
+		})
+		return err
+	})
+	if err != nil && !tg.IsChatNotModified(err) {
+		return fmt.Errorf("failed to toggle signatures: %w", err)
+	}
+	return nil
+}
+
+// canChangeInfo reports whether the logged-in user may edit channel settings
+func canChangeInfo(c *tg.Channel) bool {
+	if c.Creator {
+		return true
+	}
+	rights, ok := c.GetAdminRights()
+	return ok && rights.ChangeInfo
+}
+
+// getFullChannel resolves channel and returns its input together with the
+// up to date channel entity from its full info
+func getFullChannel(ctx context.Context, api *tg.Client, peers *peerResolver, channel string) (*tg.InputChannel, *tg.Channel, error) {
+	input, err := peers.ResolveChannel(ctx, channel)
+	if err != nil {
+		return nil, nil, err
+	}
+	_, chats, err := channelFull(ctx, api, peers, input)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, ch := range chats {
+		if c, ok := ch.(*tg.Channel); ok && c.ID == input.ChannelID {
+			return input, c, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("channel %s missing from full channel response", channel)
+}
+
+// maxPostsSince bounds how many posts get_posts_since returns, so a date far
+// in the past cannot page through a channel's entire history
+const maxPostsSince = 1000
+
+type postsSinceArgs struct {
+	Channel         string `json:"channel"`
+	Since           int    `json:"since"`
+	MaxMessageChars int    `json:"max_message_chars"`
+}
+
+type postsSinceResult struct {
+	Count int           `json:"count"`
+	Posts []messageInfo `json:"posts"`
+	// Truncated is set when more than maxPostsSince posts are newer than
+	// since and the newest were left out; asking again from the date of the
+	// newest returned post continues where this result stopped
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+func getPostsSinceTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args postsSinceArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.Channel) == "" {
+			return nil, invalidParams("channel is required")
+		}
+		if args.Since <= 0 {
+			return nil, invalidParams("since must be a positive Unix time")
+		}
+		if args.MaxMessageChars < 0 {
+			return nil, invalidParams("max_message_chars must not be negative")
+		}
+
+		posts, truncated, err := getPostsSince(ctx, api, peers, args.Channel, args.Since)
+		if err != nil {
+			return nil, err
+		}
+		truncateMessages(posts, args.MaxMessageChars)
+		return postsSinceResult{Count: len(posts), Posts: posts, Truncated: truncated}, nil
+	}
+}
+
+// getPostsSince returns the posts of a channel dated after since, newest
+// first. The first page seeks straight to since with offset_date; later pages
+// move towards the present from the newest post seen so far, until a page
+// brings nothing new.
+func getPostsSince(ctx context.Context, api *tg.Client, peers *peerResolver, channel string, since int) ([]messageInfo, bool, error) {
+	input, err := peers.ResolveChannel(ctx, channel)
+	if err != nil {
+		return nil, false, err
+	}
+	peer := &tg.InputPeerChannel{ChannelID: input.ChannelID, AccessHash: input.AccessHash}
+
+	var posts []messageInfo // oldest first
+	offsetDate, newest := since+1, 0
+	for {
+		// A negative add_offset returns the messages after the offset
+		// rather than before it
+		var history tg.MessagesMessagesClass
+		err = withFloodRetry(ctx, func() (err error) {
+			history, err = api.MessagesGetHistory(ctx, &tg.MessagesGetHistoryRequest{
+				Peer:       peer,
+				OffsetID:   newest,
+				OffsetDate: offsetDate,
+				AddOffset:  -maxMessageLimit,
+				Limit:      maxMessageLimit,
+			})
+			return err
+		})
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to read posts: %w", err)
+		}
+		modified, ok := history.AsModified()
+		if !ok {
+			return nil, false, fmt.Errorf("unexpected messages response %T", history)
+		}
+		peers.remember(modified.GetUsers(), modified.GetChats())
+
+		// Pages are newest first
+		page := modified.GetMessages()
+		advanced := false
+		for i := len(page) - 1; i >= 0; i-- {
+			id := page[i].GetID()
+			if id <= newest {
+				// The offset message itself, or one already returned
+				continue
+			}
+			newest, advanced = id, true
+			msg, ok := page[i].(*tg.Message)
+			if !ok || msg.Date <= since {
+				// Service messages still count as the paging cursor
+				continue
+			}
+			if len(posts) == maxPostsSince {
+				return reverseMessages(posts), true, nil
+			}
+			info, err := newMessageInfo(msg)
+			if err != nil {
+				return nil, false, err
+			}
+			posts = append(posts, info)
+		}
+		if !advanced {
+			return reverseMessages(posts), false, nil
+		}
+		offsetDate = 0
+	}
+}
+
+// reverseMessages reverses msgs in place and returns it, never nil
+func reverseMessages(msgs []messageInfo) []messageInfo {
+	if msgs == nil {
+		return []messageInfo{}
+	}
+	for i, j := 0, len(msgs)-1; i < j; i, j = i+1, j-1 {
+		msgs[i], msgs[j] = msgs[j], msgs[i]
+	}
+	return msgs
+}
+
+type restrictSenderArgs struct {
+	Peer string `json:"peer"`
+	User string `json:"user"`
+	// Until is when the mute ends as a Unix time, 0 for forever
+	Until int `json:"until"`
+	// Unmute lifts an earlier restriction instead
+	Unmute bool `json:"unmute"`
+}
+
+type restrictSenderResult struct {
+	Muted bool `json:"muted"`
+	Until int  `json:"until,omitempty"`
+}
+
+func restrictSenderTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args restrictSenderArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.Peer) == "" {
+			return nil, invalidParams("peer is required")
+		}
+		if strings.TrimSpace(args.User) == "" {
+			return nil, invalidParams("user is required")
+		}
+		if args.Unmute && args.Until != 0 {
+			return nil, invalidParams("until does not apply when unmuting")
+		}
+		if args.Until != 0 && int64(args.Until) <= time.Now().Unix() {
+			return nil, invalidParams("until must be in the future, or 0 to mute forever")
+		}
+
+		if args.Unmute {
+			if err := unrestrictSender(ctx, api, peers, args.Peer, args.User); err != nil {
+				return nil, err
+			}
+			return restrictSenderResult{}, nil
+		}
+		if err := restrictSender(ctx, api, peers, args.Peer, args.User, args.Until); err != nil {
+			return nil, err
+		}
+		return restrictSenderResult{Muted: true, Until: args.Until}, nil
+	}
+}
+
+// restrictSender stops userPeer from sending anything to the supergroup peer
+// until muteUntil, a Unix time, or forever when it is 0. Telegram also treats
+// dates less than 30 seconds or more than 366 days away as forever.
+func restrictSender(ctx context.Context, api *tg.Client, peers *peerResolver, peer, userPeer string, muteUntil int) error {
+	return editBanned(ctx, api, peers, peer, userPeer, muteRights(muteUntil))
+}
+
+// unrestrictSender lifts all restrictions of userPeer in peer
+func unrestrictSender(ctx context.Context, api *tg.Client, peers *peerResolver, peer, userPeer string) error {
+	return editBanned(ctx, api, peers, peer, userPeer, tg.ChatBannedRights{})
+}
+
+// muteRights bans every kind of message until muteUntil while leaving the
+// user able to read the chat
+func muteRights(muteUntil int) tg.ChatBannedRights {
+	return tg.ChatBannedRights{
+		SendMessages:    true,
+		SendMedia:       true,
+		SendStickers:    true,
+		SendGifs:        true,
+		SendGames:       true,
+		SendInline:      true,
+		EmbedLinks:      true,
+		SendPolls:       true,
+		SendPhotos:      true,
+		SendVideos:      true,
+		SendRoundvideos: true,
+		SendAudios:      true,
+		SendVoices:      true,
+		SendDocs:        true,
+		SendPlain:       true,
+		UntilDate:       muteUntil,
+	}
+}
+
+func editBanned(ctx context.Context, api *tg.Client, peers *peerResolver, peer, userPeer string, rights tg.ChatBannedRights) error {
+	input, c, err := getFullChannel(ctx, api, peers, peer)
+	if err != nil {
+		return err
+	}
+	if c.Broadcast {
+		return fmt.Errorf("%s is a channel; only supergroup members can be restricted", peer)
+	}
+	if !canBanUsers(c) {
+		return fmt.Errorf("admin rights to ban users are required to restrict members of %s", peer)
+	}
+	user, err := peers.Resolve(ctx, userPeer)
+	if err != nil {
+		return err
+	}
+
+	err = withFloodRetry(ctx, func() error {
+		_, err := api.ChannelsEditBanned(ctx, &tg.ChannelsEditBannedRequest{
+			Channel:      input,
+			Participant:  user,
+			BannedRights: rights,
+		})
+		return err
+	})
+	if tg.IsUserAdminInvalid(err) {
+		return fmt.Errorf("%s is an admin of %s and cannot be restricted", userPeer, peer)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to restrict %s: %w", userPeer, err)
+	}
+	return nil
+}
+
+// canBanUsers reports whether the logged-in user may restrict members
+func canBanUsers(c *tg.Channel) bool {
+	if c.Creator {
+		return true
+	}
+	rights, ok := c.GetAdminRights()
+	return ok && rights.BanUsers
+}
+
+type commentOnPostArgs struct {
+	Channel string `json:"channel"`
+	PostID  int    `json:"post_id"`
+	Text    string `json:"text"`
+}
+
+type commentOnPostResult struct {
+	// PeerID is the discussion group the comment was posted in
+	PeerID    int64 `json:"peer_id"`
+	MessageID int   `json:"message_id"`
+}
+
+func commentOnPostTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args commentOnPostArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.Channel) == "" {
+			return nil, invalidParams("channel is required")
+		}
+		if args.PostID <= 0 {
+			return nil, invalidParams("post_id must be positive")
+		}
+		if strings.TrimSpace(args.Text) == "" {
+			return nil, invalidParams("text is required")
+		}
+		return commentOnPost(ctx, api, peers, args.Channel, args.PostID, args.Text)
+	}
+}
+
+// commentOnPost posts text as a comment under a channel post. Comments live
+// in the channel's linked discussion group, as replies to the automatic
+// forward of the post there.
+func commentOnPost(ctx context.Context, api *tg.Client, peers *peerResolver, channel string, postID int, text string) (commentOnPostResult, error) {
+	input, err := peers.ResolveChannel(ctx, channel)
+	if err != nil {
+		return commentOnPostResult{}, err
+	}
+	linked, err := linkedChat(ctx, api, peers, input)
+	if err != nil {
+		return commentOnPostResult{}, err
+	}
+	if linked == nil {
+		return commentOnPostResult{}, fmt.Errorf("%s has no linked discussion group, so its posts take no comments", channel)
+	}
+
+	var discussion *tg.MessagesDiscussionMessage
+	err = withFloodRetry(ctx, func() (err error) {
+		discussion, err = api.MessagesGetDiscussionMessage(ctx, &tg.MessagesGetDiscussionMessageRequest{
+			Peer:  &tg.InputPeerChannel{ChannelID: input.ChannelID, AccessHash: input.AccessHash},
+			MsgID: postID,
+		})
+		return err
+	})
+	if tg.IsMsgIDInvalid(err) {
+		return commentOnPostResult{}, fmt.Errorf("post %d of %s takes no comments", postID, channel)
+	}
+	if err != nil {
+		return commentOnPostResult{}, fmt.Errorf("failed to get discussion of post %d: %w", postID, err)
+	}
+	peers.remember(discussion.Users, discussion.Chats)
+
+	group := cachedPeer{Type: peerChannel, ID: linked.ID}
+	req, err := commentRequest(peers, group, discussion.Messages, text)
+	if err != nil {
+		return commentOnPostResult{}, fmt.Errorf("post %d of %s: %w", postID, channel, err)
+	}
+	var updates tg.UpdatesClass
+	err = withFloodRetry(ctx, func() (err error) {
+		updates, err = api.MessagesSendMessage(ctx, req)
+		return err
+	})
+	if err != nil {
+		return commentOnPostResult{}, fmt.Errorf("failed to post comment: %w", err)
+	}
+	id, err := sentMessageID(updates)
+	if err != nil {
+		return commentOnPostResult{}, err
+	}
+	return commentOnPostResult{PeerID: group.MarkedID(), MessageID: id}, nil
+}
+
+// commentRequest builds the message replying to the discussion group's copy
+// of a post, found among the messages of messages.getDiscussionMessage
+func commentRequest(peers *peerResolver, group cachedPeer, msgs []tg.MessageClass, text string) (*tg.MessagesSendMessageRequest, error) {
+	cached, ok := peers.cached(group.MarkedID())
+	if !ok {
+		return nil, fmt.Errorf("discussion group %d missing from response", group.ID)
+	}
+	threadID := 0
+	for _, m := range msgs {
+		if msg, ok := m.(*tg.Message); ok {
+			if p, ok := msg.PeerID.(*tg.PeerChannel); ok && p.ChannelID == group.ID {
+				threadID = msg.ID
+			}
+		}
+	}
+	if threadID == 0 {
+		return nil, fmt.Errorf("no copy in the discussion group to reply to")
+	}
+
+	randomID, err := randomInt64()
+	if err != nil {
+		return nil, err
+	}
+	req := &tg.MessagesSendMessageRequest{
+		Peer:     cached.InputPeer(),
+		Message:  text,
+		RandomID: randomID,
+	}
+	req.SetReplyTo(&tg.InputReplyToMessage{ReplyToMsgID: threadID})
+	return req, nil
+}
+
+// linkedChat returns the discussion group linked to a channel, or the
+// channel a discussion group belongs to; nil when there is none
+func linkedChat(ctx context.Context, api *tg.Client, peers *peerResolver, input *tg.InputChannel) (*tg.Channel, error) {
+	full, chats, err := channelFull(ctx, api, peers, input)
+	if err != nil {
+		return nil, err
+	}
+	id, ok := full.GetLinkedChatID()
+	if !ok {
+		return nil, nil
+	}
+	for _, c := range chats {
+		if c, ok := c.(*tg.Channel); ok && c.ID == id {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("linked chat %d missing from full channel response", id)
+}
+
+// channelFull fetches the full info of a channel with the chats that came
+// with it
+func channelFull(ctx context.Context, api *tg.Client, peers *peerResolver, input *tg.InputChannel) (*tg.ChannelFull, []tg.ChatClass, error) {
+	var res *tg.MessagesChatFull
+	err := withFloodRetry(ctx, func() (err error) {
+		res, err = api.ChannelsGetFullChannel(ctx, input)
+		return err
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get channel info: %w", err)
+	}
+	peers.remember(res.Users, res.Chats)
+	full, ok := res.FullChat.(*tg.ChannelFull)
+	if !ok {
+		return nil, nil, fmt.Errorf("unexpected full chat %T", res.FullChat)
+	}
+	return full, res.Chats, nil
+}
+
+// ChatInfo describes the chat linked to a channel. Linked is false when
+// there is none; the other fields are then empty.
+type ChatInfo struct {
+	Linked   bool   `json:"linked"`
+	PeerID   int64  `json:"peer_id,omitempty"`
+	Type     string `json:"type,omitempty"` // "supergroup" or "channel"
+	Title    string `json:"title,omitempty"`
+	Username string `json:"username,omitempty"`
+}
+
+func getLinkedChatTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args peerArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.Peer) == "" {
+			return nil, invalidParams("peer is required")
+		}
+		return getLinkedChat(ctx, api, peers, args.Peer)
+	}
+}
+
+// getLinkedChat returns the discussion group of a channel, or for a
+// discussion group the channel it belongs to
+func getLinkedChat(ctx context.Context, api *tg.Client, peers *peerResolver, peer string) (ChatInfo, error) {
+	input, err := peers.ResolveChannel(ctx, peer)
+	if err != nil {
+		return ChatInfo{}, err
+	}
+	c, err := linkedChat(ctx, api, peers, input)
+	if err != nil || c == nil {
+		return ChatInfo{}, err
+	}
+	info := ChatInfo{
+		Linked:   true,
+		PeerID:   cachedPeer{Type: peerChannel, ID: c.ID}.MarkedID(),
+		Type:     "supergroup",
+		Title:    c.Title,
+		Username: c.Username,
+	}
+	if c.Broadcast {
+		info.Type = "channel"
+	}
+	return info, nil
+}
+
+// slowModeValues are the slow mode delays Telegram accepts, in seconds
+var slowModeValues = []int{0, 10, 30, 60, 300, 900, 3600}
+
+type slowModeArgs struct {
+	Peer    string `json:"peer"`
+	Seconds *int   `json:"seconds"`
+}
+
+type slowModeResult struct {
+	// Seconds is the minimum delay between messages of one member, 0 when
+	// slow mode is off
+	Seconds int `json:"seconds"`
+}
+
+func getSlowModeTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args peerArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.Peer) == "" {
+			return nil, invalidParams("peer is required")
+		}
+		seconds, err := getSlowMode(ctx, api, peers, args.Peer)
+		if err != nil {
+			return nil, err
+		}
+		return slowModeResult{Seconds: seconds}, nil
+	}
+}
+
+func setSlowModeTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args slowModeArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.Peer) == "" {
+			return nil, invalidParams("peer is required")
+		}
+		if args.Seconds == nil {
+			return nil, invalidParams("seconds is required")
+		}
+		if !validSlowMode(*args.Seconds) {
+			return nil, invalidParams("seconds must be one of %v", slowModeValues)
+		}
+		if err := setSlowMode(ctx, api, peers, args.Peer, *args.Seconds); err != nil {
+			return nil, err
+		}
+		return slowModeResult{Seconds: *args.Seconds}, nil
+	}
+}
+
+func validSlowMode(seconds int) bool {
+	for _, v := range slowModeValues {
+		if seconds == v {
+			return true
+		}
+	}
+	return false
+}
+
+// getSlowMode returns the slow mode delay of a supergroup in seconds
+func getSlowMode(ctx context.Context, api *tg.Client, peers *peerResolver, peer string) (int, error) {
+	input, err := peers.ResolveChannel(ctx, peer)
+	if err != nil {
+		return 0, err
+	}
+	full, _, err := channelFull(ctx, api, peers, input)
+	if err != nil {
+		return 0, err
+	}
+	seconds, _ := full.GetSlowmodeSeconds()
+	return seconds, nil
+}
+
+// setSlowMode sets the slow mode delay of a supergroup, turning it off for
+// 0. Only admins allowed to restrict members may change it.
+func setSlowMode(ctx context.Context, api *tg.Client, peers *peerResolver, peer string, seconds int) error {
+	if !validSlowMode(seconds) {
+		return fmt.Errorf("invalid slow mode delay %d; use one of %v", seconds, slowModeValues)
+	}
+	input, c, err := getFullChannel(ctx, api, peers, peer)
+	if err != nil {
+		return err
+	}
+	if c.Broadcast {
+		return fmt.Errorf("%s is a channel; slow mode only applies to supergroups", peer)
+	}
+	if !canBanUsers(c) {
+		return fmt.Errorf("admin rights to restrict members are required to change slow mode in %s", peer)
+	}
+
+	err = withFloodRetry(ctx, func() error {
+		_, err := api.ChannelsToggleSlowMode(ctx, &tg.ChannelsToggleSlowModeRequest{
+			Channel: input,
+			Seconds: seconds,
+		})
+		return err
+	})
+	if err != nil && !tg.IsChatNotModified(err) {
+		return fmt.Errorf("failed to set slow mode: %w", err)
+	}
+	return nil
+}
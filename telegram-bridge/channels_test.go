@@ -0,0 +1,401 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+// fakeHistory answers messages.getHistory over msgs the way Telegram does for
+// the requests getPostsSince makes: offset_date seeks to the first message
+// dated at or after it, offset_id to the offset message itself, and a
+// negative add_offset returns that many messages from there on, newest first
+func fakeHistory(msgs []*tg.Message) func(input bin.Encoder) (bin.Encoder, error) {
+	sort.Slice(msgs, func(i, j int) bool { return msgs[i].ID < msgs[j].ID })
+	return func(input bin.Encoder) (bin.Encoder, error) {
+		req, ok := input.(*tg.MessagesGetHistoryRequest)
+		if !ok {
+			return nil, nil
+		}
+		start := len(msgs)
+		for i, m := range msgs {
+			if (req.OffsetDate != 0 && m.Date >= req.OffsetDate) || (req.OffsetDate == 0 && m.ID >= req.OffsetID) {
+				start = i
+				break
+			}
+		}
+		end := min(start+req.Limit, len(msgs))
+		var page []tg.MessageClass
+		for i := end - 1; i >= start; i-- {
+			page = append(page, msgs[i])
+		}
+		return &tg.MessagesChannelMessages{Messages: page}, nil
+	}
+}
+
+func TestGetPostsSince(t *testing.T) {
+	// 250 posts, one a minute
+	var msgs []*tg.Message
+	for id := 1; id <= 250; id++ {
+		msgs = append(msgs, &tg.Message{ID: id, Date: 1000 + 60*id, Message: "post", PeerID: &tg.PeerChannel{ChannelID: 5}})
+	}
+
+	tests := []struct {
+		name      string
+		since     int
+		wantCount int
+		wantFirst int // newest returned post
+		wantLast  int // oldest returned post
+	}{
+		{name: "boundary excludes post at since", since: 1000 + 60*200, wantCount: 50, wantFirst: 250, wantLast: 201},
+		{name: "between posts", since: 1000 + 60*200 + 30, wantCount: 50, wantFirst: 250, wantLast: 201},
+		{name: "several pages", since: 1000, wantCount: 250, wantFirst: 250, wantLast: 1},
+		{name: "nothing newer", since: 1000 + 60*250, wantCount: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inv, api, peers := newFakeClient(fakeHistory(msgs))
+			peers.storeChannel(&tg.Channel{ID: 5, AccessHash: 7})
+
+			posts, truncated, err := getPostsSince(context.Background(), api, peers, "-1000000000005", tt.since)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if truncated {
+				t.Error("result truncated")
+			}
+			if len(posts) != tt.wantCount {
+				t.Fatalf("got %d posts, want %d", len(posts), tt.wantCount)
+			}
+			if tt.wantCount > 0 && (posts[0].ID != tt.wantFirst || posts[len(posts)-1].ID != tt.wantLast) {
+				t.Errorf("posts span %d..%d, want %d..%d", posts[0].ID, posts[len(posts)-1].ID, tt.wantFirst, tt.wantLast)
+			}
+			first := requests[*tg.MessagesGetHistoryRequest](inv)[0]
+			if first.OffsetDate != tt.since+1 || first.OffsetID != 0 {
+				t.Errorf("first page offset_date = %d, offset_id = %d; want a seek to since", first.OffsetDate, first.OffsetID)
+			}
+		})
+	}
+}
+
+func TestGetPostsSinceTruncated(t *testing.T) {
+	var msgs []*tg.Message
+	for id := 1; id <= maxPostsSince+10; id++ {
+		msgs = append(msgs, &tg.Message{ID: id, Date: 1000 + id, Message: "post", PeerID: &tg.PeerChannel{ChannelID: 5}})
+	}
+	_, api, peers := newFakeClient(fakeHistory(msgs))
+	peers.storeChannel(&tg.Channel{ID: 5, AccessHash: 7})
+
+	posts, truncated, err := getPostsSince(context.Background(), api, peers, "-1000000000005", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !truncated || len(posts) != maxPostsSince {
+		t.Fatalf("got %d posts, truncated = %v", len(posts), truncated)
+	}
+	// The oldest posts are kept so a follow-up call can continue from them
+	if posts[len(posts)-1].ID != 1 || posts[0].ID != maxPostsSince {
+		t.Errorf("posts span %d..%d", posts[0].ID, posts[len(posts)-1].ID)
+	}
+}
+
+func TestCommentsCount(t *testing.T) {
+	tests := []struct {
+		name    string
+		replies *tg.MessageReplies
+		want    int
+		wantErr bool
+	}{
+		{name: "comments", replies: &tg.MessageReplies{Comments: true, Replies: 12}, want: 12},
+		{name: "no comments yet", replies: &tg.MessageReplies{Comments: true}, want: 0},
+		{name: "thread replies only", replies: &tg.MessageReplies{Replies: 3}, wantErr: true},
+		{name: "comments disabled", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := &tg.Message{ID: 1}
+			if tt.replies != nil {
+				msg.SetReplies(*tt.replies)
+			}
+			got, err := commentsCount(msg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("commentsCount() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("commentsCount() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMuteRights(t *testing.T) {
+	for _, until := range []int{0, 1700000000} {
+		rights := muteRights(until)
+		if rights.UntilDate != until {
+			t.Errorf("until_date = %d, want %d", rights.UntilDate, until)
+		}
+		if !rights.SendMessages || !rights.SendMedia || !rights.SendPlain || !rights.SendDocs || !rights.SendPolls {
+			t.Errorf("muteRights(%d) leaves sending allowed: %+v", until, rights)
+		}
+		if rights.ViewMessages || rights.InviteUsers || rights.ChangeInfo {
+			t.Errorf("muteRights(%d) restricts more than sending: %+v", until, rights)
+		}
+	}
+}
+
+func TestRestrictSender(t *testing.T) {
+	tests := []struct {
+		name    string
+		channel *tg.Channel
+		unmute  bool
+		wantErr string
+	}{
+		{name: "admin mutes", channel: bannerChannel(true)},
+		{name: "admin unmutes", channel: bannerChannel(true), unmute: true},
+		{name: "not an admin", channel: bannerChannel(false), wantErr: "admin rights"},
+		{name: "broadcast channel", channel: &tg.Channel{ID: 30, AccessHash: 3, Broadcast: true, Creator: true, Photo: &tg.ChatPhotoEmpty{}}, wantErr: "is a channel"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inv, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+				switch input.(type) {
+				case *tg.ChannelsGetFullChannelRequest:
+					return &tg.MessagesChatFull{
+						FullChat: &tg.ChannelFull{ID: 30, ChatPhoto: &tg.PhotoEmpty{}},
+						Chats:    []tg.ChatClass{tt.channel},
+					}, nil
+				case *tg.ChannelsEditBannedRequest:
+					return &tg.Updates{}, nil
+				}
+				return nil, nil
+			})
+			peers.storeChannel(tt.channel)
+			peers.storeUser(&tg.User{ID: 10, AccessHash: 1})
+
+			var err error
+			if tt.unmute {
+				err = unrestrictSender(context.Background(), api, peers, "-1000000000030", "10")
+			} else {
+				err = restrictSender(context.Background(), api, peers, "-1000000000030", "10", 1700000000)
+			}
+			edits := requests[*tg.ChannelsEditBannedRequest](inv)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) || len(edits) != 0 {
+					t.Fatalf("error = %v with %d edits, want an error before editing", err, len(edits))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			rights := edits[0].BannedRights
+			if rights.SendMessages == tt.unmute || (!tt.unmute && rights.UntilDate != 1700000000) {
+				t.Errorf("banned rights = %+v", rights)
+			}
+			if p, ok := edits[0].Participant.(*tg.InputPeerUser); !ok || p.AccessHash != 1 {
+				t.Errorf("participant = %#v", edits[0].Participant)
+			}
+		})
+	}
+}
+
+// bannerChannel returns a supergroup where this account is an admin allowed
+// to ban users, or an admin without that right
+func bannerChannel(canBan bool) *tg.Channel {
+	c := &tg.Channel{ID: 30, AccessHash: 3, Megagroup: true, Photo: &tg.ChatPhotoEmpty{}}
+	c.SetAdminRights(tg.ChatAdminRights{BanUsers: canBan, DeleteMessages: true})
+	return c
+}
+
+func TestCommentOnPost(t *testing.T) {
+	for _, linked := range []bool{true, false} {
+		inv, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+			switch input.(type) {
+			case *tg.ChannelsGetFullChannelRequest:
+				full := &tg.ChannelFull{ID: 30, ChatPhoto: &tg.PhotoEmpty{}}
+				res := &tg.MessagesChatFull{FullChat: full}
+				if linked {
+					full.SetLinkedChatID(40)
+					res.Chats = []tg.ChatClass{discussionGroup()}
+				}
+				return res, nil
+			case *tg.MessagesGetDiscussionMessageRequest:
+				return &tg.MessagesDiscussionMessage{
+					Messages: []tg.MessageClass{
+						&tg.Message{ID: 5, PeerID: &tg.PeerChannel{ChannelID: 30}},
+						&tg.Message{ID: 77, PeerID: &tg.PeerChannel{ChannelID: 40}},
+					},
+					Chats: []tg.ChatClass{discussionGroup()},
+				}, nil
+			case *tg.MessagesSendMessageRequest:
+				return &tg.UpdateShortSentMessage{ID: 9}, nil
+			}
+			return nil, nil
+		})
+		peers.storeChannel(&tg.Channel{ID: 30, AccessHash: 3})
+
+		res, err := commentOnPost(context.Background(), api, peers, "-1000000000030", 5, "nice post")
+		if !linked {
+			if err == nil || !strings.Contains(err.Error(), "no linked discussion group") {
+				t.Errorf("unlinked channel: error = %v", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.PeerID != -1000000000040 || res.MessageID != 9 {
+			t.Errorf("result = %+v", res)
+		}
+		if d := requests[*tg.MessagesGetDiscussionMessageRequest](inv)[0]; d.MsgID != 5 {
+			t.Errorf("discussion requested for post %d, want 5", d.MsgID)
+		}
+		sent := requests[*tg.MessagesSendMessageRequest](inv)[0]
+		if p, ok := sent.Peer.(*tg.InputPeerChannel); !ok || p.ChannelID != 40 || p.AccessHash != 4 {
+			t.Errorf("comment sent to %#v, want the discussion group", sent.Peer)
+		}
+		if r, ok := sent.ReplyTo.(*tg.InputReplyToMessage); !ok || r.ReplyToMsgID != 77 {
+			t.Errorf("reply_to = %#v, want the group's copy of the post", sent.ReplyTo)
+		}
+	}
+}
+
+// discussionGroup returns supergroup 40, the discussion group of channel 30
+func discussionGroup() *tg.Channel {
+	return &tg.Channel{ID: 40, AccessHash: 4, Megagroup: true, Title: "News chat", Photo: &tg.ChatPhotoEmpty{}}
+}
+
+func TestGetLinkedChat(t *testing.T) {
+	for _, linked := range []bool{true, false} {
+		_, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+			if _, ok := input.(*tg.ChannelsGetFullChannelRequest); !ok {
+				return nil, nil
+			}
+			full := &tg.ChannelFull{ID: 30, ChatPhoto: &tg.PhotoEmpty{}}
+			res := &tg.MessagesChatFull{FullChat: full}
+			if linked {
+				full.SetLinkedChatID(40)
+				res.Chats = []tg.ChatClass{discussionGroup()}
+			}
+			return res, nil
+		})
+		peers.storeChannel(&tg.Channel{ID: 30, AccessHash: 3})
+
+		info, err := getLinkedChat(context.Background(), api, peers, "-1000000000030")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !linked {
+			if info != (ChatInfo{}) {
+				t.Errorf("unlinked channel: info = %+v, want empty", info)
+			}
+			continue
+		}
+		want := ChatInfo{Linked: true, PeerID: -1000000000040, Type: "supergroup", Title: "News chat"}
+		if info != want {
+			t.Errorf("info = %+v, want %+v", info, want)
+		}
+		if _, ok := peers.cached(-1000000000040); !ok {
+			t.Error("linked group not cached")
+		}
+	}
+}
+
+func TestSlowMode(t *testing.T) {
+	inv, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		switch input.(type) {
+		case *tg.ChannelsGetFullChannelRequest:
+			full := &tg.ChannelFull{ID: 30, ChatPhoto: &tg.PhotoEmpty{}}
+			full.SetSlowmodeSeconds(60)
+			return &tg.MessagesChatFull{FullChat: full, Chats: []tg.ChatClass{bannerChannel(true)}}, nil
+		case *tg.ChannelsToggleSlowModeRequest:
+			return &tg.Updates{}, nil
+		}
+		return nil, nil
+	})
+	peers.storeChannel(bannerChannel(true))
+	ctx := context.Background()
+
+	seconds, err := getSlowMode(ctx, api, peers, "-1000000000030")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seconds != 60 {
+		t.Errorf("slow mode = %d, want 60", seconds)
+	}
+
+	if err := setSlowMode(ctx, api, peers, "-1000000000030", 300); err != nil {
+		t.Fatal(err)
+	}
+	if err := setSlowMode(ctx, api, peers, "-1000000000030", 45); err == nil {
+		t.Error("setSlowMode(45) succeeded, want invalid delay")
+	}
+	toggles := requests[*tg.ChannelsToggleSlowModeRequest](inv)
+	if len(toggles) != 1 {
+		t.Fatalf("made %d toggle requests, want 1", len(toggles))
+	}
+	if toggles[0].Seconds != 300 || toggles[0].Channel.(*tg.InputChannel).AccessHash != 3 {
+		t.Errorf("toggle request = %+v", toggles[0])
+	}
+}
+
+func TestSignaturesRetryFloodWait(t *testing.T) {
+	flooded := map[string]bool{}
+	floodOnce := func(method string) error {
+		if flooded[method] {
+			return nil
+		}
+		flooded[method] = true
+		return tgerr.New(420, "FLOOD_WAIT_0")
+	}
+	inv, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		switch input.(type) {
+		case *tg.ChannelsGetFullChannelRequest:
+			if err := floodOnce("full"); err != nil {
+				return nil, err
+			}
+			c := &tg.Channel{ID: 30, AccessHash: 3, Broadcast: true, Creator: true, Signatures: true, Photo: &tg.ChatPhotoEmpty{}}
+			return &tg.MessagesChatFull{FullChat: &tg.ChannelFull{ID: 30, ChatPhoto: &tg.PhotoEmpty{}}, Chats: []tg.ChatClass{c}}, nil
+		case *tg.ChannelsToggleSignaturesRequest:
+			if err := floodOnce("toggle"); err != nil {
+				return nil, err
+			}
+			return &tg.Updates{}, nil
+		}
+		return nil, nil
+	})
+	peers.storeChannel(&tg.Channel{ID: 30, AccessHash: 3, Broadcast: true})
+	ctx := context.Background()
+
+	signed, err := getSignatures(ctx, api, peers, "-1000000000030")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !signed {
+		t.Error("signatures = false, want true")
+	}
+	if err := toggleSignatures(ctx, api, peers, "-1000000000030", false); err != nil {
+		t.Fatal(err)
+	}
+	if toggles := requests[*tg.ChannelsToggleSignaturesRequest](inv); len(toggles) != 2 || toggles[1].Enabled {
+		t.Errorf("toggle requests = %+v, want one retry disabling signatures", toggles)
+	}
+}
+
+func TestValidSlowMode(t *testing.T) {
+	for _, seconds := range []int{0, 10, 30, 60, 300, 900, 3600} {
+		if !validSlowMode(seconds) {
+			t.Errorf("validSlowMode(%d) = false", seconds)
+		}
+	}
+	for _, seconds := range []int{-10, 1, 45, 7200} {
+		if validSlowMode(seconds) {
+			t.Errorf("validSlowMode(%d) = true", seconds)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// Config is the bridge configuration, read from the environment and
+// config.ini
+type Config struct {
+	// Account is the selected [account.<name>] section, empty for the
+	// single-account [telegram] layout
+	Account string
+	// StoreDir holds the session files: store, or store/<account>
+	StoreDir string
+
+	APIID       int
+	APIHash     string
+	Phone       string
+	Password2FA string
+	// AuthMode is "qr" (default) or "phone"
+	AuthMode string
+	// QRTimeout is how long QR login waits for the code to be scanned
+	QRTimeout time.Duration
+
+	// SessionExportPretty indents the JSON files the bridge writes
+	SessionExportPretty bool
+	FloodRetryAttempts  int
+	// StreamUpdates sends incoming messages as MCP notifications, those
+	// passing StreamFilter
+	StreamUpdates bool
+	StreamFilter  messageFilter
+	// StealthMode stops the bridge from sending read receipts
+	StealthMode bool
+	// HealthAddr is the listen address of the health endpoints, disabled
+	// when empty
+	HealthAddr string
+	// ResultFormat renders tool results as "json" (default) or "text"
+	ResultFormat string
+	// RPCTimeout bounds each tool call; ToolTimeouts overrides it for the
+	// tools listed under [timeouts]
+	RPCTimeout   time.Duration
+	ToolTimeouts map[string]time.Duration
+
+	Proxy   ProxyConfig
+	Logging LoggingConfig
+}
+
+// Environment variables taking precedence over the [telegram] keys of
+// config.ini
+var envKeys = map[string]string{
+	"api_id":       "TELEGRAM_API_ID",
+	"api_hash":     "TELEGRAM_API_HASH",
+	"phone":        "TELEGRAM_PHONE",
+	"password_2fa": "TELEGRAM_PASSWORD_2FA",
+}
+
+// accountSectionPrefix starts the name of a per-account config section
+const accountSectionPrefix = "account."
+
+// validAccountName restricts account names to characters safe in a path
+var validAccountName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// loadConfig reads the configuration for account. Credentials come from the
+// TELEGRAM_* environment variables when set and from config.ini otherwise;
+// config.ini may be absent if the environment provides everything required.
+//
+// When config.ini defines [account.<name>] sections, account selects one of
+// them and may only be omitted if there is exactly one. Keys missing from
+// the account section fall back to [telegram]. Without named accounts the
+// [telegram] section is used and account must be empty.
+func loadConfig(account string) (*Config, error) {
+	file, err := ini.LooseLoad(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	account, storeDir, err := selectAccount(file, account)
+	if err != nil {
+		return nil, err
+	}
+
+	telegram := file.Section("telegram")
+	if account != "" {
+		// Inherit [telegram] keys not set for the account
+		section := file.Section(accountSectionPrefix + account)
+		for _, key := range telegram.Keys() {
+			if !section.HasKey(key.Name()) {
+				section.Key(key.Name()).SetValue(key.Value())
+			}
+		}
+		telegram = section
+	}
+	lookup := func(key string) string {
+		if v, ok := os.LookupEnv(envKeys[key]); ok && v != "" {
+			return v
+		}
+		return telegram.Key(key).String()
+	}
+
+	cfg := &Config{
+		Account:  account,
+		StoreDir: storeDir,
+
+		APIHash:     lookup("api_hash"),
+		Phone:       lookup("phone"),
+		Password2FA: lookup("password_2fa"),
+		QRTimeout:   telegram.Key("qr_timeout").MustDuration(5 * time.Minute),
+
+		// Pretty-printed JSON artifacts by default; set false for compact output
+		SessionExportPretty: file.Section("bridge").Key("session_export_pretty").MustBool(true),
+		FloodRetryAttempts:  file.Section("bridge").Key("flood_retry_attempts").MustInt(3),
+		StreamUpdates:       file.Section("bridge").Key("stream_updates").MustBool(false),
+		StealthMode:         file.Section("bridge").Key("stealth_mode").MustBool(false),
+		HealthAddr:          file.Section("bridge").Key("health_addr").String(),
+	}
+
+	// QR login is the default; "phone" signs in with a code sent to phone
+	cfg.AuthMode, err = oneOf(telegram.Key("auth_mode"), "qr", "phone")
+	if err != nil {
+		return nil, err
+	}
+	cfg.ResultFormat, err = oneOf(file.Section("bridge").Key("result_format"), resultFormatJSON, resultFormatText)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.APIID, err = parseAPIID(lookup("api_id"))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.APIHash == "" || cfg.APIID == 0 {
+		return nil, fmt.Errorf("api_id and api_hash must be set in [%s] of config.ini or TELEGRAM_API_ID/TELEGRAM_API_HASH", telegram.Name())
+	}
+	if cfg.QRTimeout <= 0 {
+		return nil, errors.New("qr_timeout must be positive")
+	}
+	if cfg.FloodRetryAttempts < 1 {
+		return nil, errors.New("flood_retry_attempts must be at least 1")
+	}
+
+	cfg.StreamFilter, err = loadMessageFilter(file)
+	if err != nil {
+		return nil, err
+	}
+	cfg.RPCTimeout, cfg.ToolTimeouts, err = loadTimeouts(file)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Proxy, err = loadProxyConfig(file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy config: %w", err)
+	}
+	cfg.Logging, err = loadLoggingConfig(file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid logging config: %w", err)
+	}
+	return cfg, nil
+}
+
+// oneOf returns the value of key, which must be one of values; an unset key
+// is the first value
+func oneOf(key *ini.Key, values ...string) (string, error) {
+	v := strings.TrimSpace(key.Value())
+	if v == "" {
+		return values[0], nil
+	}
+	if !slices.Contains(values, v) {
+		return "", fmt.Errorf("%s must be one of %s, got %q", key.Name(), strings.Join(values, ", "), v)
+	}
+	return v, nil
+}
+
+// maxTimeoutSeconds caps the configurable tool timeouts at one day
+const maxTimeoutSeconds = 24 * 60 * 60
+
+// validToolName matches the names tools are registered under
+var validToolName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// loadTimeouts reads the global tool timeout, [bridge] rpc_timeout_seconds,
+// and the per-tool overrides of [timeouts], one tool name per key
+func loadTimeouts(file *ini.File) (time.Duration, map[string]time.Duration, error) {
+	seconds := func(key *ini.Key) (time.Duration, error) {
+		n, err := key.Int()
+		if err != nil || n < 1 || n > maxTimeoutSeconds {
+			return 0, fmt.Errorf("%s must be a number of seconds between 1 and %d, got %q", key.Name(), maxTimeoutSeconds, key.Value())
+		}
+		return time.Duration(n) * time.Second, nil
+	}
+
+	global := 60 * time.Second
+	if key := file.Section("bridge").Key("rpc_timeout_seconds"); key.Value() != "" {
+		var err error
+		if global, err = seconds(key); err != nil {
+			return 0, nil, err
+		}
+	}
+	perTool := make(map[string]time.Duration)
+	for _, key := range file.Section("timeouts").Keys() {
+		if !validToolName.MatchString(key.Name()) {
+			return 0, nil, fmt.Errorf("invalid tool name %q in [timeouts]", key.Name())
+		}
+		timeout, err := seconds(key)
+		if err != nil {
+			return 0, nil, fmt.Errorf("invalid [timeouts] entry: %w", err)
+		}
+		perTool[key.Name()] = timeout
+	}
+	return global, perTool, nil
+}
+
+// selectAccount resolves the account to run and its store directory
+func selectAccount(file *ini.File, account string) (string, string, error) {
+	var names []string
+	for _, section := range file.Sections() {
+		if name, ok := strings.CutPrefix(section.Name(), accountSectionPrefix); ok {
+			names = append(names, name)
+		}
+	}
+
+	switch {
+	case len(names) == 0 && account == "":
+		return "", "store", nil
+	case len(names) == 0:
+		return "", "", fmt.Errorf("account %q selected but config.ini defines no [account.<name>] sections", account)
+	case account == "" && len(names) == 1:
+		account = names[0]
+	case account == "":
+		return "", "", fmt.Errorf("config.ini defines accounts %s; select one with --account or ACCOUNT", strings.Join(names, ", "))
+	}
+
+	if !validAccountName.MatchString(account) {
+		return "", "", fmt.Errorf("invalid account name %q: use letters, digits, _ and -", account)
+	}
+	if !file.HasSection(accountSectionPrefix + account) {
+		return "", "", fmt.Errorf("account %q not found; config.ini defines %s", account, strings.Join(names, ", "))
+	}
+	return account, filepath.Join("store", account), nil
+}
+
+// parseAPIID validates api_id the same way whichever source it came from
+func parseAPIID(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	id, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid api_id %q: must be numeric", s)
+	}
+	return id, nil
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+func TestParseAPIID(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int
+		wantErr bool
+	}{
+		{in: "12345", want: 12345},
+		{in: " 12345\n", want: 12345},
+		{in: "", want: 0},
+		{in: "abc", wantErr: true},
+		{in: "12a45", wantErr: true},
+		{in: "1.5", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseAPIID(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseAPIID(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseAPIID(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+// withConfig runs the test from a directory holding content as the
+// config.ini loadConfig reads
+func withConfig(t *testing.T, content string) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, configPath)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	for _, env := range envKeys {
+		t.Setenv(env, "")
+	}
+}
+
+func TestLoadConfigEnvironment(t *testing.T) {
+	withConfig(t, "[telegram]\napi_id = 111\napi_hash = filehash\nphone = +1000\n")
+
+	cfg, err := loadConfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.APIID != 111 || cfg.APIHash != "filehash" || cfg.Phone != "+1000" {
+		t.Errorf("config from file = %+v", cfg)
+	}
+
+	t.Setenv("TELEGRAM_API_ID", "222")
+	t.Setenv("TELEGRAM_PASSWORD_2FA", "envpass")
+	cfg, err = loadConfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.APIID != 222 || cfg.APIHash != "filehash" || cfg.Password2FA != "envpass" {
+		t.Errorf("environment did not take precedence key by key: %+v", cfg)
+	}
+
+	// Invalid api_id is reported the same way from either source
+	t.Setenv("TELEGRAM_API_ID", "abc")
+	_, envErr := loadConfig("")
+	t.Setenv("TELEGRAM_API_ID", "")
+	withConfig(t, "[telegram]\napi_id = abc\napi_hash = filehash\n")
+	_, fileErr := loadConfig("")
+	if envErr == nil || fileErr == nil || envErr.Error() != fileErr.Error() {
+		t.Errorf("invalid api_id errors differ: env %v, file %v", envErr, fileErr)
+	}
+}
+
+func TestLoadConfigMissingCredentials(t *testing.T) {
+	withConfig(t, "[telegram]\napi_id = 111\n")
+	if _, err := loadConfig(""); err == nil || !strings.Contains(err.Error(), "api_hash") {
+		t.Errorf("loadConfig() error = %v, want missing api_hash", err)
+	}
+}
+
+func TestSelectAccount(t *testing.T) {
+	const accounts = "[telegram]\napi_hash = shared\n[account.work]\napi_id = 1\n[account.home]\napi_id = 2\n"
+	tests := []struct {
+		name         string
+		config       string
+		account      string
+		wantAccount  string
+		wantStoreDir string
+		wantErr      string
+	}{
+		{name: "single account layout", config: "[telegram]\napi_id = 1\n", wantStoreDir: "store"},
+		{name: "account without sections", config: "[telegram]\napi_id = 1\n", account: "work", wantErr: "defines no [account.<name>] sections"},
+		{name: "only account is implied", config: "[account.work]\napi_id = 1\n", wantAccount: "work", wantStoreDir: filepath.Join("store", "work")},
+		{name: "selected account", config: accounts, account: "home", wantAccount: "home", wantStoreDir: filepath.Join("store", "home")},
+		{name: "ambiguous", config: accounts, wantErr: "select one with --account"},
+		{name: "unknown account", config: accounts, account: "play", wantErr: `account "play" not found`},
+		{name: "path traversal", config: accounts, account: "../work", wantErr: "invalid account name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := ini.Load([]byte(tt.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+			account, storeDir, err := selectAccount(file, tt.account)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("selectAccount() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if account != tt.wantAccount || storeDir != tt.wantStoreDir {
+				t.Errorf("selectAccount() = %q, %q; want %q, %q", account, storeDir, tt.wantAccount, tt.wantStoreDir)
+			}
+		})
+	}
+}
+
+func TestLoadConfigAccountInherits(t *testing.T) {
+	withConfig(t, "[telegram]\napi_hash = shared\nphone = +1000\n[account.work]\napi_id = 7\nphone = +2000\n")
+	cfg, err := loadConfig("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.APIID != 7 || cfg.APIHash != "shared" || cfg.Phone != "+2000" || cfg.StoreDir != filepath.Join("store", "work") {
+		t.Errorf("loadConfig(work) = %+v", cfg)
+	}
+}
+
+func TestLoadTimeouts(t *testing.T) {
+	credentials := "[telegram]\napi_id = 111\napi_hash = hash\n"
+	tests := []struct {
+		name       string
+		config     string
+		wantGlobal time.Duration
+		wantTools  map[string]time.Duration
+		wantErr    bool
+	}{
+		{name: "defaults", wantGlobal: time.Minute, wantTools: map[string]time.Duration{}},
+		{
+			name:       "global and per tool",
+			config:     "[bridge]\nrpc_timeout_seconds = 30\n[timeouts]\ndownload_media = 600\n",
+			wantGlobal: 30 * time.Second,
+			wantTools:  map[string]time.Duration{"download_media": 10 * time.Minute},
+		},
+		{name: "zero global", config: "[bridge]\nrpc_timeout_seconds = 0\n", wantErr: true},
+		{name: "negative tool timeout", config: "[timeouts]\ndownload_media = -5\n", wantErr: true},
+		{name: "not a number", config: "[timeouts]\ndownload_media = 10m\n", wantErr: true},
+		{name: "too long", config: "[timeouts]\ndownload_media = 86401\n", wantErr: true},
+		{name: "bad tool name", config: "[timeouts]\nDownload-Media = 60\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, credentials+tt.config)
+			cfg, err := loadConfig("")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if cfg.RPCTimeout != tt.wantGlobal {
+				t.Errorf("rpc timeout = %s, want %s", cfg.RPCTimeout, tt.wantGlobal)
+			}
+			if fmt.Sprint(cfg.ToolTimeouts) != fmt.Sprint(tt.wantTools) {
+				t.Errorf("tool timeouts = %v, want %v", cfg.ToolTimeouts, tt.wantTools)
+			}
+		})
+	}
+}
+
+func TestLoadConfigChoices(t *testing.T) {
+	credentials := "[telegram]\napi_id = 111\napi_hash = hash\n"
+	tests := []struct {
+		name       string
+		config     string
+		wantAuth   string
+		wantFormat string
+		wantErrKey string
+	}{
+		{name: "defaults", config: credentials, wantAuth: "qr", wantFormat: resultFormatJSON},
+		{name: "set", config: credentials + "auth_mode = phone\n[bridge]\nresult_format = text\n", wantAuth: "phone", wantFormat: resultFormatText},
+		{name: "unknown auth mode", config: credentials + "auth_mode = sms\n", wantErrKey: "auth_mode"},
+		{name: "unknown result format", config: credentials + "[bridge]\nresult_format = yaml\n", wantErrKey: "result_format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, tt.config)
+			cfg, err := loadConfig("")
+			if tt.wantErrKey != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErrKey) {
+					t.Fatalf("loadConfig() error = %v, want one naming %s", err, tt.wantErrKey)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.AuthMode != tt.wantAuth || cfg.ResultFormat != tt.wantFormat {
+				t.Errorf("auth_mode = %q, result_format = %q", cfg.AuthMode, cfg.ResultFormat)
+			}
+		})
+	}
+}
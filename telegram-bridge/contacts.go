@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gotd/td/tg"
+)
+
+// recentSearchLimit is how many top peers of each category are fetched
+const recentSearchLimit = 20
+
+// Top peer categories the official apps suggest on their search screen
+var searchCategories = []struct {
+	name     string
+	category tg.TopPeerCategoryClass
+}{
+	{"correspondents", &tg.TopPeerCategoryCorrespondents{}},
+	{"groups", &tg.TopPeerCategoryGroups{}},
+	{"channels", &tg.TopPeerCategoryChannels{}},
+}
+
+// RecentSearchPeer is a peer suggested on the search screen
+type RecentSearchPeer struct {
+	PeerID   int64   `json:"peer_id"`
+	Type     string  `json:"type"`
+	Name     string  `json:"name"`
+	Username string  `json:"username,omitempty"`
+	Category string  `json:"category"`
+	Rating   float64 `json:"rating"`
+
+	category tg.TopPeerCategoryClass
+	input    tg.InputPeerClass
+}
+
+func getRecentSearchesTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args struct{}
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		return getRecentSearches(ctx, api, peers)
+	}
+}
+
+type clearRecentSearchesResult struct {
+	Cleared int `json:"cleared"`
+}
+
+func clearRecentSearchesTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args struct{}
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		cleared, err := clearRecentSearches(ctx, api, peers)
+		if err != nil {
+			return nil, err
+		}
+		return clearRecentSearchesResult{Cleared: cleared}, nil
+	}
+}
+
+// getRecentSearches returns the people, groups and channels Telegram
+// suggests when searching, the most used first within each category.
+// Telegram keeps the search history itself on the device, so these top peers
+// are the closest record of recent intent available to the API.
+func getRecentSearches(ctx context.Context, api *tg.Client, peers *peerResolver) ([]RecentSearchPeer, error) {
+	var res tg.ContactsTopPeersClass
+	err := withFloodRetry(ctx, func() (err error) {
+		res, err = api.ContactsGetTopPeers(ctx, &tg.ContactsGetTopPeersRequest{
+			Correspondents: true,
+			Groups:         true,
+			Channels:       true,
+			Limit:          recentSearchLimit,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top peers: %w", err)
+	}
+
+	switch res := res.(type) {
+	case *tg.ContactsTopPeers:
+		peers.remember(res.Users, res.Chats)
+		return newRecentSearchPeers(res), nil
+	case *tg.ContactsTopPeersDisabled:
+		return nil, fmt.Errorf("search suggestions are disabled for this account")
+	default:
+		return nil, fmt.Errorf("unexpected top peers response %T", res)
+	}
+}
+
+func newRecentSearchPeers(res *tg.ContactsTopPeers) []RecentSearchPeer {
+	users := make(map[int64]*tg.User)
+	for _, u := range res.Users {
+		if u, ok := u.(*tg.User); ok {
+			users[u.ID] = u
+		}
+	}
+	chats := make(map[int64]tg.ChatClass)
+	for _, c := range res.Chats {
+		chats[c.GetID()] = c
+	}
+
+	result := []RecentSearchPeer{}
+	for _, cat := range res.Categories {
+		name, ok := searchCategoryName(cat.Category)
+		if !ok {
+			continue
+		}
+		for _, top := range cat.Peers {
+			p := RecentSearchPeer{Category: name, Rating: top.Rating, category: cat.Category}
+			switch peer := top.Peer.(type) {
+			case *tg.PeerUser:
+				u, ok := users[peer.UserID]
+				if !ok {
+					continue
+				}
+				p.Type = peerUser
+				p.PeerID = u.ID
+				p.Name = strings.TrimSpace(u.FirstName + " " + u.LastName)
+				p.Username = u.Username
+				p.input = &tg.InputPeerUser{UserID: u.ID, AccessHash: u.AccessHash}
+			case *tg.PeerChat:
+				c, ok := chats[peer.ChatID].(*tg.Chat)
+				if !ok {
+					continue
+				}
+				p.Type = peerChat
+				p.PeerID = cachedPeer{Type: peerChat, ID: c.ID}.MarkedID()
+				p.Name = c.Title
+				p.input = &tg.InputPeerChat{ChatID: c.ID}
+			case *tg.PeerChannel:
+				c, ok := chats[peer.ChannelID].(*tg.Channel)
+				if !ok {
+					continue
+				}
+				p.Type = peerChannel
+				p.PeerID = cachedPeer{Type: peerChannel, ID: c.ID}.MarkedID()
+				p.Name = c.Title
+				p.Username = c.Username
+				p.input = &tg.InputPeerChannel{ChannelID: c.ID, AccessHash: c.AccessHash}
+			default:
+				continue
+			}
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+func searchCategoryName(c tg.TopPeerCategoryClass) (string, bool) {
+	for _, cat := range searchCategories {
+		if cat.category.TypeID() == c.TypeID() {
+			return cat.name, true
+		}
+	}
+	return "", false
+}
+
+// clearRecentSearches resets the rating of every suggested peer, removing
+// it from the suggestions until it is used again, and returns how many were
+// reset
+func clearRecentSearches(ctx context.Context, api *tg.Client, peers *peerResolver) (int, error) {
+	suggested, err := getRecentSearches(ctx, api, peers)
+	if err != nil {
+		return 0, err
+	}
+	for i, p := range suggested {
+		err := withFloodRetry(ctx, func() error {
+			_, err := api.ContactsResetTopPeerRating(ctx, &tg.ContactsResetTopPeerRatingRequest{
+				Category: p.category,
+				Peer:     p.input,
+			})
+			return err
+		})
+		if err != nil {
+			return i, fmt.Errorf("failed to clear %s from search suggestions: %w", p.Name, err)
+		}
+	}
+	return len(suggested), nil
+}
+
+// Contact is an entry of the account's contact list
+type Contact struct {
+	UserID    int64  `json:"user_id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name,omitempty"`
+	Username  string `json:"username,omitempty"`
+	// Phone is in international format with a leading +, empty when the
+	// contact hides it
+	Phone string `json:"phone,omitempty"`
+	// Mutual is set when you are in the contact's list too
+	Mutual bool `json:"mutual"`
+}
+
+type listContactsResult struct {
+	Contacts []Contact `json:"contacts"`
+}
+
+func listContactsTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args struct{}
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		contacts, err := getContacts(ctx, api, peers)
+		if err != nil {
+			return nil, err
+		}
+		return listContactsResult{Contacts: contacts}, nil
+	}
+}
+
+// getContacts returns the whole contact list in the order Telegram keeps it
+func getContacts(ctx context.Context, api *tg.Client, peers *peerResolver) ([]Contact, error) {
+	var res tg.ContactsContactsClass
+	err := withFloodRetry(ctx, func() (err error) {
+		res, err = api.ContactsGetContacts(ctx, 0)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get contacts: %w", err)
+	}
+	// Hash 0 always gets the full list
+	list, ok := res.(*tg.ContactsContacts)
+	if !ok {
+		return nil, fmt.Errorf("unexpected contacts response %T", res)
+	}
+	peers.remember(list.Users, nil)
+
+	users := usersByID(list.Users)
+	contacts := make([]Contact, 0, len(list.Contacts))
+	for _, c := range list.Contacts {
+		u, ok := users[c.UserID]
+		if !ok {
+			continue
+		}
+		contacts = append(contacts, newContact(u, c.Mutual))
+	}
+	return contacts, nil
+}
+
+func newContact(u *tg.User, mutual bool) Contact {
+	contact := Contact{
+		UserID:    u.ID,
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		Username:  u.Username,
+		Mutual:    mutual,
+	}
+	if u.Phone != "" {
+		contact.Phone = "+" + strings.TrimPrefix(u.Phone, "+")
+	}
+	return contact
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+)
+
+func topPeersResponse() *tg.ContactsTopPeers {
+	return &tg.ContactsTopPeers{
+		Categories: []tg.TopPeerCategoryPeers{
+			{Category: &tg.TopPeerCategoryCorrespondents{}, Count: 1, Peers: []tg.TopPeer{
+				{Peer: &tg.PeerUser{UserID: 10}, Rating: 2.5},
+			}},
+			{Category: &tg.TopPeerCategoryBotsInline{}, Count: 1, Peers: []tg.TopPeer{
+				{Peer: &tg.PeerUser{UserID: 11}, Rating: 1},
+			}},
+			{Category: &tg.TopPeerCategoryChannels{}, Count: 2, Peers: []tg.TopPeer{
+				{Peer: &tg.PeerChannel{ChannelID: 30}, Rating: 1.5},
+				{Peer: &tg.PeerChannel{ChannelID: 31}, Rating: 1},
+			}},
+		},
+		Users: []tg.UserClass{
+			&tg.User{ID: 10, AccessHash: 1, FirstName: "Alice", LastName: "Liddell", Username: "alice"},
+			&tg.User{ID: 11, AccessHash: 2, FirstName: "Bot"},
+		},
+		Chats: []tg.ChatClass{
+			&tg.Channel{ID: 30, AccessHash: 3, Title: "News", Photo: &tg.ChatPhotoEmpty{}},
+		},
+	}
+}
+
+func TestGetRecentSearches(t *testing.T) {
+	_, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		if _, ok := input.(*tg.ContactsGetTopPeersRequest); ok {
+			return topPeersResponse(), nil
+		}
+		return nil, nil
+	})
+
+	got, err := getRecentSearches(context.Background(), api, peers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Inline bots are not a search category, and channel 31 has no entity
+	if len(got) != 2 {
+		t.Fatalf("got %d peers, want 2: %+v", len(got), got)
+	}
+	alice, news := got[0], got[1]
+	if alice.PeerID != 10 || alice.Type != peerUser || alice.Name != "Alice Liddell" || alice.Username != "alice" ||
+		alice.Category != "correspondents" || alice.Rating != 2.5 {
+		t.Errorf("first peer = %+v", alice)
+	}
+	if news.PeerID != -1000000000030 || news.Type != peerChannel || news.Name != "News" || news.Category != "channels" {
+		t.Errorf("second peer = %+v", news)
+	}
+	if _, ok := peers.cached(-1000000000030); !ok {
+		t.Error("suggested channel not cached")
+	}
+}
+
+func TestClearRecentSearches(t *testing.T) {
+	inv, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		switch input.(type) {
+		case *tg.ContactsGetTopPeersRequest:
+			return topPeersResponse(), nil
+		case *tg.ContactsResetTopPeerRatingRequest:
+			return &tg.BoolTrue{}, nil
+		}
+		return nil, nil
+	})
+
+	cleared, err := clearRecentSearches(context.Background(), api, peers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resets := requests[*tg.ContactsResetTopPeerRatingRequest](inv)
+	if cleared != 2 || len(resets) != 2 {
+		t.Fatalf("cleared %d with %d resets, want 2", cleared, len(resets))
+	}
+	if _, ok := resets[0].Category.(*tg.TopPeerCategoryCorrespondents); !ok {
+		t.Errorf("first reset category = %T", resets[0].Category)
+	}
+	if p, ok := resets[1].Peer.(*tg.InputPeerChannel); !ok || p.AccessHash != 3 {
+		t.Errorf("second reset peer = %#v", resets[1].Peer)
+	}
+}
+
+func TestGetRecentSearchesDisabled(t *testing.T) {
+	_, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		return &tg.ContactsTopPeersDisabled{}, nil
+	})
+	if _, err := getRecentSearches(context.Background(), api, peers); err == nil {
+		t.Error("getRecentSearches() with suggestions disabled succeeded, want error")
+	}
+}
+
+func contactsClient() func(input bin.Encoder) (bin.Encoder, error) {
+	return func(input bin.Encoder) (bin.Encoder, error) {
+		if _, ok := input.(*tg.ContactsGetContactsRequest); ok {
+			return &tg.ContactsContacts{
+				Contacts: []tg.Contact{{UserID: 10, Mutual: true}, {UserID: 11}},
+				Users: []tg.UserClass{
+					&tg.User{ID: 10, AccessHash: 1, FirstName: "Alice", LastName: "Doe, Jr.", Username: "alice", Phone: "15551234567"},
+					&tg.User{ID: 11, AccessHash: 1, FirstName: "Bob"},
+				},
+			}, nil
+		}
+		return nil, nil
+	}
+}
+
+func TestGetContacts(t *testing.T) {
+	_, api, peers := newFakeClient(contactsClient())
+	contacts, err := getContacts(context.Background(), api, peers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Contact{
+		{UserID: 10, FirstName: "Alice", LastName: "Doe, Jr.", Username: "alice", Phone: "+15551234567", Mutual: true},
+		{UserID: 11, FirstName: "Bob"},
+	}
+	if len(contacts) != len(want) || contacts[0] != want[0] || contacts[1] != want[1] {
+		t.Errorf("contacts = %+v, want %+v", contacts, want)
+	}
+	if _, ok := peers.cached(10); !ok {
+		t.Error("contact not cached")
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gotd/td/tg"
+)
+
+// customEmojiPattern matches the [emoji:<document_id>] markup outgoing text
+// uses for custom emoji
+var customEmojiPattern = regexp.MustCompile(`\[emoji:(\d+)\]`)
+
+// maxCustomEmoji is how many custom emoji Telegram renders in one message
+const maxCustomEmoji = 100
+
+// errCustomEmojiPremium is returned for custom emoji markup when the account
+// has no Telegram Premium
+var errCustomEmojiPremium = errors.New("custom emoji in messages need Telegram Premium")
+
+// expandCustomEmoji replaces each [emoji:<id>] in text with the emoji the
+// custom emoji stands in for and returns the text with a custom emoji
+// entity over each. The IDs are checked with Telegram, which also gives
+// the stand-in emoji. Text without markup is returned unchanged.
+func expandCustomEmoji(ctx context.Context, api *tg.Client, peers *peerResolver, text string) (string, []tg.MessageEntityClass, error) {
+	matches := customEmojiPattern.FindAllStringSubmatch(text, -1)
+	if len(matches) == 0 {
+		return text, nil, nil
+	}
+	if len(matches) > maxCustomEmoji {
+		return "", nil, invalidParams("at most %d custom emoji fit in a message", maxCustomEmoji)
+	}
+	self, err := peers.Self(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	if !self.Premium {
+		return "", nil, errCustomEmojiPremium
+	}
+
+	var ids []int64
+	seen := make(map[int64]bool)
+	for _, m := range matches {
+		id, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return "", nil, invalidParams("custom emoji ID %s is out of range", m[1])
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	var docs []tg.DocumentClass
+	err = withFloodRetry(ctx, func() (err error) {
+		docs, err = api.MessagesGetCustomEmojiDocuments(ctx, ids)
+		return err
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get custom emoji: %w", err)
+	}
+	alts := customEmojiAlts(docs)
+	for _, id := range ids {
+		if _, ok := alts[id]; !ok {
+			return "", nil, invalidParams("custom emoji %d does not exist", id)
+		}
+	}
+	expanded, entities := buildCustomEmoji(text, alts)
+	return expanded, entities, nil
+}
+
+// customEmojiAlts maps each custom emoji document to the emoji it stands
+// in for
+func customEmojiAlts(docs []tg.DocumentClass) map[int64]string {
+	alts := make(map[int64]string, len(docs))
+	for _, d := range docs {
+		doc, ok := d.AsNotEmpty()
+		if !ok {
+			continue
+		}
+		for _, attr := range doc.Attributes {
+			if a, ok := attr.(*tg.DocumentAttributeCustomEmoji); ok && a.Alt != "" {
+				alts[doc.ID] = a.Alt
+			}
+		}
+	}
+	return alts
+}
+
+// buildCustomEmoji replaces the markup of text by the emoji in alts and
+// returns the entities covering them, with offsets in UTF-16 code units.
+// Markup with an ID missing from alts is left as it is.
+func buildCustomEmoji(text string, alts map[int64]string) (string, []tg.MessageEntityClass) {
+	var (
+		b        strings.Builder
+		entities []tg.MessageEntityClass
+		last     int
+	)
+	for _, m := range customEmojiPattern.FindAllStringSubmatchIndex(text, -1) {
+		id, err := strconv.ParseInt(text[m[2]:m[3]], 10, 64)
+		alt, ok := alts[id]
+		if err != nil || !ok {
+			continue
+		}
+		b.WriteString(text[last:m[0]])
+		entities = append(entities, &tg.MessageEntityCustomEmoji{
+			Offset:     utf16Len(b.String()),
+			Length:     utf16Len(alt),
+			DocumentID: id,
+		})
+		b.WriteString(alt)
+		last = m[1]
+	}
+	b.WriteString(text[last:])
+	return b.String(), entities
+}
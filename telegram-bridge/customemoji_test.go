@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+)
+
+func TestBuildCustomEmojiOffsets(t *testing.T) {
+	alts := map[int64]string{5368324170671202286: "👍", 42: "🔥"}
+	// "é" is one UTF-16 unit, "😀" two; the offsets must count units
+	text, entities := buildCustomEmoji("é😀 [emoji:5368324170671202286] ok [emoji:42][emoji:7] end", alts)
+
+	if want := "é😀 👍 ok 🔥[emoji:7] end"; text != want {
+		t.Errorf("text = %q, want %q", text, want)
+	}
+	want := []tg.MessageEntityCustomEmoji{
+		{Offset: 4, Length: 2, DocumentID: 5368324170671202286},
+		{Offset: 10, Length: 2, DocumentID: 42},
+	}
+	if len(entities) != len(want) {
+		t.Fatalf("entities = %+v, want %+v", entities, want)
+	}
+	for i, e := range entities {
+		if e, ok := e.(*tg.MessageEntityCustomEmoji); !ok || *e != want[i] {
+			t.Errorf("entity %d = %+v, want %+v", i, e, want[i])
+		}
+	}
+}
+
+func customEmojiClient(premium bool) (*fakeInvoker, *tg.Client, *peerResolver) {
+	inv, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		if req, ok := input.(*tg.MessagesGetCustomEmojiDocumentsRequest); ok {
+			var docs []tg.DocumentClass
+			for _, id := range req.DocumentID {
+				if id == 42 {
+					docs = append(docs, &tg.Document{
+						ID:         42,
+						Thumbs:     []tg.PhotoSizeClass{},
+						Attributes: []tg.DocumentAttributeClass{&tg.DocumentAttributeCustomEmoji{Alt: "🔥", Stickerset: &tg.InputStickerSetEmpty{}}},
+					})
+				}
+			}
+			return &tg.DocumentClassVector{Elems: docs}, nil
+		}
+		return nil, nil
+	})
+	peers.self.set(&tg.User{ID: 1, Self: true, Premium: premium})
+	return inv, api, peers
+}
+
+func TestExpandCustomEmoji(t *testing.T) {
+	inv, api, peers := customEmojiClient(true)
+	text, entities, err := expandCustomEmoji(context.Background(), api, peers, "hot [emoji:42] [emoji:42]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "hot 🔥 🔥" || len(entities) != 2 {
+		t.Errorf("text = %q, entities = %+v", text, entities)
+	}
+	reqs := requests[*tg.MessagesGetCustomEmojiDocumentsRequest](inv)
+	if len(reqs) != 1 || len(reqs[0].DocumentID) != 1 || reqs[0].DocumentID[0] != 42 {
+		t.Errorf("requests = %+v, want one lookup of 42", reqs)
+	}
+
+	// Text without markup needs no lookup
+	if text, entities, err := expandCustomEmoji(context.Background(), api, peers, "plain [emoji:x]"); err != nil || text != "plain [emoji:x]" || entities != nil {
+		t.Errorf("plain text = %q, %v, %v", text, entities, err)
+	}
+}
+
+func TestExpandCustomEmojiInvalid(t *testing.T) {
+	_, api, peers := customEmojiClient(true)
+	for _, text := range []string{"[emoji:7]", "[emoji:99999999999999999999]"} {
+		_, _, err := expandCustomEmoji(context.Background(), api, peers, text)
+		var rpcErr *rpcError
+		if !errors.As(err, &rpcErr) || rpcErr.Code != rpcInvalidParams {
+			t.Errorf("%s: err = %v, want invalid params", text, err)
+		}
+	}
+}
+
+func TestExpandCustomEmojiNotPremium(t *testing.T) {
+	inv, api, peers := customEmojiClient(false)
+	if _, _, err := expandCustomEmoji(context.Background(), api, peers, "[emoji:42]"); !errors.Is(err, errCustomEmojiPremium) {
+		t.Errorf("err = %v, want %v", err, errCustomEmojiPremium)
+	}
+	if n := len(requests[*tg.MessagesGetCustomEmojiDocumentsRequest](inv)); n != 0 {
+		t.Errorf("looked up emoji %d times without Premium", n)
+	}
+}
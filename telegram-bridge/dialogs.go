@@ -0,0 +1,592 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gotd/td/telegram/message/peer"
+	"github.com/gotd/td/telegram/query"
+	"github.com/gotd/td/tg"
+)
+
+// Bounds for the number of dialogs list_dialogs returns
+const (
+	defaultDialogLimit = 50
+	maxDialogLimit     = 500
+)
+
+type listDialogsArgs struct {
+	Limit int `json:"limit"`
+}
+
+// dialogInfo is the JSON shape of a dialog returned by list_dialogs
+type dialogInfo struct {
+	PeerID      int64  `json:"peer_id"`
+	Type        string `json:"type"`
+	Title       string `json:"title"`
+	Username    string `json:"username,omitempty"`
+	AccessHash  int64  `json:"access_hash,omitempty"`
+	UnreadCount int    `json:"unread_count"`
+}
+
+func listDialogsTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args listDialogsArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if args.Limit < 0 {
+			return nil, invalidParams("limit must not be negative")
+		}
+		return listDialogs(ctx, api, peers, clampLimit(args.Limit, defaultDialogLimit, maxDialogLimit))
+	}
+}
+
+// listDialogs pages through messages.getDialogs, caching each peer's access
+// hash so the emitted IDs work with the other tools
+func listDialogs(ctx context.Context, api *tg.Client, peers *peerResolver, limit int) ([]dialogInfo, error) {
+	result := make([]dialogInfo, 0, limit)
+	iter := query.GetDialogs(api).BatchSize(100).Iter()
+	for len(result) < limit && iter.Next(ctx) {
+		elem := iter.Value()
+		peers.rememberEntities(elem.Entities.Users(), elem.Entities.Channels())
+
+		dialog, ok := elem.Dialog.(*tg.Dialog)
+		if !ok {
+			// Archived folder entries carry no peer of their own
+			continue
+		}
+		info, ok := newDialogInfo(dialog, elem.Entities)
+		if !ok {
+			continue
+		}
+		result = append(result, info)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list dialogs: %w", err)
+	}
+	return result, nil
+}
+
+func newDialogInfo(d *tg.Dialog, entities peer.Entities) (dialogInfo, bool) {
+	info := dialogInfo{UnreadCount: d.UnreadCount}
+	switch p := d.Peer.(type) {
+	case *tg.PeerUser:
+		u, ok := entities.User(p.UserID)
+		if !ok {
+			return info, false
+		}
+		info.Type = peerUser
+		info.Title = strings.TrimSpace(u.FirstName + " " + u.LastName)
+		info.Username = u.Username
+		info.AccessHash = u.AccessHash
+		info.PeerID = cachedPeer{Type: peerUser, ID: u.ID}.MarkedID()
+	case *tg.PeerChat:
+		c, ok := entities.Chat(p.ChatID)
+		if !ok {
+			return info, false
+		}
+		info.Type = peerChat
+		info.Title = c.Title
+		info.PeerID = cachedPeer{Type: peerChat, ID: c.ID}.MarkedID()
+	case *tg.PeerChannel:
+		c, ok := entities.Channel(p.ChannelID)
+		if !ok {
+			return info, false
+		}
+		info.Type = peerChannel
+		info.Title = c.Title
+		info.Username = c.Username
+		info.AccessHash = c.AccessHash
+		info.PeerID = cachedPeer{Type: peerChannel, ID: c.ID}.MarkedID()
+	default:
+		return info, false
+	}
+	return info, true
+}
+
+// DialogState is the read and unread state of a single dialog
+type DialogState struct {
+	PeerID               int64 `json:"peer_id"`
+	UnreadCount          int   `json:"unread_count"`
+	UnreadMentionsCount  int   `json:"unread_mentions_count"`
+	UnreadReactionsCount int   `json:"unread_reactions_count"`
+	ReadInboxMaxID       int   `json:"read_inbox_max_id"`
+	ReadOutboxMaxID      int   `json:"read_outbox_max_id"`
+	TopMessageID         int   `json:"top_message_id"`
+	Pinned               bool  `json:"pinned"`
+	// MarkedUnread is set when the chat was manually marked as unread
+	MarkedUnread bool `json:"marked_unread"`
+}
+
+func getDialogStateTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args peerArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.Peer) == "" {
+			return nil, invalidParams("peer is required")
+		}
+		return getDialogState(ctx, api, peers, args.Peer)
+	}
+}
+
+// getDialogState fetches the dialog with peer and reports its sync state
+func getDialogState(ctx context.Context, api *tg.Client, peers *peerResolver, peer string) (DialogState, error) {
+	input, err := peers.Resolve(ctx, peer)
+	if err != nil {
+		return DialogState{}, err
+	}
+	var res *tg.MessagesPeerDialogs
+	err = withFloodRetry(ctx, func() (err error) {
+		res, err = api.MessagesGetPeerDialogs(ctx, []tg.InputDialogPeerClass{&tg.InputDialogPeer{Peer: input}})
+		return err
+	})
+	if err != nil {
+		return DialogState{}, fmt.Errorf("failed to get dialog: %w", err)
+	}
+	peers.remember(res.Users, res.Chats)
+
+	for _, d := range res.Dialogs {
+		if d, ok := d.(*tg.Dialog); ok {
+			return newDialogState(d)
+		}
+	}
+	return DialogState{}, fmt.Errorf("no dialog with %s", peer)
+}
+
+func newDialogState(d *tg.Dialog) (DialogState, error) {
+	peerID, err := markedPeerID(d.Peer)
+	if err != nil {
+		return DialogState{}, err
+	}
+	return DialogState{
+		PeerID:               peerID,
+		UnreadCount:          d.UnreadCount,
+		UnreadMentionsCount:  d.UnreadMentionsCount,
+		UnreadReactionsCount: d.UnreadReactionsCount,
+		ReadInboxMaxID:       d.ReadInboxMaxID,
+		ReadOutboxMaxID:      d.ReadOutboxMaxID,
+		TopMessageID:         d.TopMessage,
+		Pinned:               d.Pinned,
+		MarkedUnread:         d.UnreadMark,
+	}, nil
+}
+
+type markReadArgs struct {
+	Peer  string `json:"peer"`
+	MaxID int    `json:"max_id"`
+}
+
+type markReadResult struct {
+	UnreadCount int `json:"unread_count"`
+}
+
+func markReadTool(api *tg.Client, peers *peerResolver, stealth bool) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args markReadArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.Peer) == "" {
+			return nil, invalidParams("peer is required")
+		}
+		if args.MaxID < 0 {
+			return nil, invalidParams("max_id must not be negative")
+		}
+		if stealth {
+			return nil, errStealthMode
+		}
+
+		unread, err := markRead(ctx, api, peers, args.Peer, args.MaxID)
+		if err != nil {
+			return nil, err
+		}
+		return markReadResult{UnreadCount: unread}, nil
+	}
+}
+
+// markRead marks the messages of peer up to maxID as read, or the whole
+// dialog when maxID is 0, and returns the remaining unread count. Channels
+// and supergroups keep their own read state and need channels.readHistory.
+func markRead(ctx context.Context, api *tg.Client, peers *peerResolver, peer string, maxID int) (int, error) {
+	p, err := peers.resolve(ctx, peer)
+	if err != nil {
+		return 0, err
+	}
+	if err := readHistory(ctx, api, p, maxID); err != nil {
+		return 0, err
+	}
+
+	state, err := getDialogState(ctx, api, peers, peer)
+	if err != nil {
+		return 0, err
+	}
+	return state.UnreadCount, nil
+}
+
+// readHistory sends the read receipt for the messages of p up to maxID
+func readHistory(ctx context.Context, api *tg.Client, p cachedPeer, maxID int) error {
+	err := withFloodRetry(ctx, func() error {
+		if p.Type == peerChannel {
+			_, err := api.ChannelsReadHistory(ctx, &tg.ChannelsReadHistoryRequest{
+				Channel: &tg.InputChannel{ChannelID: p.ID, AccessHash: p.AccessHash},
+				MaxID:   maxID,
+			})
+			return err
+		}
+		_, err := api.MessagesReadHistory(ctx, &tg.MessagesReadHistoryRequest{
+			Peer:  p.InputPeer(),
+			MaxID: maxID,
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark messages as read: %w", err)
+	}
+	return nil
+}
+
+// maxMarkAllReadPeers caps how many chats one mark_all_read call names
+const maxMarkAllReadPeers = 100
+
+type markAllReadArgs struct {
+	Peers []string `json:"peers"`
+	// FolderID adds the unread chats of a folder: 0 is the main list and 1
+	// the archive
+	FolderID *int `json:"folder_id"`
+}
+
+type markAllReadResult struct {
+	Read []string `json:"read"`
+	// Failed maps the peers that could not be marked to the reason
+	Failed map[string]string `json:"failed,omitempty"`
+}
+
+func markAllReadTool(api *tg.Client, peers *peerResolver, stealth bool) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args markAllReadArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if len(args.Peers) == 0 && args.FolderID == nil {
+			return nil, invalidParams("peers or folder_id is required")
+		}
+		if len(args.Peers) > maxMarkAllReadPeers {
+			return nil, invalidParams("at most 100 peers can be given")
+		}
+		if args.FolderID != nil && *args.FolderID != 0 && *args.FolderID != 1 {
+			return nil, invalidParams("folder_id must be 0 (main list) or 1 (archive)")
+		}
+		if stealth {
+			return nil, errStealthMode
+		}
+
+		targets := args.Peers
+		if args.FolderID != nil {
+			unread, err := unreadFolderPeers(ctx, api, peers, *args.FolderID)
+			if err != nil {
+				return nil, err
+			}
+			targets = append(targets, unread...)
+		}
+		failed, err := markAllRead(ctx, api, peers, targets, stealth)
+		if err != nil {
+			return nil, err
+		}
+		result := markAllReadResult{Read: []string{}}
+		seen := make(map[string]bool, len(targets))
+		for _, target := range targets {
+			if seen[target] {
+				continue
+			}
+			seen[target] = true
+			if err, ok := failed[target]; ok {
+				if result.Failed == nil {
+					result.Failed = make(map[string]string)
+				}
+				result.Failed[target] = err.Error()
+				continue
+			}
+			result.Read = append(result.Read, target)
+		}
+		return result, nil
+	}
+}
+
+// markAllRead marks each of targets read up to its latest message. A peer
+// that fails is reported in the map and the others are still read; the
+// error is only set when ctx ends or stealth mode forbids read receipts.
+func markAllRead(ctx context.Context, api *tg.Client, peers *peerResolver, targets []string, stealth bool) (map[string]error, error) {
+	if stealth {
+		return nil, errStealthMode
+	}
+	failed := make(map[string]error)
+	done := make(map[string]bool, len(targets))
+	for _, target := range targets {
+		if done[target] {
+			continue
+		}
+		done[target] = true
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		p, err := peers.resolve(ctx, target)
+		if err == nil {
+			err = readHistory(ctx, api, p, 0)
+		}
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+			failed[target] = err
+		}
+	}
+	return failed, nil
+}
+
+// unreadFolderPeers returns the marked IDs of the chats in a folder with
+// unread messages
+func unreadFolderPeers(ctx context.Context, api *tg.Client, peers *peerResolver, folderID int) ([]string, error) {
+	var result []string
+	iter := query.GetDialogs(api).FolderID(folderID).BatchSize(100).Iter()
+	for iter.Next(ctx) {
+		elem := iter.Value()
+		peers.rememberEntities(elem.Entities.Users(), elem.Entities.Channels())
+
+		dialog, ok := elem.Dialog.(*tg.Dialog)
+		if !ok || dialog.UnreadCount == 0 {
+			continue
+		}
+		info, ok := newDialogInfo(dialog, elem.Entities)
+		if !ok {
+			continue
+		}
+		result = append(result, strconv.FormatInt(info.PeerID, 10))
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list dialogs: %w", err)
+	}
+	return result, nil
+}
+
+// errStealthMode is returned by the tools that would send read receipts
+// while [bridge] stealth_mode is set
+var errStealthMode = errors.New("stealth mode is on, chats are not marked as read; set stealth_mode = false under [bridge] to allow it")
+
+// Pinned chat limits Telegram applies when the app config does not say
+const (
+	defaultPinnedDialogLimit = 5
+	premiumPinnedDialogLimit = 10
+)
+
+type pinDialogArgs struct {
+	Peer   string `json:"peer"`
+	Pinned *bool  `json:"pinned"`
+}
+
+type pinDialogResult struct {
+	Pinned bool `json:"pinned"`
+}
+
+type reorderPinnedArgs struct {
+	Peers []string `json:"peers"`
+}
+
+type reorderPinnedResult struct {
+	// Pinned lists the pinned chats in their new order
+	Pinned []int64 `json:"pinned"`
+}
+
+func toggleDialogPinTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args pinDialogArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.Peer) == "" {
+			return nil, invalidParams("peer is required")
+		}
+		if args.Pinned == nil {
+			return nil, invalidParams("pinned is required")
+		}
+		if err := toggleDialogPin(ctx, api, peers, args.Peer, *args.Pinned); err != nil {
+			return nil, err
+		}
+		return pinDialogResult{Pinned: *args.Pinned}, nil
+	}
+}
+
+func reorderPinnedDialogsTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args reorderPinnedArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if len(args.Peers) == 0 {
+			return nil, invalidParams("peers is required")
+		}
+		pinned, err := reorderPinnedDialogs(ctx, api, peers, args.Peers)
+		if err != nil {
+			return nil, err
+		}
+		return reorderPinnedResult{Pinned: pinned}, nil
+	}
+}
+
+// toggleDialogPin pins or unpins a chat in the main chat list. Pinning fails
+// up front once the account's pinned chat limit is reached.
+func toggleDialogPin(ctx context.Context, api *tg.Client, peers *peerResolver, peer string, pinned bool) error {
+	p, err := peers.resolve(ctx, peer)
+	if err != nil {
+		return err
+	}
+	if pinned {
+		current, err := pinnedDialogs(ctx, api, peers)
+		if err != nil {
+			return err
+		}
+		limit, err := pinnedDialogLimit(ctx, api, peers)
+		if err != nil {
+			return err
+		}
+		if !containsID(current, p.MarkedID()) && len(current) >= limit {
+			return fmt.Errorf("already %d pinned chats, the limit of this account; unpin one first", len(current))
+		}
+	}
+
+	err = withFloodRetry(ctx, func() error {
+		_, err := api.MessagesToggleDialogPin(ctx, &tg.MessagesToggleDialogPinRequest{
+			Pinned: pinned,
+			Peer:   &tg.InputDialogPeer{Peer: p.InputPeer()},
+		})
+		return err
+	})
+	if tg.IsPinnedDialogsTooMuch(err) {
+		return fmt.Errorf("too many pinned chats; unpin one first")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to pin %s: %w", peer, err)
+	}
+	return nil
+}
+
+// reorderPinnedDialogs makes order the pinned chats of the main chat list,
+// top first. Chats left out are unpinned, chats not yet pinned are pinned.
+func reorderPinnedDialogs(ctx context.Context, api *tg.Client, peers *peerResolver, order []string) ([]int64, error) {
+	limit, err := pinnedDialogLimit(ctx, api, peers)
+	if err != nil {
+		return nil, err
+	}
+	if len(order) > limit {
+		return nil, fmt.Errorf("%d chats given but this account can pin at most %d", len(order), limit)
+	}
+
+	var (
+		inputs = make([]tg.InputDialogPeerClass, 0, len(order))
+		ids    = make([]int64, 0, len(order))
+	)
+	for _, peer := range order {
+		p, err := peers.resolve(ctx, peer)
+		if err != nil {
+			return nil, err
+		}
+		if containsID(ids, p.MarkedID()) {
+			return nil, fmt.Errorf("%s is listed twice", peer)
+		}
+		inputs = append(inputs, &tg.InputDialogPeer{Peer: p.InputPeer()})
+		ids = append(ids, p.MarkedID())
+	}
+
+	err = withFloodRetry(ctx, func() error {
+		_, err := api.MessagesReorderPinnedDialogs(ctx, &tg.MessagesReorderPinnedDialogsRequest{
+			Force: true,
+			Order: inputs,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to reorder pinned chats: %w", err)
+	}
+	return ids, nil
+}
+
+// pinnedDialogs returns the marked IDs of the pinned chats of the main list
+func pinnedDialogs(ctx context.Context, api *tg.Client, peers *peerResolver) ([]int64, error) {
+	var res *tg.MessagesPeerDialogs
+	err := withFloodRetry(ctx, func() (err error) {
+		res, err = api.MessagesGetPinnedDialogs(ctx, 0)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pinned chats: %w", err)
+	}
+	peers.remember(res.Users, res.Chats)
+
+	var ids []int64
+	for _, d := range res.Dialogs {
+		if d, ok := d.(*tg.Dialog); ok {
+			if id, err := markedPeerID(d.Peer); err == nil {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// pinnedDialogLimit returns how many chats this account may pin, which is
+// higher with Telegram Premium
+func pinnedDialogLimit(ctx context.Context, api *tg.Client, peers *peerResolver) (int, error) {
+	self, err := peers.Self(ctx)
+	if err != nil {
+		return 0, err
+	}
+	premium := self.Premium
+
+	var cfg tg.HelpAppConfigClass
+	err = withFloodRetry(ctx, func() (err error) {
+		cfg, err = api.HelpGetAppConfig(ctx, 0)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get app config: %w", err)
+	}
+	key, limit := "dialogs_pinned_limit_default", defaultPinnedDialogLimit
+	if premium {
+		key, limit = "dialogs_pinned_limit_premium", premiumPinnedDialogLimit
+	}
+	if cfg, ok := cfg.(*tg.HelpAppConfig); ok {
+		if n, ok := appConfigInt(cfg.Config, key); ok {
+			limit = n
+		}
+	}
+	return limit, nil
+}
+
+// appConfigInt reads a number from the JSON object of help.getAppConfig
+func appConfigInt(cfg tg.JSONValueClass, key string) (int, bool) {
+	obj, ok := cfg.(*tg.JSONObject)
+	if !ok {
+		return 0, false
+	}
+	for _, v := range obj.Value {
+		if n, ok := v.Value.(*tg.JSONNumber); ok && v.Key == key {
+			return int(n.Value), true
+		}
+	}
+	return 0, false
+}
+
+func containsID(ids []int64, id int64) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+func TestNewDialogState(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialog  *tg.Dialog
+		want    DialogState
+		wantErr bool
+	}{
+		{
+			name: "channel",
+			dialog: &tg.Dialog{
+				Peer:                 &tg.PeerChannel{ChannelID: 30},
+				Pinned:               true,
+				TopMessage:           500,
+				ReadInboxMaxID:       490,
+				ReadOutboxMaxID:      480,
+				UnreadCount:          10,
+				UnreadMentionsCount:  2,
+				UnreadReactionsCount: 1,
+			},
+			want: DialogState{
+				PeerID:               -1000000000030,
+				UnreadCount:          10,
+				UnreadMentionsCount:  2,
+				UnreadReactionsCount: 1,
+				ReadInboxMaxID:       490,
+				ReadOutboxMaxID:      480,
+				TopMessageID:         500,
+				Pinned:               true,
+			},
+		},
+		{
+			name:   "marked unread user",
+			dialog: &tg.Dialog{Peer: &tg.PeerUser{UserID: 10}, UnreadMark: true, TopMessage: 7, ReadInboxMaxID: 7},
+			want:   DialogState{PeerID: 10, TopMessageID: 7, ReadInboxMaxID: 7, MarkedUnread: true},
+		},
+		{
+			name:   "group",
+			dialog: &tg.Dialog{Peer: &tg.PeerChat{ChatID: 20}},
+			want:   DialogState{PeerID: -20},
+		},
+		{name: "no peer", dialog: &tg.Dialog{}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newDialogState(tt.dialog)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newDialogState() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("newDialogState() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// pinningClient fakes an account without Premium whose app config allows
+// three pinned chats, with users 10 to 12 pinned
+func pinningClient() (*fakeInvoker, *tg.Client, *peerResolver) {
+	inv, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		switch input.(type) {
+		case *tg.UsersGetUsersRequest:
+			return &tg.UserClassVector{Elems: []tg.UserClass{&tg.User{ID: 1, Self: true}}}, nil
+		case *tg.HelpGetAppConfigRequest:
+			return &tg.HelpAppConfig{Config: &tg.JSONObject{Value: []tg.JSONObjectValue{
+				{Key: "dialogs_pinned_limit_default", Value: &tg.JSONNumber{Value: 3}},
+				{Key: "dialogs_pinned_limit_premium", Value: &tg.JSONNumber{Value: 6}},
+			}}}, nil
+		case *tg.MessagesGetPinnedDialogsRequest:
+			res := &tg.MessagesPeerDialogs{}
+			for id := int64(10); id <= 12; id++ {
+				res.Dialogs = append(res.Dialogs, &tg.Dialog{Pinned: true, Peer: &tg.PeerUser{UserID: id}})
+			}
+			return res, nil
+		case *tg.MessagesReorderPinnedDialogsRequest, *tg.MessagesToggleDialogPinRequest:
+			return &tg.BoolTrue{}, nil
+		}
+		return nil, nil
+	})
+	for id := int64(10); id <= 13; id++ {
+		peers.storeUser(&tg.User{ID: id, AccessHash: id})
+	}
+	return inv, api, peers
+}
+
+func TestReorderPinnedDialogs(t *testing.T) {
+	inv, api, peers := pinningClient()
+	ctx := context.Background()
+
+	pinned, err := reorderPinnedDialogs(ctx, api, peers, []string{"12", "-20", "10"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(pinned) != "[12 -20 10]" {
+		t.Errorf("pinned = %v", pinned)
+	}
+	req := requests[*tg.MessagesReorderPinnedDialogsRequest](inv)[0]
+	if !req.Force || len(req.Order) != 3 {
+		t.Fatalf("request = %+v, want a forced order of 3", req)
+	}
+	if p := req.Order[0].(*tg.InputDialogPeer).Peer.(*tg.InputPeerUser); p.UserID != 12 || p.AccessHash != 12 {
+		t.Errorf("first pinned = %+v, want user 12", p)
+	}
+	if _, ok := req.Order[1].(*tg.InputDialogPeer).Peer.(*tg.InputPeerChat); !ok {
+		t.Errorf("second pinned = %#v, want basic group 20", req.Order[1])
+	}
+
+	if _, err := reorderPinnedDialogs(ctx, api, peers, []string{"10", "11", "12", "13"}); err == nil {
+		t.Error("reordering beyond the limit succeeded")
+	}
+	if _, err := reorderPinnedDialogs(ctx, api, peers, []string{"10", "10"}); err == nil {
+		t.Error("reordering with a duplicate succeeded")
+	}
+	if n := len(requests[*tg.MessagesReorderPinnedDialogsRequest](inv)); n != 1 {
+		t.Errorf("sent %d reorder requests, want only the valid one", n)
+	}
+}
+
+func TestToggleDialogPin(t *testing.T) {
+	inv, api, peers := pinningClient()
+	ctx := context.Background()
+
+	if err := toggleDialogPin(ctx, api, peers, "13", true); err == nil || !strings.Contains(err.Error(), "limit") {
+		t.Errorf("pinning a fourth chat: error = %v, want the limit", err)
+	}
+	// Pinning an already pinned chat does not count against the limit
+	if err := toggleDialogPin(ctx, api, peers, "11", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := toggleDialogPin(ctx, api, peers, "10", false); err != nil {
+		t.Fatal(err)
+	}
+	toggles := requests[*tg.MessagesToggleDialogPinRequest](inv)
+	if len(toggles) != 2 || !toggles[0].Pinned || toggles[1].Pinned {
+		t.Errorf("toggle requests = %+v", toggles)
+	}
+}
+
+func TestMarkAllRead(t *testing.T) {
+	inv, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		switch r := input.(type) {
+		case *tg.MessagesReadHistoryRequest:
+			if p, ok := r.Peer.(*tg.InputPeerUser); ok && p.UserID == 11 {
+				return nil, tgerr.New(400, "PEER_ID_INVALID")
+			}
+			return &tg.MessagesAffectedMessages{}, nil
+		case *tg.ChannelsReadHistoryRequest:
+			return &tg.BoolTrue{}, nil
+		}
+		return nil, nil
+	})
+	peers.storeUser(&tg.User{ID: 10, AccessHash: 1})
+	peers.storeUser(&tg.User{ID: 11, AccessHash: 1})
+	peers.storeChannel(&tg.Channel{ID: 30, AccessHash: 3, Photo: &tg.ChatPhotoEmpty{}})
+
+	failed, err := markAllRead(context.Background(), api, peers, []string{"10", "11", "-1000000000030", "10"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) != 1 || !tgerr.Is(failed["11"], "PEER_ID_INVALID") {
+		t.Errorf("failed = %v, want only 11 with PEER_ID_INVALID", failed)
+	}
+	// The repeated peer is read once, up to its latest message
+	reads := requests[*tg.MessagesReadHistoryRequest](inv)
+	if len(reads) != 2 || reads[0].MaxID != 0 {
+		t.Errorf("messages.readHistory calls = %+v, want 2 with max_id 0", reads)
+	}
+	if n := len(requests[*tg.ChannelsReadHistoryRequest](inv)); n != 1 {
+		t.Errorf("channels.readHistory calls = %d, want 1", n)
+	}
+}
+
+func TestMarkAllReadStealthMode(t *testing.T) {
+	inv, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		return &tg.MessagesAffectedMessages{}, nil
+	})
+	peers.storeUser(&tg.User{ID: 10, AccessHash: 1})
+
+	if _, err := markAllRead(context.Background(), api, peers, []string{"10"}, true); !errors.Is(err, errStealthMode) {
+		t.Errorf("err = %v, want errStealthMode", err)
+	}
+	_, err := markAllReadTool(api, peers, true)(context.Background(), json.RawMessage(`{"peers":["10"]}`))
+	if !errors.Is(err, errStealthMode) {
+		t.Errorf("tool err = %v, want errStealthMode", err)
+	}
+	if n := len(requests[*tg.MessagesReadHistoryRequest](inv)); n != 0 {
+		t.Errorf("sent %d read receipts in stealth mode", n)
+	}
+}
+
+func TestUnreadFolderPeers(t *testing.T) {
+	inv, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		if _, ok := input.(*tg.MessagesGetDialogsRequest); ok {
+			return &tg.MessagesDialogs{
+				Dialogs: []tg.DialogClass{
+					&tg.Dialog{Peer: &tg.PeerUser{UserID: 10}, UnreadCount: 2},
+					&tg.Dialog{Peer: &tg.PeerUser{UserID: 11}},
+					&tg.Dialog{Peer: &tg.PeerChat{ChatID: 20}, UnreadCount: 1},
+				},
+				Users: []tg.UserClass{&tg.User{ID: 10, AccessHash: 1}, &tg.User{ID: 11, AccessHash: 1}},
+				Chats: []tg.ChatClass{&tg.Chat{ID: 20, Photo: &tg.ChatPhotoEmpty{}}},
+			}, nil
+		}
+		return nil, nil
+	})
+
+	got, err := unreadFolderPeers(context.Background(), api, peers, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != "[10 -20]" {
+		t.Errorf("peers = %v, want [10 -20]", got)
+	}
+	if reqs := requests[*tg.MessagesGetDialogsRequest](inv); len(reqs) == 0 || reqs[0].FolderID != 1 {
+		t.Errorf("dialogs request = %+v, want folder 1", reqs)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/gotd/td/tg"
+)
+
+// maxDigestPeers caps how many chats one digest is scheduled in
+const maxDigestPeers = 100
+
+// digestInterval spaces out the sends of a digest to stay clear of flood
+// limits; flood waits that still happen are retried by withFloodRetry
+var digestInterval = 200 * time.Millisecond
+
+type scheduleDigestArgs struct {
+	Peers []string `json:"peers"`
+	Text  string   `json:"text"`
+	At    int      `json:"at"`
+}
+
+type scheduleDigestResult struct {
+	// Scheduled maps each peer to the ID of its scheduled message
+	Scheduled map[string]int `json:"scheduled"`
+	// Failed maps the peers the digest could not be scheduled in to the
+	// reason
+	Failed map[string]string `json:"failed,omitempty"`
+}
+
+func scheduleDigestTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args scheduleDigestArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if len(args.Peers) == 0 {
+			return nil, invalidParams("peers is required")
+		}
+		if len(args.Peers) > maxDigestPeers {
+			return nil, invalidParams("at most 100 peers can be given")
+		}
+		if strings.TrimSpace(args.Text) == "" {
+			return nil, invalidParams("text is required")
+		}
+		if err := validScheduleDate(args.At, time.Now()); err != nil {
+			return nil, invalidParams(err.Error())
+		}
+		scheduled, failed, err := scheduleDigest(ctx, api, peers, args.Peers, args.Text, args.At)
+		if err != nil {
+			return nil, err
+		}
+		result := scheduleDigestResult{Scheduled: scheduled}
+		if len(failed) > 0 {
+			result.Failed = make(map[string]string, len(failed))
+			for peer, err := range failed {
+				result.Failed[peer] = err.Error()
+			}
+		}
+		return result, nil
+	}
+}
+
+// scheduleDigest schedules text as a silent message in each of targets at
+// the Unix time at. A peer that fails is reported in the second map and the
+// others are still scheduled; the error is only set when ctx ends.
+func scheduleDigest(ctx context.Context, api *tg.Client, peers *peerResolver, targets []string, text string, at int) (map[string]int, map[string]error, error) {
+	scheduled := make(map[string]int, len(targets))
+	failed := make(map[string]error)
+	for i, target := range targets {
+		if _, done := scheduled[target]; done {
+			continue
+		}
+		if _, done := failed[target]; done {
+			continue
+		}
+		if i > 0 {
+			timer := time.NewTimer(digestInterval)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return scheduled, failed, ctx.Err()
+			case <-timer.C:
+			}
+		}
+
+		peer, err := peers.Resolve(ctx, target)
+		if err == nil {
+			var id int
+			id, err = sendText(ctx, api, peer, text, sendOptions{Silent: true, ScheduleDate: at})
+			if err == nil {
+				scheduled[target] = id
+				continue
+			}
+		}
+		if ctx.Err() != nil {
+			return scheduled, failed, ctx.Err()
+		}
+		failed[target] = err
+	}
+	return scheduled, failed, nil
+}
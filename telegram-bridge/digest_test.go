@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+func TestScheduleDigest(t *testing.T) {
+	digestInterval = 0
+	defer func() { digestInterval = 200 * time.Millisecond }()
+
+	nextID := 0
+	inv, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		req, ok := input.(*tg.MessagesSendMessageRequest)
+		if !ok {
+			return nil, nil
+		}
+		if p, ok := req.Peer.(*tg.InputPeerChat); ok && p.ChatID == 21 {
+			return nil, tgerr.New(403, "CHAT_WRITE_FORBIDDEN")
+		}
+		nextID++
+		return &tg.Updates{Updates: []tg.UpdateClass{&tg.UpdateMessageID{ID: nextID, RandomID: req.RandomID}}}, nil
+	})
+	peers.storeUser(&tg.User{ID: 10, AccessHash: 1})
+	peers.storeChannel(&tg.Channel{ID: 30, AccessHash: 3})
+
+	at := int(time.Now().Add(time.Hour).Unix())
+	scheduled, failed, err := scheduleDigest(context.Background(), api, peers,
+		[]string{"10", "-20", "-21", "-1000000000030", "10"}, "Weekly digest", at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{"10": 1, "-20": 2, "-1000000000030": 3}
+	if len(scheduled) != len(want) {
+		t.Errorf("scheduled = %v, want %v", scheduled, want)
+	}
+	for peer, id := range want {
+		if scheduled[peer] != id {
+			t.Errorf("scheduled[%s] = %d, want %d", peer, scheduled[peer], id)
+		}
+	}
+	if len(failed) != 1 || !tgerr.Is(failed["-21"], "CHAT_WRITE_FORBIDDEN") {
+		t.Errorf("failed = %v, want -21", failed)
+	}
+
+	reqs := requests[*tg.MessagesSendMessageRequest](inv)
+	if len(reqs) != 4 {
+		t.Fatalf("sent %d requests, want 4 (the repeated peer is skipped)", len(reqs))
+	}
+	for _, req := range reqs {
+		date, ok := req.GetScheduleDate()
+		if !req.Silent || !ok || date != at || req.Message != "Weekly digest" {
+			t.Errorf("request = %+v, want a silent message scheduled at %d", req, at)
+		}
+	}
+}
+
+func TestValidScheduleDate(t *testing.T) {
+	now := time.Unix(1_000_000, 0)
+	tests := []struct {
+		date int
+		ok   bool
+	}{
+		{1_000_060, true},
+		{1_000_000, false},
+		{999_000, false},
+		{int(now.Add(maxScheduleAhead + time.Hour).Unix()), false},
+	}
+	for _, tt := range tests {
+		if err := validScheduleDate(tt.date, now); (err == nil) != tt.ok {
+			t.Errorf("validScheduleDate(%d) = %v, want ok %v", tt.date, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gotd/td/tg"
+	"golang.org/x/sync/errgroup"
+)
+
+// downloadLogSize is how many downloads are remembered; older ones are
+// dropped first
+const downloadLogSize = 200
+
+// Download sources
+const (
+	downloadMessage   = "message"
+	downloadStory     = "story"
+	downloadUserPhoto = "user_photo"
+)
+
+// DownloadRecord is a file a tool saved to disk this session
+type DownloadRecord struct {
+	Filename string `json:"filename"`
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	MimeType string `json:"mime_type"`
+	PeerID   int64  `json:"peer_id"`
+	// Source is what was downloaded, and ItemID the message, story or
+	// photo ID within it
+	Source string `json:"source"`
+	ItemID int64  `json:"item_id"`
+	// At is when the download completed, as a Unix time
+	At int64 `json:"at"`
+}
+
+// downloadLog keeps the most recent completed downloads. A nil log records
+// nothing.
+type downloadLog struct {
+	mu      sync.Mutex
+	entries []DownloadRecord // oldest first
+}
+
+func newDownloadLog() *downloadLog {
+	return &downloadLog{}
+}
+
+// record logs res, downloaded from source item itemID of peerID
+func (l *downloadLog) record(res downloadMediaResult, peerID int64, source string, itemID int64) {
+	if l == nil {
+		return
+	}
+	r := DownloadRecord{
+		Filename: filepath.Base(res.Path),
+		Path:     res.Path,
+		Size:     res.Size,
+		MimeType: res.MimeType,
+		PeerID:   peerID,
+		Source:   source,
+		ItemID:   itemID,
+		At:       time.Now().Unix(),
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, r)
+	if len(l.entries) > downloadLogSize {
+		l.entries = l.entries[len(l.entries)-downloadLogSize:]
+	}
+}
+
+// recent returns the logged downloads, newest first
+func (l *downloadLog) recent() []DownloadRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	result := make([]DownloadRecord, 0, len(l.entries))
+	for i := len(l.entries) - 1; i >= 0; i-- {
+		result = append(result, l.entries[i])
+	}
+	return result
+}
+
+func getRecentDownloadsTool(downloads *downloadLog) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args struct{}
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		return getRecentDownloads(ctx, downloads)
+	}
+}
+
+// getRecentDownloads returns the files downloaded since the bridge started,
+// newest first
+func getRecentDownloads(_ context.Context, downloads *downloadLog) ([]DownloadRecord, error) {
+	return downloads.recent(), nil
+}
+
+// Limits of download_many
+const (
+	maxDownloadMany = 100
+	// maxConcurrentDownloads is how many files download at once, keeping
+	// to the parallel transfers Telegram allows a client
+	maxConcurrentDownloads = 4
+)
+
+// downloadErrors are the messages of a batch download that failed, keyed by
+// message ID
+type downloadErrors map[int]error
+
+func (e downloadErrors) Error() string {
+	return fmt.Sprintf("media of %d messages could not be downloaded", len(e))
+}
+
+type downloadManyArgs struct {
+	Peer       string `json:"peer"`
+	MessageIDs []int  `json:"message_ids"`
+	DestDir    string `json:"dest_dir"`
+}
+
+// downloadManyResult maps message IDs to the saved file or the reason it
+// was not saved
+type downloadManyResult struct {
+	Paths  map[int]string `json:"paths"`
+	Errors map[int]string `json:"errors"`
+}
+
+func downloadManyTool(api *tg.Client, peers *peerResolver, downloads *downloadLog) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args downloadManyArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.Peer) == "" {
+			return nil, invalidParams("peer is required")
+		}
+		if len(args.MessageIDs) == 0 || len(args.MessageIDs) > maxDownloadMany {
+			return nil, invalidParams("message_ids must list 1 to %d messages", maxDownloadMany)
+		}
+		for _, id := range args.MessageIDs {
+			if id <= 0 {
+				return nil, invalidParams("message ID %d must be positive", id)
+			}
+		}
+		if strings.TrimSpace(args.DestDir) == "" {
+			return nil, invalidParams("dest_dir is required")
+		}
+
+		paths, err := downloadMany(ctx, api, peers, args.Peer, args.MessageIDs, args.DestDir, downloads)
+		var failed downloadErrors
+		if err != nil && !errors.As(err, &failed) {
+			return nil, err
+		}
+		result := downloadManyResult{Paths: paths, Errors: make(map[int]string, len(failed))}
+		for id, err := range failed {
+			result.Errors[id] = err.Error()
+		}
+		return result, nil
+	}
+}
+
+// downloadMany saves the photo or document of each message of peer into
+// dir as <message_id> with an extension for its type, a few at a time. It
+// returns the paths by message ID; messages that are missing, have no media
+// or fail to download are reported in a downloadErrors error alongside the
+// paths of the others.
+func downloadMany(ctx context.Context, api *tg.Client, peers *peerResolver, peer string, ids []int, dir string, downloads *downloadLog) (map[int]string, error) {
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("%s is not an existing directory", dir)
+	}
+	msgs, err := getMessages(ctx, api, peers, peer, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		mu     sync.Mutex
+		paths  = make(map[int]string)
+		failed = make(downloadErrors)
+		fail   = func(id int, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			failed[id] = err
+		}
+		queued = make(map[int]bool)
+		g      errgroup.Group
+	)
+	g.SetLimit(maxConcurrentDownloads)
+	for _, id := range ids {
+		if queued[id] {
+			continue
+		}
+		queued[id] = true
+		msg, ok := msgs[id]
+		if !ok {
+			fail(id, fmt.Errorf("message %d not found in %s", id, peer))
+			continue
+		}
+		file, err := messageMediaFile(msg)
+		if err != nil {
+			fail(id, err)
+			continue
+		}
+		dest := filepath.Join(dir, strconv.Itoa(id)+mediaFileExt(file.MimeType))
+		g.Go(func() error {
+			res, err := saveMessageMedia(ctx, api, peers, peer, msg, dest)
+			if err != nil {
+				fail(msg.ID, fmt.Errorf("failed to download media of message %d: %w", msg.ID, err))
+				return nil
+			}
+			mu.Lock()
+			paths[msg.ID] = res.Path
+			mu.Unlock()
+			peerID, _ := markedPeerID(msg.PeerID)
+			downloads.record(res, peerID, downloadMessage, int64(msg.ID))
+			return nil
+		})
+	}
+	_ = g.Wait()
+	if len(failed) > 0 {
+		return paths, failed
+	}
+	return paths, nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+func TestDownloadMediaLogged(t *testing.T) {
+	doc := &tg.Document{ID: 5, AccessHash: 6, MimeType: "application/pdf", Thumbs: []tg.PhotoSizeClass{}, Attributes: []tg.DocumentAttributeClass{}}
+	_, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		switch input.(type) {
+		case *tg.MessagesGetMessagesRequest:
+			return &tg.MessagesMessages{Messages: []tg.MessageClass{
+				&tg.Message{ID: 7, PeerID: &tg.PeerUser{UserID: 10}, Media: &tg.MessageMediaDocument{Document: doc}},
+			}}, nil
+		case *tg.UploadGetFileRequest:
+			return &tg.UploadFile{Type: &tg.StorageFilePdf{}, Bytes: []byte("%PDF-1.4")}, nil
+		}
+		return nil, nil
+	})
+	peers.storeUser(&tg.User{ID: 10, AccessHash: 1})
+	downloads := newDownloadLog()
+	dest := filepath.Join(t.TempDir(), "report.pdf")
+
+	before := time.Now().Unix()
+	if _, err := downloadMedia(context.Background(), api, peers, "10", 7, dest, downloads); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(dest); err != nil || string(data) != "%PDF-1.4" {
+		t.Fatalf("downloaded %q, %v", data, err)
+	}
+
+	got, err := getRecentDownloads(context.Background(), downloads)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("log = %+v, want one download", got)
+	}
+	r := got[0]
+	if r.Filename != "report.pdf" || r.Path != dest || r.Size != 8 || r.MimeType != "application/pdf" ||
+		r.PeerID != 10 || r.Source != downloadMessage || r.ItemID != 7 || r.At < before {
+		t.Errorf("record = %+v", r)
+	}
+}
+
+func TestDownloadLogBounded(t *testing.T) {
+	downloads := newDownloadLog()
+	for i := 0; i < downloadLogSize+3; i++ {
+		downloads.record(downloadMediaResult{Path: "/tmp/f"}, 10, downloadMessage, int64(i))
+	}
+	got := downloads.recent()
+	if len(got) != downloadLogSize || got[0].ItemID != downloadLogSize+2 || got[len(got)-1].ItemID != 3 {
+		t.Errorf("kept %d records from %d to %d", len(got), got[len(got)-1].ItemID, got[0].ItemID)
+	}
+
+	// A nil log records nothing
+	var none *downloadLog
+	none.record(downloadMediaResult{}, 10, downloadMessage, 1)
+}
+
+func TestDownloadMany(t *testing.T) {
+	var (
+		mu                  sync.Mutex
+		inFlight, maxFlight int
+		fetches             int
+	)
+	document := func(id int64, ref byte) *tg.MessageMediaDocument {
+		return &tg.MessageMediaDocument{Document: &tg.Document{
+			ID: id, AccessHash: 1, FileReference: []byte{ref}, MimeType: "image/png",
+			Thumbs: []tg.PhotoSizeClass{}, Attributes: []tg.DocumentAttributeClass{},
+		}}
+	}
+	inv, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		switch r := input.(type) {
+		case *tg.MessagesGetMessagesRequest:
+			mu.Lock()
+			fetches++
+			// Message 6 has a fresh file reference once fetched again
+			ref := byte(1)
+			if fetches > 1 {
+				ref = 2
+			}
+			mu.Unlock()
+			var msgs []tg.MessageClass
+			for _, m := range r.ID {
+				id := m.(*tg.InputMessageID).ID
+				switch {
+				case id <= 6:
+					msgs = append(msgs, &tg.Message{ID: id, PeerID: &tg.PeerUser{UserID: 10}, Media: document(int64(id), ref)})
+				case id == 7:
+					msgs = append(msgs, &tg.Message{ID: id, PeerID: &tg.PeerUser{UserID: 10}, Message: "no media"})
+				}
+			}
+			return &tg.MessagesMessages{Messages: msgs}, nil
+		case *tg.UploadGetFileRequest:
+			loc := r.Location.(*tg.InputDocumentFileLocation)
+			if loc.ID == 6 && loc.FileReference[0] == 1 {
+				return nil, tgerr.New(400, "FILE_REFERENCE_EXPIRED")
+			}
+			mu.Lock()
+			inFlight++
+			maxFlight = max(maxFlight, inFlight)
+			mu.Unlock()
+			time.Sleep(20 * time.Millisecond)
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+			return &tg.UploadFile{Type: &tg.StorageFilePng{}, Bytes: []byte(fmt.Sprintf("data-%d", loc.ID))}, nil
+		}
+		return nil, nil
+	})
+	peers.storeUser(&tg.User{ID: 10, AccessHash: 1})
+	dir := t.TempDir()
+	downloads := newDownloadLog()
+
+	paths, err := downloadMany(context.Background(), api, peers, "10", []int{1, 2, 3, 4, 5, 6, 7, 8, 1}, dir, downloads)
+	var failed downloadErrors
+	if !errors.As(err, &failed) {
+		t.Fatalf("err = %v, want per-message errors", err)
+	}
+	if len(failed) != 2 || failed[7] == nil || failed[8] == nil {
+		t.Errorf("failed = %v, want messages 7 (no media) and 8 (missing)", failed)
+	}
+	if len(paths) != 6 {
+		t.Fatalf("paths = %v, want 6", paths)
+	}
+	for id := 1; id <= 6; id++ {
+		data, err := os.ReadFile(paths[id])
+		if err != nil || string(data) != fmt.Sprintf("data-%d", id) || paths[id] != filepath.Join(dir, fmt.Sprintf("%d.png", id)) {
+			t.Errorf("message %d: %s = %q, %v", id, paths[id], data, err)
+		}
+	}
+	if maxFlight < 2 || maxFlight > maxConcurrentDownloads {
+		t.Errorf("%d downloads ran at once, want 2 to %d", maxFlight, maxConcurrentDownloads)
+	}
+	if n := len(requests[*tg.MessagesGetMessagesRequest](inv)); n != 2 {
+		t.Errorf("fetched messages %d times, want once plus one refresh", n)
+	}
+	if n := len(downloads.recent()); n != 6 {
+		t.Errorf("logged %d downloads, want 6", n)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+)
+
+// fakeInvoker is a tg.Invoker answering requests with handle and recording
+// them, so tools can be tested against a tg.Client without a connection
+type fakeInvoker struct {
+	handle func(input bin.Encoder) (bin.Encoder, error)
+
+	mu    sync.Mutex
+	calls []bin.Encoder
+}
+
+func (f *fakeInvoker) Invoke(_ context.Context, input bin.Encoder, output bin.Decoder) error {
+	f.mu.Lock()
+	f.calls = append(f.calls, input)
+	f.mu.Unlock()
+
+	res, err := f.handle(input)
+	if err != nil {
+		return err
+	}
+	if res == nil {
+		return fmt.Errorf("unexpected request %T", input)
+	}
+	var buf bin.Buffer
+	if err := res.Encode(&buf); err != nil {
+		return err
+	}
+	return output.Decode(&buf)
+}
+
+// requests returns the recorded requests of type T
+func requests[T bin.Encoder](f *fakeInvoker) []T {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var found []T
+	for _, c := range f.calls {
+		if r, ok := c.(T); ok {
+			found = append(found, r)
+		}
+	}
+	return found
+}
+
+// newFakeClient returns an API client and peer resolver backed by handle
+func newFakeClient(handle func(input bin.Encoder) (bin.Encoder, error)) (*fakeInvoker, *tg.Client, *peerResolver) {
+	inv := &fakeInvoker{handle: handle}
+	api := tg.NewClient(inv)
+	return inv, api, newPeerResolver(api, 1)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+// fileHandlePrefix starts every handle upload_once returns
+const fileHandlePrefix = "doc:"
+
+// FileHandle is a file uploaded once to Telegram, sendable to any chat by
+// its document without uploading it again
+type FileHandle struct {
+	// Handle is the string upload_file takes; it carries the document ID,
+	// access hash and file reference
+	Handle   string `json:"handle"`
+	Name     string `json:"name"`
+	MimeType string `json:"mime_type"`
+	Size     int64  `json:"size"`
+
+	document tg.InputDocument
+}
+
+type uploadOnceArgs struct {
+	Path string `json:"path"`
+}
+
+func uploadOnceTool(api *tg.Client) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args uploadOnceArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.Path) == "" {
+			return nil, invalidParams("path is required")
+		}
+		return uploadOnce(ctx, api, args.Path)
+	}
+}
+
+// uploadOnce uploads the file at path as a document without sending it
+// anywhere and returns a handle to send it with. messages.uploadMedia turns
+// the short-lived upload into a stored document.
+func uploadOnce(ctx context.Context, api *tg.Client, path string) (FileHandle, error) {
+	file, err := uploadLocalFile(ctx, api, path)
+	if err != nil {
+		return FileHandle{}, err
+	}
+	name := filepath.Base(path)
+	var media tg.MessageMediaClass
+	err = withFloodRetry(ctx, func() (err error) {
+		media, err = api.MessagesUploadMedia(ctx, &tg.MessagesUploadMediaRequest{
+			Peer:  &tg.InputPeerSelf{},
+			Media: uploadedDocument(file, name),
+		})
+		return err
+	})
+	if err != nil {
+		return FileHandle{}, fmt.Errorf("failed to store %s: %w", name, err)
+	}
+	doc, ok := documentOf(media)
+	if !ok {
+		return FileHandle{}, fmt.Errorf("storing %s returned no document", name)
+	}
+	handle := newFileHandle(doc.AsInput())
+	handle.Name = name
+	handle.MimeType = doc.MimeType
+	handle.Size = doc.Size
+	return handle, nil
+}
+
+func newFileHandle(doc *tg.InputDocument) FileHandle {
+	return FileHandle{
+		Handle: fileHandlePrefix + strconv.FormatInt(doc.ID, 10) + ":" + strconv.FormatInt(doc.AccessHash, 10) + ":" +
+			base64.RawURLEncoding.EncodeToString(doc.FileReference),
+		document: *doc,
+	}
+}
+
+// parseFileHandle reads a handle returned by upload_once
+func parseFileHandle(s string) (FileHandle, error) {
+	parts := strings.Split(strings.TrimPrefix(strings.TrimSpace(s), fileHandlePrefix), ":")
+	if !strings.HasPrefix(strings.TrimSpace(s), fileHandlePrefix) || len(parts) != 3 {
+		return FileHandle{}, errors.New("handle is not one returned by upload_once")
+	}
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return FileHandle{}, fmt.Errorf("handle has an invalid document ID")
+	}
+	hash, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return FileHandle{}, fmt.Errorf("handle has an invalid access hash")
+	}
+	ref, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return FileHandle{}, fmt.Errorf("handle has an invalid file reference")
+	}
+	return newFileHandle(&tg.InputDocument{ID: id, AccessHash: hash, FileReference: ref}), nil
+}
+
+// sendFileHandle sends the document of handle to peer
+func sendFileHandle(ctx context.Context, api *tg.Client, peers *peerResolver, peer string, handle FileHandle, caption string) (int, error) {
+	input, err := peers.Resolve(ctx, peer)
+	if err != nil {
+		return 0, err
+	}
+	doc := handle.document
+	id, err := sendMedia(ctx, api, input, &tg.InputMediaDocument{ID: &doc}, caption, "file")
+	if tgerr.Is(err, "FILE_REFERENCE_EXPIRED") {
+		return 0, fmt.Errorf("the file handle expired; upload the file again with upload_once")
+	}
+	return id, err
+}
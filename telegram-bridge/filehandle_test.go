@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+func TestUploadOnceReusedAcrossSends(t *testing.T) {
+	inv, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		switch r := input.(type) {
+		case *tg.UploadSaveFilePartRequest:
+			return &tg.BoolTrue{}, nil
+		case *tg.MessagesUploadMediaRequest:
+			return &tg.MessageMediaDocument{Document: &tg.Document{
+				ID: 500, AccessHash: 5, FileReference: []byte{1, 2, 3}, MimeType: "text/plain", Size: 5,
+				Thumbs: []tg.PhotoSizeClass{}, Attributes: []tg.DocumentAttributeClass{},
+			}}, nil
+		case *tg.MessagesSendMediaRequest:
+			return &tg.UpdateShortSentMessage{ID: int(r.RandomID%1000 + 1000)}, nil
+		}
+		return nil, nil
+	})
+	peers.storeUser(&tg.User{ID: 10, AccessHash: 1})
+	peers.storeUser(&tg.User{ID: 11, AccessHash: 1})
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(path, []byte("hello"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	handle, err := uploadOnce(context.Background(), api, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if handle.Name != "notes.txt" || handle.MimeType != "text/plain" || handle.Size != 5 {
+		t.Errorf("handle = %+v", handle)
+	}
+	upload := requests[*tg.MessagesUploadMediaRequest](inv)
+	if len(upload) != 1 {
+		t.Fatalf("uploadMedia calls = %d, want 1", len(upload))
+	}
+	if _, ok := upload[0].Peer.(*tg.InputPeerSelf); !ok {
+		t.Errorf("uploadMedia peer = %T, want self", upload[0].Peer)
+	}
+
+	send := uploadFileTool(api, peers)
+	for _, peer := range []string{"10", "11"} {
+		args, _ := json.Marshal(uploadFileArgs{Peer: peer, Handle: handle.Handle})
+		if _, err := send(context.Background(), args); err != nil {
+			t.Fatalf("send to %s: %v", peer, err)
+		}
+	}
+
+	parts := len(requests[*tg.UploadSaveFilePartRequest](inv))
+	if parts != 1 {
+		t.Errorf("uploaded %d file parts, want 1 for both sends", parts)
+	}
+	sends := requests[*tg.MessagesSendMediaRequest](inv)
+	if len(sends) != 2 {
+		t.Fatalf("sendMedia calls = %d, want 2", len(sends))
+	}
+	for _, s := range sends {
+		media, ok := s.Media.(*tg.InputMediaDocument)
+		if !ok {
+			t.Fatalf("media = %T, want the stored document", s.Media)
+		}
+		doc, _ := media.ID.(*tg.InputDocument)
+		if doc == nil || doc.ID != 500 || doc.AccessHash != 5 || !bytes.Equal(doc.FileReference, []byte{1, 2, 3}) {
+			t.Errorf("document = %+v", media.ID)
+		}
+	}
+}
+
+func TestParseFileHandle(t *testing.T) {
+	want := newFileHandle(&tg.InputDocument{ID: 500, AccessHash: -5, FileReference: []byte{0xff, 0}})
+	got, err := parseFileHandle(want.Handle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Handle != want.Handle || got.document.ID != 500 || got.document.AccessHash != -5 || !bytes.Equal(got.document.FileReference, []byte{0xff, 0}) {
+		t.Errorf("parsed %+v, want %+v", got, want)
+	}
+	for _, bad := range []string{"", "500:5:AQ", "doc:500:5", "doc:x:5:AQ", "doc:500:5:!!"} {
+		if _, err := parseFileHandle(bad); err == nil {
+			t.Errorf("parseFileHandle(%q): want error", bad)
+		}
+	}
+}
+
+func TestSendFileHandleExpired(t *testing.T) {
+	_, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		return nil, tgerr.New(400, "FILE_REFERENCE_EXPIRED")
+	})
+	peers.storeUser(&tg.User{ID: 10, AccessHash: 1})
+	handle := newFileHandle(&tg.InputDocument{ID: 500, AccessHash: 5})
+	if _, err := sendFileHandle(context.Background(), api, peers, "10", handle, ""); err == nil || !strings.Contains(err.Error(), "upload_once") {
+		t.Errorf("err = %v, want a hint to upload again", err)
+	}
+}
+
+func TestUploadFileNeedsPathOrHandle(t *testing.T) {
+	_, api, peers := newFakeClient(nil)
+	for _, args := range []string{`{"peer":"10"}`, `{"peer":"10","path":"a","handle":"doc:1:1:"}`} {
+		if _, err := uploadFileTool(api, peers)(context.Background(), json.RawMessage(args)); err == nil {
+			t.Errorf("%s: want error", args)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gotd/td/tg"
+	"gopkg.in/ini.v1"
+)
+
+// Chat types a stream filter can select
+const (
+	chatTypePrivate = "private"
+	chatTypeGroup   = "group"
+	chatTypeChannel = "channel"
+)
+
+// messageFilter selects which incoming messages the update stream forwards
+// as notifications
+type messageFilter struct {
+	// ChatTypes are the chat types forwarded; nil forwards all
+	ChatTypes map[string]bool
+	// Peers is an allowlist of marked chat IDs; nil forwards every chat
+	Peers map[int64]bool
+	// MentionsOnly forwards only messages that mention or reply to you
+	MentionsOnly bool
+}
+
+// allows reports whether msg passes every configured filter
+func (f messageFilter) allows(msg *tg.Message) bool {
+	if f.ChatTypes != nil && !f.ChatTypes[messageChatType(msg)] {
+		return false
+	}
+	if f.Peers != nil {
+		peerID, err := markedPeerID(msg.PeerID)
+		if err != nil || !f.Peers[peerID] {
+			return false
+		}
+	}
+	return !f.MentionsOnly || msg.Mentioned
+}
+
+// messageChatType returns the type of chat msg was sent in. Posts are only
+// made in broadcast channels, so any other channel message is in a
+// supergroup.
+func messageChatType(msg *tg.Message) string {
+	switch msg.PeerID.(type) {
+	case *tg.PeerUser:
+		return chatTypePrivate
+	case *tg.PeerChannel:
+		if msg.Post {
+			return chatTypeChannel
+		}
+	}
+	return chatTypeGroup
+}
+
+// loadMessageFilter reads the stream filter keys of [bridge]:
+// stream_chat_types and stream_peers are comma-separated lists, empty for
+// no restriction, and stream_mentions_only is a boolean
+func loadMessageFilter(file *ini.File) (messageFilter, error) {
+	bridge := file.Section("bridge")
+	var f messageFilter
+	if types := bridge.Key("stream_chat_types").Strings(","); len(types) > 0 {
+		f.ChatTypes = make(map[string]bool, len(types))
+		for _, t := range types {
+			switch t {
+			case chatTypePrivate, chatTypeGroup, chatTypeChannel:
+				f.ChatTypes[t] = true
+			default:
+				return messageFilter{}, fmt.Errorf("stream_chat_types must list private, group or channel, got %q", t)
+			}
+		}
+	}
+	if ids := bridge.Key("stream_peers").Strings(","); len(ids) > 0 {
+		f.Peers = make(map[int64]bool, len(ids))
+		for _, s := range ids {
+			id, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+			if err != nil || id == 0 {
+				return messageFilter{}, fmt.Errorf("stream_peers must list numeric peer IDs, got %q", s)
+			}
+			f.Peers[id] = true
+		}
+	}
+	if key := bridge.Key("stream_mentions_only"); key.Value() != "" {
+		var err error
+		if f.MentionsOnly, err = key.Bool(); err != nil {
+			return messageFilter{}, fmt.Errorf("stream_mentions_only must be true or false, got %q", key.Value())
+		}
+	}
+	return f, nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gotd/td/tg"
+	"gopkg.in/ini.v1"
+)
+
+func TestMessageFilterChatTypes(t *testing.T) {
+	private := &tg.Message{PeerID: &tg.PeerUser{UserID: 10}}
+	group := &tg.Message{PeerID: &tg.PeerChat{ChatID: 20}}
+	supergroup := &tg.Message{PeerID: &tg.PeerChannel{ChannelID: 30}}
+	post := &tg.Message{PeerID: &tg.PeerChannel{ChannelID: 31}, Post: true}
+
+	tests := []struct {
+		name  string
+		types []string
+		want  []bool // private, group, supergroup, post
+	}{
+		{name: "all", want: []bool{true, true, true, true}},
+		{name: "private", types: []string{chatTypePrivate}, want: []bool{true, false, false, false}},
+		{name: "groups", types: []string{chatTypeGroup}, want: []bool{false, true, true, false}},
+		{name: "channels", types: []string{chatTypeChannel}, want: []bool{false, false, false, true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var f messageFilter
+			if tt.types != nil {
+				f.ChatTypes = make(map[string]bool)
+				for _, typ := range tt.types {
+					f.ChatTypes[typ] = true
+				}
+			}
+			for i, msg := range []*tg.Message{private, group, supergroup, post} {
+				if got := f.allows(msg); got != tt.want[i] {
+					t.Errorf("allows(%s) = %v, want %v", messageChatType(msg), got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMessageFilterPeers(t *testing.T) {
+	f := messageFilter{Peers: map[int64]bool{10: true, -1000000000030: true}}
+	tests := []struct {
+		peer tg.PeerClass
+		want bool
+	}{
+		{&tg.PeerUser{UserID: 10}, true},
+		{&tg.PeerUser{UserID: 11}, false},
+		{&tg.PeerChannel{ChannelID: 30}, true},
+		{&tg.PeerChat{ChatID: 30}, false},
+	}
+	for _, tt := range tests {
+		if got := f.allows(&tg.Message{PeerID: tt.peer}); got != tt.want {
+			t.Errorf("allows(%v) = %v, want %v", tt.peer, got, tt.want)
+		}
+	}
+}
+
+func TestMessageFilterMentions(t *testing.T) {
+	f := messageFilter{MentionsOnly: true}
+	if f.allows(&tg.Message{PeerID: &tg.PeerChat{ChatID: 20}}) {
+		t.Error("message without mention allowed")
+	}
+	if !f.allows(&tg.Message{PeerID: &tg.PeerChat{ChatID: 20}, Mentioned: true}) {
+		t.Error("mention dropped")
+	}
+}
+
+func TestLoadMessageFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		want    messageFilter
+		wantErr string
+	}{
+		{name: "defaults", config: "[bridge]\n"},
+		{
+			name:   "all keys",
+			config: "[bridge]\nstream_chat_types = private, channel\nstream_peers = 10, -1000000000030\nstream_mentions_only = true\n",
+			want: messageFilter{
+				ChatTypes:    map[string]bool{chatTypePrivate: true, chatTypeChannel: true},
+				Peers:        map[int64]bool{10: true, -1000000000030: true},
+				MentionsOnly: true,
+			},
+		},
+		{name: "bad chat type", config: "[bridge]\nstream_chat_types = bots\n", wantErr: "stream_chat_types"},
+		{name: "username peer", config: "[bridge]\nstream_peers = @someone\n", wantErr: "stream_peers"},
+		{name: "bad bool", config: "[bridge]\nstream_mentions_only = maybe\n", wantErr: "stream_mentions_only"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := ini.Load([]byte(tt.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+			f, err := loadMessageFilter(file)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(f.ChatTypes) != len(tt.want.ChatTypes) || len(f.Peers) != len(tt.want.Peers) || f.MentionsOnly != tt.want.MentionsOnly {
+				t.Fatalf("filter = %+v, want %+v", f, tt.want)
+			}
+			for k := range tt.want.ChatTypes {
+				if !f.ChatTypes[k] {
+					t.Errorf("chat type %s missing", k)
+				}
+			}
+			for k := range tt.want.Peers {
+				if !f.Peers[k] {
+					t.Errorf("peer %d missing", k)
+				}
+			}
+		})
+	}
+}
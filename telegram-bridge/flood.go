@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gotd/td/tgerr"
+)
+
+// floodRetryAttempts is how many times withFloodRetry calls fn in total,
+// configured by [bridge] flood_retry_attempts
+var floodRetryAttempts = 3
+
+// withFloodRetry calls fn, and while it fails with FLOOD_WAIT_X waits the
+// requested X seconds and tries again, up to floodRetryAttempts calls. The
+// wait is abandoned when ctx is done.
+func withFloodRetry(ctx context.Context, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		wait, ok := tgerr.AsFloodWait(err)
+		if !ok || attempt >= floodRetryAttempts {
+			return err
+		}
+
+		slog.Warn("Flood wait, retrying", "wait", wait, "attempt", attempt, "max_attempts", floodRetryAttempts)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Flood risk levels reported by estimate_flood_risk
+const (
+	floodRiskLow    = "low"
+	floodRiskMedium = "medium"
+	floodRiskHigh   = "high"
+)
+
+// floodOperation describes a kind of bulk operation: the requests it makes,
+// a pace Telegram usually tolerates, and the batch sizes past which flood
+// waits become likely
+type floodOperation struct {
+	methods  []string
+	interval time.Duration
+	medium   int
+	high     int
+}
+
+// floodOperations are the bulk operations estimate_flood_risk knows. The
+// limits are not published, so the numbers are conservative observations.
+var floodOperations = map[string]floodOperation{
+	"send": {
+		methods:  []string{"messages.sendMessage", "messages.sendMedia", "messages.sendMultiMedia"},
+		interval: time.Second, medium: 30, high: 200,
+	},
+	"forward": {
+		methods:  []string{"messages.forwardMessages"},
+		interval: time.Second, medium: 30, high: 200,
+	},
+	"join": {
+		methods:  []string{"channels.joinChannel", "messages.importChatInvite"},
+		interval: 30 * time.Second, medium: 5, high: 20,
+	},
+	"invite": {
+		methods:  []string{"channels.inviteToChannel", "messages.addChatUser"},
+		interval: 10 * time.Second, medium: 10, high: 50,
+	},
+	"resolve": {
+		methods:  []string{"contacts.resolveUsername"},
+		interval: 2 * time.Second, medium: 20, high: 100,
+	},
+	"import_contacts": {
+		methods:  []string{"contacts.importContacts"},
+		interval: 5 * time.Second, medium: 5, high: 20,
+	},
+}
+
+// maxFloodInterval caps the suggested pace between requests
+const maxFloodInterval = time.Minute
+
+// FloodRisk is how likely a bulk operation is to hit flood waits, with a
+// pace to send its requests at
+type FloodRisk struct {
+	Operation string `json:"operation"`
+	Count     int    `json:"count"`
+	Level     string `json:"level"`
+	// RecentFloodWaits counts the flood waits of the operation's requests in
+	// the last hour; LongestWaitSeconds is the longest of them
+	RecentFloodWaits   int `json:"recent_flood_waits"`
+	LongestWaitSeconds int `json:"longest_wait_seconds"`
+	// WaitSeconds is how long a flood wait still in force has left; start
+	// after it
+	WaitSeconds int `json:"wait_seconds,omitempty"`
+	// IntervalMS is the suggested pause between requests and
+	// EstimatedSeconds the whole operation's duration at that pace
+	IntervalMS       int64  `json:"interval_ms"`
+	EstimatedSeconds int64  `json:"estimated_seconds"`
+	Reason           string `json:"reason"`
+}
+
+type floodRiskArgs struct {
+	Operation string `json:"operation"`
+	Count     int    `json:"count"`
+}
+
+func estimateFloodRiskTool(m *qualityMonitor) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args floodRiskArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if _, ok := floodOperations[args.Operation]; !ok {
+			return nil, invalidParams("operation must be one of %s", strings.Join(floodOperationNames(), ", "))
+		}
+		if args.Count < 1 {
+			return nil, invalidParams("count must be at least 1")
+		}
+		return estimateFloodRisk(ctx, m, args.Operation, args.Count)
+	}
+}
+
+func floodOperationNames() []string {
+	names := make([]string, 0, len(floodOperations))
+	for name := range floodOperations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// estimateFloodRisk rates a bulk operation of count requests against the
+// flood waits the monitor saw recently
+func estimateFloodRisk(_ context.Context, m *qualityMonitor, operation string, count int) (FloodRisk, error) {
+	op, ok := floodOperations[operation]
+	if !ok {
+		return FloodRisk{}, fmt.Errorf("unknown operation %q", operation)
+	}
+	return floodRisk(operation, op, count, m.recentFloods(time.Now()), time.Now()), nil
+}
+
+// floodRisk rates count requests of op by the batch size, raised by the
+// flood waits its requests already got. Each recent flood wait slows the
+// suggested pace further.
+func floodRisk(name string, op floodOperation, count int, floods []floodEvent, now time.Time) FloodRisk {
+	risk := FloodRisk{Operation: name, Count: count, Level: floodRiskLow, Reason: "batch is small"}
+	switch {
+	case count > op.high:
+		risk.Level, risk.Reason = floodRiskHigh, fmt.Sprintf("batches over %d usually hit flood waits", op.high)
+	case count > op.medium:
+		risk.Level, risk.Reason = floodRiskMedium, fmt.Sprintf("batches over %d may hit flood waits", op.medium)
+	}
+
+	var longest, remaining time.Duration
+	for _, e := range floods {
+		if !containsString(op.methods, e.Method) {
+			continue
+		}
+		risk.RecentFloodWaits++
+		longest = max(longest, e.Wait)
+		remaining = max(remaining, e.At.Add(e.Wait).Sub(now))
+	}
+	risk.LongestWaitSeconds = int(longest.Seconds())
+	if remaining > 0 {
+		risk.WaitSeconds = int((remaining + time.Second - 1) / time.Second)
+	}
+	switch {
+	case risk.WaitSeconds > 0 || risk.RecentFloodWaits >= 3 || longest >= time.Minute:
+		risk.Level, risk.Reason = floodRiskHigh, fmt.Sprintf("%d flood waits in the last hour, the longest %s", risk.RecentFloodWaits, longest)
+	case risk.RecentFloodWaits > 0 && risk.Level == floodRiskLow:
+		risk.Level, risk.Reason = floodRiskMedium, fmt.Sprintf("a flood wait of %s in the last hour", longest)
+	}
+
+	interval := min(op.interval*time.Duration(1+risk.RecentFloodWaits), maxFloodInterval)
+	if risk.Level == floodRiskHigh {
+		interval = min(interval*2, maxFloodInterval)
+	}
+	risk.IntervalMS = interval.Milliseconds()
+	risk.EstimatedSeconds = int64(risk.WaitSeconds) + int64((time.Duration(count-1) * interval).Seconds())
+	return risk
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
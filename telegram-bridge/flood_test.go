@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFloodRisk(t *testing.T) {
+	now := time.Now()
+	send := floodOperations["send"]
+	tests := []struct {
+		name     string
+		count    int
+		floods   []floodEvent
+		level    string
+		interval time.Duration
+		waits    int
+		wait     int
+	}{
+		{name: "small batch", count: 10, level: floodRiskLow, interval: time.Second},
+		{name: "large batch", count: 100, level: floodRiskMedium, interval: time.Second},
+		{name: "huge batch", count: 500, level: floodRiskHigh, interval: 2 * time.Second},
+		{
+			name:  "flood waits of other requests ignored",
+			count: 10, level: floodRiskLow, interval: time.Second,
+			floods: []floodEvent{{At: now.Add(-10 * time.Minute), Method: "channels.joinChannel", Wait: 5 * time.Minute}},
+		},
+		{
+			name:  "one past flood wait",
+			count: 10, level: floodRiskMedium, interval: 2 * time.Second, waits: 1,
+			floods: []floodEvent{{At: now.Add(-10 * time.Minute), Method: "messages.sendMessage", Wait: 20 * time.Second}},
+		},
+		{
+			name:  "flood wait still in force",
+			count: 10, level: floodRiskHigh, interval: 4 * time.Second, waits: 1, wait: 20,
+			floods: []floodEvent{{At: now.Add(-10 * time.Second), Method: "messages.sendMedia", Wait: 30 * time.Second}},
+		},
+		{
+			name:  "repeated flood waits",
+			count: 10, level: floodRiskHigh, interval: 8 * time.Second, waits: 3,
+			floods: []floodEvent{
+				{At: now.Add(-50 * time.Minute), Method: "messages.sendMessage", Wait: 5 * time.Second},
+				{At: now.Add(-40 * time.Minute), Method: "messages.sendMessage", Wait: 5 * time.Second},
+				{At: now.Add(-30 * time.Minute), Method: "messages.sendMultiMedia", Wait: 5 * time.Second},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			risk := floodRisk("send", send, tt.count, tt.floods, now)
+			if risk.Level != tt.level || risk.IntervalMS != tt.interval.Milliseconds() || risk.RecentFloodWaits != tt.waits || risk.WaitSeconds != tt.wait {
+				t.Errorf("risk = %+v, want level %s, interval %s, %d waits, wait %ds", risk, tt.level, tt.interval, tt.waits, tt.wait)
+			}
+			want := int64(tt.wait) + int64((time.Duration(tt.count-1) * tt.interval).Seconds())
+			if risk.EstimatedSeconds != want {
+				t.Errorf("estimated = %ds, want %ds", risk.EstimatedSeconds, want)
+			}
+		})
+	}
+}
+
+func TestEstimateFloodRiskUsesMonitor(t *testing.T) {
+	m := newQualityMonitor()
+	m.recordFlood(floodEvent{At: time.Now(), Method: "channels.joinChannel", Wait: 5 * time.Minute})
+	risk, err := estimateFloodRisk(context.Background(), m, "join", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if risk.Level != floodRiskHigh || risk.RecentFloodWaits != 1 || risk.LongestWaitSeconds != 300 || risk.WaitSeconds == 0 {
+		t.Errorf("risk = %+v", risk)
+	}
+	if _, err := estimateFloodRisk(context.Background(), m, "spam", 1); err == nil {
+		t.Error("unknown operation: want error")
+	}
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+// Folder IDs 0 and 1 are the main chat list and the archive, which have no
+// rules of their own
+const (
+	minFolderID = 2
+	maxFolderID = 255
+)
+
+// FolderRules are the rules of a chat folder: which kinds of chat it shows,
+// which it hides, and chats included or excluded individually. Peers are
+// marked IDs.
+type FolderRules struct {
+	ID       int    `json:"folder_id"`
+	Title    string `json:"title"`
+	Emoticon string `json:"emoticon,omitempty"`
+	// Chatlist is set for folders shared by invite link, which only have
+	// included and pinned chats
+	Chatlist bool `json:"chatlist,omitempty"`
+
+	Contacts        bool `json:"contacts"`
+	NonContacts     bool `json:"non_contacts"`
+	Groups          bool `json:"groups"`
+	Broadcasts      bool `json:"broadcasts"`
+	Bots            bool `json:"bots"`
+	ExcludeMuted    bool `json:"exclude_muted"`
+	ExcludeRead     bool `json:"exclude_read"`
+	ExcludeArchived bool `json:"exclude_archived"`
+
+	IncludePeers []int64 `json:"include_peers"`
+	ExcludePeers []int64 `json:"exclude_peers"`
+	PinnedPeers  []int64 `json:"pinned_peers"`
+}
+
+type listFoldersResult struct {
+	Folders []FolderRules `json:"folders"`
+}
+
+type folderArgs struct {
+	FolderID int `json:"folder_id"`
+}
+
+func listFoldersTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args struct{}
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		folders, err := getFolders(ctx, api, peers)
+		if err != nil {
+			return nil, err
+		}
+		return listFoldersResult{Folders: folders}, nil
+	}
+}
+
+func getFolderRulesTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args folderArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if err := validFolderID(args.FolderID); err != nil {
+			return nil, err
+		}
+		return getFolderRules(ctx, api, peers, args.FolderID)
+	}
+}
+
+func validFolderID(id int) error {
+	if id < minFolderID || id > maxFolderID {
+		return invalidParams("folder_id must be between 2 and 255")
+	}
+	return nil
+}
+
+// getFolders returns the rules of every chat folder, in the order the apps
+// show them
+func getFolders(ctx context.Context, api *tg.Client, peers *peerResolver) ([]FolderRules, error) {
+	var filters []tg.DialogFilterClass
+	err := withFloodRetry(ctx, func() (err error) {
+		filters, err = api.MessagesGetDialogFilters(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get folders: %w", err)
+	}
+	folders := []FolderRules{}
+	for _, f := range filters {
+		if rules, ok := newFolderRules(f, peers); ok {
+			folders = append(folders, rules)
+		}
+	}
+	return folders, nil
+}
+
+// getFolderRules returns the rules of the folder with id
+func getFolderRules(ctx context.Context, api *tg.Client, peers *peerResolver, id int) (FolderRules, error) {
+	folders, err := getFolders(ctx, api, peers)
+	if err != nil {
+		return FolderRules{}, err
+	}
+	for _, f := range folders {
+		if f.ID == id {
+			return f, nil
+		}
+	}
+	return FolderRules{}, fmt.Errorf("folder %d not found", id)
+}
+
+// newFolderRules converts a dialog filter, caching the access hashes of its
+// peers so they can be written back. The "All chats" entry has no rules and
+// gives false.
+func newFolderRules(f tg.DialogFilterClass, peers *peerResolver) (FolderRules, bool) {
+	switch f := f.(type) {
+	case *tg.DialogFilter:
+		return FolderRules{
+			ID:              f.ID,
+			Title:           f.Title,
+			Emoticon:        f.Emoticon,
+			Contacts:        f.Contacts,
+			NonContacts:     f.NonContacts,
+			Groups:          f.Groups,
+			Broadcasts:      f.Broadcasts,
+			Bots:            f.Bots,
+			ExcludeMuted:    f.ExcludeMuted,
+			ExcludeRead:     f.ExcludeRead,
+			ExcludeArchived: f.ExcludeArchived,
+			IncludePeers:    folderPeerIDs(f.IncludePeers, peers),
+			ExcludePeers:    folderPeerIDs(f.ExcludePeers, peers),
+			PinnedPeers:     folderPeerIDs(f.PinnedPeers, peers),
+		}, true
+	case *tg.DialogFilterChatlist:
+		return FolderRules{
+			ID:           f.ID,
+			Title:        f.Title,
+			Emoticon:     f.Emoticon,
+			Chatlist:     true,
+			IncludePeers: folderPeerIDs(f.IncludePeers, peers),
+			ExcludePeers: []int64{},
+			PinnedPeers:  folderPeerIDs(f.PinnedPeers, peers),
+		}, true
+	default:
+		return FolderRules{}, false
+	}
+}
+
+func folderPeerIDs(input []tg.InputPeerClass, peers *peerResolver) []int64 {
+	ids := make([]int64, 0, len(input))
+	for _, in := range input {
+		var p cachedPeer
+		switch in := in.(type) {
+		case *tg.InputPeerSelf:
+			p = cachedPeer{Type: peerUser, ID: peers.selfID}
+		case *tg.InputPeerUser:
+			p = cachedPeer{Type: peerUser, ID: in.UserID, AccessHash: in.AccessHash}
+			peers.store(p)
+		case *tg.InputPeerChat:
+			p = cachedPeer{Type: peerChat, ID: in.ChatID}
+			peers.store(p)
+		case *tg.InputPeerChannel:
+			p = cachedPeer{Type: peerChannel, ID: in.ChannelID, AccessHash: in.AccessHash}
+			peers.store(p)
+		default:
+			continue
+		}
+		ids = append(ids, p.MarkedID())
+	}
+	return ids
+}
+
+type setFolderRulesArgs struct {
+	FolderID int     `json:"folder_id"`
+	Title    *string `json:"title"`
+	Emoticon *string `json:"emoticon"`
+
+	Contacts        *bool `json:"contacts"`
+	NonContacts     *bool `json:"non_contacts"`
+	Groups          *bool `json:"groups"`
+	Broadcasts      *bool `json:"broadcasts"`
+	Bots            *bool `json:"bots"`
+	ExcludeMuted    *bool `json:"exclude_muted"`
+	ExcludeRead     *bool `json:"exclude_read"`
+	ExcludeArchived *bool `json:"exclude_archived"`
+
+	// Peer lists replace the current ones when given; an empty list clears
+	IncludePeers []string `json:"include_peers"`
+	ExcludePeers []string `json:"exclude_peers"`
+	PinnedPeers  []string `json:"pinned_peers"`
+}
+
+func setFolderRulesTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args setFolderRulesArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if err := validFolderID(args.FolderID); err != nil {
+			return nil, err
+		}
+
+		folders, err := getFolders(ctx, api, peers)
+		if err != nil {
+			return nil, err
+		}
+		// A folder that does not exist yet is created
+		rules := FolderRules{ID: args.FolderID, IncludePeers: []int64{}, ExcludePeers: []int64{}, PinnedPeers: []int64{}}
+		for _, f := range folders {
+			if f.ID == args.FolderID {
+				rules = f
+			}
+		}
+		if err := args.apply(ctx, peers, &rules); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(rules.Title) == "" {
+			return nil, invalidParams("title is required for a new folder")
+		}
+		if err := setFolderRules(ctx, api, peers, rules); err != nil {
+			return nil, err
+		}
+		return rules, nil
+	}
+}
+
+// apply changes the rules the arguments set
+func (a setFolderRulesArgs) apply(ctx context.Context, peers *peerResolver, rules *FolderRules) error {
+	if a.Title != nil {
+		rules.Title = *a.Title
+	}
+	if a.Emoticon != nil {
+		rules.Emoticon = *a.Emoticon
+	}
+	for _, flag := range []struct {
+		arg  *bool
+		rule *bool
+	}{
+		{a.Contacts, &rules.Contacts},
+		{a.NonContacts, &rules.NonContacts},
+		{a.Groups, &rules.Groups},
+		{a.Broadcasts, &rules.Broadcasts},
+		{a.Bots, &rules.Bots},
+		{a.ExcludeMuted, &rules.ExcludeMuted},
+		{a.ExcludeRead, &rules.ExcludeRead},
+		{a.ExcludeArchived, &rules.ExcludeArchived},
+	} {
+		if flag.arg != nil {
+			*flag.rule = *flag.arg
+		}
+	}
+	for _, list := range []struct {
+		arg  []string
+		rule *[]int64
+	}{
+		{a.IncludePeers, &rules.IncludePeers},
+		{a.ExcludePeers, &rules.ExcludePeers},
+		{a.PinnedPeers, &rules.PinnedPeers},
+	} {
+		if list.arg == nil {
+			continue
+		}
+		ids := make([]int64, 0, len(list.arg))
+		for _, peer := range list.arg {
+			p, err := peers.resolve(ctx, peer)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, p.MarkedID())
+		}
+		*list.rule = ids
+	}
+	return nil
+}
+
+// setFolderRules writes rules as the folder with rules.ID, creating it if
+// needed. Shared chat lists are refused: their chats follow the invite link.
+func setFolderRules(ctx context.Context, api *tg.Client, peers *peerResolver, rules FolderRules) error {
+	if rules.Chatlist {
+		return fmt.Errorf("folder %d is shared by invite link; its rules can't be changed here", rules.ID)
+	}
+	filter, err := rules.dialogFilter(ctx, peers)
+	if err != nil {
+		return err
+	}
+	err = withFloodRetry(ctx, func() error {
+		_, err := api.MessagesUpdateDialogFilter(ctx, &tg.MessagesUpdateDialogFilterRequest{ID: rules.ID, Filter: filter})
+		return err
+	})
+	switch {
+	case err == nil:
+		return nil
+	case tgerr.Is(err, "FILTER_INCLUDE_EMPTY"):
+		return invalidParams("a folder needs at least one chat type or included chat")
+	case tgerr.Is(err, "FILTER_TITLE_EMPTY"):
+		return invalidParams("title must not be empty")
+	case tgerr.Is(err, "DIALOG_FILTERS_TOO_MUCH"):
+		return fmt.Errorf("the account has the maximum number of folders")
+	default:
+		return fmt.Errorf("failed to update folder %d: %w", rules.ID, err)
+	}
+}
+
+// dialogFilter converts rules back into a dialog filter, resolving peers
+// from the cache
+func (r FolderRules) dialogFilter(ctx context.Context, peers *peerResolver) (*tg.DialogFilter, error) {
+	filter := &tg.DialogFilter{
+		ID:              r.ID,
+		Title:           r.Title,
+		Contacts:        r.Contacts,
+		NonContacts:     r.NonContacts,
+		Groups:          r.Groups,
+		Broadcasts:      r.Broadcasts,
+		Bots:            r.Bots,
+		ExcludeMuted:    r.ExcludeMuted,
+		ExcludeRead:     r.ExcludeRead,
+		ExcludeArchived: r.ExcludeArchived,
+	}
+	if r.Emoticon != "" {
+		filter.SetEmoticon(r.Emoticon)
+	}
+	var err error
+	if filter.IncludePeers, err = folderInputPeers(ctx, peers, r.IncludePeers); err != nil {
+		return nil, err
+	}
+	if filter.ExcludePeers, err = folderInputPeers(ctx, peers, r.ExcludePeers); err != nil {
+		return nil, err
+	}
+	if filter.PinnedPeers, err = folderInputPeers(ctx, peers, r.PinnedPeers); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+func folderInputPeers(ctx context.Context, peers *peerResolver, ids []int64) ([]tg.InputPeerClass, error) {
+	input := make([]tg.InputPeerClass, 0, len(ids))
+	for _, id := range ids {
+		if id == peers.selfID {
+			input = append(input, &tg.InputPeerSelf{})
+			continue
+		}
+		p, err := peers.Resolve(ctx, strconv.FormatInt(id, 10))
+		if err != nil {
+			return nil, err
+		}
+		input = append(input, p)
+	}
+	return input, nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+)
+
+func folderFilters() []tg.DialogFilterClass {
+	work := &tg.DialogFilter{
+		ID:           2,
+		Title:        "Work",
+		Groups:       true,
+		ExcludeMuted: true,
+		IncludePeers: []tg.InputPeerClass{&tg.InputPeerUser{UserID: 10, AccessHash: 100}, &tg.InputPeerSelf{}},
+		ExcludePeers: []tg.InputPeerClass{&tg.InputPeerChannel{ChannelID: 30, AccessHash: 300}},
+		PinnedPeers:  []tg.InputPeerClass{&tg.InputPeerChat{ChatID: 20}},
+	}
+	work.SetEmoticon("💼")
+	return []tg.DialogFilterClass{
+		&tg.DialogFilterDefault{},
+		work,
+		&tg.DialogFilterChatlist{ID: 3, Title: "Shared", IncludePeers: []tg.InputPeerClass{&tg.InputPeerChannel{ChannelID: 31, AccessHash: 310}}},
+	}
+}
+
+func TestGetFolderRules(t *testing.T) {
+	_, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		if _, ok := input.(*tg.MessagesGetDialogFiltersRequest); ok {
+			return &tg.DialogFilterClassVector{Elems: folderFilters()}, nil
+		}
+		return nil, nil
+	})
+
+	rules, err := getFolderRules(context.Background(), api, peers, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := json.Marshal(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"folder_id":2,"title":"Work","emoticon":"💼","contacts":false,"non_contacts":false,"groups":true,"broadcasts":false,"bots":false,` +
+		`"exclude_muted":true,"exclude_read":false,"exclude_archived":false,"include_peers":[10,1],"exclude_peers":[-1000000000030],"pinned_peers":[-20]}`
+	if string(got) != want {
+		t.Errorf("rules =\n%s\nwant\n%s", got, want)
+	}
+	// Peers of the folder can be written back without resolving them again
+	if p, ok := peers.cached(-1000000000030); !ok || p.AccessHash != 300 {
+		t.Errorf("cached channel = %+v, %v", p, ok)
+	}
+
+	shared, err := getFolderRules(context.Background(), api, peers, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !shared.Chatlist || len(shared.IncludePeers) != 1 || shared.ExcludePeers == nil {
+		t.Errorf("shared folder = %+v", shared)
+	}
+	if _, err := getFolderRules(context.Background(), api, peers, 9); err == nil {
+		t.Error("missing folder: want error")
+	}
+}
+
+func TestFolderRulesDialogFilter(t *testing.T) {
+	_, _, peers := newFakeClient(nil)
+	want := folderFilters()[1].(*tg.DialogFilter)
+	rules, ok := newFolderRules(want, peers)
+	if !ok {
+		t.Fatal("rules not converted")
+	}
+	got, err := rules.dialogFilter(context.Background(), peers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != want.Title || got.Emoticon != want.Emoticon || !got.Groups || !got.ExcludeMuted || got.Contacts {
+		t.Errorf("filter = %+v", got)
+	}
+	if u, ok := got.IncludePeers[0].(*tg.InputPeerUser); !ok || u.UserID != 10 || u.AccessHash != 100 {
+		t.Errorf("include_peers[0] = %#v", got.IncludePeers[0])
+	}
+	if _, ok := got.IncludePeers[1].(*tg.InputPeerSelf); !ok {
+		t.Errorf("include_peers[1] = %#v, want self", got.IncludePeers[1])
+	}
+	if c, ok := got.ExcludePeers[0].(*tg.InputPeerChannel); !ok || c.AccessHash != 300 {
+		t.Errorf("exclude_peers[0] = %#v", got.ExcludePeers[0])
+	}
+}
+
+func TestSetFolderRules(t *testing.T) {
+	inv, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		switch input.(type) {
+		case *tg.MessagesGetDialogFiltersRequest:
+			return &tg.DialogFilterClassVector{Elems: folderFilters()}, nil
+		case *tg.MessagesUpdateDialogFilterRequest:
+			return &tg.BoolTrue{}, nil
+		}
+		return nil, nil
+	})
+	peers.storeUser(&tg.User{ID: 11, AccessHash: 110})
+	set := setFolderRulesTool(api, peers)
+
+	// Only the given rules change
+	result, err := set(context.Background(), json.RawMessage(`{"folder_id":2,"bots":true,"exclude_muted":false,"exclude_peers":["11"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := result.(FolderRules)
+	if rules.Title != "Work" || !rules.Groups || !rules.Bots || rules.ExcludeMuted || len(rules.IncludePeers) != 2 {
+		t.Errorf("rules = %+v", rules)
+	}
+	req := requests[*tg.MessagesUpdateDialogFilterRequest](inv)[0]
+	filter := req.Filter.(*tg.DialogFilter)
+	if req.ID != 2 || !filter.Bots || filter.ExcludeMuted || len(filter.ExcludePeers) != 1 {
+		t.Errorf("request = %+v", filter)
+	}
+	if u, ok := filter.ExcludePeers[0].(*tg.InputPeerUser); !ok || u.UserID != 11 || u.AccessHash != 110 {
+		t.Errorf("exclude_peers = %#v", filter.ExcludePeers)
+	}
+
+	// New folders need a title
+	if _, err := set(context.Background(), json.RawMessage(`{"folder_id":5,"bots":true}`)); err == nil {
+		t.Error("new folder without title: want error")
+	}
+	if _, err := set(context.Background(), json.RawMessage(`{"folder_id":5,"title":"Bots","bots":true}`)); err != nil {
+		t.Errorf("new folder: %v", err)
+	}
+	if _, err := set(context.Background(), json.RawMessage(`{"folder_id":3,"bots":true}`)); err == nil || !strings.Contains(err.Error(), "invite link") {
+		t.Errorf("shared folder: err = %v", err)
+	}
+	if _, err := set(context.Background(), json.RawMessage(`{"folder_id":1}`)); err == nil {
+		t.Error("archive folder: want error")
+	}
+}
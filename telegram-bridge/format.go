@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Tool result formats selected by [bridge] result_format
+const (
+	resultFormatJSON = "json"
+	resultFormatText = "text"
+)
+
+// formatResult renders a tool result for the MCP client: compact JSON for
+// programmatic clients, or an indented "key: value" outline for chat display
+func formatResult(result interface{}, format string) (string, error) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+	if format != resultFormatText {
+		return string(data), nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	v, err := decodeOrdered(dec)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	writeText(&b, v, 0)
+	return strings.TrimRight(b.String(), "\n"), nil
+}
+
+// orderedField is an object member, kept in the order it was encoded so the
+// text follows the struct field order
+type orderedField struct {
+	key   string
+	value interface{}
+}
+
+// decodeOrdered reads one JSON value, decoding objects to []orderedField,
+// arrays to []interface{} and scalars as encoding/json does
+func decodeOrdered(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		fields := []orderedField{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, orderedField{key: key.(string), value: value})
+		}
+		_, err := dec.Token()
+		return fields, err
+	case json.Delim('['):
+		items := []interface{}{}
+		for dec.More() {
+			item, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		_, err := dec.Token()
+		return items, err
+	default:
+		return tok, nil
+	}
+}
+
+// writeText writes v at the given nesting depth. Objects become "key: value"
+// lines, arrays "- item" lines, and nested values are indented below their
+// key or list marker.
+func writeText(b *strings.Builder, v interface{}, depth int) {
+	indent := strings.Repeat("  ", depth)
+	switch v := v.(type) {
+	case []orderedField:
+		if len(v) == 0 {
+			b.WriteString(indent + "(empty)\n")
+		}
+		for _, f := range v {
+			if isComposite(f.value) {
+				b.WriteString(indent + f.key + ":\n")
+				writeText(b, f.value, depth+1)
+				continue
+			}
+			b.WriteString(indent + f.key + ": " + textScalar(f.value, depth+1) + "\n")
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			b.WriteString(indent + "(none)\n")
+		}
+		for _, item := range v {
+			if !isComposite(item) {
+				b.WriteString(indent + "- " + textScalar(item, depth+1) + "\n")
+				continue
+			}
+			// Put the first line of the item on the list marker
+			var nested strings.Builder
+			writeText(&nested, item, depth+1)
+			b.WriteString(indent + "- " + strings.TrimPrefix(nested.String(), indent+"  "))
+		}
+	default:
+		b.WriteString(indent + textScalar(v, depth) + "\n")
+	}
+}
+
+func isComposite(v interface{}) bool {
+	switch v.(type) {
+	case []orderedField, []interface{}:
+		return true
+	}
+	return false
+}
+
+// textScalar formats a JSON scalar, indenting continuation lines of
+// multi-line strings to depth
+func textScalar(v interface{}, depth int) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return strings.ReplaceAll(v, "\n", "\n"+strings.Repeat("  ", depth))
+	default:
+		return fmt.Sprint(v)
+	}
+}
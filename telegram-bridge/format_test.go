@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+// sampleResult mixes the shapes tools return: nested structs, lists of
+// structs, scalars, empty lists and multi-line text
+type sampleResult struct {
+	PeerID   int64         `json:"peer_id"`
+	Title    string        `json:"title"`
+	Verified bool          `json:"verified"`
+	Owner    sampleOwner   `json:"owner"`
+	Messages []messageInfo `json:"messages"`
+	Tags     []string      `json:"tags"`
+	Admins   []int64       `json:"admins"`
+}
+
+type sampleOwner struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+}
+
+var sample = sampleResult{
+	PeerID:   -1000000000030,
+	Title:    "News",
+	Verified: true,
+	Owner:    sampleOwner{ID: 10, Username: "alice"},
+	Messages: []messageInfo{
+		{ID: 2, SenderID: 10, Date: 100, Text: "hello\nworld"},
+		{ID: 1, SenderID: 11, Date: 90, Text: "hi", FromMe: true},
+	},
+	Tags:   []string{"a", "b"},
+	Admins: []int64{},
+}
+
+func TestFormatResultJSON(t *testing.T) {
+	got, err := formatResult(sample, resultFormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"peer_id":-1000000000030,"title":"News","verified":true,` +
+		`"owner":{"id":10,"username":"alice"},` +
+		`"messages":[{"id":2,"sender_id":10,"date":100,"text":"hello\nworld","from_me":false},` +
+		`{"id":1,"sender_id":11,"date":90,"text":"hi","from_me":true}],` +
+		`"tags":["a","b"],"admins":[]}`
+	if got != want {
+		t.Errorf("formatResult(json) =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFormatResultText(t *testing.T) {
+	got, err := formatResult(sample, resultFormatText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `peer_id: -1000000000030
+title: News
+verified: true
+owner:
+  id: 10
+  username: alice
+messages:
+  - id: 2
+    sender_id: 10
+    date: 100
+    text: hello
+      world
+    from_me: false
+  - id: 1
+    sender_id: 11
+    date: 90
+    text: hi
+    from_me: true
+tags:
+  - a
+  - b
+admins:
+  (none)`
+	if got != want {
+		t.Errorf("formatResult(text) =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFormatResultTextScalars(t *testing.T) {
+	tests := []struct {
+		in   interface{}
+		want string
+	}{
+		{in: "plain", want: "plain"},
+		{in: 42, want: "42"},
+		{in: nil, want: "null"},
+		{in: struct{}{}, want: "(empty)"},
+		{in: []int{}, want: "(none)"},
+		{in: [][]int{{1, 2}}, want: "- - 1\n  - 2"},
+	}
+	for _, tt := range tests {
+		got, err := formatResult(tt.in, resultFormatText)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("formatResult(%#v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCallToolResultFormat(t *testing.T) {
+	for _, format := range []string{resultFormatJSON, resultFormatText} {
+		server := NewMCPServer(&bytes.Buffer{})
+		server.ResultFormat = format
+		server.RegisterTool(Tool{
+			Name: "sample",
+			Handler: func(context.Context, json.RawMessage) (interface{}, error) {
+				return sampleOwner{ID: 10, Username: "alice"}, nil
+			},
+		})
+
+		res, err := server.callTool(context.Background(), json.RawMessage(`{"name":"sample"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, _ := formatResult(sampleOwner{ID: 10, Username: "alice"}, format)
+		if got := res.(toolResult).Content[0].Text; got != want {
+			t.Errorf("%s: text = %q, want %q", format, got, want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// healthState is what the health endpoints report, updated as the client
+// starts and logs in
+type healthState struct {
+	running atomic.Bool
+	// userID is the logged-in user, 0 until authentication succeeded
+	userID atomic.Int64
+}
+
+type readyResponse struct {
+	Status string `json:"status"`
+	UserID int64  `json:"user_id,omitempty"`
+}
+
+// startHealthServer serves /healthz and /readyz on addr until ctx is done.
+// /healthz succeeds while the client is running, including while waiting for
+// a QR scan; /readyz only once the user is logged in. The listener is opened
+// before returning so a bad address fails at startup.
+func startHealthServer(ctx context.Context, addr string, state *healthState) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		if !state.running.Load() {
+			http.Error(w, "client not running", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		resp := readyResponse{Status: "not ready"}
+		code := http.StatusServiceUnavailable
+		if id := state.userID.Load(); id != 0 && state.running.Load() {
+			resp = readyResponse{Status: "ready", UserID: id}
+			code = http.StatusOK
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Health server failed", "error", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	slog.Info("Serving health checks", "addr", ln.Addr().String())
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+)
+
+// InputPeerExport is a resolved peer in a form other clients of the shared
+// session can build their own input peers from
+type InputPeerExport struct {
+	// Type is user, chat or channel
+	Type       string `json:"type"`
+	ID         int64  `json:"id"`
+	AccessHash int64  `json:"access_hash"`
+	// MarkedID is the ID in Bot API form, as the other tools take it
+	MarkedID int64 `json:"marked_id"`
+	// Constructor names the TL input peer type to build, e.g. inputPeerUser
+	Constructor string `json:"constructor"`
+	// SessionUserID is the account the access hash was issued to; it is
+	// only valid for requests made on this account's sessions
+	SessionUserID int64 `json:"session_user_id"`
+}
+
+func getInputPeerTool(peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args peerArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.Peer) == "" {
+			return nil, invalidParams("peer is required")
+		}
+		return getInputPeer(ctx, peers, args.Peer)
+	}
+}
+
+// getInputPeer resolves peer and returns its input entity for use outside
+// the bridge, alongside the exported session
+func getInputPeer(ctx context.Context, peers *peerResolver, peer string) (InputPeerExport, error) {
+	p, err := peers.resolve(ctx, peer)
+	if err != nil {
+		return InputPeerExport{}, err
+	}
+	return newInputPeerExport(p, peers.selfID), nil
+}
+
+func newInputPeerExport(p cachedPeer, selfID int64) InputPeerExport {
+	return InputPeerExport{
+		Type:          p.Type,
+		ID:            p.ID,
+		AccessHash:    p.AccessHash,
+		MarkedID:      p.MarkedID(),
+		Constructor:   p.InputPeer().TypeName(),
+		SessionUserID: selfID,
+	}
+}
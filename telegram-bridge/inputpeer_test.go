@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestGetInputPeer(t *testing.T) {
+	_, _, peers := newFakeClient(nil)
+	peers.selfID = 1
+	peers.store(cachedPeer{Type: peerUser, ID: 10, AccessHash: 100})
+	peers.store(cachedPeer{Type: peerChat, ID: 20})
+	peers.store(cachedPeer{Type: peerChannel, ID: 30, AccessHash: 300})
+
+	tests := []struct {
+		peer string
+		want string
+	}{
+		{"10", `{"type":"user","id":10,"access_hash":100,"marked_id":10,"constructor":"inputPeerUser","session_user_id":1}`},
+		{"-20", `{"type":"chat","id":20,"access_hash":0,"marked_id":-20,"constructor":"inputPeerChat","session_user_id":1}`},
+		{"-1000000000030", `{"type":"channel","id":30,"access_hash":300,"marked_id":-1000000000030,"constructor":"inputPeerChannel","session_user_id":1}`},
+	}
+	for _, tt := range tests {
+		export, err := getInputPeer(context.Background(), peers, tt.peer)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.peer, err)
+		}
+		got, err := json.Marshal(export)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s:\n got %s\nwant %s", tt.peer, got, tt.want)
+		}
+		var back InputPeerExport
+		if err := json.Unmarshal(got, &back); err != nil || back != export {
+			t.Errorf("%s: round trip = %+v, %v", tt.peer, back, err)
+		}
+	}
+}
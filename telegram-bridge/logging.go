@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/ini.v1"
+)
+
+// LoggingConfig is the [logging] section of config.ini
+type LoggingConfig struct {
+	Level slog.Level
+	// Format is "text" (default) or "json"
+	Format string
+}
+
+// loadLoggingConfig reads the [logging] section, defaulting to text logs at
+// info level
+func loadLoggingConfig(file *ini.File) (LoggingConfig, error) {
+	section := file.Section("logging")
+	lc := LoggingConfig{
+		Format: section.Key("format").In("text", []string{"text", "json"}),
+	}
+	level := section.Key("level").MustString("info")
+	if err := lc.Level.UnmarshalText([]byte(level)); err != nil {
+		return LoggingConfig{}, fmt.Errorf("invalid level %q: must be debug, info, warn or error", level)
+	}
+	return lc, nil
+}
+
+// secretLogKeys are attributes whose values are never written, whatever the
+// level; gotd's fields pass through the same filter
+var secretLogKeys = map[string]bool{
+	"auth_key":  true,
+	"api_hash":  true,
+	"password":  true,
+	"token":     true,
+	"login_url": true,
+}
+
+// newLogger builds the bridge logger, writing to w (stderr, since stdout
+// carries MCP traffic)
+func newLogger(w io.Writer, lc LoggingConfig) *slog.Logger {
+	opts := &slog.HandlerOptions{
+		Level: lc.Level,
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if secretLogKeys[strings.ToLower(a.Key)] {
+				return slog.String(a.Key, "[redacted]")
+			}
+			return a
+		},
+	}
+	if lc.Format == "json" {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+// fatal logs msg at error level and exits, like log.Fatalf
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// newZapLogger returns a zap logger for gotd that writes through h, so the
+// client's logs share the bridge's level, format and secret filtering
+func newZapLogger(h slog.Handler) *zap.Logger {
+	return zap.New(&slogCore{handler: h}).Named("gotd")
+}
+
+// slogCore is a zapcore.Core backed by a slog.Handler
+type slogCore struct {
+	handler slog.Handler
+}
+
+func (c *slogCore) Enabled(level zapcore.Level) bool {
+	return c.handler.Enabled(context.Background(), slogLevel(level))
+}
+
+func (c *slogCore) With(fields []zapcore.Field) zapcore.Core {
+	return &slogCore{handler: c.handler.WithAttrs(zapAttrs(fields))}
+}
+
+func (c *slogCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(e.Level) {
+		return ce.AddCore(e, c)
+	}
+	return ce
+}
+
+func (c *slogCore) Write(e zapcore.Entry, fields []zapcore.Field) error {
+	r := slog.NewRecord(e.Time, slogLevel(e.Level), e.Message, 0)
+	if e.LoggerName != "" {
+		r.AddAttrs(slog.String("logger", e.LoggerName))
+	}
+	r.AddAttrs(zapAttrs(fields)...)
+	return c.handler.Handle(context.Background(), r)
+}
+
+func (c *slogCore) Sync() error {
+	return nil
+}
+
+// slogLevel maps a zap level to the nearest slog level
+func slogLevel(level zapcore.Level) slog.Level {
+	switch {
+	case level <= zapcore.DebugLevel:
+		return slog.LevelDebug
+	case level == zapcore.InfoLevel:
+		return slog.LevelInfo
+	case level == zapcore.WarnLevel:
+		return slog.LevelWarn
+	default:
+		return slog.LevelError
+	}
+}
+
+// zapAttrs converts zap fields to slog attributes
+func zapAttrs(fields []zapcore.Field) []slog.Attr {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	attrs := make([]slog.Attr, 0, len(enc.Fields))
+	for k, v := range enc.Fields {
+		attrs = append(attrs, slog.Any(k, v))
+	}
+	return attrs
+}
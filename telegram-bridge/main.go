@@ -2,76 +2,131 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"io/ioutil"
-	"log"
+	"log/slog"
 	"os"
-	"time"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"github.com/gotd/td/session"
 	"github.com/gotd/td/telegram"
-	"github.com/skip2/go-qrcode"
-	"gopkg.in/ini.v1"
+	"github.com/gotd/td/telegram/auth/qrlogin"
+	"github.com/gotd/td/tg"
 )
 
-// Structure to hold essential session data for export
-type ExportedSession struct {
-	DC      int    `json:"dc_id"`
-	Addr    string `json:"addr"`
-	AuthKey []byte `json:"auth_key"`
-	UserID  int64  `json:"user_id"`
-}
+// version is the bridge version, set at build time with
+// -ldflags "-X main.version=<version>"
+var version = "dev"
 
-// Data structure that should match the gotd/td session format
-type SessionData struct {
-	DC      int    `json:"dc_id"`
-	Addr    string `json:"addr"`
-	AuthKey []byte `json:"auth_key"`
-	UserID  int64  `json:"user_id"`
-}
+const configPath = "telegram-bridge/config.ini"
 
 func main() {
-	log.Println("Starting Telegram bridge...")
+	importSessionMode := flag.Bool("import-session", false, "read an exported session JSON from stdin, verify it and store it, then exit")
+	account := flag.String("account", os.Getenv("ACCOUNT"), "name of the [account.<name>] section of config.ini to run (default $ACCOUNT)")
+	flag.Parse()
 
 	// Load configuration
-	cfg, err := ini.Load("telegram-bridge/config.ini")
+	cfg, err := loadConfig(*account)
 	if err != nil {
-		log.Fatalf("Failed to load config: %v\n", err)
+		fatal("Failed to load configuration", "error", err)
 	}
+	logger := newLogger(os.Stderr, cfg.Logging)
+	slog.SetDefault(logger)
+	slog.Info("Starting Telegram bridge")
+	floodRetryAttempts = cfg.FloodRetryAttempts
+
+	// Set up session storage, separate for each account
+	printStartupInfo(configPath, cfg)
 
-	apiIDStr := cfg.Section("telegram").Key("api_id").String()
-	apiHash := cfg.Section("telegram").Key("api_hash").String()
+	if err := os.MkdirAll(cfg.StoreDir, 0700); err != nil {
+		fatal("Failed to create session directory", "error", err)
+	}
+	paths := newSessionPaths(cfg.StoreDir)
+	sessionFilePath := paths.Session
+	sharedSessionPath := paths.Shared // Path for JSON export
+	fileStorage := &session.FileStorage{
+		Path: sessionFilePath,
+	}
+	var sessionStorage session.Storage = fileStorage
 
-	var apiID int
-	_, err = fmt.Sscan(apiIDStr, &apiID)
+	resolver, err := proxyResolver(cfg.Proxy)
 	if err != nil {
-		log.Fatalf("Invalid api_id: %v\n", err)
+		fatal("Failed to set up proxy", "error", err)
+	}
+	if resolver != nil {
+		slog.Info("Connecting through SOCKS5 proxy", "addr", cfg.Proxy.Addr)
 	}
 
-	if apiHash == "" || apiID == 0 {
-		log.Fatalf("api_id and api_hash must be set in config.ini")
+	// Stop on SIGINT/SIGTERM so the session can be flushed on the way out
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *importSessionMode {
+		verify := newSessionVerifier(cfg.APIID, cfg.APIHash, resolver)
+		if err := importSession(ctx, os.Stdin, verify, fileStorage); err != nil {
+			fatal("Session import failed", "error", err)
+		}
+		slog.Info("Session imported", "path", sessionFilePath)
+		return
+	}
+
+	// Bootstrap from the shared export when there is no gotd session yet
+	imported := false
+	if _, err := os.Stat(sessionFilePath); errors.Is(err, os.ErrNotExist) {
+		if _, err := os.Stat(sharedSessionPath); err == nil {
+			exported, err := LoadExportedSession(sharedSessionPath)
+			if err != nil {
+				fatal("Failed to import shared session", "error", err)
+			}
+			slog.Info("No session file found, importing shared session", "path", sharedSessionPath)
+			sessionStorage = &ExportedSessionStorage{Session: exported, Next: fileStorage}
+			imported = true
+		}
 	}
 
-	// Set up session storage
-	sessionDir := "store"
-	if err := os.MkdirAll(sessionDir, 0700); err != nil {
-		log.Fatalf("Failed to create session directory: %v", err)
+	// The dispatcher also delivers the update confirming a QR login
+	server := NewMCPServer(os.Stdout)
+	server.ResultFormat = cfg.ResultFormat
+	server.Timeout = cfg.RPCTimeout
+	server.ToolTimeouts = cfg.ToolTimeouts
+	dispatcher := tg.NewUpdateDispatcher()
+	loggedIn := qrlogin.OnLoginToken(dispatcher)
+	quality := newQualityMonitor()
+	selfUser := &selfCache{}
+	opts := telegram.Options{
+		SessionStorage: sessionStorage,
+		Resolver:       resolver,
+		UpdateHandler:  dispatcher,
+		Logger:         newZapLogger(logger.Handler()),
+		Middlewares:    []telegram.Middleware{quality, selfUser},
 	}
-	sessionStorage := &session.FileStorage{
-		Path: fmt.Sprintf("%s/telegram.session", sessionDir),
+	var stream *updateStream
+	if cfg.StreamUpdates {
+		stream = newUpdateStream(dispatcher, server, cfg.StreamFilter)
+		opts.UpdateHandler = stream.gaps
 	}
-	sessionFilePath := fmt.Sprintf("%s/telegram.session", sessionDir)
-	sharedSessionPath := fmt.Sprintf("%s/shared_session.json", sessionDir) // Path for JSON export
 
 	// Create Telegram client
-	client := telegram.NewClient(apiID, apiHash, telegram.Options{
-		SessionStorage: sessionStorage,
-	})
+	client := telegram.NewClient(cfg.APIID, cfg.APIHash, opts)
+	quality.ping = client.Ping
+
+	// Start health checks before the client so liveness passes during login
+	var health healthState
+	if cfg.HealthAddr != "" {
+		if err := startHealthServer(ctx, cfg.HealthAddr, &health); err != nil {
+			fatal("Failed to start health server", "error", err)
+		}
+	}
 
 	// Run the client
-	err = client.Run(context.Background(), func(ctx context.Context) error {
-		log.Println("Client started, checking authentication...")
+	var self *tg.User
+	err = client.Run(ctx, func(ctx context.Context) error {
+		health.running.Store(true)
+		defer health.running.Store(false)
+		slog.Info("Client started, checking authentication")
 
 		status, err := client.Auth().Status(ctx)
 		if err != nil {
@@ -79,104 +134,89 @@ func main() {
 		}
 
 		if !status.Authorized {
-			log.Println("Not authorized. Please scan the QR code below with Telegram mobile app.")
-			log.Println("Open Telegram app → Settings → Devices → Link Desktop Device")
-
-			// Generate QR code for web.telegram.org
-			loginURL := "https://web.telegram.org/a/"
-			if err := qrcode.WriteFile(loginURL, qrcode.Medium, 256, "store/qrcode.png"); err != nil {
-				log.Printf("Failed to generate QR code image: %v", err)
-			} else {
-				log.Println("QR code saved to store/qrcode.png")
+			if imported {
+				return fmt.Errorf("imported session from %s is not authorized", sharedSessionPath)
 			}
-
-			// Also show terminal QR code
-			qrTerminal, err := qrcode.New(loginURL, qrcode.Medium)
-			if err == nil {
-				fmt.Println(qrTerminal.ToSmallString(false))
+			switch cfg.AuthMode {
+			case "phone":
+				err = phoneCodeAuth(ctx, newClientSignIn(client), cfg.Phone, cfg.Password2FA)
+			default:
+				err = qrAuth(ctx, client, loggedIn, paths.QRCode, cfg.Password2FA, cfg.QRTimeout)
 			}
-
-			// Wait for user to scan QR code and login
-			log.Println("Waiting for authorization... Please scan the QR code.")
-			for {
-				// Check every few seconds if we're authorized
-				time.Sleep(3 * time.Second)
-
-				status, err := client.Auth().Status(ctx)
-				if err != nil {
-					log.Printf("Error checking auth status: %v", err)
-					continue
-				}
-
-				if status.Authorized {
-					log.Println("Authorization successful!")
-					break
-				}
-			}
-
-			// Export session data after successful login
-			if err := exportSession(sessionFilePath, sharedSessionPath); err != nil {
-				log.Printf("Warning: Failed to export session: %v", err)
+			if err != nil {
+				return fmt.Errorf("authentication failed: %w", err)
 			}
 		} else {
-			log.Println("Already authorized.")
-			// Also export session if already authorized
-			if err := exportSession(sessionFilePath, sharedSessionPath); err != nil {
-				log.Printf("Warning: Failed to export session: %v", err)
-			}
+			slog.Info("Already authorized")
 		}
 
-		self, err := client.Self(ctx)
+		self, err = client.Self(ctx)
 		if err != nil {
 			return fmt.Errorf("failed to get self info: %w", err)
 		}
-		log.Printf("Logged in as: %s %s (@%s)\n", self.FirstName, self.LastName, self.Username)
+		health.userID.Store(self.ID)
+		slog.Info("Logged in", "user_id", self.ID, "name", strings.TrimSpace(self.FirstName+" "+self.LastName), "username", self.Username)
 
-		log.Println("Telegram bridge running. Press Ctrl+C to exit.")
-		<-ctx.Done()
-		return ctx.Err()
-	})
-
-	if err != nil {
-		log.Fatalf("Telegram client run failed: %v", err)
-	}
+		// Export session data for the Python server
+		if err := exportSession(sessionFilePath, sharedSessionPath, self.ID, cfg.SessionExportPretty); err != nil {
+			slog.Warn("Failed to export session", "error", err)
+		}
 
-	log.Println("Telegram bridge stopped.")
-}
+		// Serve MCP over stdio until stdin closes
+		peers := newPeerResolver(client.API(), self.ID)
+		peers.self = selfUser
+		selfUser.set(self)
+		box, err := newOutbox(paths.Outbox, cfg.SessionExportPretty)
+		if err != nil {
+			return err
+		}
+		registerTools(server, client.API(), peers, cfg, paths, quality, stream, box)
+		for name := range cfg.ToolTimeouts {
+			if !server.HasTool(name) {
+				slog.Warn("Ignoring timeout for unknown tool", "tool", name)
+			}
+		}
+		slog.Info("Telegram bridge running, serving MCP on stdio")
+		if stream != nil {
+			return serveWithUpdates(ctx, server, stdin, stream.gaps, client.API(), self)
+		}
+		return server.Serve(ctx, stdin)
+	})
 
-// exportSession reads the session file directly and exports it to the specified path
-func exportSession(sessionFilePath, exportPath string) error {
-	// Read the session file
-	sessionFileData, err := ioutil.ReadFile(sessionFilePath)
-	if err != nil {
-		return fmt.Errorf("failed to read session file: %w", err)
+	if self != nil {
+		// Re-export in case the client refreshed the session while running
+		if err := exportSession(sessionFilePath, sharedSessionPath, self.ID, cfg.SessionExportPretty); err != nil {
+			slog.Warn("Failed to export session", "error", err)
+		}
+	} else if err := os.Remove(paths.QRCode); err == nil {
+		slog.Info("Login not completed, removed QR code", "path", paths.QRCode)
 	}
 
-	// Try to parse the session data
-	var sessionData SessionData
-	if err := json.Unmarshal(sessionFileData, &sessionData); err != nil {
-		return fmt.Errorf("failed to parse session data: %w", err)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		fatal("Telegram client run failed", "error", err)
 	}
 
-	// Create exported session data
-	exported := ExportedSession{
-		DC:      sessionData.DC,
-		Addr:    sessionData.Addr,
-		AuthKey: sessionData.AuthKey,
-		UserID:  sessionData.UserID,
-	}
+	slog.Info("Shutdown complete")
+}
 
-	// Export to the shared session file
-	jsonData, err := json.MarshalIndent(exported, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal session data to JSON: %w", err)
-	}
+// printStartupInfo logs the bridge version and a summary of the configuration
+// in use. The api_hash is redacted; the auth key is never logged.
+func printStartupInfo(configFile string, cfg *Config) {
+	slog.Info("Telegram bridge configuration",
+		"version", version,
+		"config_file", configFile,
+		"account", cfg.Account,
+		"store_dir", cfg.StoreDir,
+		"mode", "user account",
+		"api_id", cfg.APIID,
+		"api_hash_suffix", redact(cfg.APIHash),
+	)
+}
 
-	err = os.WriteFile(exportPath, jsonData, 0600)
-	if err != nil {
-		return fmt.Errorf("failed to write shared session file '%s': %w", exportPath, err)
+// redact masks all but the last 4 characters of a secret
+func redact(secret string) string {
+	if len(secret) <= 4 {
+		return strings.Repeat("*", len(secret))
 	}
-
-	log.Printf("Session data successfully exported to %s", exportPath)
-	return nil
+	return strings.Repeat("*", len(secret)-4) + secret[len(secret)-4:]
 }
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		secret string
+		want   string
+	}{
+		{secret: "", want: ""},
+		{secret: "abc", want: "***"},
+		{secret: "abcd", want: "****"},
+		{secret: "0123456789abcdef", want: "************cdef"},
+	}
+	for _, tt := range tests {
+		if got := redact(tt.secret); got != tt.want {
+			t.Errorf("redact(%q) = %q, want %q", tt.secret, got, tt.want)
+		}
+	}
+}
+
+func TestPrintStartupInfoRedactsHash(t *testing.T) {
+	var out bytes.Buffer
+	saved := slog.Default()
+	slog.SetDefault(newLogger(&out, LoggingConfig{Level: slog.LevelDebug, Format: "json"}))
+	defer slog.SetDefault(saved)
+
+	const hash = "0123456789abcdef0123456789abcdef"
+	printStartupInfo("config.ini", &Config{APIID: 12345, APIHash: hash, StoreDir: "store"})
+
+	logged := out.String()
+	if strings.Contains(logged, hash) || strings.Contains(logged, hash[:8]) {
+		t.Fatalf("api_hash leaked into startup info: %s", logged)
+	}
+	for _, want := range []string{`"api_id":12345`, `"api_hash_suffix":"****************************cdef"`, `"store_dir":"store"`, `"version":"dev"`} {
+		if !strings.Contains(logged, want) {
+			t.Errorf("startup info lacks %s: %s", want, logged)
+		}
+	}
+}
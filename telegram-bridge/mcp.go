@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// MCP protocol revision implemented by the server
+const mcpProtocolVersion = "2024-11-05"
+
+// JSON-RPC 2.0 error codes
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+)
+
+// maxMessageSize bounds a single JSON-RPC message read from stdin
+const maxMessageSize = 10 << 20
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("JSON-RPC error %d: %s", e.Code, e.Message)
+}
+
+// invalidParams reports a tool call with missing or malformed arguments
+func invalidParams(format string, args ...interface{}) error {
+	return &rpcError{Code: rpcInvalidParams, Message: fmt.Sprintf(format, args...)}
+}
+
+// ToolHandler executes a tool call with its raw JSON arguments and returns a
+// JSON-serializable result
+type ToolHandler func(ctx context.Context, args json.RawMessage) (interface{}, error)
+
+// Tool is an MCP tool exposed by the server
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"inputSchema"`
+	Handler     ToolHandler     `json:"-"`
+}
+
+// MCPServer is a JSON-RPC 2.0 server speaking MCP over a line-delimited stream
+type MCPServer struct {
+	// ResultFormat is how tool results are rendered: resultFormatJSON
+	// (default) or resultFormatText
+	ResultFormat string
+	// Timeout bounds each tool call unless ToolTimeouts has an entry for the
+	// tool; zero means no limit
+	Timeout      time.Duration
+	ToolTimeouts map[string]time.Duration
+
+	tools map[string]Tool
+	order []string
+
+	outMu sync.Mutex
+	out   *json.Encoder
+}
+
+// NewMCPServer creates a server writing responses to w
+func NewMCPServer(w io.Writer) *MCPServer {
+	return &MCPServer{
+		ResultFormat: resultFormatJSON,
+		tools:        make(map[string]Tool),
+		out:          json.NewEncoder(w),
+	}
+}
+
+// RegisterTool adds a tool; registering the same name twice panics
+func (s *MCPServer) RegisterTool(t Tool) {
+	if _, ok := s.tools[t.Name]; ok {
+		panic(fmt.Sprintf("tool %q registered twice", t.Name))
+	}
+	s.tools[t.Name] = t
+	s.order = append(s.order, t.Name)
+}
+
+// HasTool reports whether a tool named name is registered
+func (s *MCPServer) HasTool(name string) bool {
+	_, ok := s.tools[name]
+	return ok
+}
+
+// toolTimeout returns the time limit of a call to the named tool
+func (s *MCPServer) toolTimeout(name string) time.Duration {
+	if timeout, ok := s.ToolTimeouts[name]; ok {
+		return timeout
+	}
+	return s.Timeout
+}
+
+// Serve reads requests from r until it is closed or ctx is done, handling each
+// request in its own goroutine. It returns nil once r reaches EOF and in-flight
+// requests have been answered.
+func (s *MCPServer) Serve(ctx context.Context, r io.Reader) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- s.serve(ctx, r)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		// The reader cannot be interrupted; it is abandoned at shutdown
+		return ctx.Err()
+	}
+}
+
+func (s *MCPServer) serve(ctx context.Context, r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxMessageSize)
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var req rpcRequest
+		if err := json.Unmarshal(line, &req); err != nil {
+			s.reply(nil, nil, &rpcError{Code: rpcParseError, Message: "parse error"})
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.handle(ctx, req)
+		}()
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read MCP input: %w", err)
+	}
+	return nil
+}
+
+// Notify sends a JSON-RPC notification to the client
+func (s *MCPServer) Notify(method string, params interface{}) error {
+	return s.write(rpcNotification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func (s *MCPServer) handle(ctx context.Context, req rpcRequest) {
+	// Requests without an id are notifications and get no response
+	isNotification := len(req.ID) == 0
+
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		if !isNotification {
+			s.reply(req.ID, nil, &rpcError{Code: rpcInvalidRequest, Message: "invalid request"})
+		}
+		return
+	}
+
+	var (
+		result interface{}
+		err    error
+	)
+	switch req.Method {
+	case "initialize":
+		result = map[string]interface{}{
+			"protocolVersion": mcpProtocolVersion,
+			"capabilities": map[string]interface{}{
+				"tools": map[string]interface{}{},
+			},
+			"serverInfo": map[string]string{
+				"name":    "telegram-bridge",
+				"version": version,
+			},
+		}
+	case "ping":
+		result = map[string]interface{}{}
+	case "tools/list":
+		result = s.listTools()
+	case "tools/call":
+		result, err = s.callTool(ctx, req.Params)
+	default:
+		if isNotification {
+			// e.g. notifications/initialized
+			return
+		}
+		err = &rpcError{Code: rpcMethodNotFound, Message: fmt.Sprintf("method not found: %s", req.Method)}
+	}
+
+	if isNotification {
+		return
+	}
+	var rpcErr *rpcError
+	if err != nil && !errors.As(err, &rpcErr) {
+		rpcErr = &rpcError{Code: rpcInternalError, Message: err.Error()}
+	}
+	s.reply(req.ID, result, rpcErr)
+}
+
+func (s *MCPServer) listTools() map[string]interface{} {
+	tools := make([]Tool, 0, len(s.order))
+	for _, name := range s.order {
+		tools = append(tools, s.tools[name])
+	}
+	return map[string]interface{}{"tools": tools}
+}
+
+type toolCallParams struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+type toolContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type toolResult struct {
+	Content []toolContent `json:"content"`
+	IsError bool          `json:"isError,omitempty"`
+}
+
+// callTool dispatches a tools/call request. Argument errors are returned as
+// JSON-RPC errors; failures while executing the tool are reported in the
+// result with isError set, as MCP expects.
+func (s *MCPServer) callTool(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+	var params toolCallParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, invalidParams("invalid tools/call params: %v", err)
+	}
+	tool, ok := s.tools[params.Name]
+	if !ok {
+		return nil, invalidParams("unknown tool: %s", params.Name)
+	}
+	if len(params.Arguments) == 0 || string(params.Arguments) == "null" {
+		params.Arguments = json.RawMessage("{}")
+	}
+
+	if timeout := s.toolTimeout(params.Name); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	result, err := tool.Handler(ctx, params.Arguments)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("%s timed out after %s: %w", params.Name, s.toolTimeout(params.Name), err)
+	}
+	var rpcErr *rpcError
+	if errors.As(err, &rpcErr) {
+		return nil, rpcErr
+	}
+	if err != nil {
+		slog.Warn("Tool failed", "tool", params.Name, "error", err)
+		return toolResult{
+			Content: []toolContent{{Type: "text", Text: err.Error()}},
+			IsError: true,
+		}, nil
+	}
+
+	text, err := formatResult(result, s.ResultFormat)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s result: %w", params.Name, err)
+	}
+	return toolResult{Content: []toolContent{{Type: "text", Text: text}}}, nil
+}
+
+func (s *MCPServer) reply(id json.RawMessage, result interface{}, rpcErr *rpcError) {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	resp := rpcResponse{JSONRPC: "2.0", ID: id, Error: rpcErr}
+	if rpcErr == nil {
+		resp.Result = result
+	}
+	if err := s.write(resp); err != nil {
+		slog.Error("Failed to write MCP response", "error", err)
+	}
+}
+
+func (s *MCPServer) write(v interface{}) error {
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	return s.out.Encode(v)
+}
+
+// decodeArgs unmarshals tool arguments into v, rejecting unknown fields
+func decodeArgs(args json.RawMessage, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(args))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return invalidParams("invalid arguments: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCallToolTimeouts(t *testing.T) {
+	server := NewMCPServer(&bytes.Buffer{})
+	server.Timeout = time.Minute
+	server.ToolTimeouts = map[string]time.Duration{"download_media": 10 * time.Minute}
+
+	// Each tool reports the time left until its deadline
+	remaining := func(ctx context.Context, _ json.RawMessage) (interface{}, error) {
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			return "none", nil
+		}
+		return time.Until(deadline).Round(time.Minute).String(), nil
+	}
+	server.RegisterTool(Tool{Name: "download_media", Handler: remaining})
+	server.RegisterTool(Tool{Name: "send_message", Handler: remaining})
+
+	for name, want := range map[string]string{"download_media": `"10m0s"`, "send_message": `"1m0s"`} {
+		res, err := server.callTool(context.Background(), json.RawMessage(`{"name":"`+name+`"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := res.(toolResult).Content[0].Text; got != want {
+			t.Errorf("%s ran with %s left, want %s", name, got, want)
+		}
+	}
+}
+
+func TestCallToolTimedOut(t *testing.T) {
+	server := NewMCPServer(&bytes.Buffer{})
+	server.Timeout = time.Minute
+	server.ToolTimeouts = map[string]time.Duration{"slow": 10 * time.Millisecond}
+	server.RegisterTool(Tool{
+		Name: "slow",
+		Handler: func(ctx context.Context, _ json.RawMessage) (interface{}, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	})
+
+	res, err := server.callTool(context.Background(), json.RawMessage(`{"name":"slow"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := res.(toolResult)
+	if !result.IsError || !strings.Contains(result.Content[0].Text, "slow timed out after 10ms") {
+		t.Errorf("result = %+v, want a timeout error", result)
+	}
+}
@@ -0,0 +1,628 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gotd/td/telegram/downloader"
+	"github.com/gotd/td/telegram/message/entity"
+	"github.com/gotd/td/telegram/message/html"
+	"github.com/gotd/td/telegram/uploader"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+// Media kinds reported by tools
+const (
+	mediaPhoto    = "photo"
+	mediaVideo    = "video"
+	mediaAudio    = "audio"
+	mediaDocument = "document"
+)
+
+// mediaKind returns the kind of downloadable media in msg, or "" if it has none
+func mediaKind(msg *tg.Message) string {
+	return messageMediaKind(msg.Media)
+}
+
+// messageMediaKind returns the kind of downloadable media, or "" for media
+// without a file such as locations and polls
+func messageMediaKind(media tg.MessageMediaClass) string {
+	switch m := media.(type) {
+	case *tg.MessageMediaPhoto:
+		if _, ok := m.GetPhoto(); ok {
+			return mediaPhoto
+		}
+	case *tg.MessageMediaDocument:
+		doc, ok := m.GetDocument()
+		if !ok {
+			return ""
+		}
+		if doc, ok := doc.(*tg.Document); ok {
+			switch {
+			case strings.HasPrefix(doc.MimeType, "video/"):
+				return mediaVideo
+			case strings.HasPrefix(doc.MimeType, "audio/"):
+				return mediaAudio
+			}
+		}
+		return mediaDocument
+	}
+	return ""
+}
+
+// albumItem is a media message within an album
+type albumItem struct {
+	MessageID int    `json:"message_id"`
+	Date      int    `json:"date"`
+	Media     string `json:"media"`
+	Caption   string `json:"caption,omitempty"`
+}
+
+// Album groups media messages sent together; single media messages form an
+// album of one without a grouped_id
+type Album struct {
+	GroupedID int64       `json:"grouped_id,omitempty"`
+	Items     []albumItem `json:"items"`
+}
+
+type chatAlbumsArgs struct {
+	Peer  string `json:"peer"`
+	Limit int    `json:"limit"`
+}
+
+func getChatAlbumsTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args chatAlbumsArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.Peer) == "" {
+			return nil, invalidParams("peer is required")
+		}
+		if args.Limit < 0 {
+			return nil, invalidParams("limit must not be negative")
+		}
+		return getChatAlbums(ctx, api, peers, args.Peer, clampLimit(args.Limit, defaultMessageLimit, maxMessageLimit))
+	}
+}
+
+// getChatAlbums reads the last limit messages of peer and groups its media
+// messages into albums, newest first
+func getChatAlbums(ctx context.Context, api *tg.Client, peers *peerResolver, peer string, limit int) ([]Album, error) {
+	input, err := peers.Resolve(ctx, peer)
+	if err != nil {
+		return nil, err
+	}
+	var history tg.MessagesMessagesClass
+	err = withFloodRetry(ctx, func() (err error) {
+		history, err = api.MessagesGetHistory(ctx, &tg.MessagesGetHistoryRequest{Peer: input, Limit: limit})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read messages: %w", err)
+	}
+	modified, ok := history.AsModified()
+	if !ok {
+		return nil, fmt.Errorf("unexpected messages response %T", history)
+	}
+	peers.remember(modified.GetUsers(), modified.GetChats())
+
+	var msgs []*tg.Message
+	for _, m := range modified.GetMessages() {
+		if msg, ok := m.(*tg.Message); ok {
+			msgs = append(msgs, msg)
+		}
+	}
+	return groupAlbums(msgs), nil
+}
+
+// groupAlbums groups consecutive media messages sharing a grouped_id
+func groupAlbums(msgs []*tg.Message) []Album {
+	var albums []Album
+	for _, msg := range msgs {
+		kind := mediaKind(msg)
+		if kind == "" {
+			continue
+		}
+		item := albumItem{MessageID: msg.ID, Date: msg.Date, Media: kind, Caption: msg.Message}
+
+		groupedID, grouped := msg.GetGroupedID()
+		if last := len(albums) - 1; grouped && last >= 0 && albums[last].GroupedID == groupedID {
+			albums[last].Items = append(albums[last].Items, item)
+			continue
+		}
+		album := Album{Items: []albumItem{item}}
+		if grouped {
+			album.GroupedID = groupedID
+		}
+		albums = append(albums, album)
+	}
+	return albums
+}
+
+type downloadMediaArgs struct {
+	Peer      string `json:"peer"`
+	MessageID int    `json:"message_id"`
+	Dest      string `json:"dest"`
+}
+
+type downloadMediaResult struct {
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	MimeType string `json:"mime_type"`
+}
+
+func downloadMediaTool(api *tg.Client, peers *peerResolver, downloads *downloadLog) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args downloadMediaArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.Peer) == "" {
+			return nil, invalidParams("peer is required")
+		}
+		if args.MessageID <= 0 {
+			return nil, invalidParams("message_id must be positive")
+		}
+		if strings.TrimSpace(args.Dest) == "" {
+			return nil, invalidParams("dest is required")
+		}
+		return downloadMedia(ctx, api, peers, args.Peer, args.MessageID, args.Dest, downloads)
+	}
+}
+
+// mediaFile is the file behind a message's photo or document
+type mediaFile struct {
+	Location tg.InputFileLocationClass
+	MimeType string
+}
+
+// messageMediaFile returns the downloadable file of msg; photos are fetched
+// at their largest size
+func messageMediaFile(msg *tg.Message) (mediaFile, error) {
+	return mediaFileOf(msg.Media, fmt.Sprintf("message %d", msg.ID))
+}
+
+// mediaFileOf returns the downloadable file of media attached to what, a
+// message or story named in errors
+func mediaFileOf(media tg.MessageMediaClass, what string) (mediaFile, error) {
+	switch m := media.(type) {
+	case *tg.MessageMediaPhoto:
+		p, ok := m.GetPhoto()
+		if !ok {
+			break
+		}
+		photo, ok := p.AsNotEmpty()
+		if !ok {
+			break
+		}
+		file, ok := photoFile(photo)
+		if !ok {
+			return mediaFile{}, fmt.Errorf("photo in %s has no downloadable size", what)
+		}
+		return file, nil
+	case *tg.MessageMediaDocument:
+		d, ok := m.GetDocument()
+		if !ok {
+			break
+		}
+		doc, ok := d.AsNotEmpty()
+		if !ok {
+			break
+		}
+		return mediaFile{
+			Location: doc.AsInputDocumentFileLocation(),
+			MimeType: doc.MimeType,
+		}, nil
+	}
+	return mediaFile{}, fmt.Errorf("%s has no photo or document to download", what)
+}
+
+// photoFile returns the largest size of photo, false when it has none
+func photoFile(photo *tg.Photo) (mediaFile, bool) {
+	size, ok := largestPhotoSize(photo.Sizes)
+	if !ok {
+		return mediaFile{}, false
+	}
+	return mediaFile{
+		Location: &tg.InputPhotoFileLocation{
+			ID:            photo.ID,
+			AccessHash:    photo.AccessHash,
+			FileReference: photo.FileReference,
+			ThumbSize:     size,
+		},
+		MimeType: "image/jpeg",
+	}, true
+}
+
+// largestPhotoSize returns the type of the biggest full-size photo variant
+func largestPhotoSize(sizes []tg.PhotoSizeClass) (string, bool) {
+	var (
+		best     string
+		bestArea int
+	)
+	for _, s := range sizes {
+		var typ string
+		var area int
+		switch s := s.(type) {
+		case *tg.PhotoSize:
+			typ, area = s.Type, s.W*s.H
+		case *tg.PhotoSizeProgressive:
+			typ, area = s.Type, s.W*s.H
+		default:
+			// Stripped and cached sizes are inline thumbnails
+			continue
+		}
+		if best == "" || area > bestArea {
+			best, bestArea = typ, area
+		}
+	}
+	return best, best != ""
+}
+
+// downloadMedia saves the photo or document of a message to dest, logging
+// it in downloads
+func downloadMedia(ctx context.Context, api *tg.Client, peers *peerResolver, peer string, id int, dest string, downloads *downloadLog) (downloadMediaResult, error) {
+	msg, err := getMessage(ctx, api, peers, peer, id)
+	if err != nil {
+		return downloadMediaResult{}, err
+	}
+	res, err := saveMessageMedia(ctx, api, peers, peer, msg, dest)
+	if err != nil {
+		return downloadMediaResult{}, fmt.Errorf("failed to download media of message %d: %w", id, err)
+	}
+	peerID, _ := markedPeerID(msg.PeerID)
+	downloads.record(res, peerID, downloadMessage, int64(id))
+	return res, nil
+}
+
+// saveMessageMedia downloads the photo or document of msg, a message of
+// peer, to dest. File references expire after a while, so on
+// FILE_REFERENCE_EXPIRED the message is fetched again for a fresh one.
+func saveMessageMedia(ctx context.Context, api *tg.Client, peers *peerResolver, peer string, msg *tg.Message, dest string) (downloadMediaResult, error) {
+	file, err := messageMediaFile(msg)
+	if err != nil {
+		return downloadMediaResult{}, err
+	}
+	res, err := saveMediaFile(ctx, api, file, dest)
+	if !tgerr.Is(err, "FILE_REFERENCE_EXPIRED") {
+		return res, err
+	}
+	fresh, err := getMessage(ctx, api, peers, peer, msg.ID)
+	if err != nil {
+		return downloadMediaResult{}, err
+	}
+	if file, err = messageMediaFile(fresh); err != nil {
+		return downloadMediaResult{}, err
+	}
+	return saveMediaFile(ctx, api, file, dest)
+}
+
+// saveMediaFile downloads file to dest. The file is streamed to a temporary
+// file next to dest and renamed into place once complete, so a failed
+// download never leaves a truncated file behind.
+func saveMediaFile(ctx context.Context, api *tg.Client, file mediaFile, dest string) (downloadMediaResult, error) {
+	tmp := dest + ".part"
+	defer os.Remove(tmp)
+
+	var size int64
+	err := withFloodRetry(ctx, func() (err error) {
+		size, err = downloadToFile(ctx, api, file.Location, tmp)
+		return err
+	})
+	if err != nil {
+		return downloadMediaResult{}, err
+	}
+
+	mimeType := file.MimeType
+	if mimeType == "" {
+		if mimeType, err = sniffMimeType(tmp); err != nil {
+			return downloadMediaResult{}, err
+		}
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		return downloadMediaResult{}, fmt.Errorf("failed to move download to %s: %w", dest, err)
+	}
+	return downloadMediaResult{Path: dest, Size: size, MimeType: mimeType}, nil
+}
+
+// downloadToFile streams location into a freshly truncated file at path and
+// returns the number of bytes written
+func downloadToFile(ctx context.Context, api *tg.Client, location tg.InputFileLocationClass, path string) (int64, error) {
+	f, err := os.Create(filepath.Clean(path))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	w := &countingWriter{w: f}
+	_, err = downloader.NewDownloader().Download(api, location).Stream(ctx, w)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return w.n, err
+}
+
+// sniffMimeType detects the MIME type of a file from its first bytes
+func sniffMimeType(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return http.DetectContentType(head[:n]), nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+type uploadFileArgs struct {
+	Peer string `json:"peer"`
+	Path string `json:"path"`
+	// Handle sends a file uploaded earlier with upload_once instead of Path
+	Handle  string `json:"handle"`
+	Caption string `json:"caption"`
+}
+
+func uploadFileTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args uploadFileArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.Peer) == "" {
+			return nil, invalidParams("peer is required")
+		}
+		hasPath, hasHandle := strings.TrimSpace(args.Path) != "", strings.TrimSpace(args.Handle) != ""
+		if hasPath == hasHandle {
+			return nil, invalidParams("give exactly one of path and handle")
+		}
+
+		var (
+			id  int
+			err error
+		)
+		if hasHandle {
+			handle, perr := parseFileHandle(args.Handle)
+			if perr != nil {
+				return nil, invalidParams(perr.Error())
+			}
+			id, err = sendFileHandle(ctx, api, peers, args.Peer, handle, args.Caption)
+		} else {
+			id, err = uploadFile(ctx, api, peers, args.Peer, args.Path, args.Caption)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return sendMessageResult{MessageID: id}, nil
+	}
+}
+
+// uploadFile uploads a local file and sends it to peer as a document named
+// after the file
+func uploadFile(ctx context.Context, api *tg.Client, peers *peerResolver, peer, path, caption string) (int, error) {
+	input, err := peers.Resolve(ctx, peer)
+	if err != nil {
+		return 0, err
+	}
+	file, err := uploadLocalFile(ctx, api, path)
+	if err != nil {
+		return 0, err
+	}
+	name := filepath.Base(path)
+	return sendMedia(ctx, api, input, uploadedDocument(file, name), caption, name)
+}
+
+// uploadedDocument is the media sending an uploaded file as a document
+// named name
+func uploadedDocument(file tg.InputFileClass, name string) *tg.InputMediaUploadedDocument {
+	return &tg.InputMediaUploadedDocument{
+		ForceFile:  true,
+		File:       file,
+		MimeType:   mimeTypeByName(name),
+		Attributes: []tg.DocumentAttributeClass{&tg.DocumentAttributeFilename{FileName: name}},
+	}
+}
+
+// sendMedia sends media with caption to peer and returns the message ID;
+// what names the file in errors
+func sendMedia(ctx context.Context, api *tg.Client, peer tg.InputPeerClass, media tg.InputMediaClass, caption, what string) (int, error) {
+	randomID, err := randomInt64()
+	if err != nil {
+		return 0, err
+	}
+	var updates tg.UpdatesClass
+	err = withFloodRetry(ctx, func() (err error) {
+		updates, err = api.MessagesSendMedia(ctx, &tg.MessagesSendMediaRequest{
+			Peer:     peer,
+			Media:    media,
+			Message:  caption,
+			RandomID: randomID,
+		})
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to send %s: %w", what, err)
+	}
+	return sentMessageID(updates)
+}
+
+// uploadLocalFile uploads the file at path for use in a later request. The
+// uploader switches to big-file parts on its own for files over 10 MB, which
+// is why the size is passed along with the reader.
+func uploadLocalFile(ctx context.Context, api *tg.Client, path string) (tg.InputFileClass, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", path)
+	}
+
+	var file tg.InputFileClass
+	err = withFloodRetry(ctx, func() (err error) {
+		// A retry starts the upload over from the beginning of the file
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		file, err = uploader.NewUploader(api).Upload(ctx, uploader.NewUpload(filepath.Base(path), f, info.Size()))
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload %s: %w", path, err)
+	}
+	return file, nil
+}
+
+// mimeTypeByName guesses a MIME type from a file extension
+func mimeTypeByName(name string) string {
+	if t := mime.TypeByExtension(filepath.Ext(name)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+type forwardWithCaptionArgs struct {
+	FromPeer  string `json:"from_peer"`
+	ToPeer    string `json:"to_peer"`
+	MessageID int    `json:"message_id"`
+	Caption   string `json:"caption"`
+	// ParseMode is "html" to read formatting from Caption, or empty for
+	// plain text
+	ParseMode string `json:"parse_mode"`
+}
+
+func forwardWithCaptionTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args forwardWithCaptionArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.FromPeer) == "" {
+			return nil, invalidParams("from_peer is required")
+		}
+		if strings.TrimSpace(args.ToPeer) == "" {
+			return nil, invalidParams("to_peer is required")
+		}
+		if args.MessageID <= 0 {
+			return nil, invalidParams("message_id must be positive")
+		}
+		if args.ParseMode != "" && args.ParseMode != "html" {
+			return nil, invalidParams("parse_mode must be \"html\" or empty")
+		}
+
+		caption, entities, err := parseCaption(ctx, peers, args.Caption, args.ParseMode)
+		if err != nil {
+			return nil, invalidParams("invalid caption: %v", err)
+		}
+		id, err := forwardWithCaption(ctx, api, peers, args.FromPeer, args.ToPeer, args.MessageID, caption, entities)
+		if err != nil {
+			return nil, err
+		}
+		return sendMessageResult{MessageID: id}, nil
+	}
+}
+
+// parseCaption turns a caption written in parseMode into text and entities.
+// Mentions of users by ID are resolved through peers.
+func parseCaption(ctx context.Context, peers *peerResolver, caption, parseMode string) (string, []tg.MessageEntityClass, error) {
+	if parseMode == "" {
+		return caption, nil, nil
+	}
+	var b entity.Builder
+	err := html.HTML(strings.NewReader(caption), &b, html.Options{
+		UserResolver: func(id int64) (tg.InputUserClass, error) {
+			return peers.ResolveUser(ctx, strconv.FormatInt(id, 10))
+		},
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	text, entities := b.Complete()
+	return text, entities, nil
+}
+
+// forwardWithCaption copies the photo or document of a message to toPeer
+// with a new caption. A real forward keeps the original caption, so the
+// media is sent again by reference instead, without re-uploading it.
+func forwardWithCaption(ctx context.Context, api *tg.Client, peers *peerResolver, fromPeer, toPeer string, id int, caption string, entities []tg.MessageEntityClass) (int, error) {
+	msg, err := getMessage(ctx, api, peers, fromPeer, id)
+	if err != nil {
+		return 0, err
+	}
+	media, err := inputMediaOf(msg)
+	if err != nil {
+		return 0, err
+	}
+	input, err := peers.Resolve(ctx, toPeer)
+	if err != nil {
+		return 0, err
+	}
+
+	randomID, err := randomInt64()
+	if err != nil {
+		return 0, err
+	}
+	var updates tg.UpdatesClass
+	err = withFloodRetry(ctx, func() (err error) {
+		updates, err = api.MessagesSendMedia(ctx, &tg.MessagesSendMediaRequest{
+			Peer:     input,
+			Media:    media,
+			Message:  caption,
+			Entities: entities,
+			RandomID: randomID,
+		})
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to send media: %w", err)
+	}
+	return sentMessageID(updates)
+}
+
+// inputMediaOf references the photo or document of msg, with its file
+// reference, for sending it again
+func inputMediaOf(msg *tg.Message) (tg.InputMediaClass, error) {
+	switch m := msg.Media.(type) {
+	case *tg.MessageMediaPhoto:
+		if p, ok := m.GetPhoto(); ok {
+			if photo, ok := p.AsNotEmpty(); ok {
+				return &tg.InputMediaPhoto{ID: photo.AsInput()}, nil
+			}
+		}
+	case *tg.MessageMediaDocument:
+		if d, ok := m.GetDocument(); ok {
+			if doc, ok := d.AsNotEmpty(); ok {
+				return &tg.InputMediaDocument{ID: doc.AsInput()}, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("message %d has no photo or document to forward", msg.ID)
+}
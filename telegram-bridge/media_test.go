@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+)
+
+// photoMessage returns a photo message, part of album groupedID unless it
+// is 0
+func photoMessage(id int, groupedID int64, caption string) *tg.Message {
+	media := &tg.MessageMediaPhoto{}
+	media.SetPhoto(&tg.Photo{ID: int64(id) * 100, AccessHash: 1, FileReference: []byte{1}})
+	msg := &tg.Message{ID: id, Date: 1000 + id, Message: caption, Media: media, PeerID: &tg.PeerUser{UserID: 10}}
+	if groupedID != 0 {
+		msg.SetGroupedID(groupedID)
+	}
+	return msg
+}
+
+// documentMessage returns a document message with mimeType
+func documentMessage(id int, groupedID int64, mimeType string) *tg.Message {
+	media := &tg.MessageMediaDocument{}
+	media.SetDocument(&tg.Document{ID: int64(id) * 100, AccessHash: 1, MimeType: mimeType, FileReference: []byte{1}})
+	msg := &tg.Message{ID: id, Date: 1000 + id, Media: media, PeerID: &tg.PeerUser{UserID: 10}}
+	if groupedID != 0 {
+		msg.SetGroupedID(groupedID)
+	}
+	return msg
+}
+
+func TestGroupAlbums(t *testing.T) {
+	msgs := []*tg.Message{
+		photoMessage(9, 0, "single"),
+		photoMessage(8, 77, "album caption"),
+		documentMessage(7, 77, "video/mp4"),
+		photoMessage(6, 77, ""),
+		{ID: 5, Message: "text only"},
+		documentMessage(4, 0, "audio/ogg"),
+		// Same grouped_id again after another message starts a new album
+		photoMessage(3, 77, ""),
+		documentMessage(2, 88, "application/pdf"),
+		documentMessage(1, 88, "application/zip"),
+	}
+
+	type album struct {
+		groupedID int64
+		ids       []int
+		media     []string
+	}
+	want := []album{
+		{ids: []int{9}, media: []string{mediaPhoto}},
+		{groupedID: 77, ids: []int{8, 7, 6}, media: []string{mediaPhoto, mediaVideo, mediaPhoto}},
+		{ids: []int{4}, media: []string{mediaAudio}},
+		{groupedID: 77, ids: []int{3}, media: []string{mediaPhoto}},
+		{groupedID: 88, ids: []int{2, 1}, media: []string{mediaDocument, mediaDocument}},
+	}
+
+	got := groupAlbums(msgs)
+	if len(got) != len(want) {
+		t.Fatalf("got %d albums, want %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		if got[i].GroupedID != w.groupedID || len(got[i].Items) != len(w.ids) {
+			t.Errorf("album %d = %+v, want %+v", i, got[i], w)
+			continue
+		}
+		for j, item := range got[i].Items {
+			if item.MessageID != w.ids[j] || item.Media != w.media[j] {
+				t.Errorf("album %d item %d = %+v, want id %d %s", i, j, item, w.ids[j], w.media[j])
+			}
+		}
+	}
+	if got[1].Items[0].Caption != "album caption" {
+		t.Errorf("album caption = %q", got[1].Items[0].Caption)
+	}
+}
+
+func TestForwardWithCaption(t *testing.T) {
+	tests := []struct {
+		name         string
+		msg          *tg.Message
+		caption      string
+		parseMode    string
+		wantText     string
+		wantEntities int
+		wantErr      bool
+	}{
+		{name: "photo plain caption", msg: photoMessage(5, 0, "old caption"), caption: "new caption", wantText: "new caption"},
+		{name: "document html caption", msg: documentMessage(5, 0, "application/pdf"), caption: "<b>new</b> caption", parseMode: "html", wantText: "new caption", wantEntities: 1},
+		{name: "no media", msg: &tg.Message{ID: 5, Message: "text", PeerID: &tg.PeerUser{UserID: 10}}, caption: "x", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inv, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+				switch input.(type) {
+				case *tg.MessagesGetMessagesRequest:
+					return &tg.MessagesMessages{Messages: []tg.MessageClass{tt.msg}}, nil
+				case *tg.MessagesSendMediaRequest:
+					return &tg.UpdateShortSentMessage{ID: 42}, nil
+				}
+				return nil, nil
+			})
+			peers.storeUser(&tg.User{ID: 10, AccessHash: 1})
+			peers.storeUser(&tg.User{ID: 11, AccessHash: 2})
+
+			caption, entities, err := parseCaption(context.Background(), peers, tt.caption, tt.parseMode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			id, err := forwardWithCaption(context.Background(), api, peers, "10", "11", 5, caption, entities)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("forwardWithCaption() succeeded, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if id != 42 {
+				t.Errorf("message id = %d, want 42", id)
+			}
+
+			sent := requests[*tg.MessagesSendMediaRequest](inv)
+			if len(sent) != 1 {
+				t.Fatalf("got %d send requests, want 1", len(sent))
+			}
+			req := sent[0]
+			if req.Message != tt.wantText || len(req.Entities) != tt.wantEntities {
+				t.Errorf("sent caption %q with %d entities, want %q with %d", req.Message, len(req.Entities), tt.wantText, tt.wantEntities)
+			}
+			if to, ok := req.Peer.(*tg.InputPeerUser); !ok || to.UserID != 11 {
+				t.Errorf("sent to %v, want user 11", req.Peer)
+			}
+			// The media is sent by reference, never uploaded again
+			switch m := req.Media.(type) {
+			case *tg.InputMediaPhoto:
+				if p := m.ID.(*tg.InputPhoto); p.ID != 500 || len(p.FileReference) == 0 {
+					t.Errorf("photo reference = %+v", p)
+				}
+			case *tg.InputMediaDocument:
+				if d := m.ID.(*tg.InputDocument); d.ID != 500 || len(d.FileReference) == 0 {
+					t.Errorf("document reference = %+v", d)
+				}
+			default:
+				t.Errorf("sent media %T", req.Media)
+			}
+			if len(requests[*tg.UploadSaveFilePartRequest](inv)) != 0 {
+				t.Error("media was uploaded again")
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+// Member listing limits; supergroups are read in pages of memberPageSize
+const (
+	defaultMemberLimit = 200
+	maxMemberLimit     = 10000
+	memberPageSize     = 200
+)
+
+// Member roles reported by get_chat_members
+const (
+	roleCreator = "creator"
+	roleAdmin   = "admin"
+	roleMember  = "member"
+)
+
+// MemberInfo is a member of a group or channel with their last-seen status
+type MemberInfo struct {
+	UserID   int64  `json:"user_id"`
+	Name     string `json:"name"`
+	Username string `json:"username,omitempty"`
+	Bot      bool   `json:"bot,omitempty"`
+	Role     string `json:"role"`
+	// Status is a get_last_seen bucket; LastSeen is set when the user shares
+	// the exact time
+	Status   string `json:"status"`
+	LastSeen int64  `json:"last_seen,omitempty"`
+}
+
+type chatMembersArgs struct {
+	Peer  string `json:"peer"`
+	Limit int    `json:"limit"`
+}
+
+type chatMembersResult struct {
+	Members []MemberInfo `json:"members"`
+}
+
+func chatMembersTool(api *tg.Client, peers *peerResolver, onlineOnly bool) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args chatMembersArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.Peer) == "" {
+			return nil, invalidParams("peer is required")
+		}
+		limit := clampLimit(args.Limit, defaultMemberLimit, maxMemberLimit)
+		var (
+			members []MemberInfo
+			err     error
+		)
+		if onlineOnly {
+			members, err = getOnlineMembers(ctx, api, peers, args.Peer, limit)
+		} else {
+			members, err = getChatMembers(ctx, api, peers, args.Peer, limit)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return chatMembersResult{Members: members}, nil
+	}
+}
+
+// getOnlineMembers returns the members of peer that are online now, out of
+// the first limit members
+func getOnlineMembers(ctx context.Context, api *tg.Client, peers *peerResolver, peer string, limit int) ([]MemberInfo, error) {
+	members, err := getChatMembers(ctx, api, peers, peer, limit)
+	if err != nil {
+		return nil, err
+	}
+	return filterMembersByStatus(members, seenOnline), nil
+}
+
+// filterMembersByStatus returns the members with the given last-seen status
+func filterMembersByStatus(members []MemberInfo, status string) []MemberInfo {
+	result := []MemberInfo{}
+	for _, m := range members {
+		if m.Status == status {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+// getChatMembers returns up to limit members of a group or channel, most
+// recently joined first for supergroups and channels. Listing the members
+// of a broadcast channel needs admin rights.
+func getChatMembers(ctx context.Context, api *tg.Client, peers *peerResolver, peer string, limit int) ([]MemberInfo, error) {
+	p, err := peers.resolve(ctx, peer)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	switch p.Type {
+	case peerChat:
+		return basicGroupMembers(ctx, api, peers, p.ID, limit, now)
+	case peerChannel:
+		return channelMembers(ctx, api, peers, &tg.InputChannel{ChannelID: p.ID, AccessHash: p.AccessHash}, peer, limit, now)
+	}
+	return nil, fmt.Errorf("%s is a user; members are listed for groups and channels", peer)
+}
+
+func basicGroupMembers(ctx context.Context, api *tg.Client, peers *peerResolver, chatID int64, limit int, now time.Time) ([]MemberInfo, error) {
+	var res *tg.MessagesChatFull
+	err := withFloodRetry(ctx, func() (err error) {
+		res, err = api.MessagesGetFullChat(ctx, chatID)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group info: %w", err)
+	}
+	peers.remember(res.Users, res.Chats)
+	full, ok := res.FullChat.(*tg.ChatFull)
+	if !ok {
+		return nil, fmt.Errorf("unexpected full chat %T", res.FullChat)
+	}
+	list, ok := full.Participants.AsNotForbidden()
+	if !ok {
+		return nil, fmt.Errorf("the member list of this group is hidden from you")
+	}
+
+	users := usersByID(res.Users)
+	result := []MemberInfo{}
+	for _, m := range list.Participants {
+		if len(result) == limit {
+			break
+		}
+		role := roleMember
+		switch m.(type) {
+		case *tg.ChatParticipantCreator:
+			role = roleCreator
+		case *tg.ChatParticipantAdmin:
+			role = roleAdmin
+		}
+		if u, ok := users[m.GetUserID()]; ok {
+			result = append(result, newMemberInfo(u, role, now))
+		}
+	}
+	return result, nil
+}
+
+func channelMembers(ctx context.Context, api *tg.Client, peers *peerResolver, channel *tg.InputChannel, peer string, limit int, now time.Time) ([]MemberInfo, error) {
+	result := []MemberInfo{}
+	for offset := 0; len(result) < limit; {
+		var res tg.ChannelsChannelParticipantsClass
+		err := withFloodRetry(ctx, func() (err error) {
+			res, err = api.ChannelsGetParticipants(ctx, &tg.ChannelsGetParticipantsRequest{
+				Channel: channel,
+				Filter:  &tg.ChannelParticipantsRecent{},
+				Offset:  offset,
+				Limit:   min(memberPageSize, limit-len(result)),
+			})
+			return err
+		})
+		switch {
+		case tgerr.Is(err, "CHAT_ADMIN_REQUIRED"):
+			return nil, fmt.Errorf("listing the members of %s needs admin rights: %w", peer, err)
+		case err != nil:
+			return nil, fmt.Errorf("failed to get members: %w", err)
+		}
+		page, ok := res.(*tg.ChannelsChannelParticipants)
+		if !ok {
+			// Not modified is only returned for a non-zero hash
+			return nil, fmt.Errorf("unexpected participants response %T", res)
+		}
+		peers.remember(page.Users, page.Chats)
+
+		users := usersByID(page.Users)
+		for _, m := range page.Participants {
+			userID, role, ok := channelMember(m)
+			if !ok {
+				continue
+			}
+			if u, ok := users[userID]; ok && len(result) < limit {
+				result = append(result, newMemberInfo(u, role, now))
+			}
+		}
+		offset += len(page.Participants)
+		if len(page.Participants) == 0 || offset >= page.Count {
+			break
+		}
+	}
+	return result, nil
+}
+
+// channelMember returns the user and role of a participant; banned and
+// left participants are not members
+func channelMember(m tg.ChannelParticipantClass) (int64, string, bool) {
+	switch m := m.(type) {
+	case *tg.ChannelParticipant:
+		return m.UserID, roleMember, true
+	case *tg.ChannelParticipantSelf:
+		return m.UserID, roleMember, true
+	case *tg.ChannelParticipantCreator:
+		return m.UserID, roleCreator, true
+	case *tg.ChannelParticipantAdmin:
+		return m.UserID, roleAdmin, true
+	}
+	return 0, "", false
+}
+
+func usersByID(users []tg.UserClass) map[int64]*tg.User {
+	result := make(map[int64]*tg.User, len(users))
+	for _, u := range users {
+		if u, ok := u.(*tg.User); ok {
+			result[u.ID] = u
+		}
+	}
+	return result
+}
+
+func newMemberInfo(u *tg.User, role string, now time.Time) MemberInfo {
+	m := MemberInfo{
+		UserID:   u.ID,
+		Name:     strings.TrimSpace(u.FirstName + " " + u.LastName),
+		Username: u.Username,
+		Bot:      u.Bot,
+		Role:     role,
+	}
+	var seen time.Time
+	seen, m.Status = memberStatus(u.Status, now)
+	if !seen.IsZero() {
+		m.LastSeen = seen.Unix()
+	}
+	return m
+}
+
+// memberStatus is lastSeen, except that an online status past its expiry
+// counts as offline since then. Statuses from a member list may be stale,
+// unlike the fresh ones get_last_seen fetches.
+func memberStatus(status tg.UserStatusClass, now time.Time) (time.Time, string) {
+	if s, ok := status.(*tg.UserStatusOnline); ok && int64(s.Expires) < now.Unix() {
+		return time.Unix(int64(s.Expires), 0), seenOffline
+	}
+	return lastSeen(status, now)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+func TestMemberStatus(t *testing.T) {
+	now := time.Unix(1000, 0)
+	tests := []struct {
+		status tg.UserStatusClass
+		want   string
+	}{
+		{&tg.UserStatusOnline{Expires: 1100}, seenOnline},
+		{&tg.UserStatusOnline{Expires: 900}, seenOffline},
+		{&tg.UserStatusOffline{WasOnline: 800}, seenOffline},
+		{&tg.UserStatusRecently{}, seenRecently},
+		{nil, seenHidden},
+	}
+	for _, tt := range tests {
+		if _, got := memberStatus(tt.status, now); got != tt.want {
+			t.Errorf("memberStatus(%v) = %q, want %q", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestFilterMembersByStatus(t *testing.T) {
+	members := []MemberInfo{
+		{UserID: 1, Status: seenOnline},
+		{UserID: 2, Status: seenOffline},
+		{UserID: 3, Status: seenRecently},
+		{UserID: 4, Status: seenOnline},
+	}
+	online := filterMembersByStatus(members, seenOnline)
+	if len(online) != 2 || online[0].UserID != 1 || online[1].UserID != 4 {
+		t.Errorf("online = %+v", online)
+	}
+	if none := filterMembersByStatus(members, seenLastMonth); none == nil || len(none) != 0 {
+		t.Errorf("filter = %#v, want empty list", none)
+	}
+}
+
+func onlineUser(id int64, online bool) *tg.User {
+	u := &tg.User{ID: id, AccessHash: id, FirstName: "User"}
+	if online {
+		u.SetStatus(&tg.UserStatusOnline{Expires: int(time.Now().Add(time.Minute).Unix())})
+	} else {
+		u.SetStatus(&tg.UserStatusOffline{WasOnline: 100})
+	}
+	return u
+}
+
+func TestGetOnlineMembersSupergroup(t *testing.T) {
+	pages := [][]tg.ChannelParticipantClass{
+		{&tg.ChannelParticipantCreator{UserID: 1}, &tg.ChannelParticipant{UserID: 2}},
+		{&tg.ChannelParticipantAdmin{UserID: 3}, &tg.ChannelParticipantBanned{Peer: &tg.PeerUser{UserID: 4}, BannedRights: tg.ChatBannedRights{}}},
+	}
+	users := []tg.UserClass{onlineUser(1, true), onlineUser(2, false), onlineUser(3, true), onlineUser(4, true)}
+	inv, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		req, ok := input.(*tg.ChannelsGetParticipantsRequest)
+		if !ok {
+			return nil, nil
+		}
+		page := pages[req.Offset/2]
+		return &tg.ChannelsChannelParticipants{Count: 4, Participants: page, Users: users}, nil
+	})
+	peers.storeChannel(&tg.Channel{ID: 30, AccessHash: 3})
+
+	online, err := getOnlineMembers(context.Background(), api, peers, "-1000000000030", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(online) != 2 || online[0].UserID != 1 || online[0].Role != roleCreator ||
+		online[1].UserID != 3 || online[1].Role != roleAdmin {
+		t.Errorf("online = %+v", online)
+	}
+	if reqs := requests[*tg.ChannelsGetParticipantsRequest](inv); len(reqs) != 2 || reqs[1].Offset != 2 {
+		t.Errorf("requests = %+v, want two pages", reqs)
+	}
+}
+
+func TestGetChatMembersBasicGroup(t *testing.T) {
+	_, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		if _, ok := input.(*tg.MessagesGetFullChatRequest); ok {
+			return &tg.MessagesChatFull{
+				FullChat: &tg.ChatFull{ID: 20, Participants: &tg.ChatParticipants{ChatID: 20, Participants: []tg.ChatParticipantClass{
+					&tg.ChatParticipantCreator{UserID: 1},
+					&tg.ChatParticipant{UserID: 2},
+				}}},
+				Users: []tg.UserClass{onlineUser(1, false), onlineUser(2, true)},
+			}, nil
+		}
+		return nil, nil
+	})
+	members, err := getChatMembers(context.Background(), api, peers, "-20", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 1 || members[0].UserID != 1 || members[0].Role != roleCreator ||
+		members[0].Status != seenOffline || members[0].LastSeen != 100 {
+		t.Errorf("members = %+v, want the creator only", members)
+	}
+}
+
+func TestGetChatMembersAdminRequired(t *testing.T) {
+	_, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		return nil, tgerr.New(400, "CHAT_ADMIN_REQUIRED")
+	})
+	peers.storeChannel(&tg.Channel{ID: 30, AccessHash: 3})
+	_, err := getChatMembers(context.Background(), api, peers, "-1000000000030", 10)
+	if err == nil || !strings.Contains(err.Error(), "needs admin rights") {
+		t.Errorf("err = %v", err)
+	}
+}
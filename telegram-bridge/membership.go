@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gotd/td/tg"
+)
+
+// membershipLogSize is how many membership events are kept per chat; older
+// events are dropped first
+const membershipLogSize = 100
+
+// Membership event actions
+const (
+	memberJoined  = "joined"
+	memberAdded   = "added"
+	memberLeft    = "left"
+	memberRemoved = "removed"
+)
+
+// MembershipEvent is a user joining or leaving a group, seen on the update
+// stream
+type MembershipEvent struct {
+	PeerID int64  `json:"peer_id"`
+	UserID int64  `json:"user_id"`
+	Action string `json:"action"`
+	Date   int    `json:"date"`
+	// ActorID is who added or removed the user, set when it was not the
+	// user themselves
+	ActorID int64 `json:"actor_id,omitempty"`
+}
+
+// joins reports whether the event adds the user to the chat
+func (e MembershipEvent) joins() bool {
+	return e.Action == memberJoined || e.Action == memberAdded
+}
+
+// membershipLog keeps the recent membership events of each chat in a ring
+// buffer, together with the last member list seen for basic groups so list
+// updates can be turned into events
+type membershipLog struct {
+	mu      sync.Mutex
+	events  map[int64][]MembershipEvent // keyed by marked chat ID, oldest first
+	members map[int64]map[int64]bool
+}
+
+func newMembershipLog() *membershipLog {
+	return &membershipLog{
+		events:  make(map[int64][]MembershipEvent),
+		members: make(map[int64]map[int64]bool),
+	}
+}
+
+// recordMessage records the joins and leaves announced by a service message
+func (l *membershipLog) recordMessage(m tg.MessageClass) {
+	msg, ok := m.(*tg.MessageService)
+	if !ok {
+		return
+	}
+	peerID, err := markedPeerID(msg.PeerID)
+	if err != nil {
+		return
+	}
+	var actorID int64
+	if from, ok := msg.GetFromID(); ok {
+		actorID, _ = markedPeerID(from)
+	}
+
+	event := func(userID int64, action string) MembershipEvent {
+		e := MembershipEvent{PeerID: peerID, UserID: userID, Action: action, Date: msg.Date}
+		switch {
+		case userID == actorID && action == memberAdded:
+			// Adding yourself is joining, e.g. through a public username
+			e.Action = memberJoined
+		case userID == actorID && action == memberRemoved:
+			e.Action = memberLeft
+		case action == memberAdded || action == memberRemoved:
+			e.ActorID = actorID
+		}
+		return e
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	switch a := msg.Action.(type) {
+	case *tg.MessageActionChatAddUser:
+		for _, id := range a.Users {
+			l.append(event(id, memberAdded))
+		}
+	case *tg.MessageActionChatDeleteUser:
+		l.append(event(a.UserID, memberRemoved))
+	case *tg.MessageActionChatJoinedByLink, *tg.MessageActionChatJoinedByRequest:
+		l.append(event(actorID, memberJoined))
+	}
+}
+
+// recordParticipants compares a basic group's member list with the previous
+// one and records the difference. The first list seen for a chat only sets
+// the baseline. Changes already announced by a service message are skipped.
+func (l *membershipLog) recordParticipants(p tg.ChatParticipantsClass, now time.Time) {
+	list, ok := p.AsNotForbidden()
+	if !ok {
+		return
+	}
+	peerID := cachedPeer{Type: peerChat, ID: list.ChatID}.MarkedID()
+	current := make(map[int64]bool, len(list.Participants))
+	for _, m := range list.Participants {
+		current[m.GetUserID()] = true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	previous, known := l.members[peerID]
+	l.members[peerID] = current
+	if !known {
+		return
+	}
+	date := int(now.Unix())
+	for id := range current {
+		if !previous[id] && !l.lastJoins(peerID, id, true) {
+			l.append(MembershipEvent{PeerID: peerID, UserID: id, Action: memberJoined, Date: date})
+		}
+	}
+	for id := range previous {
+		if !current[id] && !l.lastJoins(peerID, id, false) {
+			l.append(MembershipEvent{PeerID: peerID, UserID: id, Action: memberLeft, Date: date})
+		}
+	}
+}
+
+// lastJoins reports whether the latest event of userID in the chat exists
+// and matches joins
+func (l *membershipLog) lastJoins(peerID, userID int64, joins bool) bool {
+	events := l.events[peerID]
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].UserID == userID {
+			return events[i].joins() == joins
+		}
+	}
+	return false
+}
+
+func (l *membershipLog) append(e MembershipEvent) {
+	events := append(l.events[e.PeerID], e)
+	if len(events) > membershipLogSize {
+		events = events[len(events)-membershipLogSize:]
+	}
+	l.events[e.PeerID] = events
+}
+
+// recent returns the logged events of a chat, newest first
+func (l *membershipLog) recent(peerID int64) []MembershipEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	events := l.events[peerID]
+	result := make([]MembershipEvent, 0, len(events))
+	for i := len(events) - 1; i >= 0; i-- {
+		result = append(result, events[i])
+	}
+	return result
+}
+
+func getRecentMembershipEventsTool(stream *updateStream, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args peerArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.Peer) == "" {
+			return nil, invalidParams("peer is required")
+		}
+		if stream == nil {
+			return nil, fmt.Errorf("membership events need the update stream; set stream_updates = true under [bridge]")
+		}
+		return getRecentMembershipEvents(ctx, stream.members, peers, args.Peer)
+	}
+}
+
+// getRecentMembershipEvents returns the joins and leaves of peer seen since
+// the bridge started, newest first
+func getRecentMembershipEvents(ctx context.Context, members *membershipLog, peers *peerResolver, peer string) ([]MembershipEvent, error) {
+	p, err := peers.resolve(ctx, peer)
+	if err != nil {
+		return nil, err
+	}
+	if p.Type == peerUser {
+		return nil, fmt.Errorf("%s is a user; membership events are tracked for groups", peer)
+	}
+	return members.recent(p.MarkedID()), nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gotd/td/tg"
+)
+
+// serviceMessage returns a service message in basic group 20 sent by from
+func serviceMessage(from int64, date int, action tg.MessageActionClass) *tg.MessageService {
+	msg := &tg.MessageService{PeerID: &tg.PeerChat{ChatID: 20}, Date: date, Action: action}
+	msg.SetFromID(&tg.PeerUser{UserID: from})
+	return msg
+}
+
+func TestMembershipLogServiceMessages(t *testing.T) {
+	log := newMembershipLog()
+	log.recordMessage(serviceMessage(10, 100, &tg.MessageActionChatAddUser{Users: []int64{11, 12}}))
+	log.recordMessage(serviceMessage(13, 101, &tg.MessageActionChatJoinedByLink{InviterID: 10}))
+	log.recordMessage(serviceMessage(11, 102, &tg.MessageActionChatDeleteUser{UserID: 11}))
+	log.recordMessage(serviceMessage(10, 103, &tg.MessageActionChatDeleteUser{UserID: 12}))
+	log.recordMessage(serviceMessage(10, 104, &tg.MessageActionChatEditTitle{Title: "ignored"}))
+	log.recordMessage(&tg.Message{ID: 1, PeerID: &tg.PeerChat{ChatID: 20}, Message: "not a service message"})
+
+	want := []MembershipEvent{
+		{PeerID: -20, UserID: 12, Action: memberRemoved, Date: 103, ActorID: 10},
+		{PeerID: -20, UserID: 11, Action: memberLeft, Date: 102},
+		{PeerID: -20, UserID: 13, Action: memberJoined, Date: 101},
+		{PeerID: -20, UserID: 12, Action: memberAdded, Date: 100, ActorID: 10},
+		{PeerID: -20, UserID: 11, Action: memberAdded, Date: 100, ActorID: 10},
+	}
+	got := log.recent(-20)
+	if len(got) != len(want) {
+		t.Fatalf("got %d events, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("event %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestMembershipLogParticipants(t *testing.T) {
+	now := time.Unix(200, 0)
+	list := func(ids ...int64) *tg.ChatParticipants {
+		p := &tg.ChatParticipants{ChatID: 20}
+		for _, id := range ids {
+			p.Participants = append(p.Participants, &tg.ChatParticipant{UserID: id})
+		}
+		return p
+	}
+
+	log := newMembershipLog()
+	log.recordParticipants(list(10, 11), now)
+	if got := log.recent(-20); len(got) != 0 {
+		t.Fatalf("first member list produced events: %+v", got)
+	}
+
+	// 12 was announced by a service message, so only 13 and 11 are new
+	log.recordMessage(serviceMessage(12, 150, &tg.MessageActionChatJoinedByRequest{}))
+	log.recordParticipants(list(10, 12, 13), now)
+
+	got := log.recent(-20)
+	if len(got) != 3 {
+		t.Fatalf("got %d events, want 3: %+v", len(got), got)
+	}
+	byUser := make(map[int64]MembershipEvent)
+	for _, e := range got {
+		byUser[e.UserID] = e
+	}
+	if e := byUser[13]; e.Action != memberJoined || e.Date != 200 {
+		t.Errorf("event of 13 = %+v, want joined at 200", e)
+	}
+	if e := byUser[11]; e.Action != memberLeft {
+		t.Errorf("event of 11 = %+v, want left", e)
+	}
+	if e := byUser[12]; e.Date != 150 {
+		t.Errorf("event of 12 = %+v, want only the service message one", e)
+	}
+}
+
+func TestMembershipLogBounded(t *testing.T) {
+	log := newMembershipLog()
+	for i := 0; i < membershipLogSize+10; i++ {
+		log.recordMessage(serviceMessage(int64(i), i, &tg.MessageActionChatJoinedByLink{}))
+	}
+	got := log.recent(-20)
+	if len(got) != membershipLogSize {
+		t.Fatalf("kept %d events, want %d", len(got), membershipLogSize)
+	}
+	if got[0].Date != membershipLogSize+9 || got[len(got)-1].Date != 10 {
+		t.Errorf("kept dates %d..%d, want the newest events", got[len(got)-1].Date, got[0].Date)
+	}
+}
+
+func TestGetRecentMembershipEventsUser(t *testing.T) {
+	_, _, peers := newFakeClient(nil)
+	peers.storeUser(&tg.User{ID: 10, AccessHash: 1})
+	if _, err := getRecentMembershipEvents(context.Background(), newMembershipLog(), peers, "10"); err == nil {
+		t.Error("getRecentMembershipEvents() on a user succeeded, want error")
+	}
+}
@@ -0,0 +1,650 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+type sendMessageArgs struct {
+	Peer string `json:"peer"`
+	Text string `json:"text"`
+	// ResolveMentions links each @username in Text to its user
+	ResolveMentions bool `json:"resolve_mentions"`
+	// Silent delivers the message without a notification sound
+	Silent bool `json:"silent"`
+	// ScheduleDate, when set, is the Unix time the message is sent at
+	ScheduleDate int `json:"schedule_date"`
+	// ClearDraft clears the chat's draft on send, as the apps do; default
+	// true
+	ClearDraft *bool `json:"clear_draft"`
+}
+
+type sendMessageResult struct {
+	MessageID int `json:"message_id"`
+	// Queued is set instead of MessageID when Telegram was unreachable and
+	// the message went to the outbox as LocalID
+	Queued  bool   `json:"queued,omitempty"`
+	LocalID string `json:"local_id,omitempty"`
+}
+
+// maxScheduleAhead is how far in the future Telegram accepts scheduled
+// messages
+const maxScheduleAhead = 365 * 24 * time.Hour
+
+// sendOptions are the optional settings of a text message
+type sendOptions struct {
+	Entities []tg.MessageEntityClass
+	Silent   bool
+	// ClearDraft removes the chat's draft once the message is sent
+	ClearDraft bool
+	// ScheduleDate is the Unix time to send at, 0 to send now
+	ScheduleDate int
+	// RandomID identifies the message to Telegram; 0 picks a new one
+	RandomID int64
+}
+
+// sendMessageTool sends text messages. Messages that fail because Telegram
+// could not be reached are queued in box, unless scheduled.
+func sendMessageTool(api *tg.Client, peers *peerResolver, box *outbox) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args sendMessageArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.Peer) == "" {
+			return nil, invalidParams("peer is required")
+		}
+		if strings.TrimSpace(args.Text) == "" {
+			return nil, invalidParams("text is required")
+		}
+		if args.ScheduleDate != 0 {
+			if err := validScheduleDate(args.ScheduleDate, time.Now()); err != nil {
+				return nil, invalidParams(err.Error())
+			}
+		}
+
+		randomID, err := randomInt64()
+		if err != nil {
+			return nil, err
+		}
+		clearDraft := args.ClearDraft == nil || *args.ClearDraft
+		opts := sendOptions{Silent: args.Silent, ClearDraft: clearDraft, ScheduleDate: args.ScheduleDate, RandomID: randomID}
+		id, err := resolveAndSend(ctx, api, peers, args.Peer, args.Text, args.ResolveMentions, opts)
+		if isDeliveryFailure(err) && box != nil && args.ScheduleDate == 0 {
+			localID, qerr := box.add(OutboxEntry{
+				Peer:            args.Peer,
+				Text:            args.Text,
+				ResolveMentions: args.ResolveMentions,
+				Silent:          args.Silent,
+				KeepDraft:       !clearDraft,
+				RandomID:        randomID,
+				LastError:       err.Error(),
+			})
+			if qerr != nil {
+				return nil, fmt.Errorf("%w; queueing it also failed: %v", err, qerr)
+			}
+			slog.Warn("Telegram unreachable, message queued in the outbox", "local_id", localID, "error", err)
+			return sendMessageResult{Queued: true, LocalID: localID}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return sendMessageResult{MessageID: id}, nil
+	}
+}
+
+// resolveAndSend resolves peer, formats text and sends it. Resolving and
+// formatting may need Telegram too, so a connection failure in any step
+// leaves the message to be queued whole.
+func resolveAndSend(ctx context.Context, api *tg.Client, peers *peerResolver, peer, text string, mentions bool, opts sendOptions) (int, error) {
+	inputPeer, err := peers.Resolve(ctx, peer)
+	if err != nil {
+		return 0, err
+	}
+	text, opts.Entities, err = formatText(ctx, api, peers, text, mentions)
+	if err != nil {
+		return 0, err
+	}
+	return sendText(ctx, api, inputPeer, text, opts)
+}
+
+// validScheduleDate checks that date is a Unix time Telegram can schedule a
+// message for
+func validScheduleDate(date int, now time.Time) error {
+	at := time.Unix(int64(date), 0)
+	switch {
+	case !at.After(now):
+		return fmt.Errorf("schedule_date %d is not in the future", date)
+	case at.Sub(now) > maxScheduleAhead:
+		return fmt.Errorf("schedule_date %d is more than a year ahead", date)
+	}
+	return nil
+}
+
+// sendText sends text to peer and returns the message ID; for a scheduled
+// message it is the ID in the chat's scheduled list
+func sendText(ctx context.Context, api *tg.Client, peer tg.InputPeerClass, text string, opts sendOptions) (int, error) {
+	req := &tg.MessagesSendMessageRequest{Peer: peer, Message: text, Silent: opts.Silent, ClearDraft: opts.ClearDraft}
+	if len(opts.Entities) > 0 {
+		req.SetEntities(opts.Entities)
+	}
+	if opts.ScheduleDate != 0 {
+		req.SetScheduleDate(opts.ScheduleDate)
+	}
+	req.RandomID = opts.RandomID
+	if req.RandomID == 0 {
+		var err error
+		if req.RandomID, err = randomInt64(); err != nil {
+			return 0, err
+		}
+	}
+	// Retries reuse random_id so Telegram never delivers the message twice
+	var updates tg.UpdatesClass
+	err := withFloodRetry(ctx, func() (err error) {
+		updates, err = api.MessagesSendMessage(ctx, req)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to send message: %w", err)
+	}
+	return sentMessageID(updates)
+}
+
+// formatText expands the custom emoji markup of text and, if mentions is
+// set, links its @usernames, returning the text to send and its entities
+func formatText(ctx context.Context, api *tg.Client, peers *peerResolver, text string, mentions bool) (string, []tg.MessageEntityClass, error) {
+	text, entities, err := expandCustomEmoji(ctx, api, peers, text)
+	if err != nil {
+		return "", nil, err
+	}
+	if mentions {
+		entities = append(entities, resolveMentions(ctx, peers, text)...)
+		sort.SliceStable(entities, func(i, j int) bool {
+			return entities[i].GetOffset() < entities[j].GetOffset()
+		})
+	}
+	return text, entities, nil
+}
+
+// mentionPattern matches an @username not preceded by a word character, so
+// e-mail addresses are left alone. Usernames are 4 to 32 characters.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])(@[A-Za-z][A-Za-z0-9_]{3,31})\b`)
+
+// resolveMentions returns a mention-name entity for each @username in text
+// that resolves to a user, so the mention links the account even if the
+// username changes later. Mentions that do not resolve, or resolve to a chat,
+// stay plain text.
+func resolveMentions(ctx context.Context, peers *peerResolver, text string) []tg.MessageEntityClass {
+	var (
+		entities []tg.MessageEntityClass
+		users    = make(map[string]*tg.InputUser) // nil for unresolvable names
+	)
+	for _, m := range mentionPattern.FindAllStringSubmatchIndex(text, -1) {
+		start, end := m[2], m[3]
+		name := strings.ToLower(text[start+1 : end])
+		user, seen := users[name]
+		if !seen {
+			if p, err := peers.resolve(ctx, name); err == nil && p.Type == peerUser {
+				user = &tg.InputUser{UserID: p.ID, AccessHash: p.AccessHash}
+			} else if err != nil {
+				slog.Debug("Leaving mention as plain text", "username", name, "error", err)
+			}
+			users[name] = user
+		}
+		if user == nil {
+			continue
+		}
+		entities = append(entities, &tg.InputMessageEntityMentionName{
+			Offset: utf16Len(text[:start]),
+			Length: utf16Len(text[start:end]),
+			UserID: user,
+		})
+	}
+	return entities
+}
+
+// Bounds for the number of messages a history tool returns
+const (
+	defaultMessageLimit = 20
+	maxMessageLimit     = 100
+)
+
+type readMessagesArgs struct {
+	Peer            string `json:"peer"`
+	Limit           int    `json:"limit"`
+	MaxMessageChars int    `json:"max_message_chars"`
+}
+
+// messageInfo is the JSON shape of a message returned by tools
+type messageInfo struct {
+	ID       int    `json:"id"`
+	SenderID int64  `json:"sender_id"`
+	Date     int    `json:"date"`
+	Text     string `json:"text"`
+	FromMe   bool   `json:"from_me"`
+	// PeerID is the chat of the message, set where results span chats
+	PeerID int64 `json:"peer_id,omitempty"`
+	// FullLength is the original text length in UTF-16 code units, set
+	// only when Text was truncated
+	FullLength int `json:"full_length,omitempty"`
+}
+
+func readMessagesTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args readMessagesArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.Peer) == "" {
+			return nil, invalidParams("peer is required")
+		}
+		if args.Limit < 0 {
+			return nil, invalidParams("limit must not be negative")
+		}
+		if args.MaxMessageChars < 0 {
+			return nil, invalidParams("max_message_chars must not be negative")
+		}
+
+		peer, err := peers.Resolve(ctx, args.Peer)
+		if err != nil {
+			return nil, err
+		}
+		var history tg.MessagesMessagesClass
+		err = withFloodRetry(ctx, func() (err error) {
+			history, err = api.MessagesGetHistory(ctx, &tg.MessagesGetHistoryRequest{
+				Peer:  peer,
+				Limit: clampLimit(args.Limit, defaultMessageLimit, maxMessageLimit),
+			})
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read messages: %w", err)
+		}
+		msgs, err := collectMessages(history, peers)
+		if err != nil {
+			return nil, err
+		}
+		truncateMessages(msgs, args.MaxMessageChars)
+		return msgs, nil
+	}
+}
+
+// truncateMessages shortens each message text to at most max UTF-16 code
+// units, recording the original length. A max of 0 disables truncation.
+func truncateMessages(msgs []messageInfo, max int) {
+	if max <= 0 {
+		return
+	}
+	for i := range msgs {
+		if text, full, ok := truncateText(msgs[i].Text, max); ok {
+			msgs[i].Text = text
+			msgs[i].FullLength = full
+		}
+	}
+}
+
+// truncateText cuts s to at most max UTF-16 code units including a trailing
+// ellipsis, never splitting a character. It returns the original length and
+// whether s was cut.
+func truncateText(s string, max int) (string, int, bool) {
+	full := utf16Len(s)
+	if full <= max {
+		return s, full, false
+	}
+
+	const ellipsis = "…" // one UTF-16 code unit
+	n := 0
+	for i, r := range s {
+		n += utf16RuneLen(r)
+		if n > max-1 {
+			return s[:i] + ellipsis, full, true
+		}
+	}
+	return s, full, false
+}
+
+// utf16Len returns the length of s in UTF-16 code units, the unit Telegram
+// uses for text lengths and entity offsets
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16RuneLen(r)
+	}
+	return n
+}
+
+// clampLimit applies def to an unset limit and caps it at max
+func clampLimit(limit, def, max int) int {
+	if limit <= 0 {
+		return def
+	}
+	if limit > max {
+		return max
+	}
+	return limit
+}
+
+// collectMessages converts a messages response to messageInfo, newest first,
+// skipping service messages and messages without text
+func collectMessages(res tg.MessagesMessagesClass, peers *peerResolver) ([]messageInfo, error) {
+	modified, ok := res.AsModified()
+	if !ok {
+		return nil, fmt.Errorf("unexpected messages response %T", res)
+	}
+	peers.remember(modified.GetUsers(), modified.GetChats())
+
+	result := make([]messageInfo, 0, len(modified.GetMessages()))
+	for _, m := range modified.GetMessages() {
+		msg, ok := m.(*tg.Message)
+		if !ok || msg.Message == "" {
+			continue
+		}
+		info, err := newMessageInfo(msg)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, info)
+	}
+	return result, nil
+}
+
+func newMessageInfo(msg *tg.Message) (messageInfo, error) {
+	// Without from_id the sender is the chat itself: the other party in a
+	// private chat, or the channel for channel posts
+	sender, ok := msg.GetFromID()
+	if !ok {
+		sender = msg.PeerID
+	}
+	senderID, err := markedPeerID(sender)
+	if err != nil {
+		return messageInfo{}, err
+	}
+	return messageInfo{
+		ID:       msg.ID,
+		SenderID: senderID,
+		Date:     msg.Date,
+		Text:     msg.Message,
+		FromMe:   msg.Out,
+	}, nil
+}
+
+// sentMessageID extracts the ID of the message just sent from the updates
+// returned by a send request
+func sentMessageID(u tg.UpdatesClass) (int, error) {
+	var updates []tg.UpdateClass
+	switch u := u.(type) {
+	case *tg.UpdateShortSentMessage:
+		return u.ID, nil
+	case *tg.Updates:
+		updates = u.Updates
+	case *tg.UpdatesCombined:
+		updates = u.Updates
+	default:
+		return 0, fmt.Errorf("unexpected send response %T", u)
+	}
+
+	for _, update := range updates {
+		switch update := update.(type) {
+		case *tg.UpdateMessageID:
+			return update.ID, nil
+		case *tg.UpdateNewMessage:
+			return update.Message.GetID(), nil
+		case *tg.UpdateNewChannelMessage:
+			return update.Message.GetID(), nil
+		case *tg.UpdateNewScheduledMessage:
+			return update.Message.GetID(), nil
+		}
+	}
+	return 0, fmt.Errorf("sent message ID missing from response")
+}
+
+// randomInt64 returns a random_id for send requests
+func randomInt64() (int64, error) {
+	var buf [8]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return 0, fmt.Errorf("failed to generate random_id: %w", err)
+	}
+	return int64(binary.LittleEndian.Uint64(buf[:])), nil
+}
+
+// utf16RuneLen returns the number of UTF-16 code units needed to encode r
+func utf16RuneLen(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// getMessage fetches a single message from any peer
+func getMessage(ctx context.Context, api *tg.Client, peers *peerResolver, peer string, id int) (*tg.Message, error) {
+	msgs, err := getMessages(ctx, api, peers, peer, []int{id})
+	if err != nil {
+		return nil, err
+	}
+	if msg, ok := msgs[id]; ok {
+		return msg, nil
+	}
+	return nil, fmt.Errorf("message %d not found in %s", id, peer)
+}
+
+// getMessages fetches messages of peer by ID, keyed by ID; IDs that do not
+// exist are missing from the result. Channels and supergroups have their
+// own message ID space and need channels.getMessages.
+func getMessages(ctx context.Context, api *tg.Client, peers *peerResolver, peer string, messageIDs []int) (map[int]*tg.Message, error) {
+	p, err := peers.resolve(ctx, peer)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]tg.InputMessageClass, 0, len(messageIDs))
+	wanted := make(map[int]bool, len(messageIDs))
+	for _, id := range messageIDs {
+		ids = append(ids, &tg.InputMessageID{ID: id})
+		wanted[id] = true
+	}
+
+	var res tg.MessagesMessagesClass
+	err = withFloodRetry(ctx, func() (err error) {
+		if p.Type == peerChannel {
+			res, err = api.ChannelsGetMessages(ctx, &tg.ChannelsGetMessagesRequest{
+				Channel: &tg.InputChannel{ChannelID: p.ID, AccessHash: p.AccessHash},
+				ID:      ids,
+			})
+			return err
+		}
+		res, err = api.MessagesGetMessages(ctx, ids)
+		return err
+	})
+	if err != nil {
+		if len(messageIDs) == 1 {
+			return nil, fmt.Errorf("failed to get message %d: %w", messageIDs[0], err)
+		}
+		return nil, fmt.Errorf("failed to get messages: %w", err)
+	}
+	modified, ok := res.AsModified()
+	if !ok {
+		return nil, fmt.Errorf("unexpected messages response %T", res)
+	}
+	peers.remember(modified.GetUsers(), modified.GetChats())
+	msgs := make(map[int]*tg.Message, len(messageIDs))
+	for _, m := range modified.GetMessages() {
+		msg, ok := m.(*tg.Message)
+		if !ok || !wanted[msg.ID] {
+			continue
+		}
+		// Users and basic groups share one ID space, so the message may
+		// belong to another chat
+		if marked, err := markedPeerID(msg.PeerID); err == nil && marked == p.MarkedID() {
+			msgs[msg.ID] = msg
+		}
+	}
+	return msgs, nil
+}
+
+type messageRefArgs struct {
+	Peer      string `json:"peer"`
+	MessageID int    `json:"message_id"`
+}
+
+type readByResult struct {
+	ReadBy []int64 `json:"read_by"`
+}
+
+func getReadByTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args messageRefArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.Peer) == "" {
+			return nil, invalidParams("peer is required")
+		}
+		if args.MessageID <= 0 {
+			return nil, invalidParams("message_id must be positive")
+		}
+
+		readBy, err := getMessageReadParticipants(ctx, api, peers, args.Peer, args.MessageID)
+		if err != nil {
+			return nil, err
+		}
+		return readByResult{ReadBy: readBy}, nil
+	}
+}
+
+// getMessageReadParticipants returns the IDs of the members who have read an
+// outgoing group message. Telegram only keeps read receipts for small groups
+// and recent messages, and reports the other cases as dedicated errors.
+func getMessageReadParticipants(ctx context.Context, api *tg.Client, peers *peerResolver, peer string, messageID int) ([]int64, error) {
+	p, err := peers.resolve(ctx, peer)
+	if err != nil {
+		return nil, err
+	}
+	if p.Type == peerUser {
+		return nil, fmt.Errorf("read receipts are only available in groups; use get_dialog_state for private chats")
+	}
+
+	var participants []tg.ReadParticipantDate
+	err = withFloodRetry(ctx, func() (err error) {
+		participants, err = api.MessagesGetMessageReadParticipants(ctx, &tg.MessagesGetMessageReadParticipantsRequest{
+			Peer:  p.InputPeer(),
+			MsgID: messageID,
+		})
+		return err
+	})
+	switch {
+	case tg.IsChatTooBig(err):
+		return nil, fmt.Errorf("%s is too large for read receipts", peer)
+	case tg.IsMsgTooOld(err):
+		return nil, fmt.Errorf("message %d is too old for read receipts", messageID)
+	case err != nil:
+		return nil, fmt.Errorf("failed to get readers of message %d: %w", messageID, err)
+	}
+
+	readBy := make([]int64, 0, len(participants))
+	for _, r := range participants {
+		readBy = append(readBy, cachedPeer{Type: peerUser, ID: r.UserID}.MarkedID())
+	}
+	return readBy, nil
+}
+
+type translateArgs struct {
+	Peer       string   `json:"peer"`
+	MessageIDs []int    `json:"message_ids"`
+	Text       []string `json:"text"`
+	ToLang     string   `json:"to_lang"`
+}
+
+type translateResult struct {
+	Translations []string `json:"translations"`
+}
+
+func translateTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args translateArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.ToLang) == "" {
+			return nil, invalidParams("to_lang is required")
+		}
+		fromMessages := strings.TrimSpace(args.Peer) != "" || len(args.MessageIDs) > 0
+		switch {
+		case fromMessages && len(args.Text) > 0:
+			return nil, invalidParams("give either peer with message_ids or text, not both")
+		case fromMessages && (strings.TrimSpace(args.Peer) == "" || len(args.MessageIDs) == 0):
+			return nil, invalidParams("peer and message_ids must be given together")
+		case !fromMessages && len(args.Text) == 0:
+			return nil, invalidParams("peer with message_ids or text is required")
+		}
+
+		var (
+			translations []string
+			err          error
+		)
+		if fromMessages {
+			translations, err = translateMessages(ctx, api, peers, args.Peer, args.MessageIDs, args.ToLang)
+		} else {
+			translations, err = translateText(ctx, api, args.Text, args.ToLang)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return translateResult{Translations: translations}, nil
+	}
+}
+
+// translateMessages translates existing messages of peer to toLang, an ISO
+// 639-1 code, returning the translations in the order of messageIDs
+func translateMessages(ctx context.Context, api *tg.Client, peers *peerResolver, peer string, messageIDs []int, toLang string) ([]string, error) {
+	input, err := peers.Resolve(ctx, peer)
+	if err != nil {
+		return nil, err
+	}
+	req := &tg.MessagesTranslateTextRequest{ToLang: toLang}
+	req.SetPeer(input)
+	req.SetID(messageIDs)
+	return requestTranslation(ctx, api, req)
+}
+
+// translateText translates raw text to toLang
+func translateText(ctx context.Context, api *tg.Client, text []string, toLang string) ([]string, error) {
+	input := make([]tg.TextWithEntities, 0, len(text))
+	for _, t := range text {
+		input = append(input, tg.TextWithEntities{Text: t})
+	}
+	req := &tg.MessagesTranslateTextRequest{ToLang: toLang}
+	req.SetText(input)
+	return requestTranslation(ctx, api, req)
+}
+
+func requestTranslation(ctx context.Context, api *tg.Client, req *tg.MessagesTranslateTextRequest) ([]string, error) {
+	var res *tg.MessagesTranslateResult
+	err := withFloodRetry(ctx, func() (err error) {
+		res, err = api.MessagesTranslateText(ctx, req)
+		return err
+	})
+	switch {
+	case tgerr.Is(err, "TO_LANG_INVALID"):
+		return nil, invalidParams("unsupported target language %q", req.ToLang)
+	case tgerr.Is(err, "TRANSLATE_REQ_QUOTA_EXCEEDED", "TRANSLATIONS_DISABLED") || tg.IsPremiumAccountRequired(err):
+		return nil, fmt.Errorf("translation is unavailable for this account: %w", err)
+	case err != nil:
+		return nil, fmt.Errorf("failed to translate: %w", err)
+	}
+
+	translations := make([]string, 0, len(res.Result))
+	for _, r := range res.Result {
+		translations = append(translations, r.Text)
+	}
+	return translations, nil
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+func TestGetMessage(t *testing.T) {
+	tests := []struct {
+		name        string
+		peer        string
+		msgPeer     tg.PeerClass
+		wantChannel bool
+		wantErr     bool
+	}{
+		{name: "user", peer: "10", msgPeer: &tg.PeerUser{UserID: 10}},
+		{name: "basic group", peer: "-20", msgPeer: &tg.PeerChat{ChatID: 20}},
+		{name: "channel", peer: "-1000000000030", msgPeer: &tg.PeerChannel{ChannelID: 30}, wantChannel: true},
+		{name: "message of another chat", peer: "10", msgPeer: &tg.PeerUser{UserID: 11}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found := &tg.MessagesMessages{Messages: []tg.MessageClass{
+				&tg.Message{ID: 7, PeerID: tt.msgPeer, Message: "hello"},
+			}}
+			inv, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+				switch input.(type) {
+				case *tg.MessagesGetMessagesRequest, *tg.ChannelsGetMessagesRequest:
+					return found, nil
+				}
+				return nil, nil
+			})
+			peers.storeUser(&tg.User{ID: 10, AccessHash: 1})
+			peers.storeChannel(&tg.Channel{ID: 30, AccessHash: 3})
+
+			msg, err := getMessage(context.Background(), api, peers, tt.peer, 7)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("getMessage() succeeded, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg.ID != 7 {
+				t.Errorf("message id = %d, want 7", msg.ID)
+			}
+			channel := requests[*tg.ChannelsGetMessagesRequest](inv)
+			if gotChannel := len(channel) == 1; gotChannel != tt.wantChannel {
+				t.Errorf("used channels.getMessages = %v, want %v", gotChannel, tt.wantChannel)
+			}
+			if tt.wantChannel && channel[0].Channel.(*tg.InputChannel).AccessHash != 3 {
+				t.Error("channel request lacks the cached access hash")
+			}
+		})
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		max      int
+		want     string
+		wantFull int
+		wantCut  bool
+	}{
+		{name: "short", text: "hello", max: 10, want: "hello", wantFull: 5},
+		{name: "exact", text: "hello", max: 5, want: "hello", wantFull: 5},
+		{name: "ascii", text: "hello world", max: 6, want: "hello…", wantFull: 11, wantCut: true},
+		{name: "multibyte", text: "привет мир", max: 4, want: "при…", wantFull: 10, wantCut: true},
+		// 😀 is two UTF-16 code units and must not be split
+		{name: "surrogate pair kept whole", text: "ab😀cd", max: 4, want: "ab…", wantFull: 6, wantCut: true},
+		{name: "surrogate pair fits", text: "ab😀cd", max: 5, want: "ab😀…", wantFull: 6, wantCut: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, full, cut := truncateText(tt.text, tt.max)
+			if got != tt.want || full != tt.wantFull || cut != tt.wantCut {
+				t.Errorf("truncateText(%q, %d) = %q, %d, %v; want %q, %d, %v", tt.text, tt.max, got, full, cut, tt.want, tt.wantFull, tt.wantCut)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("truncateText(%q, %d) = %q is not valid UTF-8", tt.text, tt.max, got)
+			}
+			if utf16Len(got) > tt.max && tt.wantCut {
+				t.Errorf("truncated text is %d code units, over %d", utf16Len(got), tt.max)
+			}
+		})
+	}
+}
+
+func TestTruncateMessages(t *testing.T) {
+	msgs := []messageInfo{{Text: "short"}, {Text: "a much longer message"}}
+	truncateMessages(msgs, 0)
+	if msgs[1].FullLength != 0 || msgs[1].Text != "a much longer message" {
+		t.Fatalf("max 0 truncated: %+v", msgs[1])
+	}
+	truncateMessages(msgs, 6)
+	if msgs[0].Text != "short" || msgs[0].FullLength != 0 {
+		t.Errorf("short message changed: %+v", msgs[0])
+	}
+	if msgs[1].Text != "a muc…" || msgs[1].FullLength != 21 {
+		t.Errorf("long message = %+v", msgs[1])
+	}
+}
+
+func TestGetMessageReadParticipants(t *testing.T) {
+	tests := []struct {
+		name    string
+		peer    string
+		rpcErr  error
+		want    []int64
+		wantErr string
+	}{
+		{name: "readers", peer: "-20", want: []int64{10, 11}},
+		{name: "private chat", peer: "10", wantErr: "only available in groups"},
+		{name: "large group", peer: "-1000000000030", rpcErr: tgerr.New(400, "CHAT_TOO_BIG"), wantErr: "too large"},
+		{name: "old message", peer: "-20", rpcErr: tgerr.New(400, "MSG_TOO_OLD"), wantErr: "too old"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inv, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+				if _, ok := input.(*tg.MessagesGetMessageReadParticipantsRequest); ok {
+					if tt.rpcErr != nil {
+						return nil, tt.rpcErr
+					}
+					return &tg.ReadParticipantDateVector{Elems: []tg.ReadParticipantDate{
+						{UserID: 10, Date: 100},
+						{UserID: 11, Date: 101},
+					}}, nil
+				}
+				return nil, nil
+			})
+			peers.storeUser(&tg.User{ID: 10, AccessHash: 1})
+			peers.storeChannel(&tg.Channel{ID: 30, AccessHash: 3})
+
+			got, err := getMessageReadParticipants(context.Background(), api, peers, tt.peer, 7)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("read by = %v, want %v", got, tt.want)
+			}
+			if req := requests[*tg.MessagesGetMessageReadParticipantsRequest](inv); req[0].MsgID != 7 {
+				t.Errorf("msg_id = %d, want 7", req[0].MsgID)
+			}
+		})
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	var rpcErr error
+	inv, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		req, ok := input.(*tg.MessagesTranslateTextRequest)
+		if !ok {
+			return nil, nil
+		}
+		if rpcErr != nil {
+			return nil, rpcErr
+		}
+		n := len(req.ID) + len(req.Text)
+		res := &tg.MessagesTranslateResult{}
+		for i := 0; i < n; i++ {
+			res.Result = append(res.Result, tg.TextWithEntities{Text: fmt.Sprintf("%s-%d", req.ToLang, i)})
+		}
+		return res, nil
+	})
+	peers.storeUser(&tg.User{ID: 10, AccessHash: 1})
+	ctx := context.Background()
+
+	got, err := translateMessages(ctx, api, peers, "10", []int{7, 8}, "de")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != "[de-0 de-1]" {
+		t.Errorf("message translations = %v", got)
+	}
+	req := requests[*tg.MessagesTranslateTextRequest](inv)[0]
+	if _, ok := req.GetPeer(); !ok || fmt.Sprint(req.ID) != "[7 8]" || len(req.Text) != 0 {
+		t.Errorf("message request = %+v, want peer and IDs only", req)
+	}
+
+	got, err = translateText(ctx, api, []string{"hola"}, "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != "[en-0]" {
+		t.Errorf("text translations = %v", got)
+	}
+	req = requests[*tg.MessagesTranslateTextRequest](inv)[1]
+	if _, ok := req.GetPeer(); ok || len(req.ID) != 0 || req.Text[0].Text != "hola" {
+		t.Errorf("text request = %+v, want text only", req)
+	}
+
+	rpcErr = tgerr.New(400, "TRANSLATE_REQ_QUOTA_EXCEEDED")
+	if _, err := translateText(ctx, api, []string{"hola"}, "en"); err == nil || !strings.Contains(err.Error(), "unavailable") {
+		t.Errorf("quota error = %v, want translation unavailable", err)
+	}
+}
+
+func TestResolveMentions(t *testing.T) {
+	inv, _, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		req, ok := input.(*tg.ContactsResolveUsernameRequest)
+		if !ok {
+			return nil, nil
+		}
+		switch req.Username {
+		case "alice":
+			return &tg.ContactsResolvedPeer{
+				Peer:  &tg.PeerUser{UserID: 10},
+				Users: []tg.UserClass{&tg.User{ID: 10, AccessHash: 1}},
+			}, nil
+		case "news_channel":
+			return &tg.ContactsResolvedPeer{
+				Peer:  &tg.PeerChannel{ChannelID: 30},
+				Chats: []tg.ChatClass{&tg.Channel{ID: 30, AccessHash: 3, Photo: &tg.ChatPhotoEmpty{}}},
+			}, nil
+		}
+		return nil, tgerr.New(400, "USERNAME_NOT_OCCUPIED")
+	})
+
+	text := "🙂 @alice meet @nobody, see @news_channel and mail bob@example.com (@Alice)"
+	entities := resolveMentions(context.Background(), peers, text)
+
+	// The emoji takes two UTF-16 code units
+	want := []struct{ offset, length int }{{3, 6}, {68, 6}}
+	if len(entities) != len(want) {
+		t.Fatalf("got %d entities, want %d: %+v", len(entities), len(want), entities)
+	}
+	for i, w := range want {
+		e := entities[i].(*tg.InputMessageEntityMentionName)
+		if e.Offset != w.offset || e.Length != w.length {
+			t.Errorf("entity %d at %d+%d, want %d+%d", i, e.Offset, e.Length, w.offset, w.length)
+		}
+		if u := e.UserID.(*tg.InputUser); u.UserID != 10 || u.AccessHash != 1 {
+			t.Errorf("entity %d links %+v, want user 10", i, u)
+		}
+	}
+
+	var names []string
+	for _, r := range requests[*tg.ContactsResolveUsernameRequest](inv) {
+		names = append(names, r.Username)
+	}
+	if fmt.Sprint(names) != "[alice nobody news_channel]" {
+		t.Errorf("resolved %v, want each distinct username once", names)
+	}
+}
+
+func TestSendMessageClearDraft(t *testing.T) {
+	inv, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		if _, ok := input.(*tg.MessagesSendMessageRequest); ok {
+			return &tg.UpdateShortSentMessage{ID: 42}, nil
+		}
+		return nil, nil
+	})
+	peers.storeUser(&tg.User{ID: 10, AccessHash: 1})
+	send := sendMessageTool(api, peers, nil)
+
+	for _, tt := range []struct {
+		args string
+		want bool
+	}{
+		{`{"peer":"10","text":"hi"}`, true},
+		{`{"peer":"10","text":"hi","clear_draft":true}`, true},
+		{`{"peer":"10","text":"hi","clear_draft":false}`, false},
+	} {
+		if _, err := send(context.Background(), json.RawMessage(tt.args)); err != nil {
+			t.Fatal(err)
+		}
+		reqs := requests[*tg.MessagesSendMessageRequest](inv)
+		if got := reqs[len(reqs)-1].ClearDraft; got != tt.want {
+			t.Errorf("%s: clear_draft = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
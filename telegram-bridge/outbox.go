@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+// Outbox entry states: queued until a retry is tried, failed after a retry
+// did not go through
+const (
+	outboxQueued = "queued"
+	outboxFailed = "failed"
+)
+
+// OutboxEntry is a message send_message could not deliver because Telegram
+// was unreachable, kept for a later retry. Peer is kept as given and
+// resolved when the entry is sent.
+type OutboxEntry struct {
+	LocalID string `json:"local_id"`
+	Peer    string `json:"peer"`
+	Text    string `json:"text"`
+	// ResolveMentions and Silent are the send_message options to retry with
+	ResolveMentions bool `json:"resolve_mentions,omitempty"`
+	Silent          bool `json:"silent,omitempty"`
+	// KeepDraft is set when the send was not to clear the chat's draft
+	KeepDraft bool `json:"keep_draft,omitempty"`
+	// RandomID is reused by every retry, so Telegram drops the message if
+	// an earlier attempt was delivered after all
+	RandomID      int64  `json:"random_id"`
+	Status        string `json:"status"`
+	CreatedAt     int64  `json:"created_at"`
+	Attempts      int    `json:"attempts"`
+	LastAttemptAt int64  `json:"last_attempt_at,omitempty"`
+	LastError     string `json:"last_error,omitempty"`
+}
+
+// outbox holds the undelivered messages, saved to path after every change
+// so they survive a restart
+type outbox struct {
+	path   string
+	pretty bool
+
+	mu      sync.Mutex
+	entries []OutboxEntry
+}
+
+// newOutbox loads the outbox saved at path; a missing file is an empty
+// outbox
+func newOutbox(path string, pretty bool) (*outbox, error) {
+	box := &outbox{path: path, pretty: pretty, entries: []OutboxEntry{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return box, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read outbox: %w", err)
+	}
+	if err := json.Unmarshal(data, &box.entries); err != nil {
+		return nil, fmt.Errorf("failed to parse outbox '%s': %w", path, err)
+	}
+	return box, nil
+}
+
+// isDeliveryFailure reports whether err is a connection failure that kept
+// the request from getting an answer from Telegram. Telegram errors,
+// canceled or timed out calls and unexpected responses are not: the message
+// may have been sent, so it is not queued to be sent again.
+func isDeliveryFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var rpcErr *tgerr.Error
+	if errors.As(err, &rpcErr) {
+		return false
+	}
+	var (
+		opErr  *net.OpError
+		dnsErr *net.DNSError
+	)
+	for _, target := range []error{
+		net.ErrClosed, io.EOF, io.ErrUnexpectedEOF,
+		syscall.ECONNREFUSED, syscall.ECONNRESET, syscall.ENETUNREACH, syscall.EHOSTUNREACH, syscall.EPIPE,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return errors.As(err, &opErr) || errors.As(err, &dnsErr)
+}
+
+// add queues entry and returns its local ID
+func (b *outbox) add(entry OutboxEntry) (string, error) {
+	id := make([]byte, 6)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	entry.LocalID = "out_" + hex.EncodeToString(id)
+	entry.Status = outboxQueued
+	if entry.CreatedAt == 0 {
+		entry.CreatedAt = time.Now().Unix()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.entries = append(b.entries, entry)
+	if err := b.save(); err != nil {
+		b.entries = b.entries[:len(b.entries)-1]
+		return "", err
+	}
+	return entry.LocalID, nil
+}
+
+// list returns the entries, oldest first
+func (b *outbox) list() []OutboxEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]OutboxEntry{}, b.entries...)
+}
+
+// entry returns the entry with localID
+func (b *outbox) entry(localID string) (OutboxEntry, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if i := b.index(localID); i >= 0 {
+		return b.entries[i], nil
+	}
+	return OutboxEntry{}, fmt.Errorf("no outbox entry %s", localID)
+}
+
+func (b *outbox) index(localID string) int {
+	for i, e := range b.entries {
+		if e.LocalID == localID {
+			return i
+		}
+	}
+	return -1
+}
+
+// update replaces the stored entry with the same local ID, if still queued
+func (b *outbox) update(entry OutboxEntry) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if i := b.index(entry.LocalID); i >= 0 {
+		b.entries[i] = entry
+		return b.save()
+	}
+	return nil
+}
+
+// drop removes the entry with localID
+func (b *outbox) drop(localID string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	i := b.index(localID)
+	if i < 0 {
+		return fmt.Errorf("no outbox entry %s", localID)
+	}
+	b.entries = append(b.entries[:i], b.entries[i+1:]...)
+	return b.save()
+}
+
+// save writes the entries to path; the caller holds mu
+func (b *outbox) save() error {
+	data, err := marshalJSON(b.entries, b.pretty)
+	if err != nil {
+		return fmt.Errorf("failed to marshal outbox: %w", err)
+	}
+	if err := os.WriteFile(b.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write outbox '%s': %w", b.path, err)
+	}
+	return nil
+}
+
+type listOutboxResult struct {
+	Entries []OutboxEntry `json:"entries"`
+}
+
+type outboxEntryArgs struct {
+	LocalID string `json:"local_id"`
+}
+
+type dropOutboxResult struct {
+	Dropped string `json:"dropped"`
+}
+
+func listOutboxTool(box *outbox) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args struct{}
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		entries, err := listOutbox(ctx, box)
+		if err != nil {
+			return nil, err
+		}
+		return listOutboxResult{Entries: entries}, nil
+	}
+}
+
+func retryOutboxTool(api *tg.Client, peers *peerResolver, box *outbox) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args outboxEntryArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.LocalID) == "" {
+			return nil, invalidParams("local_id is required")
+		}
+		id, err := retryOutbox(ctx, api, peers, box, args.LocalID)
+		if err != nil {
+			return nil, err
+		}
+		return sendMessageResult{MessageID: id}, nil
+	}
+}
+
+func dropOutboxTool(box *outbox) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args outboxEntryArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.LocalID) == "" {
+			return nil, invalidParams("local_id is required")
+		}
+		if err := dropOutbox(ctx, box, args.LocalID); err != nil {
+			return nil, err
+		}
+		return dropOutboxResult{Dropped: args.LocalID}, nil
+	}
+}
+
+// listOutbox returns the undelivered messages, oldest first
+func listOutbox(_ context.Context, box *outbox) ([]OutboxEntry, error) {
+	return box.list(), nil
+}
+
+// retryOutbox sends the entry with localID again and removes it once
+// delivered. A failed retry keeps the entry, marked failed with the error.
+func retryOutbox(ctx context.Context, api *tg.Client, peers *peerResolver, box *outbox, localID string) (int, error) {
+	entry, err := box.entry(localID)
+	if err != nil {
+		return 0, err
+	}
+	id, sendErr := sendOutboxEntry(ctx, api, peers, entry)
+	if sendErr == nil {
+		if err := box.drop(localID); err != nil {
+			return id, err
+		}
+		return id, nil
+	}
+
+	entry.Status = outboxFailed
+	entry.Attempts++
+	entry.LastAttemptAt = time.Now().Unix()
+	entry.LastError = sendErr.Error()
+	if err := box.update(entry); err != nil {
+		return 0, err
+	}
+	return 0, sendErr
+}
+
+// dropOutbox discards the entry with localID without sending it
+func dropOutbox(_ context.Context, box *outbox, localID string) error {
+	return box.drop(localID)
+}
+
+func sendOutboxEntry(ctx context.Context, api *tg.Client, peers *peerResolver, entry OutboxEntry) (int, error) {
+	opts := sendOptions{Silent: entry.Silent, ClearDraft: !entry.KeepDraft, RandomID: entry.RandomID}
+	return resolveAndSend(ctx, api, peers, entry.Peer, entry.Text, entry.ResolveMentions, opts)
+}
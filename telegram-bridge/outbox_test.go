@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+func TestOutbox(t *testing.T) {
+	offline := true
+	var sendErr error
+	inv, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		if _, ok := input.(*tg.MessagesSendMessageRequest); ok {
+			if offline {
+				return nil, &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+			}
+			if sendErr != nil {
+				return nil, sendErr
+			}
+			return &tg.UpdateShortSentMessage{ID: 42}, nil
+		}
+		return nil, nil
+	})
+	peers.storeUser(&tg.User{ID: 10, AccessHash: 1})
+	path := filepath.Join(t.TempDir(), "outbox.json")
+	box, err := newOutbox(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	send := sendMessageTool(api, peers, box)
+
+	// Sends that cannot reach Telegram are queued
+	var queued []string
+	for _, text := range []string{"first", "second", "third"} {
+		args, _ := json.Marshal(sendMessageArgs{Peer: "10", Text: text, Silent: true})
+		result, err := send(context.Background(), args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := result.(sendMessageResult)
+		if !r.Queued || r.LocalID == "" || r.MessageID != 0 {
+			t.Fatalf("result = %+v, want queued", r)
+		}
+		queued = append(queued, r.LocalID)
+	}
+
+	// The outbox survives a restart
+	box, err = newOutbox(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := listOutbox(context.Background(), box)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || entries[0].Text != "first" || entries[0].Status != outboxQueued || !entries[0].Silent || entries[0].LastError == "" {
+		t.Fatalf("entries = %+v", entries)
+	}
+
+	// Retrying one entry sends it with its original random_id and removes it
+	offline = false
+	id, err := retryOutbox(context.Background(), api, peers, box, queued[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 42 {
+		t.Errorf("id = %d, want 42", id)
+	}
+	sent := requests[*tg.MessagesSendMessageRequest](inv)
+	if last := sent[len(sent)-1]; last.RandomID != entries[1].RandomID || last.Message != "second" || !last.Silent || !last.ClearDraft {
+		t.Errorf("retry = %+v, want random_id %d", last, entries[1].RandomID)
+	}
+	if entries = box.list(); len(entries) != 2 || entries[0].LocalID != queued[0] || entries[1].LocalID != queued[2] {
+		t.Errorf("entries after retry = %+v", entries)
+	}
+
+	// A failed retry keeps the entry with the reason
+	sendErr = tgerr.New(403, "CHAT_WRITE_FORBIDDEN")
+	if _, err := retryOutbox(context.Background(), api, peers, box, queued[0]); !tgerr.Is(err, "CHAT_WRITE_FORBIDDEN") {
+		t.Errorf("err = %v, want CHAT_WRITE_FORBIDDEN", err)
+	}
+	e, err := box.entry(queued[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Status != outboxFailed || e.Attempts != 1 || e.LastAttemptAt == 0 {
+		t.Errorf("entry after failed retry = %+v", e)
+	}
+
+	// Dropping discards without sending
+	before := len(requests[*tg.MessagesSendMessageRequest](inv))
+	if err := dropOutbox(context.Background(), box, queued[0]); err != nil {
+		t.Fatal(err)
+	}
+	if entries = box.list(); len(entries) != 1 || entries[0].LocalID != queued[2] {
+		t.Errorf("entries after drop = %+v", entries)
+	}
+	if after := len(requests[*tg.MessagesSendMessageRequest](inv)); after != before {
+		t.Errorf("drop sent %d messages", after-before)
+	}
+	if err := dropOutbox(context.Background(), box, queued[0]); err == nil {
+		t.Error("dropping a missing entry: want error")
+	}
+	if _, err := retryOutbox(context.Background(), api, peers, box, "out_missing"); err == nil {
+		t.Error("retrying a missing entry: want error")
+	}
+}
+
+func TestSendMessageNotQueuedOnTelegramError(t *testing.T) {
+	_, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		return nil, tgerr.New(400, "MESSAGE_TOO_LONG")
+	})
+	peers.storeUser(&tg.User{ID: 10, AccessHash: 1})
+	box, err := newOutbox(filepath.Join(t.TempDir(), "outbox.json"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sendMessageTool(api, peers, box)(context.Background(), json.RawMessage(`{"peer":"10","text":"hi"}`)); err == nil {
+		t.Error("want the Telegram error")
+	}
+	if entries := box.list(); len(entries) != 0 {
+		t.Errorf("rejected message queued: %+v", entries)
+	}
+}
+
+func TestIsDeliveryFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"connection refused", fmt.Errorf("failed to send message: %w", &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}), true},
+		{"connection reset", syscall.ECONNRESET, true},
+		{"closed connection", fmt.Errorf("wrapped: %w", net.ErrClosed), true},
+		{"eof", fmt.Errorf("wrapped: %w", io.ErrUnexpectedEOF), true},
+		{"dns", &net.DNSError{Err: "no such host", Name: "149.154.167.50"}, true},
+		{"telegram error", tgerr.New(400, "PEER_ID_INVALID"), false},
+		{"canceled", fmt.Errorf("failed to send message: %w", context.Canceled), false},
+		{"timed out", context.DeadlineExceeded, false},
+		{"unexpected response", errors.New("unexpected send response *tg.UpdatesTooLong"), false},
+	}
+	for _, tt := range tests {
+		if got := isDeliveryFailure(tt.err); got != tt.want {
+			t.Errorf("%s: isDeliveryFailure = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSendMessageQueuedBeforeResolve(t *testing.T) {
+	offline := true
+	inv, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		if offline {
+			return nil, &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+		}
+		switch input.(type) {
+		case *tg.ContactsResolveUsernameRequest:
+			return &tg.ContactsResolvedPeer{
+				Peer:  &tg.PeerUser{UserID: 20},
+				Users: []tg.UserClass{&tg.User{ID: 20, AccessHash: 2, Username: "alice"}},
+			}, nil
+		case *tg.MessagesSendMessageRequest:
+			return &tg.UpdateShortSentMessage{ID: 7}, nil
+		}
+		return nil, nil
+	})
+	box, err := newOutbox(filepath.Join(t.TempDir(), "outbox.json"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := sendMessageTool(api, peers, box)(context.Background(), json.RawMessage(`{"peer":"@alice","text":"hi"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := result.(sendMessageResult)
+	if !r.Queued {
+		t.Fatalf("result = %+v, want queued", r)
+	}
+	if entries := box.list(); len(entries) != 1 || entries[0].Peer != "@alice" {
+		t.Fatalf("entries = %+v, want the unresolved peer", entries)
+	}
+
+	// The peer is resolved when the entry is sent
+	offline = false
+	if id, err := retryOutbox(context.Background(), api, peers, box, r.LocalID); err != nil || id != 7 {
+		t.Fatalf("retry = %d, %v", id, err)
+	}
+	sent := requests[*tg.MessagesSendMessageRequest](inv)
+	if len(sent) != 1 {
+		t.Fatalf("sent %d messages, want 1", len(sent))
+	}
+	if p, ok := sent[0].Peer.(*tg.InputPeerUser); !ok || p.UserID != 20 || p.AccessHash != 2 {
+		t.Errorf("sent to %+v, want user 20", sent[0].Peer)
+	}
+}
+
+func TestSendMessageNotQueuedAfterResponse(t *testing.T) {
+	for name, handle := range map[string]func() (bin.Encoder, error){
+		"unexpected response": func() (bin.Encoder, error) { return &tg.UpdatesTooLong{}, nil },
+		"timed out":           func() (bin.Encoder, error) { return nil, context.DeadlineExceeded },
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+				if _, ok := input.(*tg.MessagesSendMessageRequest); ok {
+					return handle()
+				}
+				return nil, nil
+			})
+			peers.storeUser(&tg.User{ID: 10, AccessHash: 1})
+			box, err := newOutbox(filepath.Join(t.TempDir(), "outbox.json"), false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := sendMessageTool(api, peers, box)(context.Background(), json.RawMessage(`{"peer":"10","text":"hi"}`)); err == nil {
+				t.Error("want the send error")
+			}
+			if entries := box.list(); len(entries) != 0 {
+				t.Errorf("message queued: %+v", entries)
+			}
+		})
+	}
+}
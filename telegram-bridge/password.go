@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+// errNoPendingEmail is returned when no recovery email waits for its code
+var errNoPendingEmail = errors.New("no recovery email is waiting for confirmation; set one when enabling two-step verification")
+
+type confirmPasswordEmailArgs struct {
+	Code string `json:"code"`
+}
+
+type passwordEmailResult struct {
+	// Email is the masked address, e.g. a***@example.com
+	Email string `json:"email"`
+}
+
+func confirmPasswordEmailTool(api *tg.Client) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args confirmPasswordEmailArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		code := strings.TrimSpace(args.Code)
+		if code == "" {
+			return nil, invalidParams("code is required")
+		}
+		email, err := pendingPasswordEmail(ctx, api)
+		if err != nil {
+			return nil, err
+		}
+		if err := confirmPasswordEmail(ctx, api, code); err != nil {
+			return nil, err
+		}
+		return passwordEmailResult{Email: email}, nil
+	}
+}
+
+func resendPasswordEmailTool(api *tg.Client) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args struct{}
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		email, err := pendingPasswordEmail(ctx, api)
+		if err != nil {
+			return nil, err
+		}
+		if err := resendPasswordEmail(ctx, api); err != nil {
+			return nil, err
+		}
+		return passwordEmailResult{Email: email}, nil
+	}
+}
+
+// pendingPasswordEmail returns the masked recovery email that still needs
+// its confirmation code, or errNoPendingEmail
+func pendingPasswordEmail(ctx context.Context, api *tg.Client) (string, error) {
+	var password *tg.AccountPassword
+	err := withFloodRetry(ctx, func() (err error) {
+		password, err = api.AccountGetPassword(ctx)
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get password settings: %w", err)
+	}
+	email, ok := password.GetEmailUnconfirmedPattern()
+	if !ok || email == "" {
+		return "", errNoPendingEmail
+	}
+	return email, nil
+}
+
+// confirmPasswordEmail completes the recovery email setup with the code
+// Telegram mailed to it
+func confirmPasswordEmail(ctx context.Context, api *tg.Client, code string) error {
+	err := withFloodRetry(ctx, func() error {
+		_, err := api.AccountConfirmPasswordEmail(ctx, code)
+		return err
+	})
+	switch {
+	case err == nil:
+		return nil
+	case tgerr.Is(err, "CODE_INVALID"):
+		return invalidParams("the confirmation code is invalid")
+	case tgerr.Is(err, "EMAIL_HASH_EXPIRED"):
+		return fmt.Errorf("the confirmation code expired; use resend_password_email for a new one")
+	default:
+		return fmt.Errorf("failed to confirm recovery email: %w", err)
+	}
+}
+
+// resendPasswordEmail mails a new confirmation code to the pending recovery
+// email
+func resendPasswordEmail(ctx context.Context, api *tg.Client) error {
+	err := withFloodRetry(ctx, func() error {
+		_, err := api.AccountResendPasswordEmail(ctx)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to resend recovery email code: %w", err)
+	}
+	return nil
+}
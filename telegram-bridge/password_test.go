@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+func passwordSettings(pendingEmail string) *tg.AccountPassword {
+	p := &tg.AccountPassword{
+		NewAlgo:       &tg.PasswordKdfAlgoUnknown{},
+		NewSecureAlgo: &tg.SecurePasswordKdfAlgoUnknown{},
+		SecureRandom:  []byte{1},
+	}
+	p.SetCurrentAlgo(&tg.PasswordKdfAlgoUnknown{})
+	if pendingEmail != "" {
+		p.SetEmailUnconfirmedPattern(pendingEmail)
+	}
+	return p
+}
+
+func TestConfirmPasswordEmail(t *testing.T) {
+	inv, api, _ := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		switch r := input.(type) {
+		case *tg.AccountGetPasswordRequest:
+			return passwordSettings("a***@example.com"), nil
+		case *tg.AccountConfirmPasswordEmailRequest:
+			if r.Code != "12345" {
+				return nil, tgerr.New(400, "CODE_INVALID")
+			}
+			return &tg.BoolTrue{}, nil
+		}
+		return nil, nil
+	})
+	confirm := confirmPasswordEmailTool(api)
+
+	result, err := confirm(context.Background(), json.RawMessage(`{"code":" 12345 "}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.(passwordEmailResult).Email != "a***@example.com" {
+		t.Errorf("result = %+v", result)
+	}
+	reqs := requests[*tg.AccountConfirmPasswordEmailRequest](inv)
+	if len(reqs) != 1 || reqs[0].Code != "12345" {
+		t.Errorf("confirm requests = %+v, want one with the trimmed code", reqs)
+	}
+
+	_, err = confirm(context.Background(), json.RawMessage(`{"code":"99999"}`))
+	var rpcErr *rpcError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != rpcInvalidParams {
+		t.Errorf("wrong code: err = %v, want invalid params", err)
+	}
+}
+
+func TestResendPasswordEmail(t *testing.T) {
+	inv, api, _ := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		switch input.(type) {
+		case *tg.AccountGetPasswordRequest:
+			return passwordSettings("a***@example.com"), nil
+		case *tg.AccountResendPasswordEmailRequest:
+			return &tg.BoolTrue{}, nil
+		}
+		return nil, nil
+	})
+	if _, err := resendPasswordEmailTool(api)(context.Background(), json.RawMessage(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(requests[*tg.AccountResendPasswordEmailRequest](inv)); n != 1 {
+		t.Errorf("resend requests = %d, want 1", n)
+	}
+}
+
+func TestPasswordEmailNotPending(t *testing.T) {
+	inv, api, _ := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		return passwordSettings(""), nil
+	})
+	if _, err := resendPasswordEmailTool(api)(context.Background(), json.RawMessage(`{}`)); !errors.Is(err, errNoPendingEmail) {
+		t.Errorf("resend err = %v, want errNoPendingEmail", err)
+	}
+	if _, err := confirmPasswordEmailTool(api)(context.Background(), json.RawMessage(`{"code":"1"}`)); !errors.Is(err, errNoPendingEmail) {
+		t.Errorf("confirm err = %v, want errNoPendingEmail", err)
+	}
+	if n := len(requests[*tg.AccountResendPasswordEmailRequest](inv)) + len(requests[*tg.AccountConfirmPasswordEmailRequest](inv)); n != 0 {
+		t.Errorf("sent %d email requests with nothing pending", n)
+	}
+}
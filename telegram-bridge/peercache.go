@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// peerCacheFile is the JSON document exchanged by export_peer_cache and
+// import_peer_cache
+type peerCacheFile struct {
+	// UserID is the account the access hashes belong to
+	UserID     int64        `json:"user_id"`
+	ExportedAt int64        `json:"exported_at"`
+	Peers      []cachedPeer `json:"peers"`
+}
+
+type peerCachePathArgs struct {
+	Path string `json:"path"`
+}
+
+type peerCacheResult struct {
+	Path  string `json:"path"`
+	Peers int    `json:"peers"`
+}
+
+func exportPeerCacheTool(peers *peerResolver, pretty bool) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args peerCachePathArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.Path) == "" {
+			return nil, invalidParams("path is required")
+		}
+		n, err := exportPeerCache(ctx, peers, args.Path, pretty)
+		if err != nil {
+			return nil, err
+		}
+		return peerCacheResult{Path: args.Path, Peers: n}, nil
+	}
+}
+
+func importPeerCacheTool(peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args peerCachePathArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.Path) == "" {
+			return nil, invalidParams("path is required")
+		}
+		n, err := importPeerCache(ctx, peers, args.Path)
+		if err != nil {
+			return nil, err
+		}
+		return peerCacheResult{Path: args.Path, Peers: n}, nil
+	}
+}
+
+// exportPeerCache writes every cached peer to outPath so other bridge
+// instances logged into the same account can reuse the access hashes. It
+// returns the number of peers written.
+func exportPeerCache(_ context.Context, peers *peerResolver, outPath string, pretty bool) (int, error) {
+	peers.mu.RLock()
+	file := peerCacheFile{
+		UserID:     peers.selfID,
+		ExportedAt: time.Now().Unix(),
+		Peers:      make([]cachedPeer, 0, len(peers.peers)),
+	}
+	for _, p := range peers.peers {
+		file.Peers = append(file.Peers, p)
+	}
+	peers.mu.RUnlock()
+
+	data, err := marshalJSON(file, pretty)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal peer cache: %w", err)
+	}
+	if err := os.WriteFile(outPath, data, 0600); err != nil {
+		return 0, fmt.Errorf("failed to write peer cache '%s': %w", outPath, err)
+	}
+	return len(file.Peers), nil
+}
+
+// importPeerCache merges peers exported by another instance into the cache.
+// The file must belong to the same account. Invalid entries, and entries
+// whose access hash conflicts with a fresher local one, are skipped. It
+// returns the number of peers added or updated.
+func importPeerCache(_ context.Context, peers *peerResolver, inPath string) (int, error) {
+	data, err := os.ReadFile(inPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read peer cache: %w", err)
+	}
+	var file peerCacheFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return 0, fmt.Errorf("failed to parse peer cache '%s': %w", inPath, err)
+	}
+	if file.UserID != peers.selfID {
+		return 0, fmt.Errorf("peer cache belongs to user %d, not the logged-in user %d", file.UserID, peers.selfID)
+	}
+
+	peers.mu.Lock()
+	defer peers.mu.Unlock()
+
+	imported, skipped := 0, 0
+	for _, p := range file.Peers {
+		if !validCachedPeer(p) {
+			skipped++
+			continue
+		}
+		id := p.MarkedID()
+		if local, ok := peers.peers[id]; ok {
+			if local.AccessHash == p.AccessHash {
+				continue
+			}
+			if local.UpdatedAt >= p.UpdatedAt {
+				// Conflicting hash older than what this instance saw
+				skipped++
+				continue
+			}
+		}
+		peers.peers[id] = p
+		imported++
+	}
+	if skipped > 0 {
+		slog.Warn("Skipped invalid or stale peer cache entries", "count", skipped, "path", inPath)
+	}
+	return imported, nil
+}
+
+// validCachedPeer reports whether p can be used to address a peer
+func validCachedPeer(p cachedPeer) bool {
+	switch p.Type {
+	case peerChat:
+		return p.ID > 0
+	case peerUser, peerChannel:
+		return p.ID > 0 && p.AccessHash != 0
+	default:
+		return false
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPeerCacheRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "peers.json")
+	src := newPeerResolver(nil, 1)
+	src.store(cachedPeer{Type: peerUser, ID: 10, AccessHash: 100})
+	src.store(cachedPeer{Type: peerChat, ID: 20})
+	src.store(cachedPeer{Type: peerChannel, ID: 30, AccessHash: 300})
+
+	n, err := exportPeerCache(context.Background(), src, path, false)
+	if err != nil || n != 3 {
+		t.Fatalf("exportPeerCache() = %d, %v", n, err)
+	}
+
+	dst := newPeerResolver(nil, 1)
+	n, err = importPeerCache(context.Background(), dst, path)
+	if err != nil || n != 3 {
+		t.Fatalf("importPeerCache() = %d, %v", n, err)
+	}
+	for id, want := range src.peers {
+		got, ok := dst.cached(id)
+		if !ok || got != want {
+			t.Errorf("peer %d = %+v, want %+v", id, got, want)
+		}
+	}
+
+	// Importing the same file again changes nothing
+	if n, err := importPeerCache(context.Background(), dst, path); err != nil || n != 0 {
+		t.Errorf("second import = %d, %v; want 0", n, err)
+	}
+}
+
+func TestImportPeerCacheConflicts(t *testing.T) {
+	tests := []struct {
+		name     string
+		local    *cachedPeer
+		imported cachedPeer
+		want     int
+		wantHash int64
+	}{
+		{name: "new peer", imported: cachedPeer{Type: peerUser, ID: 10, AccessHash: 5, UpdatedAt: 100}, want: 1, wantHash: 5},
+		{name: "fresher conflicting hash", local: &cachedPeer{Type: peerUser, ID: 10, AccessHash: 4, UpdatedAt: 50}, imported: cachedPeer{Type: peerUser, ID: 10, AccessHash: 5, UpdatedAt: 100}, want: 1, wantHash: 5},
+		{name: "stale conflicting hash", local: &cachedPeer{Type: peerUser, ID: 10, AccessHash: 4, UpdatedAt: 200}, imported: cachedPeer{Type: peerUser, ID: 10, AccessHash: 5, UpdatedAt: 100}, want: 0, wantHash: 4},
+		{name: "missing access hash", imported: cachedPeer{Type: peerChannel, ID: 10, UpdatedAt: 100}, want: 0},
+		{name: "unknown type", imported: cachedPeer{Type: "bot", ID: 10, AccessHash: 5}, want: 0},
+		{name: "invalid id", imported: cachedPeer{Type: peerChat, ID: -3}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "peers.json")
+			writePeerCache(t, path, peerCacheFile{UserID: 1, Peers: []cachedPeer{tt.imported}})
+
+			peers := newPeerResolver(nil, 1)
+			if tt.local != nil {
+				peers.peers[tt.local.MarkedID()] = *tt.local
+			}
+			n, err := importPeerCache(context.Background(), peers, path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != tt.want {
+				t.Errorf("imported %d peers, want %d", n, tt.want)
+			}
+			got, _ := peers.cached(tt.imported.MarkedID())
+			if got.AccessHash != tt.wantHash {
+				t.Errorf("cached access hash = %d, want %d", got.AccessHash, tt.wantHash)
+			}
+		})
+	}
+}
+
+func TestImportPeerCacheOtherAccount(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "peers.json")
+	writePeerCache(t, path, peerCacheFile{UserID: 2, Peers: []cachedPeer{{Type: peerUser, ID: 10, AccessHash: 5}}})
+	_, err := importPeerCache(context.Background(), newPeerResolver(nil, 1), path)
+	if err == nil || !strings.Contains(err.Error(), "belongs to user 2") {
+		t.Errorf("importPeerCache() error = %v, want account mismatch", err)
+	}
+}
+
+func writePeerCache(t *testing.T, path string, file peerCacheFile) {
+	data, err := json.Marshal(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/gotd/td/tg"
+)
+
+// PeerInfo describes the peer behind a username. Found is false when no
+// account or chat uses the username; the other fields are then empty.
+type PeerInfo struct {
+	Found      bool   `json:"found"`
+	Username   string `json:"username"`
+	PeerID     int64  `json:"peer_id,omitempty"`
+	ID         int64  `json:"id,omitempty"`
+	AccessHash int64  `json:"access_hash,omitempty"`
+	Type       string `json:"type,omitempty"`
+	FirstName  string `json:"first_name,omitempty"`
+	LastName   string `json:"last_name,omitempty"`
+	Title      string `json:"title,omitempty"`
+	Bot        bool   `json:"bot,omitempty"`
+	Verified   bool   `json:"verified,omitempty"`
+	Deleted    bool   `json:"deleted,omitempty"`
+	// Scam and Fake are set when Telegram flagged the peer as reported for
+	// fraud or as impersonating someone
+	Scam bool `json:"scam,omitempty"`
+	Fake bool `json:"fake,omitempty"`
+}
+
+type resolvePeerArgs struct {
+	Username string `json:"username"`
+}
+
+// resolvePeerTool looks up usernames, remembering every peer it finds for
+// the lifetime of the process. Misses are not cached since a free username
+// can be taken at any time.
+func resolvePeerTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	var (
+		mu    sync.Mutex
+		cache = make(map[string]PeerInfo)
+	)
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args resolvePeerArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		username := strings.TrimPrefix(strings.TrimSpace(args.Username), "@")
+		if username == "" {
+			return nil, invalidParams("username is required")
+		}
+		// Usernames are case-insensitive
+		key := strings.ToLower(username)
+
+		mu.Lock()
+		info, ok := cache[key]
+		mu.Unlock()
+		if ok {
+			return info, nil
+		}
+
+		info, err := resolvePeer(ctx, api, peers, username)
+		if err != nil {
+			return nil, err
+		}
+		if info.Found {
+			mu.Lock()
+			cache[key] = info
+			mu.Unlock()
+		}
+		return info, nil
+	}
+}
+
+// resolvePeer resolves username with contacts.resolveUsername, reporting an
+// unknown or malformed username as not found rather than as an error
+func resolvePeer(ctx context.Context, api *tg.Client, peers *peerResolver, username string) (PeerInfo, error) {
+	var resolved *tg.ContactsResolvedPeer
+	err := withFloodRetry(ctx, func() (err error) {
+		resolved, err = api.ContactsResolveUsername(ctx, username)
+		return err
+	})
+	if tg.IsUsernameNotOccupied(err) || tg.IsUsernameInvalid(err) {
+		return PeerInfo{Username: username}, nil
+	}
+	if err != nil {
+		return PeerInfo{}, fmt.Errorf("failed to resolve @%s: %w", username, err)
+	}
+	peers.remember(resolved.Users, resolved.Chats)
+
+	info := PeerInfo{Found: true, Username: username}
+	switch p := resolved.Peer.(type) {
+	case *tg.PeerUser:
+		for _, u := range resolved.Users {
+			if u, ok := u.(*tg.User); ok && u.ID == p.UserID {
+				info.Type = peerUser
+				info.ID = u.ID
+				info.AccessHash = u.AccessHash
+				info.FirstName = u.FirstName
+				info.LastName = u.LastName
+				info.Bot = u.Bot
+				info.Verified = u.Verified
+				info.Deleted = u.Deleted
+				info.Scam = u.Scam
+				info.Fake = u.Fake
+			}
+		}
+	case *tg.PeerChannel:
+		for _, c := range resolved.Chats {
+			if c, ok := c.(*tg.Channel); ok && c.ID == p.ChannelID {
+				info.Type = peerChannel
+				info.ID = c.ID
+				info.AccessHash = c.AccessHash
+				info.Title = c.Title
+				info.Verified = c.Verified
+				info.Scam = c.Scam
+				info.Fake = c.Fake
+			}
+		}
+	}
+	if info.Type == "" {
+		return PeerInfo{}, fmt.Errorf("@%s resolved to %T without entity", username, resolved.Peer)
+	}
+	info.PeerID = cachedPeer{Type: info.Type, ID: info.ID}.MarkedID()
+	return info, nil
+}
+
+// SafetyCheck reports the trust flags Telegram keeps on a peer. Warning
+// explains a scam or fake flag and is empty otherwise.
+type SafetyCheck struct {
+	PeerID   int64  `json:"peer_id"`
+	Type     string `json:"type"`
+	Verified bool   `json:"verified"`
+	Scam     bool   `json:"scam"`
+	Fake     bool   `json:"fake"`
+	Warning  string `json:"warning,omitempty"`
+}
+
+func safetyCheckTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args peerArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.Peer) == "" {
+			return nil, invalidParams("peer is required")
+		}
+		return safetyCheck(ctx, api, peers, args.Peer)
+	}
+}
+
+// safetyCheck fetches the current entity of peer and reports its verified,
+// scam and fake flags. Basic groups carry no such flags.
+func safetyCheck(ctx context.Context, api *tg.Client, peers *peerResolver, peer string) (SafetyCheck, error) {
+	p, err := peers.resolve(ctx, peer)
+	if err != nil {
+		return SafetyCheck{}, err
+	}
+	check := SafetyCheck{PeerID: p.MarkedID(), Type: p.Type}
+
+	switch p.Type {
+	case peerUser:
+		var users []tg.UserClass
+		err = withFloodRetry(ctx, func() (err error) {
+			users, err = api.UsersGetUsers(ctx, []tg.InputUserClass{&tg.InputUser{UserID: p.ID, AccessHash: p.AccessHash}})
+			return err
+		})
+		if err != nil {
+			return SafetyCheck{}, fmt.Errorf("failed to get user: %w", err)
+		}
+		peers.remember(users, nil)
+		for _, u := range users {
+			if u, ok := u.(*tg.User); ok && u.ID == p.ID {
+				check.Verified, check.Scam, check.Fake = u.Verified, u.Scam, u.Fake
+			}
+		}
+	case peerChannel:
+		var chats tg.MessagesChatsClass
+		err = withFloodRetry(ctx, func() (err error) {
+			chats, err = api.ChannelsGetChannels(ctx, []tg.InputChannelClass{&tg.InputChannel{ChannelID: p.ID, AccessHash: p.AccessHash}})
+			return err
+		})
+		if err != nil {
+			return SafetyCheck{}, fmt.Errorf("failed to get channel: %w", err)
+		}
+		peers.remember(nil, chats.GetChats())
+		for _, c := range chats.GetChats() {
+			if c, ok := c.(*tg.Channel); ok && c.ID == p.ID {
+				check.Verified, check.Scam, check.Fake = c.Verified, c.Scam, c.Fake
+			}
+		}
+	}
+
+	switch {
+	case check.Scam:
+		check.Warning = "Telegram flagged this peer as a scam; do not send money or personal data"
+	case check.Fake:
+		check.Warning = "Telegram flagged this peer as impersonating a person or organization"
+	}
+	return check, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+)
+
+func TestResolvePeerFlags(t *testing.T) {
+	_, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		if _, ok := input.(*tg.ContactsResolveUsernameRequest); ok {
+			return &tg.ContactsResolvedPeer{
+				Peer:  &tg.PeerUser{UserID: 10},
+				Users: []tg.UserClass{&tg.User{ID: 10, AccessHash: 1, Scam: true, Fake: true}},
+			}, nil
+		}
+		return nil, nil
+	})
+	info, err := resolvePeer(context.Background(), api, peers, "crook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Scam || !info.Fake || info.Verified {
+		t.Errorf("info = %+v, want scam and fake", info)
+	}
+}
+
+func TestSafetyCheck(t *testing.T) {
+	tests := []struct {
+		name         string
+		peer         string
+		wantScam     bool
+		wantFake     bool
+		wantVerified bool
+		wantWarning  bool
+	}{
+		{name: "scam user", peer: "10", wantScam: true, wantWarning: true},
+		{name: "fake channel", peer: "-1000000000030", wantFake: true, wantWarning: true},
+		{name: "verified channel", peer: "-1000000000031", wantVerified: true},
+		{name: "basic group", peer: "-20"},
+	}
+	_, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		switch req := input.(type) {
+		case *tg.UsersGetUsersRequest:
+			return &tg.UserClassVector{Elems: []tg.UserClass{&tg.User{ID: 10, AccessHash: 1, Scam: true}}}, nil
+		case *tg.ChannelsGetChannelsRequest:
+			id := req.ID[0].(*tg.InputChannel).ChannelID
+			c := &tg.Channel{ID: id, AccessHash: 3, Photo: &tg.ChatPhotoEmpty{}, Fake: id == 30, Verified: id == 31}
+			return &tg.MessagesChats{Chats: []tg.ChatClass{c}}, nil
+		}
+		return nil, nil
+	})
+	peers.storeUser(&tg.User{ID: 10, AccessHash: 1})
+	peers.storeChannel(&tg.Channel{ID: 30, AccessHash: 3})
+	peers.storeChannel(&tg.Channel{ID: 31, AccessHash: 3})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check, err := safetyCheck(context.Background(), api, peers, tt.peer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if check.Scam != tt.wantScam || check.Fake != tt.wantFake || check.Verified != tt.wantVerified ||
+				(check.Warning != "") != tt.wantWarning {
+				t.Errorf("check = %+v", check)
+			}
+		})
+	}
+}
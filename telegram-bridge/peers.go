@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gotd/td/constant"
+	"github.com/gotd/td/telegram/query"
+	"github.com/gotd/td/tg"
+)
+
+// Peer types as reported by tools
+const (
+	peerUser    = "user"
+	peerChat    = "chat"
+	peerChannel = "channel"
+)
+
+// cachedPeer is a resolved peer together with the access hash needed to
+// address it
+type cachedPeer struct {
+	Type       string `json:"type"`
+	ID         int64  `json:"id"`
+	AccessHash int64  `json:"access_hash"`
+	// UpdatedAt is when the peer was last seen in an API response, as a
+	// Unix time
+	UpdatedAt int64 `json:"updated_at"`
+}
+
+// MarkedID returns the peer ID in Bot API form: users are positive, basic
+// groups negative and channels prefixed with -100
+func (p cachedPeer) MarkedID() int64 {
+	var id constant.TDLibPeerID
+	switch p.Type {
+	case peerChat:
+		id.Chat(p.ID)
+	case peerChannel:
+		id.Channel(p.ID)
+	default:
+		id.User(p.ID)
+	}
+	return int64(id)
+}
+
+// InputPeer returns the peer as an API input
+func (p cachedPeer) InputPeer() tg.InputPeerClass {
+	switch p.Type {
+	case peerChat:
+		return &tg.InputPeerChat{ChatID: p.ID}
+	case peerChannel:
+		return &tg.InputPeerChannel{ChannelID: p.ID, AccessHash: p.AccessHash}
+	default:
+		return &tg.InputPeerUser{UserID: p.ID, AccessHash: p.AccessHash}
+	}
+}
+
+// peerResolver turns tool peer arguments into input peers, caching every user
+// and chat it sees so repeated lookups avoid extra RPCs
+type peerResolver struct {
+	api *tg.Client
+	// selfID is the logged-in user; access hashes are only valid for them
+	selfID int64
+	self   *selfCache
+
+	mu    sync.RWMutex
+	peers map[int64]cachedPeer // keyed by marked ID
+}
+
+func newPeerResolver(api *tg.Client, selfID int64) *peerResolver {
+	return &peerResolver{
+		api:    api,
+		selfID: selfID,
+		self:   &selfCache{},
+		peers:  make(map[int64]cachedPeer),
+	}
+}
+
+// Resolve accepts a @username (the @ is optional), a numeric Bot API style
+// peer ID, or "me" for the logged-in account, and returns the matching input
+// peer
+func (r *peerResolver) Resolve(ctx context.Context, peer string) (tg.InputPeerClass, error) {
+	p, err := r.resolve(ctx, peer)
+	if err != nil {
+		return nil, err
+	}
+	return p.InputPeer(), nil
+}
+
+// ResolveChannel resolves peer and requires it to be a channel or supergroup
+func (r *peerResolver) ResolveChannel(ctx context.Context, peer string) (*tg.InputChannel, error) {
+	p, err := r.resolve(ctx, peer)
+	if err != nil {
+		return nil, err
+	}
+	if p.Type != peerChannel {
+		return nil, fmt.Errorf("%s is not a channel or supergroup", peer)
+	}
+	return &tg.InputChannel{ChannelID: p.ID, AccessHash: p.AccessHash}, nil
+}
+
+// ResolveUser resolves peer and requires it to be a user
+func (r *peerResolver) ResolveUser(ctx context.Context, peer string) (*tg.InputUser, error) {
+	p, err := r.resolve(ctx, peer)
+	if err != nil {
+		return nil, err
+	}
+	if p.Type != peerUser {
+		return nil, fmt.Errorf("%s is not a user", peer)
+	}
+	return &tg.InputUser{UserID: p.ID, AccessHash: p.AccessHash}, nil
+}
+
+func (r *peerResolver) resolve(ctx context.Context, peer string) (cachedPeer, error) {
+	peer = strings.TrimSpace(peer)
+	if peer == "" {
+		return cachedPeer{}, fmt.Errorf("empty peer")
+	}
+	if selfPeerNames[strings.ToLower(peer)] {
+		u, err := r.Self(ctx)
+		if err != nil {
+			return cachedPeer{}, err
+		}
+		return cachedPeer{Type: peerUser, ID: u.ID, AccessHash: u.AccessHash}, nil
+	}
+	if id, err := strconv.ParseInt(peer, 10, 64); err == nil {
+		return r.resolveID(ctx, id)
+	}
+	return r.resolveUsername(ctx, strings.TrimPrefix(peer, "@"))
+}
+
+func (r *peerResolver) resolveUsername(ctx context.Context, username string) (cachedPeer, error) {
+	resolved, err := r.api.ContactsResolveUsername(ctx, username)
+	if err != nil {
+		return cachedPeer{}, fmt.Errorf("failed to resolve @%s: %w", username, err)
+	}
+	r.remember(resolved.Users, resolved.Chats)
+
+	id, err := markedPeerID(resolved.Peer)
+	if err != nil {
+		return cachedPeer{}, err
+	}
+	if p, ok := r.cached(id); ok {
+		return p, nil
+	}
+	return cachedPeer{}, fmt.Errorf("@%s resolved to peer %d without entity", username, id)
+}
+
+// errNoCachedHash is returned for a numeric peer ID whose type cannot be
+// told, or whose access hash is unknown, after searching the dialogs
+var errNoCachedHash = errors.New("cannot classify peer ID without a cached access hash")
+
+// resolveID resolves a numeric peer ID. Marked IDs (negative for groups,
+// -100 prefixed for channels) name one type; a positive ID may also be the
+// bare ID of a chat or channel as it appears in API peers, so every form
+// with a cached entry is tried.
+func (r *peerResolver) resolveID(ctx context.Context, id int64) (cachedPeer, error) {
+	candidates, err := peerIDCandidates(id)
+	if err != nil {
+		return cachedPeer{}, err
+	}
+	if p, ok := r.cachedAny(candidates); ok {
+		return p, nil
+	}
+	if len(candidates) == 1 && candidates[0].Type == peerChat {
+		// Basic groups need no access hash
+		return candidates[0], nil
+	}
+
+	// Users and channels need an access hash; look for them in the dialogs
+	iter := query.GetDialogs(r.api).BatchSize(100).Iter()
+	for iter.Next(ctx) {
+		r.rememberEntities(iter.Value().Entities.Users(), iter.Value().Entities.Channels())
+		if p, ok := r.cachedAny(candidates); ok {
+			return p, nil
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return cachedPeer{}, fmt.Errorf("failed to search dialogs for peer %d: %w", id, err)
+	}
+	return cachedPeer{}, fmt.Errorf("%w: %d is not in the peer cache or your dialogs; use a @username instead", errNoCachedHash, id)
+}
+
+// peerIDCandidates returns the peers a numeric ID may name, most likely
+// first
+func peerIDCandidates(id int64) ([]cachedPeer, error) {
+	marked := constant.TDLibPeerID(id)
+	switch {
+	case id > 0 && marked.IsUser():
+		return []cachedPeer{
+			{Type: peerUser, ID: id},
+			{Type: peerChannel, ID: id},
+			{Type: peerChat, ID: id},
+		}, nil
+	case marked.IsChat():
+		return []cachedPeer{{Type: peerChat, ID: marked.ToPlain()}}, nil
+	case marked.IsChannel():
+		return []cachedPeer{{Type: peerChannel, ID: marked.ToPlain()}}, nil
+	}
+	return nil, fmt.Errorf("invalid peer ID %d", id)
+}
+
+// cachedAny returns the cached entry of the first candidate that has one
+func (r *peerResolver) cachedAny(candidates []cachedPeer) (cachedPeer, bool) {
+	for _, c := range candidates {
+		if p, ok := r.cached(c.MarkedID()); ok {
+			return p, true
+		}
+	}
+	return cachedPeer{}, false
+}
+
+func (r *peerResolver) cached(id int64) (cachedPeer, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.peers[id]
+	return p, ok
+}
+
+func (r *peerResolver) store(p cachedPeer) {
+	p.UpdatedAt = time.Now().Unix()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.peers[p.MarkedID()] = p
+}
+
+// remember caches the users and chats returned alongside an API response
+func (r *peerResolver) remember(users []tg.UserClass, chats []tg.ChatClass) {
+	for _, u := range users {
+		if u, ok := u.(*tg.User); ok {
+			r.storeUser(u)
+		}
+	}
+	for _, c := range chats {
+		switch c := c.(type) {
+		case *tg.Chat:
+			r.store(cachedPeer{Type: peerChat, ID: c.ID})
+		case *tg.Channel:
+			r.storeChannel(c)
+		}
+	}
+}
+
+func (r *peerResolver) rememberEntities(users map[int64]*tg.User, channels map[int64]*tg.Channel) {
+	for _, u := range users {
+		r.storeUser(u)
+	}
+	for _, c := range channels {
+		r.storeChannel(c)
+	}
+}
+
+// storeUser caches u unless it is a min entity, whose access hash cannot be
+// used to address it
+func (r *peerResolver) storeUser(u *tg.User) {
+	if u.Min {
+		return
+	}
+	r.store(cachedPeer{Type: peerUser, ID: u.ID, AccessHash: u.AccessHash})
+	if u.Self {
+		// Keep the cached account current, e.g. its Premium status
+		r.self.set(u)
+	}
+}
+
+// storeChannel caches c unless it is a min entity
+func (r *peerResolver) storeChannel(c *tg.Channel) {
+	if !c.Min {
+		r.store(cachedPeer{Type: peerChannel, ID: c.ID, AccessHash: c.AccessHash})
+	}
+}
+
+// markedPeerID converts an API peer to its Bot API style ID
+func markedPeerID(p tg.PeerClass) (int64, error) {
+	switch p := p.(type) {
+	case *tg.PeerUser:
+		return cachedPeer{Type: peerUser, ID: p.UserID}.MarkedID(), nil
+	case *tg.PeerChat:
+		return cachedPeer{Type: peerChat, ID: p.ChatID}.MarkedID(), nil
+	case *tg.PeerChannel:
+		return cachedPeer{Type: peerChannel, ID: p.ChannelID}.MarkedID(), nil
+	default:
+		return 0, fmt.Errorf("unexpected peer type %T", p)
+	}
+}
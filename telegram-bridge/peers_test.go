@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+)
+
+func TestPeerIDCandidates(t *testing.T) {
+	tests := []struct {
+		id   int64
+		want []cachedPeer
+	}{
+		{id: 10, want: []cachedPeer{{Type: peerUser, ID: 10}, {Type: peerChannel, ID: 10}, {Type: peerChat, ID: 10}}},
+		{id: -20, want: []cachedPeer{{Type: peerChat, ID: 20}}},
+		{id: -1000000000030, want: []cachedPeer{{Type: peerChannel, ID: 30}}},
+	}
+	for _, tt := range tests {
+		got, err := peerIDCandidates(tt.id)
+		if err != nil {
+			t.Errorf("peerIDCandidates(%d): %v", tt.id, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("peerIDCandidates(%d) = %+v, want %+v", tt.id, got, tt.want)
+		}
+	}
+	if _, err := peerIDCandidates(0); err == nil {
+		t.Error("peer ID 0: want error")
+	}
+}
+
+func TestResolveIDClassifies(t *testing.T) {
+	_, _, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		return nil, nil
+	})
+	peers.storeUser(&tg.User{ID: 10, AccessHash: 1})
+	peers.storeChannel(&tg.Channel{ID: 30, AccessHash: 3})
+	peers.remember(nil, []tg.ChatClass{&tg.Chat{ID: 40, Photo: &tg.ChatPhotoEmpty{}}})
+
+	tests := []struct {
+		peer string
+		want tg.InputPeerClass
+	}{
+		{"10", &tg.InputPeerUser{UserID: 10, AccessHash: 1}},
+		// A bare channel ID as found in API peers
+		{"30", &tg.InputPeerChannel{ChannelID: 30, AccessHash: 3}},
+		{"-1000000000030", &tg.InputPeerChannel{ChannelID: 30, AccessHash: 3}},
+		// A bare basic group ID, and a marked one not seen before
+		{"40", &tg.InputPeerChat{ChatID: 40}},
+		{"-20", &tg.InputPeerChat{ChatID: 20}},
+	}
+	for _, tt := range tests {
+		got, err := peers.Resolve(context.Background(), tt.peer)
+		if err != nil {
+			t.Errorf("Resolve(%s): %v", tt.peer, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Resolve(%s) = %#v, want %#v", tt.peer, got, tt.want)
+		}
+	}
+}
+
+func TestResolveIDWithoutCachedHash(t *testing.T) {
+	inv, _, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		if _, ok := input.(*tg.MessagesGetDialogsRequest); ok {
+			return &tg.MessagesDialogs{}, nil
+		}
+		return nil, nil
+	})
+	for _, peer := range []string{"11", "-1000000000031"} {
+		if _, err := peers.Resolve(context.Background(), peer); !errors.Is(err, errNoCachedHash) {
+			t.Errorf("Resolve(%s) = %v, want %v", peer, err, errNoCachedHash)
+		}
+	}
+	if n := len(requests[*tg.MessagesGetDialogsRequest](inv)); n != 2 {
+		t.Errorf("searched dialogs %d times, want once per peer", n)
+	}
+}
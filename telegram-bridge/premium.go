@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gotd/td/tg"
+)
+
+// PremiumGiftOption is a Telegram Premium subscription that can be gifted
+type PremiumGiftOption struct {
+	Months   int    `json:"months"`
+	Currency string `json:"currency"`
+	// Amount is the price in the smallest units of Currency, e.g. cents
+	Amount       int64  `json:"amount"`
+	BotURL       string `json:"bot_url"`
+	StoreProduct string `json:"store_product,omitempty"`
+}
+
+type premiumGiftOptionsArgs struct {
+	User string `json:"user"`
+}
+
+type premiumGiftOptionsResult struct {
+	Options []PremiumGiftOption `json:"options"`
+}
+
+func getPremiumGiftOptionsTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args premiumGiftOptionsArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		options, err := getPremiumGiftOptions(ctx, api, peers, args.User)
+		if err != nil {
+			return nil, err
+		}
+		return premiumGiftOptionsResult{Options: options}, nil
+	}
+}
+
+// getPremiumGiftOptions returns the Premium subscriptions that can be gifted
+// to userPeer, or to yourself when it is empty. Telegram lists no options
+// when gifting is disabled for the account or region, which gives an empty
+// list.
+func getPremiumGiftOptions(ctx context.Context, api *tg.Client, peers *peerResolver, userPeer string) ([]PremiumGiftOption, error) {
+	var user tg.InputUserClass = &tg.InputUserSelf{}
+	if strings.TrimSpace(userPeer) != "" {
+		u, err := peers.ResolveUser(ctx, userPeer)
+		if err != nil {
+			return nil, err
+		}
+		user = u
+	}
+
+	var full *tg.UsersUserFull
+	err := withFloodRetry(ctx, func() (err error) {
+		full, err = api.UsersGetFullUser(ctx, user)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	peers.remember(full.Users, full.Chats)
+	return newPremiumGiftOptions(full.FullUser.PremiumGifts), nil
+}
+
+func newPremiumGiftOptions(gifts []tg.PremiumGiftOption) []PremiumGiftOption {
+	result := make([]PremiumGiftOption, 0, len(gifts))
+	for _, g := range gifts {
+		result = append(result, PremiumGiftOption{
+			Months:       g.Months,
+			Currency:     g.Currency,
+			Amount:       g.Amount,
+			BotURL:       g.BotURL,
+			StoreProduct: g.StoreProduct,
+		})
+	}
+	return result
+}
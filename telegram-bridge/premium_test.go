@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+)
+
+func TestGetPremiumGiftOptions(t *testing.T) {
+	full := &tg.UserFull{ID: 10, Settings: tg.PeerSettings{}, NotifySettings: tg.PeerNotifySettings{}}
+	full.SetPremiumGifts([]tg.PremiumGiftOption{
+		{Months: 3, Currency: "USD", Amount: 1199, BotURL: "https://t.me/PremiumBot?start=3"},
+		{Months: 12, Currency: "USD", Amount: 3599, BotURL: "https://t.me/PremiumBot?start=12", StoreProduct: "premium.12"},
+	})
+	inv, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		if _, ok := input.(*tg.UsersGetFullUserRequest); ok {
+			return &tg.UsersUserFull{FullUser: *full, Users: []tg.UserClass{&tg.User{ID: 10, AccessHash: 1}}}, nil
+		}
+		return nil, nil
+	})
+	peers.storeUser(&tg.User{ID: 10, AccessHash: 1})
+
+	options, err := getPremiumGiftOptions(context.Background(), api, peers, "10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(options) != 2 || options[0].Months != 3 || options[0].Amount != 1199 ||
+		options[1].StoreProduct != "premium.12" || options[1].Currency != "USD" {
+		t.Errorf("options = %+v", options)
+	}
+	reqs := requests[*tg.UsersGetFullUserRequest](inv)
+	if u, ok := reqs[0].ID.(*tg.InputUser); !ok || u.UserID != 10 {
+		t.Errorf("requested %#v, want user 10", reqs[0].ID)
+	}
+}
+
+func TestGetPremiumGiftOptionsSelfDisabled(t *testing.T) {
+	inv, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		if _, ok := input.(*tg.UsersGetFullUserRequest); ok {
+			return &tg.UsersUserFull{FullUser: tg.UserFull{ID: 1}}, nil
+		}
+		return nil, nil
+	})
+	options, err := getPremiumGiftOptions(context.Background(), api, peers, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if options == nil || len(options) != 0 {
+		t.Errorf("options = %#v, want empty list", options)
+	}
+	if _, ok := requests[*tg.UsersGetFullUserRequest](inv)[0].ID.(*tg.InputUserSelf); !ok {
+		t.Error("empty user should request yourself")
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/gotd/td/tg"
+)
+
+// Bio length limits in UTF-16 code units, from the about_length_limit_*
+// app config values
+const (
+	maxBioLength        = 70
+	maxBioLengthPremium = 140
+)
+
+// Bio entity types
+const (
+	bioURL     = "url"
+	bioMention = "mention"
+)
+
+// bioURLPattern matches the links the apps turn into clickable URLs in a bio
+var bioURLPattern = regexp.MustCompile(`(?i)\b(?:https?://|t\.me/)\S+`)
+
+// Bio is the account's about text. Bios are plain text on the API; the apps
+// link URLs and @usernames in it, which Entities reports.
+type Bio struct {
+	About     string      `json:"about"`
+	Length    int         `json:"length"`
+	MaxLength int         `json:"max_length"`
+	Entities  []BioEntity `json:"entities"`
+}
+
+// BioEntity is a link or mention in a bio, with offsets in UTF-16 code units
+type BioEntity struct {
+	Type   string `json:"type"`
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+	Text   string `json:"text"`
+}
+
+type setBioArgs struct {
+	About string `json:"about"`
+}
+
+func getBioTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args struct{}
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		return getBio(ctx, api, peers)
+	}
+}
+
+func setBioTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args setBioArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		return setBio(ctx, api, peers, args.About)
+	}
+}
+
+// getBio fetches the account's bio
+func getBio(ctx context.Context, api *tg.Client, peers *peerResolver) (Bio, error) {
+	var full *tg.UsersUserFull
+	err := withFloodRetry(ctx, func() (err error) {
+		full, err = api.UsersGetFullUser(ctx, &tg.InputUserSelf{})
+		return err
+	})
+	if err != nil {
+		return Bio{}, fmt.Errorf("failed to get own profile: %w", err)
+	}
+	peers.remember(full.Users, full.Chats)
+	limit, err := bioLimit(ctx, peers)
+	if err != nil {
+		return Bio{}, err
+	}
+	return newBio(full.FullUser.About, limit), nil
+}
+
+// setBio replaces the account's bio with about, which may be empty to clear
+// it. Links and @usernames need no markup; custom emoji are not allowed.
+func setBio(ctx context.Context, api *tg.Client, peers *peerResolver, about string) (Bio, error) {
+	limit, err := bioLimit(ctx, peers)
+	if err != nil {
+		return Bio{}, err
+	}
+	if err := validBio(about, limit); err != nil {
+		return Bio{}, err
+	}
+	req := &tg.AccountUpdateProfileRequest{}
+	req.SetAbout(about)
+	err = withFloodRetry(ctx, func() error {
+		_, err := api.AccountUpdateProfile(ctx, req)
+		return err
+	})
+	switch {
+	case tg.IsAboutTooLong(err):
+		return Bio{}, invalidParams("bio is longer than Telegram allows for this account")
+	case err != nil:
+		return Bio{}, fmt.Errorf("failed to update bio: %w", err)
+	}
+	return newBio(about, limit), nil
+}
+
+// bioLimit is the bio length allowed for the account
+func bioLimit(ctx context.Context, peers *peerResolver) (int, error) {
+	self, err := peers.Self(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if self.Premium {
+		return maxBioLengthPremium, nil
+	}
+	return maxBioLength, nil
+}
+
+// validBio checks about against the bio rules for a limit of limit UTF-16
+// code units
+func validBio(about string, limit int) error {
+	if customEmojiPattern.MatchString(about) {
+		return invalidParams("bios are plain text; custom emoji cannot be used")
+	}
+	if n := utf16Len(about); n > limit {
+		return invalidParams("bio is %d characters, the limit is %d", n, limit)
+	}
+	return nil
+}
+
+func newBio(about string, limit int) Bio {
+	return Bio{About: about, Length: utf16Len(about), MaxLength: limit, Entities: bioEntities(about)}
+}
+
+// bioEntities finds the links and mentions the apps highlight in about,
+// ordered by offset
+func bioEntities(about string) []BioEntity {
+	entities := []BioEntity{}
+	urls := bioURLPattern.FindAllStringIndex(about, -1)
+	for _, m := range urls {
+		entities = append(entities, BioEntity{
+			Type:   bioURL,
+			Offset: utf16Len(about[:m[0]]),
+			Length: utf16Len(about[m[0]:m[1]]),
+			Text:   about[m[0]:m[1]],
+		})
+	}
+	for _, m := range mentionPattern.FindAllStringSubmatchIndex(about, -1) {
+		start, end := m[2], m[3]
+		if insideAny(start, urls) {
+			continue
+		}
+		entities = append(entities, BioEntity{
+			Type:   bioMention,
+			Offset: utf16Len(about[:start]),
+			Length: utf16Len(about[start:end]),
+			Text:   about[start:end],
+		})
+	}
+	sort.Slice(entities, func(i, j int) bool { return entities[i].Offset < entities[j].Offset })
+	return entities
+}
+
+// insideAny reports whether byte offset i falls in one of the spans
+func insideAny(i int, spans [][]int) bool {
+	for _, span := range spans {
+		if i >= span[0] && i < span[1] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+)
+
+func TestValidBio(t *testing.T) {
+	for _, tt := range []struct {
+		about string
+		limit int
+		ok    bool
+	}{
+		{"", maxBioLength, true},
+		{strings.Repeat("a", 70), maxBioLength, true},
+		{strings.Repeat("a", 71), maxBioLength, false},
+		{strings.Repeat("a", 71), maxBioLengthPremium, true},
+		// Each 😀 is two UTF-16 code units
+		{strings.Repeat("😀", 35), maxBioLength, true},
+		{strings.Repeat("😀", 36), maxBioLength, false},
+		{"hot [emoji:42]", maxBioLengthPremium, false},
+	} {
+		err := validBio(tt.about, tt.limit)
+		var rpcErr *rpcError
+		if tt.ok && err != nil || !tt.ok && !(errors.As(err, &rpcErr) && rpcErr.Code == rpcInvalidParams) {
+			t.Errorf("validBio(%q, %d) = %v, want ok %v", tt.about, tt.limit, err, tt.ok)
+		}
+	}
+}
+
+func TestBioEntities(t *testing.T) {
+	got := bioEntities("😀 dev at @acme_corp, see https://x.com/@me or t.me/acme")
+	want := []BioEntity{
+		{Type: bioMention, Offset: 10, Length: 10, Text: "@acme_corp"},
+		{Type: bioURL, Offset: 26, Length: 17, Text: "https://x.com/@me"},
+		{Type: bioURL, Offset: 47, Length: 9, Text: "t.me/acme"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("bioEntities = %+v, want %+v", got, want)
+	}
+	if got := bioEntities("plain"); got == nil || len(got) != 0 {
+		t.Errorf("plain bio entities = %#v, want empty list", got)
+	}
+}
+
+func TestSetBio(t *testing.T) {
+	inv, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		if _, ok := input.(*tg.AccountUpdateProfileRequest); ok {
+			return &tg.User{ID: 1, Self: true}, nil
+		}
+		return nil, nil
+	})
+	peers.self.set(&tg.User{ID: 1, Self: true})
+
+	bio, err := setBio(context.Background(), api, peers, "Go dev @gopher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bio.Length != 14 || bio.MaxLength != maxBioLength || len(bio.Entities) != 1 {
+		t.Errorf("bio = %+v", bio)
+	}
+	reqs := requests[*tg.AccountUpdateProfileRequest](inv)
+	if about, ok := reqs[0].GetAbout(); len(reqs) != 1 || !ok || about != "Go dev @gopher" {
+		t.Errorf("requests = %+v", reqs)
+	}
+	if _, ok := reqs[0].GetFirstName(); ok {
+		t.Error("setting the bio also changed the first name")
+	}
+
+	// Without Premium a 100-character bio is refused before any request
+	if _, err := setBio(context.Background(), api, peers, strings.Repeat("a", 100)); err == nil {
+		t.Error("want length error")
+	}
+	peers.self.set(&tg.User{ID: 1, Self: true, Premium: true})
+	if _, err := setBio(context.Background(), api, peers, strings.Repeat("a", 100)); err != nil {
+		t.Errorf("premium bio: %v", err)
+	}
+	if n := len(requests[*tg.AccountUpdateProfileRequest](inv)); n != 2 {
+		t.Errorf("sent %d updates, want 2", n)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/gotd/td/telegram/dcs"
+	"golang.org/x/net/proxy"
+	"gopkg.in/ini.v1"
+)
+
+// proxyCheckTimeout bounds the startup reachability check of the proxy
+const proxyCheckTimeout = 10 * time.Second
+
+// ProxyConfig is the [proxy] section of config.ini
+type ProxyConfig struct {
+	Type string // "socks5" or "none"
+	Addr string
+	User string
+	Pass string
+}
+
+// loadProxyConfig reads the [proxy] section; a missing section means no proxy
+func loadProxyConfig(cfg *ini.File) (ProxyConfig, error) {
+	section := cfg.Section("proxy")
+	pc := ProxyConfig{
+		Type: section.Key("type").In("none", []string{"none", "socks5"}),
+		Addr: section.Key("addr").String(),
+		User: section.Key("user").String(),
+		Pass: section.Key("pass").String(),
+	}
+	if pc.Type == "socks5" && pc.Addr == "" {
+		return pc, errors.New("[proxy] addr must be set when type = socks5")
+	}
+	return pc, nil
+}
+
+// proxyResolver returns a DC resolver dialing through the configured proxy,
+// or nil for direct connections. All client connections, including media
+// transfers, go through the resolver. The proxy must be reachable.
+func proxyResolver(pc ProxyConfig) (dcs.Resolver, error) {
+	if pc.Type != "socks5" {
+		return nil, nil
+	}
+
+	conn, err := net.DialTimeout("tcp", pc.Addr, proxyCheckTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("SOCKS5 proxy %s is unreachable: %w", pc.Addr, err)
+	}
+	conn.Close()
+
+	var auth *proxy.Auth
+	if pc.User != "" {
+		auth = &proxy.Auth{User: pc.User, Password: pc.Pass}
+	}
+	dialer, err := proxy.SOCKS5("tcp", pc.Addr, auth, proxy.Direct)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure SOCKS5 proxy: %w", err)
+	}
+	contextDialer, ok := dialer.(proxy.ContextDialer)
+	if !ok {
+		return nil, errors.New("SOCKS5 dialer does not support contexts")
+	}
+
+	return dcs.Plain(dcs.PlainOptions{
+		Dial: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return contextDialer.DialContext(ctx, network, addr)
+		},
+	}), nil
+}
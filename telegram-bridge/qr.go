@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// QROpts controls where renderQR writes the login QR code
+type QROpts struct {
+	PNGPath string    // PNG output path, skipped when empty
+	Size    int       // PNG size in pixels
+	Out     io.Writer // terminal output, os.Stderr when nil
+}
+
+// renderQR renders url as a QR code, trying in order a PNG file (if a path is
+// configured), terminal art and finally the raw URL, so the user always has
+// something to scan or open. Terminal output goes to stderr since stdout
+// carries MCP traffic. It returns the outputs that succeeded, of "png",
+// "terminal" and "url", and fails only when none did.
+func renderQR(url string, opts QROpts) ([]string, error) {
+	if url == "" {
+		return nil, errors.New("empty QR code URL")
+	}
+	out := opts.Out
+	if out == nil {
+		out = os.Stderr
+	}
+
+	var outputs []string
+	if opts.PNGPath != "" {
+		size := opts.Size
+		if size <= 0 {
+			size = 256
+		}
+		if err := qrcode.WriteFile(url, qrcode.Medium, size, opts.PNGPath); err != nil {
+			slog.Error("Failed to generate QR code image", "error", err)
+		} else {
+			slog.Info("QR code saved", "path", opts.PNGPath)
+			outputs = append(outputs, "png")
+		}
+	}
+
+	if err := renderTerminalQR(out, url); err != nil {
+		slog.Error("Failed to render terminal QR code", "error", err)
+		// Last resort: print the URL itself
+		if _, err := fmt.Fprintln(out, url); err != nil {
+			slog.Error("Failed to print QR code URL", "error", err)
+		} else {
+			outputs = append(outputs, "url")
+		}
+	} else {
+		outputs = append(outputs, "terminal")
+	}
+
+	if len(outputs) == 0 {
+		return nil, errors.New("no QR code output succeeded")
+	}
+	return outputs, nil
+}
+
+// renderTerminalQR writes url to w as QR code art
+func renderTerminalQR(w io.Writer, url string) error {
+	qr, err := qrcode.New(url, qrcode.Medium)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, qr.ToSmallString(false))
+	return err
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// failingWriter rejects every write
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestRenderQR(t *testing.T) {
+	dir := t.TempDir()
+	const url = "tg://login?token=AQID"
+	// Too long to encode, so every QR output fails and only the URL is left
+	long := "tg://login?token=" + strings.Repeat("A", 4000)
+
+	tests := []struct {
+		name         string
+		url          string
+		png          string
+		failOut      bool
+		wantPNG      bool
+		wantTerminal bool
+		wantURL      bool
+		wantOutputs  []string
+		wantErr      bool
+	}{
+		{name: "png and terminal", url: url, png: filepath.Join(dir, "ok.png"), wantPNG: true, wantTerminal: true, wantOutputs: []string{"png", "terminal"}},
+		{name: "terminal without png path", url: url, wantTerminal: true, wantOutputs: []string{"terminal"}},
+		{name: "terminal when png fails", url: url, png: filepath.Join(dir, "missing", "qr.png"), wantTerminal: true, wantOutputs: []string{"terminal"}},
+		{name: "url when terminal fails", url: long, png: filepath.Join(dir, "long.png"), wantURL: true, wantOutputs: []string{"url"}},
+		{name: "all outputs fail", url: long, png: filepath.Join(dir, "fail.png"), failOut: true, wantErr: true},
+		{name: "empty url", url: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			opts := QROpts{PNGPath: tt.png, Size: 64, Out: &out}
+			if tt.failOut {
+				opts.Out = failingWriter{}
+			}
+
+			outputs, err := renderQR(tt.url, opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("renderQR() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.Equal(outputs, tt.wantOutputs) {
+				t.Errorf("outputs = %v, want %v", outputs, tt.wantOutputs)
+			}
+
+			_, statErr := os.Stat(tt.png)
+			if gotPNG := tt.png != "" && statErr == nil; gotPNG != tt.wantPNG {
+				t.Errorf("png written = %v, want %v", gotPNG, tt.wantPNG)
+			}
+			text := out.String()
+			if gotURL := strings.Contains(text, tt.url) && tt.url != ""; gotURL != tt.wantURL {
+				t.Errorf("url printed = %v, want %v", gotURL, tt.wantURL)
+			}
+			if gotTerminal := text != "" && !strings.Contains(text, "tg://"); gotTerminal != tt.wantTerminal {
+				t.Errorf("terminal art printed = %v, want %v", gotTerminal, tt.wantTerminal)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+// Connection quality levels reported by get_connection_quality
+const (
+	qualityUnknown  = "unknown"
+	qualityGood     = "good"
+	qualityDegraded = "degraded"
+	qualityPoor     = "poor"
+)
+
+// Quality thresholds on the p95 latency and the failure rate of recent
+// requests
+const (
+	degradedLatency   = time.Second
+	poorLatency       = 3 * time.Second
+	degradedErrorRate = 0.05
+	poorErrorRate     = 0.2
+)
+
+// Only the most recent requests within qualityWindow count towards quality
+const (
+	qualityWindow     = 5 * time.Minute
+	maxQualitySamples = 200
+)
+
+// Flood waits are remembered for floodHistoryWindow, at most
+// maxFloodEvents of them
+const (
+	floodHistoryWindow = time.Hour
+	maxFloodEvents     = 100
+)
+
+// floodEvent is a FLOOD_WAIT_X error Telegram returned for method
+type floodEvent struct {
+	At     time.Time
+	Method string
+	Wait   time.Duration
+}
+
+type rpcSample struct {
+	At      time.Time
+	Latency time.Duration
+	Failed  bool
+}
+
+// Quality is the classified state of the connection to Telegram with the
+// numbers behind it
+type Quality struct {
+	Status       string  `json:"status"`
+	Samples      int     `json:"samples"`
+	AvgLatencyMS int64   `json:"avg_latency_ms"`
+	P95LatencyMS int64   `json:"p95_latency_ms"`
+	ErrorRate    float64 `json:"error_rate"`
+}
+
+// qualityMonitor is a client middleware timing every RPC. Errors returned by
+// Telegram itself, such as an unknown username, mean the connection worked
+// and count as successes; network failures and timeouts count as failures.
+type qualityMonitor struct {
+	// ping sends a keepalive ping, measured by getConnectionQuality
+	ping func(ctx context.Context) error
+
+	mu      sync.Mutex
+	samples []rpcSample
+	floods  []floodEvent
+}
+
+func newQualityMonitor() *qualityMonitor {
+	return &qualityMonitor{}
+}
+
+// Handle implements telegram.Middleware
+func (m *qualityMonitor) Handle(next tg.Invoker) telegram.InvokeFunc {
+	return func(ctx context.Context, input bin.Encoder, output bin.Decoder) error {
+		start := time.Now()
+		err := next.Invoke(ctx, input, output)
+		if !isFileTransfer(input) && ctx.Err() == nil {
+			m.record(start, time.Since(start), err)
+		}
+		if wait, ok := tgerr.AsFloodWait(err); ok {
+			m.recordFlood(floodEvent{At: start, Method: rpcMethod(input), Wait: wait})
+		}
+		return err
+	}
+}
+
+// isFileTransfer reports whether input moves file parts, whose duration
+// depends on their size rather than on the connection
+func isFileTransfer(input bin.Encoder) bool {
+	switch input.(type) {
+	case *tg.UploadGetFileRequest, *tg.UploadSaveFilePartRequest, *tg.UploadSaveBigFilePartRequest:
+		return true
+	}
+	return false
+}
+
+// rpcMethod returns the TL name of the request input, e.g. messages.sendMessage
+func rpcMethod(input bin.Encoder) string {
+	if t, ok := input.(interface{ TypeName() string }); ok {
+		return t.TypeName()
+	}
+	return "unknown"
+}
+
+func (m *qualityMonitor) record(at time.Time, latency time.Duration, err error) {
+	var rpcErr *tgerr.Error
+	failed := err != nil && !errors.As(err, &rpcErr)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.samples = append(m.samples, rpcSample{At: at, Latency: latency, Failed: failed})
+	if len(m.samples) > maxQualitySamples {
+		m.samples = m.samples[len(m.samples)-maxQualitySamples:]
+	}
+}
+
+// recordFlood remembers a flood wait for estimate_flood_risk
+func (m *qualityMonitor) recordFlood(e floodEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.floods = append(m.floods, e)
+	if len(m.floods) > maxFloodEvents {
+		m.floods = m.floods[len(m.floods)-maxFloodEvents:]
+	}
+}
+
+// recentFloods returns the flood waits within floodHistoryWindow of now
+func (m *qualityMonitor) recentFloods(now time.Time) []floodEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var recent []floodEvent
+	for _, e := range m.floods {
+		if now.Sub(e.At) <= floodHistoryWindow {
+			recent = append(recent, e)
+		}
+	}
+	return recent
+}
+
+// recent returns the samples taken within qualityWindow of now
+func (m *qualityMonitor) recent(now time.Time) []rpcSample {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var recent []rpcSample
+	for _, s := range m.samples {
+		if now.Sub(s.At) <= qualityWindow {
+			recent = append(recent, s)
+		}
+	}
+	return recent
+}
+
+func getConnectionQualityTool(m *qualityMonitor) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args struct{}
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		return getConnectionQuality(ctx, m)
+	}
+}
+
+// getConnectionQuality pings Telegram once, so there is always a fresh
+// sample, and classifies the requests of the last few minutes
+func getConnectionQuality(ctx context.Context, m *qualityMonitor) (Quality, error) {
+	if m.ping != nil {
+		start := time.Now()
+		err := m.ping(ctx)
+		if ctx.Err() != nil {
+			return Quality{}, ctx.Err()
+		}
+		m.record(start, time.Since(start), err)
+	}
+	return classifyQuality(m.recent(time.Now())), nil
+}
+
+// classifyQuality rates samples by their p95 latency and failure rate,
+// whichever is worse
+func classifyQuality(samples []rpcSample) Quality {
+	if len(samples) == 0 {
+		return Quality{Status: qualityUnknown}
+	}
+
+	latencies := make([]time.Duration, 0, len(samples))
+	var total time.Duration
+	failed := 0
+	for _, s := range samples {
+		if s.Failed {
+			failed++
+			continue
+		}
+		latencies = append(latencies, s.Latency)
+		total += s.Latency
+	}
+
+	q := Quality{
+		Samples:   len(samples),
+		ErrorRate: float64(failed) / float64(len(samples)),
+	}
+	var p95 time.Duration
+	if len(latencies) > 0 {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		p95 = latencies[(len(latencies)*95+99)/100-1]
+		q.AvgLatencyMS = (total / time.Duration(len(latencies))).Milliseconds()
+		q.P95LatencyMS = p95.Milliseconds()
+	}
+
+	switch {
+	case q.ErrorRate >= poorErrorRate || p95 >= poorLatency:
+		q.Status = qualityPoor
+	case q.ErrorRate >= degradedErrorRate || p95 >= degradedLatency:
+		q.Status = qualityDegraded
+	default:
+		q.Status = qualityGood
+	}
+	return q
+}
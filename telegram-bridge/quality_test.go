@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+// samples returns n samples of latency, the first failed of which failed
+func samples(n int, latency time.Duration, failed int) []rpcSample {
+	s := make([]rpcSample, n)
+	for i := range s {
+		s[i] = rpcSample{Latency: latency, Failed: i < failed}
+	}
+	return s
+}
+
+func TestClassifyQuality(t *testing.T) {
+	tests := []struct {
+		name       string
+		samples    []rpcSample
+		wantStatus string
+		wantP95    int64
+		wantRate   float64
+	}{
+		{name: "no samples", wantStatus: qualityUnknown},
+		{name: "fast", samples: samples(20, 100*time.Millisecond, 0), wantStatus: qualityGood, wantP95: 100},
+		{name: "just under degraded latency", samples: samples(20, degradedLatency-time.Millisecond, 0), wantStatus: qualityGood, wantP95: 999},
+		{name: "degraded latency", samples: samples(20, degradedLatency, 0), wantStatus: qualityDegraded, wantP95: 1000},
+		{name: "poor latency", samples: samples(20, poorLatency, 0), wantStatus: qualityPoor, wantP95: 3000},
+		{name: "degraded error rate", samples: samples(20, 100*time.Millisecond, 1), wantStatus: qualityDegraded, wantP95: 100, wantRate: 0.05},
+		{name: "poor error rate", samples: samples(20, 100*time.Millisecond, 4), wantStatus: qualityPoor, wantP95: 100, wantRate: 0.2},
+		{name: "all failed", samples: samples(5, time.Second, 5), wantStatus: qualityPoor, wantRate: 1},
+		{
+			// One slow request in twenty stays within the fastest 95%
+			name:       "single outlier",
+			samples:    append(samples(19, 100*time.Millisecond, 0), rpcSample{Latency: 10 * time.Second}),
+			wantStatus: qualityGood,
+			wantP95:    100,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := classifyQuality(tt.samples)
+			if got.Status != tt.wantStatus || got.P95LatencyMS != tt.wantP95 || got.ErrorRate != tt.wantRate || got.Samples != len(tt.samples) {
+				t.Errorf("classifyQuality() = %+v, want status %s, p95 %dms, error rate %v", got, tt.wantStatus, tt.wantP95, tt.wantRate)
+			}
+		})
+	}
+}
+
+func TestQualityMonitorRecord(t *testing.T) {
+	m := newQualityMonitor()
+	now := time.Now()
+	m.record(now.Add(-qualityWindow-time.Second), time.Second, nil) // too old
+	m.record(now, time.Millisecond, tgerr.New(400, "USERNAME_INVALID"))
+	m.record(now, time.Millisecond, errors.New("connection reset"))
+	for i := 0; i < maxQualitySamples; i++ {
+		m.record(now, time.Millisecond, nil)
+	}
+
+	recent := m.recent(now)
+	if len(recent) != maxQualitySamples {
+		t.Fatalf("kept %d samples, want %d", len(recent), maxQualitySamples)
+	}
+
+	m = newQualityMonitor()
+	m.record(now, time.Millisecond, tgerr.New(400, "USERNAME_INVALID"))
+	m.record(now, time.Millisecond, errors.New("connection reset"))
+	recent = m.recent(now)
+	if recent[0].Failed || !recent[1].Failed {
+		t.Errorf("Telegram errors must count as successes, network errors as failures: %+v", recent)
+	}
+}
+
+func TestGetConnectionQualityPings(t *testing.T) {
+	m := newQualityMonitor()
+	pinged := 0
+	m.ping = func(context.Context) error {
+		pinged++
+		return nil
+	}
+	q, err := getConnectionQuality(context.Background(), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pinged != 1 || q.Samples != 1 || q.Status != qualityGood {
+		t.Errorf("getConnectionQuality() = %+v after %d pings", q, pinged)
+	}
+}
+
+func TestQualityMonitorRecordsFloodWaits(t *testing.T) {
+	m := newQualityMonitor()
+	next := telegram.InvokeFunc(func(ctx context.Context, input bin.Encoder, output bin.Decoder) error {
+		return tgerr.New(420, "FLOOD_WAIT_30")
+	})
+	_ = m.Handle(next).Invoke(context.Background(), &tg.MessagesSendMessageRequest{}, nil)
+
+	floods := m.recentFloods(time.Now())
+	if len(floods) != 1 || floods[0].Method != "messages.sendMessage" || floods[0].Wait != 30*time.Second {
+		t.Errorf("floods = %+v, want one 30s wait on messages.sendMessage", floods)
+	}
+	if old := m.recentFloods(time.Now().Add(floodHistoryWindow + time.Minute)); len(old) != 0 {
+		t.Errorf("floods older than the window kept: %+v", old)
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gotd/td/tg"
+)
+
+// reactionFetchLimit is how many reactions are requested from each of the
+// top and recent lists
+const reactionFetchLimit = 50
+
+// customEmojiReactionPrefix marks custom emoji reactions, which have a
+// document ID instead of an emoticon
+const customEmojiReactionPrefix = "custom_emoji:"
+
+type suggestedReactionsResult struct {
+	Reactions []string `json:"reactions"`
+}
+
+// reactionList is a cached reaction list with the hash Telegram uses to
+// answer "not modified" while it is current
+type reactionList struct {
+	hash      int64
+	reactions []string
+}
+
+// reactionCache keeps the top and recent reaction lists between calls
+type reactionCache struct {
+	mu     sync.Mutex
+	top    reactionList
+	recent reactionList
+}
+
+func getSuggestedReactionsTool(api *tg.Client) ToolHandler {
+	cache := &reactionCache{}
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args struct{}
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		reactions, err := getDefaultReactions(ctx, api, cache)
+		if err != nil {
+			return nil, err
+		}
+		return suggestedReactionsResult{Reactions: reactions}, nil
+	}
+}
+
+// getDefaultReactions returns the reactions this account used recently
+// followed by the most popular ones, without duplicates. Both lists are
+// refreshed through cache, so unchanged lists cost no transfer.
+func getDefaultReactions(ctx context.Context, api *tg.Client, cache *reactionCache) ([]string, error) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	err := refreshReactions(ctx, &cache.recent, func(hash int64) (tg.MessagesReactionsClass, error) {
+		return api.MessagesGetRecentReactions(ctx, &tg.MessagesGetRecentReactionsRequest{Limit: reactionFetchLimit, Hash: hash})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recent reactions: %w", err)
+	}
+	err = refreshReactions(ctx, &cache.top, func(hash int64) (tg.MessagesReactionsClass, error) {
+		return api.MessagesGetTopReactions(ctx, &tg.MessagesGetTopReactionsRequest{Limit: reactionFetchLimit, Hash: hash})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top reactions: %w", err)
+	}
+	return mergeReactions(cache.recent.reactions, cache.top.reactions), nil
+}
+
+// refreshReactions updates list with fetch unless Telegram reports it as
+// not modified
+func refreshReactions(ctx context.Context, list *reactionList, fetch func(hash int64) (tg.MessagesReactionsClass, error)) error {
+	var res tg.MessagesReactionsClass
+	err := withFloodRetry(ctx, func() (err error) {
+		res, err = fetch(list.hash)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if res, ok := res.AsModified(); ok {
+		list.hash = res.Hash
+		list.reactions = reactionStrings(res.Reactions)
+	}
+	return nil
+}
+
+// reactionStrings converts reactions to emoticons, or custom_emoji:<id> for
+// custom emoji
+func reactionStrings(reactions []tg.ReactionClass) []string {
+	result := make([]string, 0, len(reactions))
+	for _, r := range reactions {
+		if s, ok := reactionString(r); ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+func reactionString(r tg.ReactionClass) (string, bool) {
+	switch r := r.(type) {
+	case *tg.ReactionEmoji:
+		return r.Emoticon, true
+	case *tg.ReactionCustomEmoji:
+		return customEmojiReactionPrefix + strconv.FormatInt(r.DocumentID, 10), true
+	}
+	return "", false
+}
+
+// mergeReactions concatenates lists, keeping the first occurrence of each
+// reaction
+func mergeReactions(lists ...[]string) []string {
+	seen := make(map[string]bool)
+	result := []string{}
+	for _, list := range lists {
+		for _, r := range list {
+			if !seen[r] {
+				seen[r] = true
+				result = append(result, r)
+			}
+		}
+	}
+	return result
+}
+
+type setChatReactionsArgs struct {
+	Peer      string   `json:"peer"`
+	Reactions []string `json:"reactions"`
+	All       bool     `json:"all"`
+}
+
+type setChatReactionsResult struct {
+	// Reactions are the allowed reactions; empty with All set means every
+	// standard reaction, and empty without it means none
+	All       bool     `json:"all"`
+	Reactions []string `json:"reactions"`
+}
+
+func setChatReactionsTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args setChatReactionsArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.Peer) == "" {
+			return nil, invalidParams("peer is required")
+		}
+		if args.All && len(args.Reactions) > 0 {
+			return nil, invalidParams("give either all or reactions, not both")
+		}
+		if err := setChatReactions(ctx, api, peers, args.Peer, args.Reactions, args.All); err != nil {
+			return nil, err
+		}
+		result := setChatReactionsResult{All: args.All, Reactions: args.Reactions}
+		if result.Reactions == nil {
+			result.Reactions = []string{}
+		}
+		return result, nil
+	}
+}
+
+// setChatReactions sets which reactions members of a group or channel may
+// use: every standard reaction when all is set, otherwise only reactions,
+// and none when that is empty. Reactions are emoticons from Telegram's
+// available list or custom_emoji:<id>. Changing them needs the admin right
+// to change the chat info.
+func setChatReactions(ctx context.Context, api *tg.Client, peers *peerResolver, peer string, reactions []string, all bool) error {
+	input, err := requireChangeInfo(ctx, api, peers, peer)
+	if err != nil {
+		return err
+	}
+	var available tg.ChatReactionsClass = &tg.ChatReactionsNone{}
+	switch {
+	case all:
+		available = &tg.ChatReactionsAll{}
+	case len(reactions) > 0:
+		parsed, err := parseChatReactions(ctx, api, reactions)
+		if err != nil {
+			return err
+		}
+		available = &tg.ChatReactionsSome{Reactions: parsed}
+	}
+
+	err = withFloodRetry(ctx, func() error {
+		_, err := api.MessagesSetChatAvailableReactions(ctx, &tg.MessagesSetChatAvailableReactionsRequest{
+			Peer:               input,
+			AvailableReactions: available,
+		})
+		return err
+	})
+	switch {
+	case tg.IsChatNotModified(err):
+		return nil
+	case tg.IsChatAdminRequired(err):
+		return fmt.Errorf("admin rights to change the info of %s are required to set its reactions", peer)
+	case err != nil:
+		return fmt.Errorf("failed to set reactions: %w", err)
+	}
+	return nil
+}
+
+// parseChatReactions converts reactions to the API form, checking emoticons
+// against the reactions Telegram currently offers
+func parseChatReactions(ctx context.Context, api *tg.Client, reactions []string) ([]tg.ReactionClass, error) {
+	var res tg.MessagesAvailableReactionsClass
+	err := withFloodRetry(ctx, func() (err error) {
+		res, err = api.MessagesGetAvailableReactions(ctx, 0)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get available reactions: %w", err)
+	}
+	offered := make(map[string]bool)
+	if res, ok := res.AsModified(); ok {
+		for _, r := range res.Reactions {
+			if !r.Inactive {
+				offered[r.Reaction] = true
+			}
+		}
+	}
+
+	result := make([]tg.ReactionClass, 0, len(reactions))
+	for _, r := range reactions {
+		reaction, err := parseReaction(r, offered)
+		if err != nil {
+			return nil, invalidParams(err.Error())
+		}
+		result = append(result, reaction)
+	}
+	return result, nil
+}
+
+// parseReaction is the inverse of reactionString; emoticons must be in
+// offered
+func parseReaction(s string, offered map[string]bool) (tg.ReactionClass, error) {
+	if id, ok := strings.CutPrefix(s, customEmojiReactionPrefix); ok {
+		documentID, err := strconv.ParseInt(id, 10, 64)
+		if err != nil || documentID == 0 {
+			return nil, fmt.Errorf("invalid custom emoji reaction %q", s)
+		}
+		return &tg.ReactionCustomEmoji{DocumentID: documentID}, nil
+	}
+	if !offered[s] {
+		return nil, fmt.Errorf("%q is not an available reaction", s)
+	}
+	return &tg.ReactionEmoji{Emoticon: s}, nil
+}
+
+// requireChangeInfo resolves peer to a group or channel where the logged-in
+// user may change the chat info
+func requireChangeInfo(ctx context.Context, api *tg.Client, peers *peerResolver, peer string) (tg.InputPeerClass, error) {
+	p, err := peers.resolve(ctx, peer)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		creator bool
+		rights  tg.ChatAdminRights
+		admin   bool
+	)
+	switch p.Type {
+	case peerChannel:
+		_, c, err := getFullChannel(ctx, api, peers, peer)
+		if err != nil {
+			return nil, err
+		}
+		creator = c.Creator
+		rights, admin = c.GetAdminRights()
+	case peerChat:
+		var res tg.MessagesChatsClass
+		err := withFloodRetry(ctx, func() (err error) {
+			res, err = api.MessagesGetChats(ctx, []int64{p.ID})
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get group: %w", err)
+		}
+		for _, c := range res.GetChats() {
+			if c, ok := c.(*tg.Chat); ok && c.ID == p.ID {
+				creator = c.Creator
+				rights, admin = c.GetAdminRights()
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%s is a user; reactions are set for groups and channels", peer)
+	}
+	if !creator && !(admin && rights.ChangeInfo) {
+		return nil, fmt.Errorf("admin rights to change the info of %s are required to set its reactions", peer)
+	}
+	return p.InputPeer(), nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+)
+
+func TestMergeReactions(t *testing.T) {
+	recent := []string{"👍", "custom_emoji:5", "🔥"}
+	top := []string{"❤", "👍", "🔥", "😂"}
+	got := mergeReactions(recent, top)
+	want := []string{"👍", "custom_emoji:5", "🔥", "❤", "😂"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("mergeReactions() = %v, want %v", got, want)
+	}
+	if got := mergeReactions(nil, nil); got == nil || len(got) != 0 {
+		t.Errorf("mergeReactions(nil, nil) = %#v, want an empty list", got)
+	}
+}
+
+func TestGetDefaultReactionsCached(t *testing.T) {
+	inv, api, _ := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		switch req := input.(type) {
+		case *tg.MessagesGetRecentReactionsRequest:
+			if req.Hash == 11 {
+				return &tg.MessagesReactionsNotModified{}, nil
+			}
+			return &tg.MessagesReactions{Hash: 11, Reactions: []tg.ReactionClass{
+				&tg.ReactionCustomEmoji{DocumentID: 5},
+				&tg.ReactionEmoji{Emoticon: "👍"},
+			}}, nil
+		case *tg.MessagesGetTopReactionsRequest:
+			if req.Hash == 22 {
+				return &tg.MessagesReactionsNotModified{}, nil
+			}
+			return &tg.MessagesReactions{Hash: 22, Reactions: []tg.ReactionClass{
+				&tg.ReactionEmoji{Emoticon: "👍"},
+				&tg.ReactionEmoji{Emoticon: "❤"},
+			}}, nil
+		}
+		return nil, nil
+	})
+	cache := &reactionCache{}
+	want := "[custom_emoji:5 👍 ❤]"
+
+	for i := 0; i < 2; i++ {
+		got, err := getDefaultReactions(context.Background(), api, cache)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(got) != want {
+			t.Errorf("call %d: reactions = %v, want %s", i, got, want)
+		}
+	}
+	if top := requests[*tg.MessagesGetTopReactionsRequest](inv); len(top) != 2 || top[1].Hash != 22 {
+		t.Errorf("second top request does not send the cached hash: %+v", top)
+	}
+}
+
+func chatReactionsClient(changeInfo bool) (*fakeInvoker, *tg.Client, *peerResolver) {
+	inv, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		switch input.(type) {
+		case *tg.ChannelsGetFullChannelRequest:
+			c := &tg.Channel{ID: 30, AccessHash: 3, Broadcast: true, Photo: &tg.ChatPhotoEmpty{}}
+			c.SetAdminRights(tg.ChatAdminRights{ChangeInfo: changeInfo, PostMessages: true})
+			return &tg.MessagesChatFull{FullChat: &tg.ChannelFull{ID: 30, ChatPhoto: &tg.PhotoEmpty{}}, Chats: []tg.ChatClass{c}}, nil
+		case *tg.MessagesGetAvailableReactionsRequest:
+			return &tg.MessagesAvailableReactions{Hash: 1, Reactions: []tg.AvailableReaction{
+				{Reaction: "👍", StaticIcon: &tg.DocumentEmpty{}, AppearAnimation: &tg.DocumentEmpty{}, SelectAnimation: &tg.DocumentEmpty{}, ActivateAnimation: &tg.DocumentEmpty{}, EffectAnimation: &tg.DocumentEmpty{}},
+				{Reaction: "🔥", StaticIcon: &tg.DocumentEmpty{}, AppearAnimation: &tg.DocumentEmpty{}, SelectAnimation: &tg.DocumentEmpty{}, ActivateAnimation: &tg.DocumentEmpty{}, EffectAnimation: &tg.DocumentEmpty{}},
+				{Reaction: "🥱", Inactive: true, StaticIcon: &tg.DocumentEmpty{}, AppearAnimation: &tg.DocumentEmpty{}, SelectAnimation: &tg.DocumentEmpty{}, ActivateAnimation: &tg.DocumentEmpty{}, EffectAnimation: &tg.DocumentEmpty{}},
+			}}, nil
+		case *tg.MessagesSetChatAvailableReactionsRequest:
+			return &tg.Updates{}, nil
+		}
+		return nil, nil
+	})
+	peers.storeChannel(&tg.Channel{ID: 30, AccessHash: 3})
+	return inv, api, peers
+}
+
+func TestSetChatReactions(t *testing.T) {
+	tests := []struct {
+		name      string
+		reactions []string
+		all       bool
+		want      tg.ChatReactionsClass
+	}{
+		{name: "all", all: true, want: &tg.ChatReactionsAll{}},
+		{name: "none", want: &tg.ChatReactionsNone{}},
+		{
+			name:      "some",
+			reactions: []string{"👍", "custom_emoji:5"},
+			want: &tg.ChatReactionsSome{Reactions: []tg.ReactionClass{
+				&tg.ReactionEmoji{Emoticon: "👍"},
+				&tg.ReactionCustomEmoji{DocumentID: 5},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inv, api, peers := chatReactionsClient(true)
+			if err := setChatReactions(context.Background(), api, peers, "-1000000000030", tt.reactions, tt.all); err != nil {
+				t.Fatal(err)
+			}
+			reqs := requests[*tg.MessagesSetChatAvailableReactionsRequest](inv)
+			if len(reqs) != 1 {
+				t.Fatalf("sent %d requests, want 1", len(reqs))
+			}
+			if got := reqs[0].AvailableReactions; got.String() != tt.want.String() {
+				t.Errorf("reactions = %v, want %v", got, tt.want)
+			}
+			if n := len(requests[*tg.MessagesGetAvailableReactionsRequest](inv)); (n > 0) != (len(tt.reactions) > 0) {
+				t.Errorf("fetched available reactions %d times", n)
+			}
+		})
+	}
+}
+
+func TestSetChatReactionsInvalid(t *testing.T) {
+	tests := []struct {
+		name       string
+		changeInfo bool
+		reactions  []string
+		wantErr    string
+	}{
+		{name: "not admin", reactions: []string{"👍"}, wantErr: "admin rights"},
+		{name: "unknown emoticon", changeInfo: true, reactions: []string{"🦄"}, wantErr: "not an available reaction"},
+		{name: "inactive emoticon", changeInfo: true, reactions: []string{"🥱"}, wantErr: "not an available reaction"},
+		{name: "bad custom emoji", changeInfo: true, reactions: []string{"custom_emoji:x"}, wantErr: "invalid custom emoji"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inv, api, peers := chatReactionsClient(tt.changeInfo)
+			err := setChatReactions(context.Background(), api, peers, "-1000000000030", tt.reactions, false)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+			if n := len(requests[*tg.MessagesSetChatAvailableReactionsRequest](inv)); n != 0 {
+				t.Errorf("sent %d set requests after a failed check", n)
+			}
+		})
+	}
+}
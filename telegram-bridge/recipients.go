@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gotd/td/tg"
+)
+
+// RecipientInfo describes a resolved peer and whether the account can send
+// messages to it
+type RecipientInfo struct {
+	PeerID   int64  `json:"peer_id"`
+	Type     string `json:"type"`
+	Name     string `json:"name"`
+	Username string `json:"username,omitempty"`
+	CanWrite bool   `json:"can_write"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+type peerArgs struct {
+	Peer string `json:"peer"`
+}
+
+func validateRecipientTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args peerArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.Peer) == "" {
+			return nil, invalidParams("peer is required")
+		}
+		return validateRecipient(ctx, api, peers, args.Peer)
+	}
+}
+
+// validateRecipient resolves peer and checks in one call whether messages can
+// be sent to it, explaining why not when they cannot
+func validateRecipient(ctx context.Context, api *tg.Client, peers *peerResolver, peer string) (RecipientInfo, error) {
+	p, err := peers.resolve(ctx, peer)
+	if err != nil {
+		return RecipientInfo{}, err
+	}
+	info := RecipientInfo{PeerID: p.MarkedID(), Type: p.Type}
+
+	switch p.Type {
+	case peerUser:
+		full, err := api.UsersGetFullUser(ctx, &tg.InputUser{UserID: p.ID, AccessHash: p.AccessHash})
+		if err != nil {
+			return RecipientInfo{}, fmt.Errorf("failed to get user: %w", err)
+		}
+		peers.remember(full.Users, full.Chats)
+		for _, u := range full.Users {
+			if u, ok := u.(*tg.User); ok && u.ID == p.ID {
+				info.Name = strings.TrimSpace(u.FirstName + " " + u.LastName)
+				info.Username = u.Username
+				info.Reason = userWriteBlocker(u, &full.FullUser)
+			}
+		}
+	case peerChat:
+		res, err := api.MessagesGetChats(ctx, []int64{p.ID})
+		if err != nil {
+			return RecipientInfo{}, fmt.Errorf("failed to get chat: %w", err)
+		}
+		info.Reason = "chat not found"
+		for _, c := range res.GetChats() {
+			if c.GetID() == p.ID {
+				info.Name, info.Reason = chatWriteBlocker(c)
+			}
+		}
+	case peerChannel:
+		res, err := api.ChannelsGetChannels(ctx, []tg.InputChannelClass{
+			&tg.InputChannel{ChannelID: p.ID, AccessHash: p.AccessHash},
+		})
+		if err != nil {
+			return RecipientInfo{}, fmt.Errorf("failed to get channel: %w", err)
+		}
+		peers.remember(nil, res.GetChats())
+		info.Reason = "channel not found"
+		for _, c := range res.GetChats() {
+			if c.GetID() == p.ID {
+				if ch, ok := c.(*tg.Channel); ok {
+					info.Username = ch.Username
+				}
+				info.Name, info.Reason = chatWriteBlocker(c)
+			}
+		}
+	}
+
+	info.CanWrite = info.Reason == ""
+	return info, nil
+}
+
+// userWriteBlocker returns why messages cannot be sent to u, or "" if they can
+func userWriteBlocker(u *tg.User, full *tg.UserFull) string {
+	switch {
+	case u.Deleted:
+		return "account deleted"
+	case full.Blocked:
+		return "user is blocked by this account"
+	default:
+		return ""
+	}
+}
+
+// chatWriteBlocker returns the title of a group or channel and why messages
+// cannot be sent to it, or "" if they can
+func chatWriteBlocker(c tg.ChatClass) (title, reason string) {
+	switch c := c.(type) {
+	case *tg.Chat:
+		switch {
+		case c.Deactivated:
+			return c.Title, "group is deactivated"
+		case c.MigratedTo != nil:
+			return c.Title, "group was upgraded to a supergroup"
+		case c.Left:
+			return c.Title, "not a member of this group"
+		case !c.Creator && c.DefaultBannedRights.SendMessages:
+			if _, admin := c.GetAdminRights(); !admin {
+				return c.Title, "sending messages is restricted in this group"
+			}
+		}
+		return c.Title, ""
+	case *tg.ChatForbidden:
+		return c.Title, "removed from this group"
+	case *tg.Channel:
+		_, admin := c.GetAdminRights()
+		switch {
+		case c.Broadcast && !c.Creator && !c.AdminRights.PostMessages:
+			return c.Title, "only admins can post in this channel"
+		case c.BannedRights.SendMessages:
+			return c.Title, "banned from sending messages"
+		case c.Left && (c.JoinToSend || c.Broadcast):
+			return c.Title, "not a member of this chat"
+		case !c.Creator && !admin && c.DefaultBannedRights.SendMessages:
+			return c.Title, "sending messages is restricted in this chat"
+		}
+		return c.Title, ""
+	case *tg.ChannelForbidden:
+		return c.Title, "banned from this channel"
+	default:
+		return "", "chat unavailable"
+	}
+}
+
+// Account statuses reported by check_accounts
+const (
+	accountActive     = "active"
+	accountDeleted    = "deleted"
+	accountRestricted = "restricted"
+	// accountUnresolved is reported for peers that could not be resolved or
+	// are not users
+	accountUnresolved = "unresolved"
+)
+
+// maxCheckAccounts caps the peers of one check_accounts call
+const maxCheckAccounts = 200
+
+type checkAccountsArgs struct {
+	Peers []string `json:"peers"`
+}
+
+type checkAccountsResult struct {
+	Statuses map[string]string `json:"statuses"`
+}
+
+func checkAccountsTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args checkAccountsArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if len(args.Peers) == 0 {
+			return nil, invalidParams("peers is required")
+		}
+		if len(args.Peers) > maxCheckAccounts {
+			return nil, invalidParams("at most 200 peers can be checked at once")
+		}
+		statuses, err := checkAccountsStatus(ctx, api, peers, args.Peers)
+		if err != nil {
+			return nil, err
+		}
+		return checkAccountsResult{Statuses: statuses}, nil
+	}
+}
+
+// checkAccountsStatus reports for each of targets whether the user account
+// is active, deleted or restricted, fetching all users in one request
+func checkAccountsStatus(ctx context.Context, api *tg.Client, peers *peerResolver, targets []string) (map[string]string, error) {
+	statuses := make(map[string]string, len(targets))
+	byID := make(map[int64][]string)
+	var inputs []tg.InputUserClass
+	for _, target := range targets {
+		if _, done := statuses[target]; done {
+			continue
+		}
+		user, err := peers.ResolveUser(ctx, target)
+		if err != nil {
+			statuses[target] = accountUnresolved
+			continue
+		}
+		// Deleted until the response shows the account
+		statuses[target] = accountDeleted
+		if len(byID[user.UserID]) == 0 {
+			inputs = append(inputs, user)
+		}
+		byID[user.UserID] = append(byID[user.UserID], target)
+	}
+	if len(inputs) == 0 {
+		return statuses, nil
+	}
+
+	var users []tg.UserClass
+	err := withFloodRetry(ctx, func() (err error) {
+		users, err = api.UsersGetUsers(ctx, inputs)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users: %w", err)
+	}
+	peers.remember(users, nil)
+	for _, u := range users {
+		u, ok := u.(*tg.User)
+		if !ok {
+			continue
+		}
+		for _, target := range byID[u.ID] {
+			statuses[target] = accountStatus(u)
+		}
+	}
+	return statuses, nil
+}
+
+// accountStatus maps the flags of u to a check_accounts status
+func accountStatus(u *tg.User) string {
+	switch {
+	case u.Deleted:
+		return accountDeleted
+	case u.Restricted:
+		return accountRestricted
+	}
+	return accountActive
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+)
+
+func TestChatWriteBlocker(t *testing.T) {
+	admin := &tg.Channel{Title: "admin", Broadcast: true}
+	admin.SetAdminRights(tg.ChatAdminRights{PostMessages: true})
+	restrictedAdmin := &tg.Channel{Title: "restricted admin", Megagroup: true, DefaultBannedRights: tg.ChatBannedRights{SendMessages: true}}
+	restrictedAdmin.SetAdminRights(tg.ChatAdminRights{BanUsers: true})
+	restrictedGroupAdmin := &tg.Chat{Title: "group admin", DefaultBannedRights: tg.ChatBannedRights{SendMessages: true}}
+	restrictedGroupAdmin.SetAdminRights(tg.ChatAdminRights{BanUsers: true})
+
+	tests := []struct {
+		name       string
+		chat       tg.ChatClass
+		wantTitle  string
+		wantReason string
+	}{
+		{name: "group member", chat: &tg.Chat{Title: "group"}, wantTitle: "group"},
+		{name: "deactivated group", chat: &tg.Chat{Title: "old", Deactivated: true}, wantTitle: "old", wantReason: "group is deactivated"},
+		{name: "migrated group", chat: &tg.Chat{Title: "old", MigratedTo: &tg.InputChannel{ChannelID: 1}}, wantTitle: "old", wantReason: "group was upgraded to a supergroup"},
+		{name: "left group", chat: &tg.Chat{Title: "group", Left: true}, wantTitle: "group", wantReason: "not a member of this group"},
+		{name: "restricted group", chat: &tg.Chat{Title: "group", DefaultBannedRights: tg.ChatBannedRights{SendMessages: true}}, wantTitle: "group", wantReason: "sending messages is restricted in this group"},
+		{name: "restricted group admin", chat: restrictedGroupAdmin, wantTitle: "group admin"},
+		{name: "kicked from group", chat: &tg.ChatForbidden{Title: "group"}, wantTitle: "group", wantReason: "removed from this group"},
+		{name: "broadcast subscriber", chat: &tg.Channel{Title: "news", Broadcast: true}, wantTitle: "news", wantReason: "only admins can post in this channel"},
+		{name: "broadcast admin", chat: admin, wantTitle: "admin"},
+		{name: "broadcast creator", chat: &tg.Channel{Title: "mine", Broadcast: true, Creator: true}, wantTitle: "mine"},
+		{name: "supergroup member", chat: &tg.Channel{Title: "chat", Megagroup: true}, wantTitle: "chat"},
+		{name: "banned in supergroup", chat: &tg.Channel{Title: "chat", Megagroup: true, BannedRights: tg.ChatBannedRights{SendMessages: true}}, wantTitle: "chat", wantReason: "banned from sending messages"},
+		{name: "join to send", chat: &tg.Channel{Title: "chat", Megagroup: true, Left: true, JoinToSend: true}, wantTitle: "chat", wantReason: "not a member of this chat"},
+		{name: "restricted supergroup", chat: &tg.Channel{Title: "chat", Megagroup: true, DefaultBannedRights: tg.ChatBannedRights{SendMessages: true}}, wantTitle: "chat", wantReason: "sending messages is restricted in this chat"},
+		{name: "restricted supergroup admin", chat: restrictedAdmin, wantTitle: "restricted admin"},
+		{name: "banned from channel", chat: &tg.ChannelForbidden{Title: "chat"}, wantTitle: "chat", wantReason: "banned from this channel"},
+		{name: "empty chat", chat: &tg.ChatEmpty{}, wantReason: "chat unavailable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			title, reason := chatWriteBlocker(tt.chat)
+			if title != tt.wantTitle || reason != tt.wantReason {
+				t.Errorf("chatWriteBlocker() = %q, %q; want %q, %q", title, reason, tt.wantTitle, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestValidateRecipientUser(t *testing.T) {
+	tests := []struct {
+		name    string
+		blocked bool
+		deleted bool
+		want    string
+	}{
+		{name: "writable"},
+		{name: "blocked", blocked: true, want: "user is blocked by this account"},
+		{name: "deleted", deleted: true, want: "account deleted"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &tg.User{ID: 10, AccessHash: 1, FirstName: "Ada", Username: "ada", Deleted: tt.deleted}
+			_, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+				if _, ok := input.(*tg.UsersGetFullUserRequest); ok {
+					return &tg.UsersUserFull{
+						FullUser: tg.UserFull{ID: 10, Blocked: tt.blocked},
+						Users:    []tg.UserClass{user},
+					}, nil
+				}
+				return nil, nil
+			})
+			peers.storeUser(user)
+
+			info, err := validateRecipient(context.Background(), api, peers, "10")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.CanWrite != (tt.want == "") || info.Reason != tt.want {
+				t.Errorf("validateRecipient() can_write = %v, reason = %q; want reason %q", info.CanWrite, info.Reason, tt.want)
+			}
+			if info.Name != "Ada" || info.Username != "ada" || info.PeerID != 10 {
+				t.Errorf("validateRecipient() = %+v", info)
+			}
+		})
+	}
+}
+
+func TestAccountStatus(t *testing.T) {
+	tests := []struct {
+		user tg.User
+		want string
+	}{
+		{tg.User{}, accountActive},
+		{tg.User{Deleted: true}, accountDeleted},
+		{tg.User{Restricted: true}, accountRestricted},
+		{tg.User{Deleted: true, Restricted: true}, accountDeleted},
+	}
+	for _, tt := range tests {
+		if got := accountStatus(&tt.user); got != tt.want {
+			t.Errorf("accountStatus(%+v) = %q, want %q", tt.user, got, tt.want)
+		}
+	}
+}
+
+func TestCheckAccountsStatus(t *testing.T) {
+	inv, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		if _, ok := input.(*tg.UsersGetUsersRequest); ok {
+			return &tg.UserClassVector{Elems: []tg.UserClass{
+				&tg.User{ID: 10, AccessHash: 1},
+				&tg.User{ID: 11, AccessHash: 1, Deleted: true},
+				&tg.User{ID: 12, AccessHash: 1, Restricted: true},
+				&tg.UserEmpty{ID: 13},
+			}}, nil
+		}
+		return nil, nil
+	})
+	for id := int64(10); id <= 13; id++ {
+		peers.storeUser(&tg.User{ID: id, AccessHash: 1})
+	}
+
+	statuses, err := checkAccountsStatus(context.Background(), api, peers, []string{"10", "11", "12", "13", "-20", "10"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"10":  accountActive,
+		"11":  accountDeleted,
+		"12":  accountRestricted,
+		"13":  accountDeleted,
+		"-20": accountUnresolved,
+	}
+	if len(statuses) != len(want) {
+		t.Errorf("statuses = %v, want %v", statuses, want)
+	}
+	for peer, status := range want {
+		if statuses[peer] != status {
+			t.Errorf("statuses[%s] = %q, want %q", peer, statuses[peer], status)
+		}
+	}
+	reqs := requests[*tg.UsersGetUsersRequest](inv)
+	if len(reqs) != 1 || len(reqs[0].ID) != 4 {
+		t.Errorf("requests = %+v, want one batch of 4 users", reqs)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gotd/td/tg"
+)
+
+// messageCacheSize is how many fetched messages messageCache keeps
+const messageCacheSize = 500
+
+// messageCache keeps recently fetched messages so following the same reply
+// chain again costs no requests. Entries are not updated on edits, which is
+// fine for the context lookups it serves.
+type messageCache struct {
+	mu    sync.Mutex
+	msgs  map[messageKey]*tg.Message
+	order []messageKey // oldest first
+}
+
+func newMessageCache() *messageCache {
+	return &messageCache{msgs: make(map[messageKey]*tg.Message)}
+}
+
+// get returns message id of the chat with marked ID peerID, fetching and
+// caching it on a miss
+func (c *messageCache) get(ctx context.Context, api *tg.Client, peers *peerResolver, peerID int64, id int) (*tg.Message, error) {
+	key := messageKey{peerID: peerID, id: id}
+	c.mu.Lock()
+	msg, ok := c.msgs[key]
+	c.mu.Unlock()
+	if ok {
+		return msg, nil
+	}
+
+	msg, err := getMessage(ctx, api, peers, strconv.FormatInt(peerID, 10), id)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.msgs[key]; !ok {
+		if len(c.order) == messageCacheSize {
+			delete(c.msgs, c.order[0])
+			c.order = c.order[1:]
+		}
+		c.order = append(c.order, key)
+	}
+	c.msgs[key] = msg
+	return msg, nil
+}
+
+func getRepliedMessageTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	cache := newMessageCache()
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args messageRefArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.Peer) == "" {
+			return nil, invalidParams("peer is required")
+		}
+		if args.MessageID <= 0 {
+			return nil, invalidParams("message_id must be positive")
+		}
+		return getRepliedMessage(ctx, api, peers, cache, args.Peer, args.MessageID)
+	}
+}
+
+// getRepliedMessage returns the message that message id of peer replies to.
+// The reply may be in another chat, e.g. a comment replying to its channel
+// post; PeerID tells which.
+func getRepliedMessage(ctx context.Context, api *tg.Client, peers *peerResolver, cache *messageCache, peer string, id int) (messageInfo, error) {
+	p, err := peers.resolve(ctx, peer)
+	if err != nil {
+		return messageInfo{}, err
+	}
+	msg, err := cache.get(ctx, api, peers, p.MarkedID(), id)
+	if err != nil {
+		return messageInfo{}, err
+	}
+
+	replyTo, ok := msg.GetReplyTo()
+	if !ok {
+		return messageInfo{}, fmt.Errorf("message %d is not a reply", id)
+	}
+	header, ok := replyTo.(*tg.MessageReplyHeader)
+	if !ok {
+		return messageInfo{}, fmt.Errorf("message %d replies to a story, not a message", id)
+	}
+	replyID := header.ReplyToMsgID
+	targetPeer := p.MarkedID()
+	if other, ok := header.GetReplyToPeerID(); ok {
+		if targetPeer, err = markedPeerID(other); err != nil {
+			return messageInfo{}, err
+		}
+	}
+
+	target, err := cache.get(ctx, api, peers, targetPeer, replyID)
+	if err != nil {
+		return messageInfo{}, fmt.Errorf("failed to get the message %d replies to: %w", id, err)
+	}
+	info, err := newMessageInfo(target)
+	if err != nil {
+		return messageInfo{}, err
+	}
+	info.PeerID = targetPeer
+	return info, nil
+}
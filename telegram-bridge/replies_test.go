@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+)
+
+func replyClient() (*fakeInvoker, *tg.Client, *peerResolver) {
+	crossPost := &tg.MessageReplyHeader{ReplyToMsgID: 7}
+	crossPost.SetReplyToPeerID(&tg.PeerChannel{ChannelID: 30})
+	msgs := map[int]*tg.Message{
+		1: {ID: 1, PeerID: &tg.PeerChannel{ChannelID: 31}, Message: "original"},
+		2: {ID: 2, PeerID: &tg.PeerChannel{ChannelID: 31}, Message: "answer", ReplyTo: &tg.MessageReplyHeader{ReplyToMsgID: 1}},
+		3: {ID: 3, PeerID: &tg.PeerChannel{ChannelID: 31}, Message: "plain"},
+		4: {ID: 4, PeerID: &tg.PeerChannel{ChannelID: 31}, Message: "comment", ReplyTo: crossPost},
+		5: {ID: 5, PeerID: &tg.PeerChannel{ChannelID: 31}, Message: "story reply", ReplyTo: &tg.MessageReplyStoryHeader{UserID: 10, StoryID: 1}},
+	}
+	inv, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		req, ok := input.(*tg.ChannelsGetMessagesRequest)
+		if !ok {
+			return nil, nil
+		}
+		id := req.ID[0].(*tg.InputMessageID).ID
+		msg := msgs[id]
+		if req.Channel.(*tg.InputChannel).ChannelID == 30 {
+			msg = &tg.Message{ID: id, PeerID: &tg.PeerChannel{ChannelID: 30}, Message: "post"}
+		}
+		return &tg.MessagesMessages{Messages: []tg.MessageClass{msg}}, nil
+	})
+	peers.storeChannel(&tg.Channel{ID: 30, AccessHash: 3})
+	peers.storeChannel(&tg.Channel{ID: 31, AccessHash: 3})
+	return inv, api, peers
+}
+
+func TestGetRepliedMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		id       int
+		wantPeer int64
+		wantID   int
+		wantText string
+		wantErr  string
+	}{
+		{name: "same chat", id: 2, wantPeer: -1000000000031, wantID: 1, wantText: "original"},
+		{name: "other chat", id: 4, wantPeer: -1000000000030, wantID: 7, wantText: "post"},
+		{name: "not a reply", id: 3, wantErr: "not a reply"},
+		{name: "story reply", id: 5, wantErr: "story"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, api, peers := replyClient()
+			info, err := getRepliedMessage(context.Background(), api, peers, newMessageCache(), "-1000000000031", tt.id)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.PeerID != tt.wantPeer || info.ID != tt.wantID || info.Text != tt.wantText {
+				t.Errorf("info = %+v", info)
+			}
+		})
+	}
+}
+
+func TestGetRepliedMessageCached(t *testing.T) {
+	inv, api, peers := replyClient()
+	cache := newMessageCache()
+	for i := 0; i < 3; i++ {
+		if _, err := getRepliedMessage(context.Background(), api, peers, cache, "-1000000000031", 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(requests[*tg.ChannelsGetMessagesRequest](inv)); n != 2 {
+		t.Errorf("made %d requests, want 2 for the reply and its target", n)
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+// Ringtone is a notification sound saved to the account
+type Ringtone struct {
+	ID       int64  `json:"id"`
+	Title    string `json:"title"`
+	MimeType string `json:"mime_type"`
+	Size     int64  `json:"size"`
+	Duration int    `json:"duration"`
+}
+
+// ringtoneCache keeps the saved ringtones between calls with the hash
+// Telegram uses to answer "not modified" while they are current
+type ringtoneCache struct {
+	mu   sync.Mutex
+	hash int64
+	docs []*tg.Document
+}
+
+type ringtonesResult struct {
+	Ringtones []Ringtone `json:"ringtones"`
+}
+
+func getSavedRingtonesTool(api *tg.Client, cache *ringtoneCache) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args struct{}
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		ringtones, err := getSavedRingtones(ctx, api, cache)
+		if err != nil {
+			return nil, err
+		}
+		return ringtonesResult{Ringtones: ringtones}, nil
+	}
+}
+
+// getSavedRingtones returns the account's saved notification sounds. The
+// list is refreshed through cache, so an unchanged list costs no transfer.
+func getSavedRingtones(ctx context.Context, api *tg.Client, cache *ringtoneCache) ([]Ringtone, error) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if err := cache.refresh(ctx, api); err != nil {
+		return nil, err
+	}
+	result := make([]Ringtone, 0, len(cache.docs))
+	for _, doc := range cache.docs {
+		result = append(result, newRingtone(doc))
+	}
+	return result, nil
+}
+
+// refresh fetches the saved ringtones unless Telegram reports them as not
+// modified; cache.mu must be held
+func (c *ringtoneCache) refresh(ctx context.Context, api *tg.Client) error {
+	var res tg.AccountSavedRingtonesClass
+	err := withFloodRetry(ctx, func() (err error) {
+		res, err = api.AccountGetSavedRingtones(ctx, c.hash)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get saved ringtones: %w", err)
+	}
+	saved, ok := res.(*tg.AccountSavedRingtones)
+	if !ok {
+		return nil
+	}
+	c.hash = saved.Hash
+	c.docs = c.docs[:0]
+	for _, d := range saved.Ringtones {
+		if doc, ok := d.AsNotEmpty(); ok {
+			c.docs = append(c.docs, doc)
+		}
+	}
+	return nil
+}
+
+// lookup returns the cached ringtone with id, refreshing the list once when
+// it is missing; cache.mu must be held
+func (c *ringtoneCache) lookup(ctx context.Context, api *tg.Client, id int64) (*tg.Document, error) {
+	for refreshed := false; ; refreshed = true {
+		for _, doc := range c.docs {
+			if doc.ID == id {
+				return doc, nil
+			}
+		}
+		if refreshed {
+			return nil, fmt.Errorf("ringtone %d is not saved to this account", id)
+		}
+		if err := c.refresh(ctx, api); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func newRingtone(doc *tg.Document) Ringtone {
+	r := Ringtone{ID: doc.ID, MimeType: doc.MimeType, Size: doc.Size}
+	for _, attr := range doc.Attributes {
+		switch attr := attr.(type) {
+		case *tg.DocumentAttributeAudio:
+			r.Duration = attr.Duration
+			if attr.Title != "" {
+				r.Title = attr.Title
+			}
+		case *tg.DocumentAttributeFilename:
+			if r.Title == "" {
+				r.Title = attr.FileName
+			}
+		}
+	}
+	return r
+}
+
+type saveRingtoneResult struct {
+	Ringtone Ringtone `json:"ringtone"`
+}
+
+func saveRingtoneTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args messageRefArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.Peer) == "" {
+			return nil, invalidParams("peer is required")
+		}
+		if args.MessageID <= 0 {
+			return nil, invalidParams("message_id must be positive")
+		}
+		r, err := saveRingtone(ctx, api, peers, args.Peer, args.MessageID)
+		if err != nil {
+			return nil, err
+		}
+		return saveRingtoneResult{Ringtone: r}, nil
+	}
+}
+
+// saveRingtone saves the audio file of a message as a notification sound.
+// Telegram may convert the file, in which case the converted copy is
+// returned.
+func saveRingtone(ctx context.Context, api *tg.Client, peers *peerResolver, peer string, id int) (Ringtone, error) {
+	msg, err := getMessage(ctx, api, peers, peer, id)
+	if err != nil {
+		return Ringtone{}, err
+	}
+	doc, ok := messageDocument(msg)
+	if !ok {
+		return Ringtone{}, fmt.Errorf("message %d has no audio file to save", id)
+	}
+
+	var res tg.AccountSavedRingtoneClass
+	err = withFloodRetry(ctx, func() (err error) {
+		res, err = api.AccountSaveRingtone(ctx, &tg.AccountSaveRingtoneRequest{ID: doc.AsInput()})
+		return err
+	})
+	switch {
+	case tgerr.Is(err, "RINGTONE_MIME_INVALID"):
+		return Ringtone{}, fmt.Errorf("message %d is a %s file, which cannot be a ringtone: %w", id, doc.MimeType, err)
+	case err != nil:
+		return Ringtone{}, fmt.Errorf("failed to save ringtone: %w", err)
+	}
+	if converted, ok := res.(*tg.AccountSavedRingtoneConverted); ok {
+		if d, ok := converted.Document.AsNotEmpty(); ok {
+			doc = d
+		}
+	}
+	return newRingtone(doc), nil
+}
+
+// messageDocument returns the document attached to msg
+func messageDocument(msg *tg.Message) (*tg.Document, bool) {
+	return documentOf(msg.Media)
+}
+
+// documentOf returns the document of media, if it has one
+func documentOf(m tg.MessageMediaClass) (*tg.Document, bool) {
+	media, ok := m.(*tg.MessageMediaDocument)
+	if !ok {
+		return nil, false
+	}
+	d, ok := media.GetDocument()
+	if !ok {
+		return nil, false
+	}
+	return d.AsNotEmpty()
+}
+
+type unsaveRingtoneArgs struct {
+	RingtoneID int64 `json:"ringtone_id"`
+}
+
+type unsaveRingtoneResult struct {
+	Removed int64 `json:"removed"`
+}
+
+func unsaveRingtoneTool(api *tg.Client, cache *ringtoneCache) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args unsaveRingtoneArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if args.RingtoneID == 0 {
+			return nil, invalidParams("ringtone_id is required")
+		}
+		if err := unsaveRingtone(ctx, api, cache, args.RingtoneID); err != nil {
+			return nil, err
+		}
+		return unsaveRingtoneResult{Removed: args.RingtoneID}, nil
+	}
+}
+
+// unsaveRingtone removes a saved ringtone by ID. The access hash needed for
+// the request comes from the saved list in cache.
+func unsaveRingtone(ctx context.Context, api *tg.Client, cache *ringtoneCache, id int64) error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	doc, err := cache.lookup(ctx, api, id)
+	if err != nil {
+		return err
+	}
+	err = withFloodRetry(ctx, func() error {
+		_, err := api.AccountSaveRingtone(ctx, &tg.AccountSaveRingtoneRequest{ID: doc.AsInput(), Unsave: true})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove ringtone %d: %w", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+func ringtoneDoc(id int64, title string) *tg.Document {
+	return &tg.Document{
+		ID:         id,
+		AccessHash: id * 10,
+		MimeType:   "audio/mpeg",
+		Size:       2048,
+		Attributes: []tg.DocumentAttributeClass{
+			&tg.DocumentAttributeAudio{Duration: 4, Title: title},
+			&tg.DocumentAttributeFilename{FileName: "tone.mp3"},
+		},
+	}
+}
+
+func TestGetSavedRingtones(t *testing.T) {
+	inv, api, _ := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		req, ok := input.(*tg.AccountGetSavedRingtonesRequest)
+		if !ok {
+			return nil, nil
+		}
+		if req.Hash == 77 {
+			return &tg.AccountSavedRingtonesNotModified{}, nil
+		}
+		return &tg.AccountSavedRingtones{Hash: 77, Ringtones: []tg.DocumentClass{
+			ringtoneDoc(1, "Chime"),
+			ringtoneDoc(2, ""),
+			&tg.DocumentEmpty{ID: 3},
+		}}, nil
+	})
+	cache := &ringtoneCache{}
+
+	want := []Ringtone{
+		{ID: 1, Title: "Chime", MimeType: "audio/mpeg", Size: 2048, Duration: 4},
+		{ID: 2, Title: "tone.mp3", MimeType: "audio/mpeg", Size: 2048, Duration: 4},
+	}
+	for i := 0; i < 2; i++ {
+		ringtones, err := getSavedRingtones(context.Background(), api, cache)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ringtones) != len(want) || ringtones[0] != want[0] || ringtones[1] != want[1] {
+			t.Errorf("call %d: ringtones = %+v, want %+v", i, ringtones, want)
+		}
+	}
+	reqs := requests[*tg.AccountGetSavedRingtonesRequest](inv)
+	if len(reqs) != 2 || reqs[0].Hash != 0 || reqs[1].Hash != 77 {
+		t.Errorf("requests = %+v, want hash 0 then the cached 77", reqs)
+	}
+}
+
+func ringtoneMessageClient(saveErr error, converted *tg.Document) (*fakeInvoker, *tg.Client, *peerResolver) {
+	inv, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		switch input.(type) {
+		case *tg.MessagesGetMessagesRequest:
+			return &tg.MessagesMessages{Messages: []tg.MessageClass{&tg.Message{
+				ID:     5,
+				PeerID: &tg.PeerUser{UserID: 10},
+				Media:  &tg.MessageMediaDocument{Document: ringtoneDoc(9, "Bell")},
+			}}}, nil
+		case *tg.AccountSaveRingtoneRequest:
+			if saveErr != nil {
+				return nil, saveErr
+			}
+			if converted != nil {
+				return &tg.AccountSavedRingtoneConverted{Document: converted}, nil
+			}
+			return &tg.AccountSavedRingtone{}, nil
+		}
+		return nil, nil
+	})
+	peers.storeUser(&tg.User{ID: 10, AccessHash: 1})
+	return inv, api, peers
+}
+
+func TestSaveRingtone(t *testing.T) {
+	inv, api, peers := ringtoneMessageClient(nil, nil)
+	r, err := saveRingtone(context.Background(), api, peers, "10", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.ID != 9 || r.Title != "Bell" {
+		t.Errorf("ringtone = %+v", r)
+	}
+	req := requests[*tg.AccountSaveRingtoneRequest](inv)[0]
+	if doc, ok := req.ID.(*tg.InputDocument); !ok || doc.ID != 9 || doc.AccessHash != 90 || req.Unsave {
+		t.Errorf("request = %+v", req)
+	}
+}
+
+func TestSaveRingtoneConverted(t *testing.T) {
+	_, api, peers := ringtoneMessageClient(nil, ringtoneDoc(11, "Bell (converted)"))
+	r, err := saveRingtone(context.Background(), api, peers, "10", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.ID != 11 {
+		t.Errorf("ringtone = %+v, want the converted copy", r)
+	}
+}
+
+func TestSaveRingtoneBadMime(t *testing.T) {
+	_, api, peers := ringtoneMessageClient(tgerr.New(400, "RINGTONE_MIME_INVALID"), nil)
+	_, err := saveRingtone(context.Background(), api, peers, "10", 5)
+	if err == nil || !strings.Contains(err.Error(), "cannot be a ringtone") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestUnsaveRingtone(t *testing.T) {
+	inv, api, _ := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		switch input.(type) {
+		case *tg.AccountGetSavedRingtonesRequest:
+			return &tg.AccountSavedRingtones{Hash: 1, Ringtones: []tg.DocumentClass{ringtoneDoc(1, "Chime")}}, nil
+		case *tg.AccountSaveRingtoneRequest:
+			return &tg.AccountSavedRingtone{}, nil
+		}
+		return nil, nil
+	})
+	cache := &ringtoneCache{}
+
+	if err := unsaveRingtone(context.Background(), api, cache, 1); err != nil {
+		t.Fatal(err)
+	}
+	req := requests[*tg.AccountSaveRingtoneRequest](inv)[0]
+	if doc, ok := req.ID.(*tg.InputDocument); !ok || doc.ID != 1 || doc.AccessHash != 10 || !req.Unsave {
+		t.Errorf("request = %+v", req)
+	}
+
+	err := unsaveRingtone(context.Background(), api, cache, 2)
+	if err == nil || !strings.Contains(err.Error(), "not saved") {
+		t.Errorf("err = %v, want not saved", err)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gotd/td/tg"
+)
+
+// maxSearchOffset bounds how deep search_messages pages into global results,
+// which can only be skipped by fetching them
+const maxSearchOffset = 1000
+
+type searchMessagesArgs struct {
+	Peer   string `json:"peer"`
+	Query  string `json:"query"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+
+	MaxMessageChars int `json:"max_message_chars"`
+}
+
+func searchMessagesTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args searchMessagesArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.Query) == "" {
+			return nil, invalidParams("query is required")
+		}
+		if args.Limit < 0 {
+			return nil, invalidParams("limit must not be negative")
+		}
+		if args.Offset < 0 || args.Offset > maxSearchOffset {
+			return nil, invalidParams("offset must be between 0 and %d", maxSearchOffset)
+		}
+		if args.MaxMessageChars < 0 {
+			return nil, invalidParams("max_message_chars must not be negative")
+		}
+
+		limit := clampLimit(args.Limit, defaultMessageLimit, maxMessageLimit)
+		var msgs []messageInfo
+		var err error
+		if strings.TrimSpace(args.Peer) == "" {
+			msgs, err = searchGlobal(ctx, api, peers, args.Query, limit, args.Offset)
+		} else {
+			msgs, err = searchPeer(ctx, api, peers, args.Peer, args.Query, limit, args.Offset)
+		}
+		if err != nil {
+			return nil, err
+		}
+		truncateMessages(msgs, args.MaxMessageChars)
+		return msgs, nil
+	}
+}
+
+// searchPeer returns messages of peer matching query, newest first, skipping
+// the first offset matches
+func searchPeer(ctx context.Context, api *tg.Client, peers *peerResolver, peer, query string, limit, offset int) ([]messageInfo, error) {
+	input, err := peers.Resolve(ctx, peer)
+	if err != nil {
+		return nil, err
+	}
+	var res tg.MessagesMessagesClass
+	err = withFloodRetry(ctx, func() (err error) {
+		res, err = api.MessagesSearch(ctx, &tg.MessagesSearchRequest{
+			Peer:      input,
+			Q:         query,
+			Filter:    &tg.InputMessagesFilterEmpty{},
+			AddOffset: offset,
+			Limit:     limit,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search messages: %w", err)
+	}
+	msgs, _, err := searchPage(res, peers)
+	return msgs, err
+}
+
+// searchGlobal returns messages from all chats matching query, newest first.
+// Global search pages by cursor only, so offset matches are fetched and
+// dropped.
+func searchGlobal(ctx context.Context, api *tg.Client, peers *peerResolver, query string, limit, offset int) ([]messageInfo, error) {
+	req := &tg.MessagesSearchGlobalRequest{
+		Q:          query,
+		Filter:     &tg.InputMessagesFilterEmpty{},
+		OffsetPeer: &tg.InputPeerEmpty{},
+	}
+	result := []messageInfo{}
+	for len(result) < offset+limit {
+		req.Limit = min(offset+limit-len(result), maxMessageLimit)
+
+		var res tg.MessagesMessagesClass
+		err := withFloodRetry(ctx, func() (err error) {
+			res, err = api.MessagesSearchGlobal(ctx, req)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to search messages: %w", err)
+		}
+		msgs, more, err := searchPage(res, peers)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, msgs...)
+		if !more || len(msgs) == 0 {
+			break
+		}
+
+		// Continue after the last message of this page
+		last := msgs[len(msgs)-1]
+		slice, _ := res.(*tg.MessagesMessagesSlice)
+		req.OffsetRate = slice.NextRate
+		req.OffsetID = last.ID
+		if req.OffsetPeer, err = peers.Resolve(ctx, strconv.FormatInt(last.PeerID, 10)); err != nil {
+			return nil, err
+		}
+	}
+
+	if offset >= len(result) {
+		return []messageInfo{}, nil
+	}
+	return result[offset:min(offset+limit, len(result))], nil
+}
+
+// searchPage converts a page of search results, tagging each with its chat.
+// more reports whether the server has further results.
+func searchPage(res tg.MessagesMessagesClass, peers *peerResolver) ([]messageInfo, bool, error) {
+	modified, ok := res.AsModified()
+	if !ok {
+		return nil, false, fmt.Errorf("unexpected messages response %T", res)
+	}
+	peers.remember(modified.GetUsers(), modified.GetChats())
+
+	result := make([]messageInfo, 0, len(modified.GetMessages()))
+	for _, m := range modified.GetMessages() {
+		msg, ok := m.(*tg.Message)
+		if !ok {
+			continue
+		}
+		info, err := newMessageInfo(msg)
+		if err != nil {
+			return nil, false, err
+		}
+		if info.PeerID, err = markedPeerID(msg.PeerID); err != nil {
+			return nil, false, err
+		}
+		result = append(result, info)
+	}
+
+	slice, ok := res.(*tg.MessagesMessagesSlice)
+	more := ok && slice.Count > 0 && slice.NextRate != 0
+	return result, more, nil
+}
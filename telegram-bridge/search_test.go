@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+)
+
+func TestSearchMessagesTool(t *testing.T) {
+	long := strings.Repeat("keyword ", 20)
+	messages := []tg.MessageClass{
+		&tg.Message{ID: 2, PeerID: &tg.PeerUser{UserID: 10}, Message: long, Date: 200},
+		&tg.Message{ID: 1, PeerID: &tg.PeerUser{UserID: 10}, Message: "keyword", Date: 100},
+	}
+
+	tests := []struct {
+		name      string
+		args      string
+		found     []tg.MessageClass
+		wantIDs   []int
+		wantFull  int
+		wantCode  int
+		wantText0 string
+	}{
+		{name: "truncated", args: `{"peer":"10","query":"keyword","max_message_chars":10}`, found: messages, wantIDs: []int{2, 1}, wantFull: len(long), wantText0: "keyword k…"},
+		{name: "untruncated", args: `{"peer":"10","query":"keyword"}`, found: messages, wantIDs: []int{2, 1}, wantText0: long},
+		{name: "no matches", args: `{"peer":"10","query":"missing"}`, wantIDs: []int{}},
+		{name: "empty query", args: `{"peer":"10","query":" "}`, wantCode: rpcInvalidParams},
+		{name: "negative max_message_chars", args: `{"query":"keyword","max_message_chars":-1}`, wantCode: rpcInvalidParams},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+				if _, ok := input.(*tg.MessagesSearchRequest); ok {
+					return &tg.MessagesMessages{Messages: tt.found}, nil
+				}
+				return nil, nil
+			})
+			peers.storeUser(&tg.User{ID: 10, AccessHash: 1})
+
+			res, err := searchMessagesTool(api, peers)(context.Background(), json.RawMessage(tt.args))
+			if tt.wantCode != 0 {
+				var rpcErr *rpcError
+				if !errors.As(err, &rpcErr) || rpcErr.Code != tt.wantCode {
+					t.Fatalf("error = %v, want code %d", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			msgs := res.([]messageInfo)
+			if len(msgs) != len(tt.wantIDs) {
+				t.Fatalf("got %d messages, want %d", len(msgs), len(tt.wantIDs))
+			}
+			for i, id := range tt.wantIDs {
+				if msgs[i].ID != id {
+					t.Errorf("message %d id = %d, want %d", i, msgs[i].ID, id)
+				}
+			}
+			if len(msgs) > 0 {
+				if msgs[0].Text != tt.wantText0 || msgs[0].FullLength != tt.wantFull {
+					t.Errorf("first message = %q (full %d), want %q (full %d)", msgs[0].Text, msgs[0].FullLength, tt.wantText0, tt.wantFull)
+				}
+			}
+		})
+	}
+}
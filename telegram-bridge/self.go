@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/telegram/auth"
+	"github.com/gotd/td/tg"
+)
+
+// selfPeerNames are the peer arguments naming the logged-in account
+var selfPeerNames = map[string]bool{"me": true, "self": true}
+
+// selfCache keeps the logged-in user so tools about the account do not fetch
+// it on every call. It is installed as a client middleware: a request
+// failing because the authorization was lost clears it, so the user is
+// fetched again once signed back in.
+type selfCache struct {
+	mu   sync.Mutex
+	user *tg.User
+	// fetch is the users.getUsers call in flight, shared by concurrent gets
+	fetch *selfFetch
+	// gen changes on invalidate, so a fetch started before does not refill
+	// the cache
+	gen int
+	// fetches counts the users.getUsers calls made to fill the cache
+	fetches int
+}
+
+// selfFetch is the result of one users.getUsers call, set before done is
+// closed
+type selfFetch struct {
+	done chan struct{}
+	user *tg.User
+	err  error
+}
+
+// Handle implements telegram.Middleware
+func (c *selfCache) Handle(next tg.Invoker) telegram.InvokeFunc {
+	return func(ctx context.Context, input bin.Encoder, output bin.Decoder) error {
+		err := next.Invoke(ctx, input, output)
+		if auth.IsUnauthorized(err) {
+			c.invalidate()
+		}
+		return err
+	}
+}
+
+// get returns the cached user, fetching it with api when the cache is empty.
+// The lock is not held during the request: the middleware takes it when
+// the request fails as unauthorized.
+func (c *selfCache) get(ctx context.Context, api *tg.Client) (*tg.User, error) {
+	c.mu.Lock()
+	if c.user != nil {
+		u := c.user
+		c.mu.Unlock()
+		return u, nil
+	}
+	if f := c.fetch; f != nil {
+		c.mu.Unlock()
+		select {
+		case <-f.done:
+			return f.user, f.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	f := &selfFetch{done: make(chan struct{})}
+	c.fetch = f
+	gen := c.gen
+	c.mu.Unlock()
+
+	f.user, f.err = fetchSelf(ctx, api)
+
+	c.mu.Lock()
+	c.fetch = nil
+	c.fetches++
+	if f.err == nil && c.gen == gen {
+		c.user = f.user
+	}
+	c.mu.Unlock()
+	close(f.done)
+	return f.user, f.err
+}
+
+// fetchSelf requests the logged-in user
+func fetchSelf(ctx context.Context, api *tg.Client) (*tg.User, error) {
+	var users []tg.UserClass
+	err := withFloodRetry(ctx, func() (err error) {
+		users, err = api.UsersGetUsers(ctx, []tg.InputUserClass{&tg.InputUserSelf{}})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get own account: %w", err)
+	}
+	for _, u := range users {
+		if u, ok := u.(*tg.User); ok && u.Self {
+			return u, nil
+		}
+	}
+	return nil, fmt.Errorf("own account missing from users response")
+}
+
+// set caches u, a full user object of the logged-in account
+func (c *selfCache) set(u *tg.User) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.user = u
+}
+
+func (c *selfCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.user = nil
+	c.gen++
+}
+
+// Self returns the logged-in user from the cache
+func (r *peerResolver) Self(ctx context.Context) (*tg.User, error) {
+	return r.self.get(ctx, r.api)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+func selfClient() (*fakeInvoker, *tg.Client, *peerResolver) {
+	return newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		if _, ok := input.(*tg.UsersGetUsersRequest); ok {
+			return &tg.UserClassVector{Elems: []tg.UserClass{&tg.User{ID: 1, AccessHash: 11, Self: true, Premium: true}}}, nil
+		}
+		return nil, nil
+	})
+}
+
+func TestSelfCached(t *testing.T) {
+	inv, _, peers := selfClient()
+	for i := 0; i < 3; i++ {
+		u, err := peers.Self(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.ID != 1 || !u.Premium {
+			t.Errorf("self = %+v", u)
+		}
+	}
+	for _, peer := range []string{"me", "Self"} {
+		p, err := peers.resolve(context.Background(), peer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p != (cachedPeer{Type: peerUser, ID: 1, AccessHash: 11}) {
+			t.Errorf("resolve(%s) = %+v", peer, p)
+		}
+	}
+	if n := len(requests[*tg.UsersGetUsersRequest](inv)); n != 1 || peers.self.fetches != 1 {
+		t.Errorf("self fetched %d times, want once", n)
+	}
+}
+
+func TestSelfSetSkipsFetch(t *testing.T) {
+	inv, _, peers := selfClient()
+	peers.self.set(&tg.User{ID: 1, Self: true})
+	if _, err := peers.Self(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(requests[*tg.UsersGetUsersRequest](inv)); n != 0 {
+		t.Errorf("self fetched %d times after set, want none", n)
+	}
+
+	// A fresh self user seen in a response replaces the cached one
+	peers.storeUser(&tg.User{ID: 1, Self: true, Premium: true})
+	if u, _ := peers.Self(context.Background()); !u.Premium {
+		t.Error("cached self not refreshed by storeUser")
+	}
+}
+
+func TestSelfInvalidatedOnUnauthorized(t *testing.T) {
+	cache := &selfCache{user: &tg.User{ID: 1}}
+	fail := func(err error) *fakeInvoker {
+		return &fakeInvoker{handle: func(bin.Encoder) (bin.Encoder, error) { return nil, err }}
+	}
+	call := func(next tg.Invoker) {
+		_ = cache.Handle(next)(context.Background(), &tg.HelpGetConfigRequest{}, &tg.Config{})
+	}
+
+	call(fail(tgerr.New(420, "FLOOD_WAIT_3")))
+	if cache.user == nil {
+		t.Fatal("cache cleared by a flood wait")
+	}
+	call(fail(tgerr.New(401, "AUTH_KEY_UNREGISTERED")))
+	if cache.user != nil {
+		t.Error("cache kept after the authorization was lost")
+	}
+}
+
+func TestSelfFetchUnauthorized(t *testing.T) {
+	cache := &selfCache{}
+	inv := &fakeInvoker{handle: func(bin.Encoder) (bin.Encoder, error) {
+		return nil, tgerr.New(401, "AUTH_KEY_UNREGISTERED")
+	}}
+	api := tg.NewClient(cache.Handle(inv))
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := cache.get(context.Background(), api)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !tgerr.Is(err, "AUTH_KEY_UNREGISTERED") {
+			t.Errorf("err = %v, want AUTH_KEY_UNREGISTERED", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("get deadlocked on an unauthorized response")
+	}
+	if cache.user != nil || cache.fetch != nil {
+		t.Errorf("cache = %+v after a failed fetch", cache)
+	}
+}
+
+func TestSelfFetchShared(t *testing.T) {
+	release := make(chan struct{})
+	inv, _, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		<-release
+		return &tg.UserClassVector{Elems: []tg.UserClass{&tg.User{ID: 1, Self: true}}}, nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if u, err := peers.Self(context.Background()); err != nil || u.ID != 1 {
+				t.Errorf("Self = %+v, %v", u, err)
+			}
+		}()
+	}
+	for len(requests[*tg.UsersGetUsersRequest](inv)) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// set does not wait for the fetch in flight
+	peers.self.set(&tg.User{ID: 1, Self: true})
+	close(release)
+	wg.Wait()
+	if n := len(requests[*tg.UsersGetUsersRequest](inv)); n != 1 {
+		t.Errorf("self fetched %d times, want once", n)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gotd/td/tg"
+)
+
+// serviceNotificationLogSize is how many service notifications are kept;
+// older ones are dropped first
+const serviceNotificationLogSize = 50
+
+// ServiceNotification is an official Telegram notice seen on the update
+// stream, such as a login alert or a terms of service change
+type ServiceNotification struct {
+	// Type identifies the notice, e.g. AUTH_KEY_DROP_DUPLICATE
+	Type string `json:"type"`
+	Text string `json:"text"`
+	// Date is when Telegram put the notice in the inbox, or when the bridge
+	// received it for popup-only notices
+	Date int `json:"date"`
+	// Popup is set for notices the apps show in a dialog
+	Popup    bool `json:"popup,omitempty"`
+	HasMedia bool `json:"has_media,omitempty"`
+}
+
+// serviceNotificationLog keeps the most recent service notifications
+type serviceNotificationLog struct {
+	mu      sync.Mutex
+	entries []ServiceNotification // oldest first
+}
+
+func newServiceNotificationLog() *serviceNotificationLog {
+	return &serviceNotificationLog{}
+}
+
+// record adds the notice in u, received at now
+func (l *serviceNotificationLog) record(u *tg.UpdateServiceNotification, now time.Time) {
+	n := ServiceNotification{
+		Type:  u.Type,
+		Text:  u.Message,
+		Date:  int(now.Unix()),
+		Popup: u.Popup,
+	}
+	if date, ok := u.GetInboxDate(); ok {
+		n.Date = date
+	}
+	if _, empty := u.Media.(*tg.MessageMediaEmpty); u.Media != nil && !empty {
+		n.HasMedia = true
+	}
+	slog.Info("Telegram service notification", "type", n.Type, "popup", n.Popup)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, n)
+	if len(l.entries) > serviceNotificationLogSize {
+		l.entries = l.entries[len(l.entries)-serviceNotificationLogSize:]
+	}
+}
+
+// recent returns the logged notifications, newest first
+func (l *serviceNotificationLog) recent() []ServiceNotification {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	result := make([]ServiceNotification, 0, len(l.entries))
+	for i := len(l.entries) - 1; i >= 0; i-- {
+		result = append(result, l.entries[i])
+	}
+	return result
+}
+
+func getServiceNotificationsTool(stream *updateStream) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args struct{}
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if stream == nil {
+			return nil, fmt.Errorf("service notifications need the update stream; set stream_updates = true under [bridge]")
+		}
+		return getServiceNotifications(ctx, stream.notices), nil
+	}
+}
+
+// getServiceNotifications returns the service notifications seen since the
+// bridge started, newest first
+func getServiceNotifications(_ context.Context, notices *serviceNotificationLog) []ServiceNotification {
+	return notices.recent()
+}
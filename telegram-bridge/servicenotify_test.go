@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gotd/td/tg"
+)
+
+func TestServiceNotificationCaptured(t *testing.T) {
+	dispatcher := tg.NewUpdateDispatcher()
+	stream := newUpdateStream(dispatcher, NewMCPServer(&bytes.Buffer{}), messageFilter{})
+
+	login := &tg.UpdateServiceNotification{
+		Type:    "AUTH_KEY_DROP_DUPLICATE",
+		Message: "New login from Chrome on Linux",
+		Media:   &tg.MessageMediaEmpty{},
+	}
+	login.SetInboxDate(1700000000)
+	popup := &tg.UpdateServiceNotification{Popup: true, Type: "POPUP", Message: "Terms updated", Media: &tg.MessageMediaEmpty{}}
+	batch := &tg.Updates{Updates: []tg.UpdateClass{login, popup}}
+	before := time.Now().Unix()
+	if err := dispatcher.Handle(context.Background(), batch); err != nil {
+		t.Fatal(err)
+	}
+
+	got := getServiceNotifications(context.Background(), stream.notices)
+	if len(got) != 2 {
+		t.Fatalf("got %d notifications, want 2: %+v", len(got), got)
+	}
+	if got[0].Type != "POPUP" || !got[0].Popup || int64(got[0].Date) < before {
+		t.Errorf("newest = %+v, want the popup dated on receipt", got[0])
+	}
+	if got[1] != (ServiceNotification{Type: "AUTH_KEY_DROP_DUPLICATE", Text: "New login from Chrome on Linux", Date: 1700000000}) {
+		t.Errorf("oldest = %+v", got[1])
+	}
+}
+
+func TestServiceNotificationLogBounded(t *testing.T) {
+	log := newServiceNotificationLog()
+	for i := 0; i < serviceNotificationLogSize+5; i++ {
+		u := &tg.UpdateServiceNotification{Message: "notice"}
+		u.SetInboxDate(i)
+		log.record(u, time.Unix(0, 0))
+	}
+	got := log.recent()
+	if len(got) != serviceNotificationLogSize || got[len(got)-1].Date != 5 {
+		t.Errorf("kept %d notifications, oldest dated %d; want %d from 5", len(got), got[len(got)-1].Date, serviceNotificationLogSize)
+	}
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gotd/td/session"
+	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/telegram/auth"
+	"github.com/gotd/td/telegram/dcs"
+	"github.com/gotd/td/tg"
+)
+
+// authKeyLength is the size of an MTProto auth key in bytes
+const authKeyLength = 256
+
+// Structure to hold essential session data for export
+type ExportedSession struct {
+	DC      int    `json:"dc_id"`
+	Addr    string `json:"addr"`
+	AuthKey []byte `json:"auth_key"`
+	UserID  int64  `json:"user_id"`
+}
+
+// exportSession reads the gotd session file and exports it to the specified path
+func exportSession(sessionFilePath, exportPath string, userID int64, pretty bool) error {
+	// Read the session file
+	loader := session.Loader{Storage: &session.FileStorage{Path: sessionFilePath}}
+	sessionData, err := loader.Load(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to read session file: %w", err)
+	}
+
+	// gotd keeps the DC address in its config rather than in Addr
+	addr, err := dcAddr(sessionData)
+	if err != nil {
+		return err
+	}
+
+	// Create exported session data
+	exported := ExportedSession{
+		DC:      sessionData.DC,
+		Addr:    addr,
+		AuthKey: sessionData.AuthKey,
+		UserID:  userID,
+	}
+
+	// Export to the shared session file
+	jsonData, err := marshalJSON(exported, pretty)
+	if err != nil {
+		return fmt.Errorf("failed to marshal session data to JSON: %w", err)
+	}
+
+	err = os.WriteFile(exportPath, jsonData, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write shared session file '%s': %w", exportPath, err)
+	}
+
+	slog.Info("Session data exported", "path", exportPath)
+	return nil
+}
+
+// dcAddr returns the IP address of the session's DC from the DC options
+// saved with it, skipping the IPv6, CDN, media-only and obfuscated-only
+// entries the Python client cannot connect to
+func dcAddr(data *session.Data) (string, error) {
+	for _, opt := range data.Config.DCOptions {
+		if opt.ID != data.DC || opt.Ipv6 || opt.CDN || opt.MediaOnly || opt.TCPObfuscatedOnly {
+			continue
+		}
+		return opt.IPAddress, nil
+	}
+	return "", fmt.Errorf("no address found for DC %d in session config", data.DC)
+}
+
+// marshalJSON encodes v for the JSON files the bridge writes, indented when
+// pretty is set and compact otherwise
+func marshalJSON(v interface{}, pretty bool) ([]byte, error) {
+	if pretty {
+		return json.MarshalIndent(v, "", "  ")
+	}
+	return json.Marshal(v)
+}
+
+// LoadExportedSession reads and validates a session exported to path by
+// exportSession
+func LoadExportedSession(path string) (*ExportedSession, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read exported session: %w", err)
+	}
+	exported, err := decodeExportedSession(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exported session '%s': %w", path, err)
+	}
+	return exported, nil
+}
+
+// decodeExportedSession parses an ExportedSession JSON document, rejecting
+// truncated documents and auth keys of the wrong size
+func decodeExportedSession(data []byte) (*ExportedSession, error) {
+	var exported ExportedSession
+	if err := json.Unmarshal(data, &exported); err != nil {
+		return nil, fmt.Errorf("failed to parse exported session: %w", err)
+	}
+	if len(exported.AuthKey) != authKeyLength {
+		return nil, fmt.Errorf("invalid auth key length: got %d bytes, want %d", len(exported.AuthKey), authKeyLength)
+	}
+	return &exported, nil
+}
+
+// toSessionData converts an exported session back into gotd's session format
+func (e *ExportedSession) toSessionData() (*session.Data, error) {
+	if len(e.AuthKey) != authKeyLength {
+		return nil, fmt.Errorf("invalid auth key length: got %d bytes, want %d", len(e.AuthKey), authKeyLength)
+	}
+
+	// auth_key_id is the lower 64 bits of SHA1(auth_key)
+	sum := sha1.Sum(e.AuthKey)
+	return &session.Data{
+		DC:        e.DC,
+		Addr:      e.Addr,
+		AuthKey:   e.AuthKey,
+		AuthKeyID: sum[12:],
+	}, nil
+}
+
+// ExportedSessionStorage is a session.Storage that serves an ExportedSession
+// to gotd until the client stores a session of its own, which is written to
+// Next and served from then on
+type ExportedSessionStorage struct {
+	Session *ExportedSession
+	Next    session.Storage
+}
+
+// LoadSession implements session.Storage
+func (s *ExportedSessionStorage) LoadSession(ctx context.Context) ([]byte, error) {
+	data, err := s.Next.LoadSession(ctx)
+	if err == nil || !errors.Is(err, session.ErrNotFound) {
+		return data, err
+	}
+
+	converted, err := s.Session.toSessionData()
+	if err != nil {
+		return nil, err
+	}
+	mem := &session.StorageMemory{}
+	loader := session.Loader{Storage: mem}
+	if err := loader.Save(ctx, converted); err != nil {
+		return nil, err
+	}
+	return mem.Bytes(nil)
+}
+
+// StoreSession implements session.Storage
+func (s *ExportedSessionStorage) StoreSession(ctx context.Context, data []byte) error {
+	return s.Next.StoreSession(ctx, data)
+}
+
+// importTimeout bounds the verification of an imported session
+const importTimeout = time.Minute
+
+// sessionVerifier runs a client on storage, which serves the imported
+// session, and returns the user it is authorized as
+type sessionVerifier func(ctx context.Context, storage session.Storage) (*tg.User, error)
+
+// newSessionVerifier returns a sessionVerifier calling users.getUsers for the
+// current user, connecting through resolver when it is set
+func newSessionVerifier(apiID int, apiHash string, resolver dcs.Resolver) sessionVerifier {
+	return func(ctx context.Context, storage session.Storage) (*tg.User, error) {
+		client := telegram.NewClient(apiID, apiHash, telegram.Options{
+			SessionStorage: storage,
+			Resolver:       resolver,
+		})
+		var self *tg.User
+		err := client.Run(ctx, func(ctx context.Context) error {
+			users, err := client.API().UsersGetUsers(ctx, []tg.InputUserClass{&tg.InputUserSelf{}})
+			if err != nil {
+				return fmt.Errorf("session is not authorized: %w", err)
+			}
+			if len(users) == 0 {
+				return errors.New("session is not authorized: no user returned")
+			}
+			user, ok := users[0].(*tg.User)
+			if !ok {
+				return errors.New("session is not authorized: empty user returned")
+			}
+			self = user
+			return nil
+		})
+		return self, err
+	}
+}
+
+// importSession reads an ExportedSession JSON document from r, verifies that it
+// is authorized with verify, and only then writes it to storage. An unverified
+// session never overwrites storage. Verification gives up after importTimeout.
+func importSession(ctx context.Context, r io.Reader, verify sessionVerifier, storage session.Storage) error {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to read exported session: %w", err)
+	}
+	exported, err := decodeExportedSession(raw)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, importTimeout)
+	defer cancel()
+
+	// Verify against an in-memory copy first
+	mem := &session.StorageMemory{}
+	imported := &ExportedSessionStorage{Session: exported, Next: mem}
+	self, err := verify(ctx, imported)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("session could not be verified within %s", importTimeout)
+	}
+	if err != nil {
+		return err
+	}
+	if exported.UserID != 0 && self.ID != exported.UserID {
+		return fmt.Errorf("session belongs to user %d, expected %d", self.ID, exported.UserID)
+	}
+	slog.Info("Imported session verified", "user_id", self.ID, "username", self.Username)
+
+	// The client may have rewritten the session; otherwise the imported one
+	// is stored as is
+	verified, err := imported.LoadSession(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read verified session: %w", err)
+	}
+	if err := storage.StoreSession(ctx, verified); err != nil {
+		return fmt.Errorf("failed to store session: %w", err)
+	}
+	return nil
+}
+
+// sessionPaths are the session files of the running bridge
+type sessionPaths struct {
+	// Session is the gotd session file
+	Session string
+	// Shared is the JSON export read by the Python server
+	Shared string
+	// QRCode is the login QR code image
+	QRCode string
+	// Outbox holds the messages waiting to be resent
+	Outbox string
+}
+
+// newSessionPaths lays out the session files in storeDir
+func newSessionPaths(storeDir string) sessionPaths {
+	return sessionPaths{
+		Session: filepath.Join(storeDir, "telegram.session"),
+		Shared:  filepath.Join(storeDir, "shared_session.json"),
+		QRCode:  filepath.Join(storeDir, "qrcode.png"),
+		Outbox:  filepath.Join(storeDir, "outbox.json"),
+	}
+}
+
+// AuthState is a read-only snapshot of the session's authorization
+type AuthState struct {
+	Authorized bool  `json:"authorized"`
+	UserID     int64 `json:"user_id,omitempty"`
+	DC         int   `json:"dc_id"`
+	// SharedExportCurrent reports whether the shared export holds the same
+	// auth key, DC and user as the live session
+	SharedExportCurrent bool `json:"shared_export_current"`
+}
+
+func checkAuthTool(api *tg.Client, cfg *Config, paths sessionPaths) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args struct{}
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		return checkAuth(ctx, api, cfg, paths)
+	}
+}
+
+// checkAuth reports whether the session is still authorized without changing
+// it: auth.Status only fetches the current user, and the session files are
+// read but never written
+func checkAuth(ctx context.Context, api *tg.Client, cfg *Config, paths sessionPaths) (AuthState, error) {
+	loader := session.Loader{Storage: &session.FileStorage{Path: paths.Session}}
+	data, err := loader.Load(ctx)
+	if err != nil {
+		return AuthState{}, fmt.Errorf("failed to read session file: %w", err)
+	}
+	state := AuthState{DC: data.DC}
+
+	status, err := auth.NewClient(api, rand.Reader, cfg.APIID, cfg.APIHash).Status(ctx)
+	if err != nil {
+		return AuthState{}, fmt.Errorf("failed to get auth status: %w", err)
+	}
+	if !status.Authorized {
+		return state, nil
+	}
+	state.Authorized = true
+	state.UserID = status.User.ID
+
+	exported, err := LoadExportedSession(paths.Shared)
+	if err != nil {
+		// A missing or broken export is reported as stale, not as a failure
+		slog.Debug("Shared session export not usable", "error", err)
+		return state, nil
+	}
+	addr, err := dcAddr(data)
+	if err != nil {
+		slog.Debug("Session DC address not found", "error", err)
+	}
+	state.SharedExportCurrent = exported.UserID == status.User.ID &&
+		exported.DC == data.DC &&
+		exported.Addr == addr &&
+		bytes.Equal(exported.AuthKey, data.AuthKey)
+	return state, nil
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/session"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+func TestDCAddr(t *testing.T) {
+	options := []tg.DCOption{
+		{ID: 1, IPAddress: "149.154.175.53"},
+		{ID: 2, IPAddress: "2001:67c:4e8:f002::a", Ipv6: true},
+		{ID: 2, IPAddress: "149.154.167.222", MediaOnly: true},
+		{ID: 2, IPAddress: "149.154.167.200", TCPObfuscatedOnly: true},
+		{ID: 2, IPAddress: "149.154.167.51"},
+		{ID: 4, IPAddress: "149.154.167.92", CDN: true},
+	}
+	tests := []struct {
+		name    string
+		dc      int
+		want    string
+		wantErr bool
+	}{
+		{name: "first match", dc: 1, want: "149.154.175.53"},
+		{name: "skips unusable options", dc: 2, want: "149.154.167.51"},
+		{name: "only cdn", dc: 4, wantErr: true},
+		{name: "unknown dc", dc: 5, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := &session.Data{DC: tt.dc, Config: session.Config{DCOptions: options}}
+			got, err := dcAddr(data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("dcAddr() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("dcAddr() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// exportedJSON returns an exported session document with an auth key of
+// keyLen bytes
+func exportedJSON(keyLen int, userID int64) string {
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, keyLen))
+	return fmt.Sprintf(`{"dc_id":2,"addr":"149.154.167.51","auth_key":%q,"user_id":%d}`, key, userID)
+}
+
+func TestDecodeExportedSession(t *testing.T) {
+	valid := exportedJSON(authKeyLength, 42)
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{name: "valid", data: valid},
+		{name: "truncated", data: valid[:len(valid)/2], wantErr: "failed to parse"},
+		{name: "short auth key", data: exportedJSON(32, 42), wantErr: "invalid auth key length"},
+		{name: "missing auth key", data: `{"dc_id":2}`, wantErr: "invalid auth key length"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeExportedSession([]byte(tt.data))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("decodeExportedSession() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeExportedSession() error = %v", err)
+			}
+			if got.DC != 2 || got.Addr != "149.154.167.51" || got.UserID != 42 {
+				t.Errorf("decodeExportedSession() = %+v", got)
+			}
+		})
+	}
+}
+
+func TestImportSession(t *testing.T) {
+	authorized := func(id int64) sessionVerifier {
+		return func(ctx context.Context, storage session.Storage) (*tg.User, error) {
+			// The verifying client must be served the imported session
+			data, err := (&session.Loader{Storage: storage}).Load(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if data.DC != 2 || len(data.AuthKey) != authKeyLength {
+				return nil, fmt.Errorf("unexpected session served: dc %d", data.DC)
+			}
+			return &tg.User{ID: id}, nil
+		}
+	}
+	unauthorized := func(context.Context, session.Storage) (*tg.User, error) {
+		return nil, errors.New("session is not authorized: AUTH_KEY_UNREGISTERED")
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		verify  sessionVerifier
+		wantErr string
+	}{
+		{name: "verified", input: exportedJSON(authKeyLength, 42), verify: authorized(42)},
+		{name: "no expected user", input: exportedJSON(authKeyLength, 0), verify: authorized(42)},
+		{name: "other user", input: exportedJSON(authKeyLength, 42), verify: authorized(43), wantErr: "belongs to user 43"},
+		{name: "not authorized", input: exportedJSON(authKeyLength, 42), verify: unauthorized, wantErr: "not authorized"},
+		{name: "invalid document", input: exportedJSON(16, 42), verify: authorized(42), wantErr: "invalid auth key length"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &session.StorageMemory{}
+			err := importSession(context.Background(), strings.NewReader(tt.input), tt.verify, storage)
+			stored, loadErr := (&session.Loader{Storage: storage}).Load(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("importSession() error = %v, want %q", err, tt.wantErr)
+				}
+				if !errors.Is(loadErr, session.ErrNotFound) {
+					t.Errorf("unverified session was stored")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("importSession() error = %v", err)
+			}
+			if loadErr != nil {
+				t.Fatalf("stored session not readable: %v", loadErr)
+			}
+			if stored.DC != 2 || !bytes.Equal(stored.AuthKey, bytes.Repeat([]byte{7}, authKeyLength)) {
+				t.Errorf("stored session = dc %d, key %d bytes", stored.DC, len(stored.AuthKey))
+			}
+		})
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	exported := ExportedSession{DC: 2, Addr: "149.154.167.51", AuthKey: bytes.Repeat([]byte{1}, authKeyLength), UserID: 42}
+	for _, pretty := range []bool{true, false} {
+		t.Run(fmt.Sprintf("pretty=%v", pretty), func(t *testing.T) {
+			data, err := marshalJSON(exported, pretty)
+			if err != nil {
+				t.Fatal(err)
+			}
+			indented := bytes.Contains(data, []byte("\n  "))
+			if indented != pretty {
+				t.Errorf("indented = %v, want %v: %s", indented, pretty, data)
+			}
+			if !pretty && bytes.ContainsAny(data, "\n\t ") {
+				t.Errorf("compact output contains whitespace: %s", data)
+			}
+
+			decoded, err := decodeExportedSession(data)
+			if err != nil {
+				t.Fatalf("round trip failed: %v", err)
+			}
+			if decoded.DC != exported.DC || decoded.Addr != exported.Addr || decoded.UserID != exported.UserID || !bytes.Equal(decoded.AuthKey, exported.AuthKey) {
+				t.Errorf("round trip = %+v, want %+v", decoded, exported)
+			}
+		})
+	}
+}
+
+func TestCheckAuth(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, authKeyLength)
+	data := &session.Data{
+		DC:      2,
+		AuthKey: key,
+		Config:  session.Config{DCOptions: []tg.DCOption{{ID: 2, IPAddress: "149.154.167.51"}}},
+	}
+
+	tests := []struct {
+		name        string
+		authorized  bool
+		exportUser  int64
+		wantCurrent bool
+	}{
+		{name: "authorized with current export", authorized: true, exportUser: 42, wantCurrent: true},
+		{name: "authorized with stale export", authorized: true, exportUser: 43},
+		{name: "authorized without export", authorized: true},
+		{name: "unauthorized", exportUser: 42},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths := newSessionPaths(t.TempDir())
+			ctx := context.Background()
+			if err := (&session.Loader{Storage: &session.FileStorage{Path: paths.Session}}).Save(ctx, data); err != nil {
+				t.Fatal(err)
+			}
+			if tt.exportUser != 0 {
+				if err := exportSession(paths.Session, paths.Shared, tt.exportUser, false); err != nil {
+					t.Fatal(err)
+				}
+			}
+			inv, api, _ := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+				if _, ok := input.(*tg.UsersGetUsersRequest); ok {
+					if !tt.authorized {
+						return nil, tgerr.New(401, "AUTH_KEY_UNREGISTERED")
+					}
+					return &tg.UserClassVector{Elems: []tg.UserClass{&tg.User{ID: 42, Self: true}}}, nil
+				}
+				return nil, nil
+			})
+			saved, _ := os.ReadFile(paths.Session)
+
+			state, err := checkAuth(ctx, api, &Config{APIID: 1, APIHash: "hash"}, paths)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := AuthState{Authorized: tt.authorized, DC: 2, SharedExportCurrent: tt.wantCurrent}
+			if tt.authorized {
+				want.UserID = 42
+			}
+			if state != want {
+				t.Errorf("checkAuth() = %+v, want %+v", state, want)
+			}
+
+			// Read-only: only users.getUsers is called and the session is kept
+			for _, c := range inv.calls {
+				if _, ok := c.(*tg.UsersGetUsersRequest); !ok {
+					t.Errorf("unexpected request %T", c)
+				}
+			}
+			if after, _ := os.ReadFile(paths.Session); !bytes.Equal(saved, after) {
+				t.Error("session file was modified")
+			}
+		})
+	}
+}
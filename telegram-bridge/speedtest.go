@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/gotd/td/telegram/downloader"
+	"github.com/gotd/td/tg"
+)
+
+// speedTestSize is the size of the file a speed test moves each way, two
+// upload parts
+const speedTestSize = 1 << 20
+
+// SpeedTest is the measured throughput between the bridge and its DC
+type SpeedTest struct {
+	// DC is the data center the account is connected to
+	DC        int   `json:"dc"`
+	Bytes     int64 `json:"bytes"`
+	LatencyMS int64 `json:"latency_ms"`
+	// UploadMS and DownloadMS are the transfer times, and the Mbps fields
+	// the throughput in megabits per second
+	UploadMS     int64   `json:"upload_ms"`
+	DownloadMS   int64   `json:"download_ms"`
+	UploadMbps   float64 `json:"upload_mbps"`
+	DownloadMbps float64 `json:"download_mbps"`
+}
+
+func speedTestTool(api *tg.Client) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args struct{}
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		return speedTest(ctx, api)
+	}
+}
+
+// speedTest times a round trip to the DC, then uploads a random file into
+// Saved Messages storage without posting it and downloads it back. The
+// local temporary file is removed afterwards.
+func speedTest(ctx context.Context, api *tg.Client) (SpeedTest, error) {
+	f, err := os.CreateTemp("", "telegram-speedtest-*.bin")
+	if err != nil {
+		return SpeedTest{}, fmt.Errorf("failed to create speed test file: %w", err)
+	}
+	path := f.Name()
+	defer os.Remove(path)
+	_, err = io.CopyN(f, rand.Reader, speedTestSize)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return SpeedTest{}, fmt.Errorf("failed to write speed test file: %w", err)
+	}
+
+	start := time.Now()
+	var nearest *tg.NearestDC
+	err = withFloodRetry(ctx, func() (err error) {
+		nearest, err = api.HelpGetNearestDC(ctx)
+		return err
+	})
+	if err != nil {
+		return SpeedTest{}, fmt.Errorf("failed to reach Telegram: %w", err)
+	}
+	latency := time.Since(start)
+
+	start = time.Now()
+	handle, err := uploadOnce(ctx, api, path)
+	if err != nil {
+		return SpeedTest{}, err
+	}
+	upload := time.Since(start)
+
+	location := &tg.InputDocumentFileLocation{
+		ID:            handle.document.ID,
+		AccessHash:    handle.document.AccessHash,
+		FileReference: handle.document.FileReference,
+	}
+	w := &countingWriter{w: io.Discard}
+	start = time.Now()
+	err = withFloodRetry(ctx, func() error {
+		w.n = 0
+		_, err := downloader.NewDownloader().Download(api, location).Stream(ctx, w)
+		return err
+	})
+	if err != nil {
+		return SpeedTest{}, fmt.Errorf("failed to download speed test file: %w", err)
+	}
+	download := time.Since(start)
+	if w.n != speedTestSize {
+		return SpeedTest{}, fmt.Errorf("downloaded %d bytes of the %d uploaded", w.n, speedTestSize)
+	}
+
+	result := newSpeedTest(speedTestSize, latency, upload, download)
+	result.DC = nearest.ThisDC
+	return result, nil
+}
+
+// newSpeedTest computes the throughput of moving size bytes each way in the
+// given times
+func newSpeedTest(size int64, latency, upload, download time.Duration) SpeedTest {
+	return SpeedTest{
+		Bytes:        size,
+		LatencyMS:    latency.Milliseconds(),
+		UploadMS:     upload.Milliseconds(),
+		DownloadMS:   download.Milliseconds(),
+		UploadMbps:   megabitsPerSecond(size, upload),
+		DownloadMbps: megabitsPerSecond(size, download),
+	}
+}
+
+// megabitsPerSecond is the rate of size bytes in d, rounded to two decimals.
+// A zero duration gives 0 rather than infinity.
+func megabitsPerSecond(size int64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	mbps := float64(size) * 8 / 1e6 / d.Seconds()
+	return float64(int64(mbps*100+0.5)) / 100
+}
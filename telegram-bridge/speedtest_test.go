@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+)
+
+func TestNewSpeedTest(t *testing.T) {
+	// 1 MiB is 8.388608 megabits
+	got := newSpeedTest(1<<20, 45*time.Millisecond, 2*time.Second, 500*time.Millisecond)
+	want := SpeedTest{
+		Bytes:        1 << 20,
+		LatencyMS:    45,
+		UploadMS:     2000,
+		DownloadMS:   500,
+		UploadMbps:   4.19,
+		DownloadMbps: 16.78,
+	}
+	if got != want {
+		t.Errorf("newSpeedTest = %+v, want %+v", got, want)
+	}
+	if got := megabitsPerSecond(1<<20, 0); got != 0 {
+		t.Errorf("zero duration = %v, want 0", got)
+	}
+}
+
+func TestSpeedTest(t *testing.T) {
+	var uploaded int
+	inv, api, _ := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		switch r := input.(type) {
+		case *tg.HelpGetNearestDCRequest:
+			return &tg.NearestDC{Country: "PT", ThisDC: 4, NearestDC: 4}, nil
+		case *tg.UploadSaveFilePartRequest:
+			uploaded += len(r.Bytes)
+			return &tg.BoolTrue{}, nil
+		case *tg.MessagesUploadMediaRequest:
+			return &tg.MessageMediaDocument{Document: &tg.Document{
+				ID: 700, AccessHash: 7, FileReference: []byte{7}, Size: speedTestSize,
+				Thumbs: []tg.PhotoSizeClass{}, Attributes: []tg.DocumentAttributeClass{},
+			}}, nil
+		case *tg.UploadGetFileRequest:
+			n := min(int64(r.Limit), speedTestSize-r.Offset)
+			return &tg.UploadFile{Type: &tg.StorageFileUnknown{}, Bytes: make([]byte, max(n, 0))}, nil
+		}
+		return nil, nil
+	})
+
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	result, err := speedTest(context.Background(), api)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if left, _ := os.ReadDir(tmp); len(left) != 0 {
+		t.Errorf("temporary files left behind: %v", left)
+	}
+	if result.DC != 4 || result.Bytes != speedTestSize || uploaded != speedTestSize {
+		t.Errorf("result = %+v, uploaded %d bytes", result, uploaded)
+	}
+	if _, ok := requests[*tg.MessagesUploadMediaRequest](inv)[0].Peer.(*tg.InputPeerSelf); !ok {
+		t.Error("speed test file not stored in Saved Messages")
+	}
+	if n := len(requests[*tg.MessagesSendMediaRequest](inv)); n != 0 {
+		t.Errorf("speed test posted %d messages", n)
+	}
+	for _, r := range requests[*tg.UploadGetFileRequest](inv) {
+		if loc, ok := r.Location.(*tg.InputDocumentFileLocation); !ok || loc.ID != 700 {
+			t.Errorf("downloaded %#v, want document 700", r.Location)
+		}
+	}
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+// Story privacy settings accepted by send_story and reported for stories
+const (
+	storyEveryone         = "everyone"
+	storyContacts         = "contacts"
+	storyCloseFriends     = "close_friends"
+	storySelectedContacts = "selected_contacts"
+)
+
+// Story is a story posted by a user or channel
+type Story struct {
+	ID         int    `json:"id"`
+	Date       int    `json:"date"`
+	ExpireDate int    `json:"expire_date"`
+	Caption    string `json:"caption,omitempty"`
+	Media      string `json:"media,omitempty"`
+	Privacy    string `json:"privacy"`
+	// Pinned stories stay on the profile after they expire
+	Pinned bool `json:"pinned"`
+	Views  int  `json:"views"`
+	// Path is where the media was saved, set when it was downloaded
+	Path string `json:"path,omitempty"`
+
+	media tg.MessageMediaClass
+}
+
+type sendStoryArgs struct {
+	Peer    string `json:"peer"`
+	Path    string `json:"path"`
+	Caption string `json:"caption"`
+	Privacy string `json:"privacy"`
+}
+
+type sendStoryResult struct {
+	StoryID int `json:"story_id"`
+}
+
+func sendStoryTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args sendStoryArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.Path) == "" {
+			return nil, invalidParams("path is required")
+		}
+		if args.Privacy == "" {
+			args.Privacy = storyEveryone
+		}
+		if _, ok := storyPrivacyRules(args.Privacy); !ok {
+			return nil, invalidParams("privacy must be everyone, contacts or close_friends")
+		}
+		id, err := sendStory(ctx, api, peers, args.Peer, args.Path, args.Caption, args.Privacy)
+		if err != nil {
+			return nil, err
+		}
+		return sendStoryResult{StoryID: id}, nil
+	}
+}
+
+// storyPrivacyRules maps a privacy setting to the rules sent with a story
+func storyPrivacyRules(privacy string) ([]tg.InputPrivacyRuleClass, bool) {
+	switch privacy {
+	case storyEveryone:
+		return []tg.InputPrivacyRuleClass{&tg.InputPrivacyValueAllowAll{}}, true
+	case storyContacts:
+		return []tg.InputPrivacyRuleClass{&tg.InputPrivacyValueAllowContacts{}}, true
+	case storyCloseFriends:
+		return []tg.InputPrivacyRuleClass{&tg.InputPrivacyValueAllowCloseFriends{}}, true
+	}
+	return nil, false
+}
+
+// sendStory posts the photo or video at path as a story of peer, or of your
+// own profile when peer is empty, and returns the story ID
+func sendStory(ctx context.Context, api *tg.Client, peers *peerResolver, peer, path, caption, privacy string) (int, error) {
+	rules, ok := storyPrivacyRules(privacy)
+	if !ok {
+		return 0, fmt.Errorf("unknown story privacy %q", privacy)
+	}
+	input, err := storyPeer(ctx, peers, peer)
+	if err != nil {
+		return 0, err
+	}
+	mimeType := mimeTypeByName(filepath.Base(path))
+	if !strings.HasPrefix(mimeType, "image/") && !strings.HasPrefix(mimeType, "video/") {
+		return 0, fmt.Errorf("%s is not a photo or video", path)
+	}
+	file, err := uploadLocalFile(ctx, api, path)
+	if err != nil {
+		return 0, err
+	}
+	var media tg.InputMediaClass = &tg.InputMediaUploadedPhoto{File: file}
+	if strings.HasPrefix(mimeType, "video/") {
+		media = &tg.InputMediaUploadedDocument{
+			File:       file,
+			MimeType:   mimeType,
+			Attributes: []tg.DocumentAttributeClass{&tg.DocumentAttributeVideo{SupportsStreaming: true}},
+		}
+	}
+
+	randomID, err := randomInt64()
+	if err != nil {
+		return 0, err
+	}
+	req := &tg.StoriesSendStoryRequest{
+		Peer:         input,
+		Media:        media,
+		PrivacyRules: rules,
+		RandomID:     randomID,
+	}
+	if caption != "" {
+		req.SetCaption(caption)
+	}
+	var updates tg.UpdatesClass
+	err = withFloodRetry(ctx, func() (err error) {
+		updates, err = api.StoriesSendStory(ctx, req)
+		return err
+	})
+	if err != nil {
+		return 0, storyError(peer, err)
+	}
+	return sentStoryID(updates)
+}
+
+// storyPeer resolves the peer a story belongs to; empty means yourself
+func storyPeer(ctx context.Context, peers *peerResolver, peer string) (tg.InputPeerClass, error) {
+	if strings.TrimSpace(peer) == "" {
+		return &tg.InputPeerSelf{}, nil
+	}
+	return peers.Resolve(ctx, peer)
+}
+
+// storyError explains the errors Telegram returns to accounts and chats
+// that may not post stories
+func storyError(peer string, err error) error {
+	if peer == "" {
+		peer = "your profile"
+	}
+	switch {
+	case tg.IsPremiumAccountRequired(err):
+		return fmt.Errorf("posting this story to %s needs Telegram Premium: %w", peer, err)
+	case tgerr.Is(err, "STORIES_TOO_MUCH"):
+		return fmt.Errorf("%s has reached its limit of active stories: %w", peer, err)
+	case tgerr.Is(err, "BOOSTS_REQUIRED"):
+		return fmt.Errorf("%s needs more boosts before it can post stories: %w", peer, err)
+	case tgerr.Is(err, "CHAT_ADMIN_REQUIRED"):
+		return fmt.Errorf("you need the post stories admin right in %s: %w", peer, err)
+	}
+	return fmt.Errorf("failed to send story to %s: %w", peer, err)
+}
+
+// sentStoryID finds the ID the server assigned to a new story
+func sentStoryID(u tg.UpdatesClass) (int, error) {
+	var updates []tg.UpdateClass
+	switch u := u.(type) {
+	case *tg.Updates:
+		updates = u.Updates
+	case *tg.UpdatesCombined:
+		updates = u.Updates
+	default:
+		return 0, fmt.Errorf("unexpected send story response %T", u)
+	}
+	for _, update := range updates {
+		switch update := update.(type) {
+		case *tg.UpdateStoryID:
+			return update.ID, nil
+		case *tg.UpdateStory:
+			return update.Story.GetID(), nil
+		}
+	}
+	return 0, fmt.Errorf("story ID missing from response")
+}
+
+type storyIDsArgs struct {
+	Peer string `json:"peer"`
+	IDs  []int  `json:"ids"`
+}
+
+type deleteStoriesResult struct {
+	Deleted []int `json:"deleted"`
+}
+
+func deleteStoriesTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args storyIDsArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if len(args.IDs) == 0 {
+			return nil, invalidParams("ids is required")
+		}
+		deleted, err := deleteStories(ctx, api, peers, args.Peer, args.IDs)
+		if err != nil {
+			return nil, err
+		}
+		return deleteStoriesResult{Deleted: deleted}, nil
+	}
+}
+
+// deleteStories deletes stories of peer, or of your profile when peer is
+// empty, and returns the IDs that were deleted
+func deleteStories(ctx context.Context, api *tg.Client, peers *peerResolver, peer string, ids []int) ([]int, error) {
+	input, err := storyPeer(ctx, peers, peer)
+	if err != nil {
+		return nil, err
+	}
+	var deleted []int
+	err = withFloodRetry(ctx, func() (err error) {
+		deleted, err = api.StoriesDeleteStories(ctx, &tg.StoriesDeleteStoriesRequest{Peer: input, ID: ids})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete stories: %w", err)
+	}
+	if deleted == nil {
+		deleted = []int{}
+	}
+	return deleted, nil
+}
+
+type getStoriesResult struct {
+	Stories []Story `json:"stories"`
+}
+
+func getStoriesTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args storyIDsArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if len(args.IDs) == 0 {
+			return nil, invalidParams("ids is required")
+		}
+		stories, err := getStories(ctx, api, peers, args.Peer, args.IDs)
+		if err != nil {
+			return nil, err
+		}
+		return getStoriesResult{Stories: stories}, nil
+	}
+}
+
+// getStories returns stories of peer, or of your profile when peer is empty,
+// by ID. Deleted stories and stories you may not see are left out.
+func getStories(ctx context.Context, api *tg.Client, peers *peerResolver, peer string, ids []int) ([]Story, error) {
+	input, err := storyPeer(ctx, peers, peer)
+	if err != nil {
+		return nil, err
+	}
+	var res *tg.StoriesStories
+	err = withFloodRetry(ctx, func() (err error) {
+		res, err = api.StoriesGetStoriesByID(ctx, &tg.StoriesGetStoriesByIDRequest{Peer: input, ID: ids})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stories: %w", err)
+	}
+	peers.remember(res.Users, res.Chats)
+	return newStories(res.Stories), nil
+}
+
+func newStories(items []tg.StoryItemClass) []Story {
+	result := make([]Story, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(*tg.StoryItem); ok {
+			result = append(result, newStory(s))
+		}
+	}
+	return result
+}
+
+func newStory(s *tg.StoryItem) Story {
+	return Story{
+		ID:         s.ID,
+		Date:       s.Date,
+		ExpireDate: s.ExpireDate,
+		Caption:    s.Caption,
+		Media:      messageMediaKind(s.Media),
+		Privacy:    storyPrivacy(s),
+		Pinned:     s.Pinned,
+		Views:      s.Views.ViewsCount,
+		media:      s.Media,
+	}
+}
+
+// storyPrivacy returns who can see a story
+func storyPrivacy(s *tg.StoryItem) string {
+	switch {
+	case s.Public:
+		return storyEveryone
+	case s.CloseFriends:
+		return storyCloseFriends
+	case s.SelectedContacts:
+		return storySelectedContacts
+	}
+	return storyContacts
+}
+
+type peerStoriesArgs struct {
+	Peer string `json:"peer"`
+	// DestDir, when set, is where the media of each story is saved as
+	// <story_id> with an extension for its type
+	DestDir string `json:"dest_dir"`
+}
+
+func getPeerStoriesTool(api *tg.Client, peers *peerResolver, downloads *downloadLog) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args peerStoriesArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.Peer) == "" {
+			return nil, invalidParams("peer is required")
+		}
+		stories, err := getPeerStories(ctx, api, peers, args.Peer)
+		if err != nil {
+			return nil, err
+		}
+		if args.DestDir != "" {
+			p, err := peers.resolve(ctx, args.Peer)
+			if err != nil {
+				return nil, err
+			}
+			if err := downloadStories(ctx, api, stories, args.DestDir, p.MarkedID(), downloads); err != nil {
+				return nil, err
+			}
+		}
+		return getStoriesResult{Stories: stories}, nil
+	}
+}
+
+// getPeerStories returns the active stories of peer, oldest first. A peer
+// without active stories gives an empty list.
+func getPeerStories(ctx context.Context, api *tg.Client, peers *peerResolver, peer string) ([]Story, error) {
+	input, err := peers.Resolve(ctx, peer)
+	if err != nil {
+		return nil, err
+	}
+	var res *tg.StoriesPeerStories
+	err = withFloodRetry(ctx, func() (err error) {
+		res, err = api.StoriesGetPeerStories(ctx, input)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stories of %s: %w", peer, err)
+	}
+	peers.remember(res.Users, res.Chats)
+	return newStories(res.Stories.Stories), nil
+}
+
+// downloadStories saves the media of each story of peerID into dir, setting
+// Path on success and logging it in downloads. Stories without a
+// downloadable file are skipped.
+func downloadStories(ctx context.Context, api *tg.Client, stories []Story, dir string, peerID int64, downloads *downloadLog) error {
+	for i := range stories {
+		file, err := mediaFileOf(stories[i].media, fmt.Sprintf("story %d", stories[i].ID))
+		if err != nil {
+			continue
+		}
+		dest := filepath.Join(dir, strconv.Itoa(stories[i].ID)+mediaFileExt(file.MimeType))
+		res, err := saveMediaFile(ctx, api, file, dest)
+		if err != nil {
+			return fmt.Errorf("failed to download story %d: %w", stories[i].ID, err)
+		}
+		stories[i].Path = res.Path
+		downloads.record(res, peerID, downloadStory, int64(stories[i].ID))
+	}
+	return nil
+}
+
+// mediaFileExt returns the file extension for downloaded media of mimeType
+func mediaFileExt(mimeType string) string {
+	switch mimeType {
+	case "image/jpeg":
+		return ".jpg"
+	case "video/mp4":
+		return ".mp4"
+	}
+	if exts, err := mime.ExtensionsByType(mimeType); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
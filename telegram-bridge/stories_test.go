@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+func TestStoryPrivacyRules(t *testing.T) {
+	tests := []struct {
+		privacy string
+		want    tg.InputPrivacyRuleClass
+	}{
+		{storyEveryone, &tg.InputPrivacyValueAllowAll{}},
+		{storyContacts, &tg.InputPrivacyValueAllowContacts{}},
+		{storyCloseFriends, &tg.InputPrivacyValueAllowCloseFriends{}},
+	}
+	for _, tt := range tests {
+		rules, ok := storyPrivacyRules(tt.privacy)
+		if !ok || len(rules) != 1 || rules[0].TypeID() != tt.want.TypeID() {
+			t.Errorf("storyPrivacyRules(%q) = %v, %v", tt.privacy, rules, ok)
+		}
+	}
+	if _, ok := storyPrivacyRules("nobody"); ok {
+		t.Error("unknown privacy accepted")
+	}
+}
+
+func TestStoryPrivacy(t *testing.T) {
+	tests := []struct {
+		item tg.StoryItem
+		want string
+	}{
+		{tg.StoryItem{Public: true}, storyEveryone},
+		{tg.StoryItem{CloseFriends: true}, storyCloseFriends},
+		{tg.StoryItem{SelectedContacts: true}, storySelectedContacts},
+		{tg.StoryItem{Contacts: true}, storyContacts},
+	}
+	for _, tt := range tests {
+		if got := storyPrivacy(&tt.item); got != tt.want {
+			t.Errorf("storyPrivacy(%+v) = %q, want %q", tt.item, got, tt.want)
+		}
+	}
+}
+
+func storyPhoto(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "story.jpg")
+	if err := os.WriteFile(path, []byte("\xff\xd8\xff photo"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSendStory(t *testing.T) {
+	inv, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		switch input.(type) {
+		case *tg.UploadSaveFilePartRequest:
+			return &tg.BoolTrue{}, nil
+		case *tg.StoriesSendStoryRequest:
+			return &tg.Updates{Updates: []tg.UpdateClass{&tg.UpdateStoryID{ID: 7, RandomID: 1}}}, nil
+		}
+		return nil, nil
+	})
+	id, err := sendStory(context.Background(), api, peers, "", storyPhoto(t), "hello", storyCloseFriends)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 7 {
+		t.Errorf("id = %d, want 7", id)
+	}
+	req := requests[*tg.StoriesSendStoryRequest](inv)[0]
+	if _, ok := req.Peer.(*tg.InputPeerSelf); !ok {
+		t.Errorf("peer = %#v, want self", req.Peer)
+	}
+	if _, ok := req.Media.(*tg.InputMediaUploadedPhoto); !ok {
+		t.Errorf("media = %T, want uploaded photo", req.Media)
+	}
+	if _, ok := req.PrivacyRules[0].(*tg.InputPrivacyValueAllowCloseFriends); !ok || req.Caption != "hello" {
+		t.Errorf("request = %+v", req)
+	}
+}
+
+func TestSendStoryPremiumRequired(t *testing.T) {
+	_, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		switch input.(type) {
+		case *tg.UploadSaveFilePartRequest:
+			return &tg.BoolTrue{}, nil
+		case *tg.StoriesSendStoryRequest:
+			return nil, tgerr.New(400, "PREMIUM_ACCOUNT_REQUIRED")
+		}
+		return nil, nil
+	})
+	_, err := sendStory(context.Background(), api, peers, "", storyPhoto(t), "", storyEveryone)
+	if err == nil || !strings.Contains(err.Error(), "needs Telegram Premium") {
+		t.Errorf("err = %v, want premium error", err)
+	}
+}
+
+func TestSendStoryRejectsDocuments(t *testing.T) {
+	_, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) { return nil, nil })
+	_, err := sendStory(context.Background(), api, peers, "", "notes.txt", "", storyEveryone)
+	if err == nil || !strings.Contains(err.Error(), "not a photo or video") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestGetStories(t *testing.T) {
+	_, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		if _, ok := input.(*tg.StoriesGetStoriesByIDRequest); ok {
+			return &tg.StoriesStories{Count: 2, Stories: []tg.StoryItemClass{
+				&tg.StoryItem{ID: 1, Date: 100, ExpireDate: 200, Public: true, Pinned: true,
+					Media: &tg.MessageMediaPhoto{Photo: &tg.PhotoEmpty{}}, Views: tg.StoryViews{ViewsCount: 5}},
+				&tg.StoryItemDeleted{ID: 2},
+			}}, nil
+		}
+		return nil, nil
+	})
+	stories, err := getStories(context.Background(), api, peers, "", []int{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Story{ID: 1, Date: 100, ExpireDate: 200, Media: mediaPhoto, Privacy: storyEveryone, Pinned: true, Views: 5}
+	if len(stories) == 1 {
+		stories[0].media = nil
+	}
+	if len(stories) != 1 || stories[0] != want {
+		t.Errorf("stories = %+v, want [%+v]", stories, want)
+	}
+}
+
+func TestGetPeerStories(t *testing.T) {
+	_, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		if _, ok := input.(*tg.StoriesGetPeerStoriesRequest); ok {
+			return &tg.StoriesPeerStories{Stories: tg.PeerStories{
+				Peer: &tg.PeerUser{UserID: 10},
+				Stories: []tg.StoryItemClass{
+					&tg.StoryItem{ID: 3, Date: 100, ExpireDate: 200, Contacts: true, Caption: "trip",
+						Media: &tg.MessageMediaDocument{Document: &tg.Document{ID: 1, MimeType: "video/mp4", Thumbs: []tg.PhotoSizeClass{}}}},
+					&tg.StoryItemSkipped{ID: 4, Date: 150, ExpireDate: 250},
+				},
+			}}, nil
+		}
+		return nil, nil
+	})
+	peers.storeUser(&tg.User{ID: 10, AccessHash: 1})
+
+	stories, err := getPeerStories(context.Background(), api, peers, "10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stories) != 1 {
+		t.Fatalf("stories = %+v, want one", stories)
+	}
+	if s := stories[0]; s.ID != 3 || s.Media != mediaVideo || s.Privacy != storyContacts || s.Caption != "trip" {
+		t.Errorf("story = %+v", s)
+	}
+}
+
+func TestGetPeerStoriesEmpty(t *testing.T) {
+	_, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		if _, ok := input.(*tg.StoriesGetPeerStoriesRequest); ok {
+			return &tg.StoriesPeerStories{Stories: tg.PeerStories{Peer: &tg.PeerUser{UserID: 10}}}, nil
+		}
+		return nil, nil
+	})
+	peers.storeUser(&tg.User{ID: 10, AccessHash: 1})
+
+	stories, err := getPeerStories(context.Background(), api, peers, "10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stories == nil || len(stories) != 0 {
+		t.Errorf("stories = %#v, want empty list", stories)
+	}
+}
+
+func TestStoryFileExt(t *testing.T) {
+	for mimeType, want := range map[string]string{"image/jpeg": ".jpg", "video/mp4": ".mp4", "": ""} {
+		if got := mediaFileExt(mimeType); got != want {
+			t.Errorf("mediaFileExt(%q) = %q, want %q", mimeType, got, want)
+		}
+	}
+}
@@ -0,0 +1,1060 @@
+package main
+
+import (
+	"github.com/gotd/td/tg"
+)
+
+// registerTools registers every bridge tool on s, using api for Telegram calls.
+// stream is nil unless [bridge] stream_updates is set; box keeps the messages
+// send_message could not deliver.
+func registerTools(s *MCPServer, api *tg.Client, peers *peerResolver, cfg *Config, paths sessionPaths, quality *qualityMonitor, stream *updateStream, box *outbox) {
+	// downloads is shared by the tools that save files to disk
+	downloads := newDownloadLog()
+	s.RegisterTool(Tool{
+		Name:        "send_message",
+		Description: "Send a text message to a user, group or channel, optionally silent or scheduled for later. Returns the sent message ID. When Telegram cannot be reached the message is queued in the outbox instead and queued and local_id are returned; see list_outbox.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"peer": {"type": "string", "description": "@username or numeric peer ID (users positive, groups negative, channels -100...)"},
+				"text": {"type": "string", "description": "Message text; [emoji:<document_id>] inserts a custom emoji (Telegram Premium only)"},
+				"resolve_mentions": {"type": "boolean", "description": "Link each @username in the text to its user; unknown usernames stay plain text"},
+				"silent": {"type": "boolean", "description": "Deliver without a notification sound"},
+				"schedule_date": {"type": "integer", "description": "Unix time to send the message at, up to a year ahead; the returned ID is then in the chat's scheduled messages"},
+				"clear_draft": {"type": "boolean", "description": "Clear the chat's draft once sent, as the apps do (default true)"}
+			},
+			"required": ["peer", "text"]
+		}`),
+		Handler: sendMessageTool(api, peers, box),
+	})
+	s.RegisterTool(Tool{
+		Name:        "read_messages",
+		Description: "Read the most recent text messages from a chat, newest first. Service messages and messages without text are skipped.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"peer": {"type": "string", "description": "@username or numeric peer ID"},
+				"limit": {"type": "integer", "description": "Number of messages to fetch (default 20, max 100)"},
+				"max_message_chars": {"type": "integer", "description": "Truncate each message to this many UTF-16 code units, reporting full_length (0 = no limit)"}
+			},
+			"required": ["peer"]
+		}`),
+		Handler: readMessagesTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "get_comments_count",
+		Description: "Get the number of discussion comments on a channel post without fetching them.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"channel": {"type": "string", "description": "@username or numeric ID of the channel"},
+				"post_id": {"type": "integer", "description": "ID of the channel post"}
+			},
+			"required": ["channel", "post_id"]
+		}`),
+		Handler: getCommentsCountTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "get_last_seen",
+		Description: "Get when a user was last seen: a Unix timestamp when exposed, otherwise a coarse bucket (recently, last_week, last_month, hidden).",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"user": {"type": "string", "description": "@username or numeric user ID"}
+			},
+			"required": ["user"]
+		}`),
+		Handler: getLastSeenTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "validate_recipient",
+		Description: "Resolve a peer and check whether this account can send messages to it, with the reason when it cannot.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"peer": {"type": "string", "description": "@username or numeric peer ID"}
+			},
+			"required": ["peer"]
+		}`),
+		Handler: validateRecipientTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "list_dialogs",
+		Description: "List the account's chats with their peer IDs, type, title, username and unread count. The returned peer IDs can be passed to the other tools.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"limit": {"type": "integer", "description": "Number of dialogs to return (default 50, max 500)"}
+			}
+		}`),
+		Handler: listDialogsTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "export_peer_cache",
+		Description: "Write the resolved peer cache (IDs and access hashes) to a JSON file for other bridge instances using the same account.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"path": {"type": "string", "description": "File to write"}
+			},
+			"required": ["path"]
+		}`),
+		Handler: exportPeerCacheTool(peers, cfg.SessionExportPretty),
+	})
+	s.RegisterTool(Tool{
+		Name:        "import_peer_cache",
+		Description: "Merge a peer cache exported by another bridge instance of the same account. Invalid and stale entries are skipped.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"path": {"type": "string", "description": "File written by export_peer_cache"}
+			},
+			"required": ["path"]
+		}`),
+		Handler: importPeerCacheTool(peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "get_chat_albums",
+		Description: "Read recent media messages from a chat grouped into albums (messages sent together share a grouped_id); single media messages form one-item albums.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"peer": {"type": "string", "description": "@username or numeric peer ID"},
+				"limit": {"type": "integer", "description": "Number of recent messages to scan (default 20, max 100)"}
+			},
+			"required": ["peer"]
+		}`),
+		Handler: getChatAlbumsTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "download_media",
+		Description: "Download the photo or document of a message to a local file. Returns the file size in bytes and its MIME type.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"peer": {"type": "string", "description": "@username or numeric peer ID"},
+				"message_id": {"type": "integer", "description": "ID of the message carrying the media"},
+				"dest": {"type": "string", "description": "Path of the file to write"}
+			},
+			"required": ["peer", "message_id", "dest"]
+		}`),
+		Handler: downloadMediaTool(api, peers, downloads),
+	})
+	s.RegisterTool(Tool{
+		Name:        "get_signatures",
+		Description: "Report whether posts in a channel are signed with the author's name.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"channel": {"type": "string", "description": "Channel @username or numeric peer ID"}
+			},
+			"required": ["channel"]
+		}`),
+		Handler: getSignaturesTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "toggle_signatures",
+		Description: "Turn author signatures on channel posts on or off. Requires admin rights to change channel info.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"channel": {"type": "string", "description": "Channel @username or numeric peer ID"},
+				"enabled": {"type": "boolean", "description": "Whether posts show the author signature"}
+			},
+			"required": ["channel", "enabled"]
+		}`),
+		Handler: toggleSignaturesTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "upload_file",
+		Description: "Upload a local file and send it as a document, keeping its file name, or send a file uploaded earlier with upload_once by its handle. Give exactly one of path and handle. Returns the sent message ID.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"peer": {"type": "string", "description": "@username or numeric peer ID"},
+				"path": {"type": "string", "description": "Path of the local file to send"},
+				"handle": {"type": "string", "description": "Handle returned by upload_once"},
+				"caption": {"type": "string", "description": "Optional caption"}
+			},
+			"required": ["peer"]
+		}`),
+		Handler: uploadFileTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "get_posts_since",
+		Description: "List a channel's posts newer than a Unix time, newest first, with their count. Useful for incremental channel monitoring.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"channel": {"type": "string", "description": "Channel @username or numeric peer ID"},
+				"since": {"type": "integer", "description": "Unix time; only posts dated after it are returned (the oldest 1000 at most)"},
+				"max_message_chars": {"type": "integer", "description": "Truncate each post to this many UTF-16 code units, reporting full_length (0 = no limit)"}
+			},
+			"required": ["channel", "since"]
+		}`),
+		Handler: getPostsSinceTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "resolve_peer",
+		Description: "Look up who a username belongs to: numeric ID, access hash, type, names and bot/verified/deleted flags. Unknown usernames return found=false.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"username": {"type": "string", "description": "Username, with or without the leading @"}
+			},
+			"required": ["username"]
+		}`),
+		Handler: resolvePeerTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "check_auth",
+		Description: "Check without side effects whether the session is authorized, and report the user ID, DC and whether the shared session export is current.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {}
+		}`),
+		Handler: checkAuthTool(api, cfg, paths),
+	})
+	s.RegisterTool(Tool{
+		Name:        "forward_with_caption",
+		Description: "Copy a photo or document message to another chat with a new caption, reusing the original file. Returns the sent message ID.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"from_peer": {"type": "string", "description": "Chat holding the original message"},
+				"to_peer": {"type": "string", "description": "Destination @username or numeric peer ID"},
+				"message_id": {"type": "integer", "description": "ID of the media message to copy"},
+				"caption": {"type": "string", "description": "Replacement caption"},
+				"parse_mode": {"type": "string", "enum": ["", "html"], "description": "Set to html to format the caption with Bot API HTML tags"}
+			},
+			"required": ["from_peer", "to_peer", "message_id"]
+		}`),
+		Handler: forwardWithCaptionTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "list_web_authorizations",
+		Description: "List the websites this account logged in to with the Telegram Login Widget.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {}
+		}`),
+		Handler: listWebAuthorizationsTool(api),
+	})
+	s.RegisterTool(Tool{
+		Name:        "reset_web_authorization",
+		Description: "Log out of one website login by its hash from list_web_authorizations.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"hash": {"type": "integer", "description": "Authorization hash"}
+			},
+			"required": ["hash"]
+		}`),
+		Handler: resetWebAuthorizationTool(api),
+	})
+	s.RegisterTool(Tool{
+		Name:        "reset_web_authorizations",
+		Description: "Log out of every website logged in with the Telegram Login Widget.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {}
+		}`),
+		Handler: resetWebAuthorizationsTool(api),
+	})
+	s.RegisterTool(Tool{
+		Name:        "get_suggested_profile_photo",
+		Description: "Find the latest profile photo a contact suggested for you in your private chat, plus the personal photo you set for them. suggested=false when there is none.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"user": {"type": "string", "description": "@username or numeric user ID"}
+			},
+			"required": ["user"]
+		}`),
+		Handler: getSuggestedProfilePhotoTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "accept_suggested_profile_photo",
+		Description: "Set the profile photo a contact last suggested as your own profile photo.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"user": {"type": "string", "description": "@username or numeric user ID of the contact who suggested it"}
+			},
+			"required": ["user"]
+		}`),
+		Handler: acceptSuggestedPhotoTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "set_contact_photo",
+		Description: "Set a personal photo for a contact from a local image file. Only you see it, in place of the photo the contact chose.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"user": {"type": "string", "description": "Contact @username or numeric user ID"},
+				"path": {"type": "string", "description": "Path of the image to upload"}
+			},
+			"required": ["user", "path"]
+		}`),
+		Handler: setContactPhotoTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "reset_contact_photo",
+		Description: "Remove the personal photo set for a contact with set_contact_photo.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"user": {"type": "string", "description": "Contact @username or numeric user ID"}
+			},
+			"required": ["user"]
+		}`),
+		Handler: resetContactPhotoTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "search_messages",
+		Description: "Search messages containing a keyword in one chat, or in all chats when peer is omitted. Returns matches newest first in the read_messages shape, with peer_id; no matches returns an empty array.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"peer": {"type": "string", "description": "@username or numeric peer ID to search in; omit to search all chats"},
+				"query": {"type": "string", "description": "Text to search for"},
+				"limit": {"type": "integer", "description": "Number of matches to return (default 20, max 100)"},
+				"offset": {"type": "integer", "description": "Number of newest matches to skip, for paging (max 1000)"},
+				"max_message_chars": {"type": "integer", "description": "Truncate each message to this many UTF-16 code units, reporting full_length (0 = no limit)"}
+			},
+			"required": ["query"]
+		}`),
+		Handler: searchMessagesTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "get_dialog_state",
+		Description: "Report a chat's sync state: unread, mention and reaction counts, read inbox/outbox max IDs, top message and pinned flag.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"peer": {"type": "string", "description": "@username or numeric peer ID"}
+			},
+			"required": ["peer"]
+		}`),
+		Handler: getDialogStateTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "get_connection_quality",
+		Description: "Ping Telegram and classify the connection as good, degraded or poor from the latency and failure rate of recent requests, with the numbers behind it.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {}
+		}`),
+		Handler: getConnectionQualityTool(quality),
+	})
+	s.RegisterTool(Tool{
+		Name:        "mark_read",
+		Description: "Mark a chat's messages as read up to a message ID, or the whole chat when max_id is 0. Returns the remaining unread count.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"peer": {"type": "string", "description": "@username or numeric peer ID"},
+				"max_id": {"type": "integer", "description": "Newest message ID to mark as read (0 = all)"}
+			},
+			"required": ["peer"]
+		}`),
+		Handler: markReadTool(api, peers, cfg.StealthMode),
+	})
+	s.RegisterTool(Tool{
+		Name:        "get_read_by",
+		Description: "List the members who have read an outgoing group message. Telegram keeps read receipts only for small groups and recent messages.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"peer": {"type": "string", "description": "@username or numeric peer ID of the group"},
+				"message_id": {"type": "integer", "description": "ID of the message"}
+			},
+			"required": ["peer", "message_id"]
+		}`),
+		Handler: getReadByTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "get_user_photos",
+		Description: "List all of a user's profile photos, newest first, with ID, date, largest dimensions and whether it is a video. Set dest_dir to also save each photo there as <photo_id>.jpg.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"user": {"type": "string", "description": "@username or numeric user ID"},
+				"limit": {"type": "integer", "description": "Number of photos to return (default 20, max 500)"},
+				"dest_dir": {"type": "string", "description": "Existing directory to download the photos into"}
+			},
+			"required": ["user"]
+		}`),
+		Handler: getUserPhotosTool(api, peers, downloads),
+	})
+	s.RegisterTool(Tool{
+		Name:        "get_suggested_reactions",
+		Description: "List reactions to choose from: the ones this account used recently, then the most popular, without duplicates. Custom emoji reactions are given as custom_emoji:<document_id>.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {}
+		}`),
+		Handler: getSuggestedReactionsTool(api),
+	})
+	s.RegisterTool(Tool{
+		Name:        "translate",
+		Description: "Translate existing messages (peer with message_ids) or raw text to a language given as an ISO 639-1 code. Returns one translation per message or text, in order.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"peer": {"type": "string", "description": "@username or numeric peer ID of the messages"},
+				"message_ids": {"type": "array", "items": {"type": "integer"}, "description": "IDs of the messages to translate"},
+				"text": {"type": "array", "items": {"type": "string"}, "description": "Raw text to translate instead of messages"},
+				"to_lang": {"type": "string", "description": "Target language, e.g. en or de"}
+			},
+			"required": ["to_lang"]
+		}`),
+		Handler: translateTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "restrict_sender",
+		Description: "Mute a member of a supergroup so they can read but not send, until a Unix time or forever, or lift the mute with unmute. Requires admin rights to ban users.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"peer": {"type": "string", "description": "@username or numeric peer ID of the supergroup"},
+				"user": {"type": "string", "description": "@username or numeric ID of the member"},
+				"until": {"type": "integer", "description": "Unix time the mute ends (0 = forever)"},
+				"unmute": {"type": "boolean", "description": "Lift the member's restrictions instead"}
+			},
+			"required": ["peer", "user"]
+		}`),
+		Handler: restrictSenderTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "get_recent_membership_events",
+		Description: "List a group's joins and leaves seen on the update stream since the bridge started, newest first. Requires stream_updates = true under [bridge]; up to 100 events are kept per chat.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"peer": {"type": "string", "description": "@username or numeric peer ID of the group"}
+			},
+			"required": ["peer"]
+		}`),
+		Handler: getRecentMembershipEventsTool(stream, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "get_recent_searches",
+		Description: "List the people, groups and channels Telegram suggests on the search screen, most used first within each category, with peer ID, name, username, category and rating.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {}
+		}`),
+		Handler: getRecentSearchesTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "clear_recent_searches",
+		Description: "Remove every peer from the search suggestions until it is used again. Returns how many were cleared.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {}
+		}`),
+		Handler: clearRecentSearchesTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "comment_on_post",
+		Description: "Post a comment under a channel post. The comment goes to the channel's linked discussion group as a reply to the post; channels without one take no comments.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"channel": {"type": "string", "description": "@username or numeric peer ID of the channel"},
+				"post_id": {"type": "integer", "description": "ID of the channel post"},
+				"text": {"type": "string", "description": "Comment text"}
+			},
+			"required": ["channel", "post_id", "text"]
+		}`),
+		Handler: commentOnPostTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "get_linked_chat",
+		Description: "Find the discussion group linked to a channel, or the channel a discussion group belongs to. Returns linked: false when there is none.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"peer": {"type": "string", "description": "@username or numeric peer ID of the channel or supergroup"}
+			},
+			"required": ["peer"]
+		}`),
+		Handler: getLinkedChatTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "list_attach_bots",
+		Description: "List the bots installed in the account's attachment and side menus, with the chat types each can be opened in. No installed bots returns an empty array.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {}
+		}`),
+		Handler: listAttachBotsTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "safety_check",
+		Description: "Check a peer's trust flags before interacting: verified, and whether Telegram flagged it as a scam or fake, with a warning when flagged.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"peer": {"type": "string", "description": "@username or numeric peer ID"}
+			},
+			"required": ["peer"]
+		}`),
+		Handler: safetyCheckTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "get_slow_mode",
+		Description: "Get a supergroup's slow mode delay: the minimum seconds between two messages of one member, 0 when off.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"peer": {"type": "string", "description": "@username or numeric peer ID of the supergroup"}
+			},
+			"required": ["peer"]
+		}`),
+		Handler: getSlowModeTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "set_slow_mode",
+		Description: "Set a supergroup's slow mode delay to 0 (off), 10, 30, 60, 300, 900 or 3600 seconds. Requires admin rights to restrict members.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"peer": {"type": "string", "description": "@username or numeric peer ID of the supergroup"},
+				"seconds": {"type": "integer", "enum": [0, 10, 30, 60, 300, 900, 3600], "description": "Delay between messages of one member"}
+			},
+			"required": ["peer", "seconds"]
+		}`),
+		Handler: setSlowModeTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "toggle_dialog_pin",
+		Description: "Pin or unpin a chat at the top of the chat list. Pinning fails when the account's pinned chat limit (higher with Premium) is reached.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"peer": {"type": "string", "description": "@username or numeric peer ID"},
+				"pinned": {"type": "boolean", "description": "true to pin, false to unpin"}
+			},
+			"required": ["peer", "pinned"]
+		}`),
+		Handler: toggleDialogPinTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "reorder_pinned_dialogs",
+		Description: "Set the pinned chats of the chat list, top first. Chats left out are unpinned. At most the account's pinned chat limit may be given.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"peers": {"type": "array", "items": {"type": "string"}, "description": "@usernames or numeric peer IDs in the new order"}
+			},
+			"required": ["peers"]
+		}`),
+		Handler: reorderPinnedDialogsTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "get_premium_gift_options",
+		Description: "List the Telegram Premium subscriptions that can be gifted to a user, or to yourself when user is omitted, with months, currency, price in the currency's smallest units and the payment bot link. Empty when gifting is unavailable.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"user": {"type": "string", "description": "@username or numeric user ID (default: yourself)"}
+			}
+		}`),
+		Handler: getPremiumGiftOptionsTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "send_story",
+		Description: "Post a local photo or video as a story on your profile, or on a channel you can post stories to. privacy is everyone (default), contacts or close_friends. Some accounts and channels need Premium or boosts to post.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"peer": {"type": "string", "description": "Channel @username or numeric ID (default: your profile)"},
+				"path": {"type": "string", "description": "Local path of the photo or video"},
+				"caption": {"type": "string", "description": "Story caption"},
+				"privacy": {"type": "string", "enum": ["everyone", "contacts", "close_friends"], "description": "Who can see the story (default everyone)"}
+			},
+			"required": ["path"]
+		}`),
+		Handler: sendStoryTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "delete_stories",
+		Description: "Delete stories from your profile or a channel by ID. Returns the IDs that were deleted.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"peer": {"type": "string", "description": "Channel @username or numeric ID (default: your profile)"},
+				"ids": {"type": "array", "items": {"type": "integer"}, "description": "Story IDs"}
+			},
+			"required": ["ids"]
+		}`),
+		Handler: deleteStoriesTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "get_stories",
+		Description: "Get stories of your profile or another peer by ID, with date, expiry, caption, media kind, privacy, pinned flag and view count. Deleted stories are left out.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"peer": {"type": "string", "description": "@username or numeric peer ID (default: your profile)"},
+				"ids": {"type": "array", "items": {"type": "integer"}, "description": "Story IDs"}
+			},
+			"required": ["ids"]
+		}`),
+		Handler: getStoriesTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "get_peer_stories",
+		Description: "List the active stories of a user or channel with date, expiry, caption, media kind, privacy and view count. Set dest_dir to also save each story's photo or video there as <story_id>.jpg or .mp4. Empty when the peer has no active stories.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"peer": {"type": "string", "description": "@username or numeric peer ID"},
+				"dest_dir": {"type": "string", "description": "Existing directory to download the story media into"}
+			},
+			"required": ["peer"]
+		}`),
+		Handler: getPeerStoriesTool(api, peers, downloads),
+	})
+	ringtones := &ringtoneCache{}
+	s.RegisterTool(Tool{
+		Name:        "get_saved_ringtones",
+		Description: "List the notification sounds saved to the account with ID, title, MIME type, size and duration in seconds.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {}
+		}`),
+		Handler: getSavedRingtonesTool(api, ringtones),
+	})
+	s.RegisterTool(Tool{
+		Name:        "save_ringtone",
+		Description: "Save the audio file of a message as a notification sound. Telegram may convert the file; the saved copy is returned.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"peer": {"type": "string", "description": "@username or numeric peer ID of the chat with the audio message"},
+				"message_id": {"type": "integer", "description": "ID of the message with the audio file"}
+			},
+			"required": ["peer", "message_id"]
+		}`),
+		Handler: saveRingtoneTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "unsave_ringtone",
+		Description: "Remove a saved notification sound by the ID get_saved_ringtones returns.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"ringtone_id": {"type": "integer", "description": "Ringtone ID"}
+			},
+			"required": ["ringtone_id"]
+		}`),
+		Handler: unsaveRingtoneTool(api, ringtones),
+	})
+	s.RegisterTool(Tool{
+		Name:        "get_chat_members",
+		Description: "List the members of a group or channel with user ID, name, username, role (creator, admin, member) and last-seen status. Supergroups and channels list the most recent members first; broadcast channels need admin rights.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"peer": {"type": "string", "description": "@username or numeric ID of the group or channel"},
+				"limit": {"type": "integer", "description": "Number of members to read (default 200, max 10000)"}
+			},
+			"required": ["peer"]
+		}`),
+		Handler: chatMembersTool(api, peers, false),
+	})
+	s.RegisterTool(Tool{
+		Name:        "get_online_members",
+		Description: "List the members of a group or channel that are online now, out of the first limit members, in the get_chat_members shape.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"peer": {"type": "string", "description": "@username or numeric ID of the group or channel"},
+				"limit": {"type": "integer", "description": "Number of members to check (default 200, max 10000)"}
+			},
+			"required": ["peer"]
+		}`),
+		Handler: chatMembersTool(api, peers, true),
+	})
+	s.RegisterTool(Tool{
+		Name:        "schedule_digest",
+		Description: "Schedule the same silent message in several chats at a Unix time. Returns the scheduled message ID per peer; peers that fail are listed with the reason while the rest are still scheduled.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"peers": {"type": "array", "items": {"type": "string"}, "description": "@usernames or numeric peer IDs, at most 100"},
+				"text": {"type": "string", "description": "Message text"},
+				"at": {"type": "integer", "description": "Unix time to send at, up to a year ahead"}
+			},
+			"required": ["peers", "text", "at"]
+		}`),
+		Handler: scheduleDigestTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "check_accounts",
+		Description: "Check up to 200 user peers in one request and report each as active, deleted, restricted, or unresolved when it could not be resolved to a user. Useful for pruning stale recipient lists.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"peers": {"type": "array", "items": {"type": "string"}, "description": "@usernames or numeric user IDs"}
+			},
+			"required": ["peers"]
+		}`),
+		Handler: checkAccountsTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "get_replied_message",
+		Description: "Get the message a given message replies to, in the read_messages shape with its peer_id, which differs from peer for replies across chats such as comments on a channel post. Fails clearly when the message is not a reply.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"peer": {"type": "string", "description": "@username or numeric peer ID of the chat with the reply"},
+				"message_id": {"type": "integer", "description": "ID of the reply"}
+			},
+			"required": ["peer", "message_id"]
+		}`),
+		Handler: getRepliedMessageTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "set_chat_reactions",
+		Description: "Set which reactions members of a group or channel may use: every standard reaction with all, only the listed ones, or none when the list is empty. Reactions are emoticons Telegram offers or custom_emoji:<id>. Requires the admin right to change the chat info.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"peer": {"type": "string", "description": "@username or numeric ID of the group or channel"},
+				"reactions": {"type": "array", "items": {"type": "string"}, "description": "Allowed reactions; empty disables reactions"},
+				"all": {"type": "boolean", "description": "Allow every standard reaction instead of a list"}
+			},
+			"required": ["peer"]
+		}`),
+		Handler: setChatReactionsTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "get_owned_bots",
+		Description: "List the bots this account owns and can edit, found among its dialogs. Bots you never opened a chat with are not listed. No owned bots returns an empty array.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {}
+		}`),
+		Handler: getOwnedBotsTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "get_bot_info",
+		Description: "Get a bot's about text, description and command list.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"bot": {"type": "string", "description": "Bot username or ID"}
+			},
+			"required": ["bot"]
+		}`),
+		Handler: getBotInfoTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "get_update_state",
+		Description: "Report the update sequence state of the update stream: pts, qts, date, seq, how many channels are tracked and when an update was last handled. Requires stream_updates = true under [bridge].",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {}
+		}`),
+		Handler: getUpdateStateTool(stream, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "mark_all_read",
+		Description: "Mark several chats as read up to their latest message, with a per-chat result. Give peers, a folder_id to read every unread chat of the main list (0) or the archive (1), or both. Refused while stealth_mode is set under [bridge].",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"peers": {"type": "array", "items": {"type": "string"}, "description": "Chats to mark as read, up to 100"},
+				"folder_id": {"type": "integer", "enum": [0, 1], "description": "Also mark the unread chats of this folder: 0 main list, 1 archive"}
+			}
+		}`),
+		Handler: markAllReadTool(api, peers, cfg.StealthMode),
+	})
+	s.RegisterTool(Tool{
+		Name:        "get_input_peer",
+		Description: "Resolve a peer to its type, ID and access hash for use by other clients of the shared session, with the TL input peer constructor to build. The access hash is only valid for the account in session_user_id.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"peer": {"type": "string", "description": "Username, marked peer ID or me"}
+			},
+			"required": ["peer"]
+		}`),
+		Handler: getInputPeerTool(peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "confirm_password_email",
+		Description: "Confirm the recovery email of two-step verification with the code Telegram mailed to it. Fails when no recovery email is waiting for confirmation.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"code": {"type": "string", "description": "Code from the confirmation email"}
+			},
+			"required": ["code"]
+		}`),
+		Handler: confirmPasswordEmailTool(api),
+	})
+	s.RegisterTool(Tool{
+		Name:        "resend_password_email",
+		Description: "Mail a new confirmation code to the recovery email waiting for confirmation.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {}
+		}`),
+		Handler: resendPasswordEmailTool(api),
+	})
+	s.RegisterTool(Tool{
+		Name:        "list_contacts",
+		Description: "List the account's contacts with user ID, names, username, phone number when visible and whether the contact is mutual.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {}
+		}`),
+		Handler: listContactsTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "export_contacts_vcard",
+		Description: "Write the whole contact list to a vCard 3.0 file with names, phone numbers and usernames (as X-TELEGRAM). Contacts without a visible phone number are exported without one.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"path": {"type": "string", "description": "File to write, e.g. contacts.vcf"}
+			},
+			"required": ["path"]
+		}`),
+		Handler: exportContactsVCardTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "import_contacts_vcard",
+		Description: "Import the contacts of a vCard file, in chunks of 100, and report how many matched a Telegram account. Cards without a phone number or END line are skipped and counted. Contacts Telegram refuses for now are counted under retry.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"path": {"type": "string", "description": "vCard file to read"}
+			},
+			"required": ["path"]
+		}`),
+		Handler: importContactsVCardTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "upload_once",
+		Description: "Upload a local file as a document without sending it and return a handle for upload_file, so the same file can go to many chats without uploading it again. Handles stop working when Telegram expires the file reference; upload again then.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"path": {"type": "string", "description": "Path of the local file to upload"}
+			},
+			"required": ["path"]
+		}`),
+		Handler: uploadOnceTool(api),
+	})
+	s.RegisterTool(Tool{
+		Name:        "list_folders",
+		Description: "List the account's chat folders with their rules: chat types shown, chats hidden, and chats included, excluded or pinned individually as marked peer IDs. Folders shared by invite link are flagged as chatlist.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {}
+		}`),
+		Handler: listFoldersTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "get_folder_rules",
+		Description: "Get the rules of one chat folder by ID.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"folder_id": {"type": "integer", "description": "Folder ID from list_folders, 2 to 255"}
+			},
+			"required": ["folder_id"]
+		}`),
+		Handler: getFolderRulesTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "set_folder_rules",
+		Description: "Change the rules of a chat folder, or create it when the ID is unused (title required then). Only the given fields change; a peer list replaces the current one, an empty list clears it. Returns the resulting rules. Folders shared by invite link can't be changed.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"folder_id": {"type": "integer", "description": "Folder ID, 2 to 255"},
+				"title": {"type": "string"},
+				"emoticon": {"type": "string", "description": "Emoji shown as the folder icon"},
+				"contacts": {"type": "boolean", "description": "Include private chats with contacts"},
+				"non_contacts": {"type": "boolean", "description": "Include private chats with non-contacts"},
+				"groups": {"type": "boolean", "description": "Include groups"},
+				"broadcasts": {"type": "boolean", "description": "Include channels"},
+				"bots": {"type": "boolean", "description": "Include bots"},
+				"exclude_muted": {"type": "boolean"},
+				"exclude_read": {"type": "boolean"},
+				"exclude_archived": {"type": "boolean"},
+				"include_peers": {"type": "array", "items": {"type": "string"}, "description": "Chats always shown"},
+				"exclude_peers": {"type": "array", "items": {"type": "string"}, "description": "Chats never shown"},
+				"pinned_peers": {"type": "array", "items": {"type": "string"}, "description": "Chats pinned at the top of the folder"}
+			},
+			"required": ["folder_id"]
+		}`),
+		Handler: setFolderRulesTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "estimate_flood_risk",
+		Description: "Estimate how likely a bulk operation is to hit Telegram flood waits, from its size and the flood waits seen in the last hour, with a suggested pause between requests, any wait still in force and the expected duration. Operations: send, forward, join, invite, resolve, import_contacts.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"operation": {"type": "string", "enum": ["send", "forward", "join", "invite", "resolve", "import_contacts"]},
+				"count": {"type": "integer", "description": "Number of requests the operation makes, e.g. messages to send"}
+			},
+			"required": ["operation", "count"]
+		}`),
+		Handler: estimateFloodRiskTool(quality),
+	})
+	s.RegisterTool(Tool{
+		Name:        "list_outbox",
+		Description: "List the messages send_message queued because Telegram was unreachable, oldest first, with their status (queued, or failed after a retry), attempts and last error. The outbox is kept in the session directory across restarts.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {}
+		}`),
+		Handler: listOutboxTool(box),
+	})
+	s.RegisterTool(Tool{
+		Name:        "retry_outbox",
+		Description: "Send a queued outbox message again and remove it once delivered. A failed retry keeps it, marked failed with the error. Retries reuse the original random ID, so a message an earlier attempt delivered is not sent twice.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"local_id": {"type": "string", "description": "Outbox entry ID from list_outbox"}
+			},
+			"required": ["local_id"]
+		}`),
+		Handler: retryOutboxTool(api, peers, box),
+	})
+	s.RegisterTool(Tool{
+		Name:        "drop_outbox",
+		Description: "Discard a queued outbox message without sending it.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"local_id": {"type": "string", "description": "Outbox entry ID from list_outbox"}
+			},
+			"required": ["local_id"]
+		}`),
+		Handler: dropOutboxTool(box),
+	})
+	s.RegisterTool(Tool{
+		Name:        "get_peer_usernames",
+		Description: "List the active usernames of a user or channel, since a peer can have several: the editable one first, then collectible ones. Each is also a public link as https://t.me/<username>. Basic groups have none.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"peer": {"type": "string", "description": "@username or numeric peer ID"}
+			},
+			"required": ["peer"]
+		}`),
+		Handler: getPeerUsernamesTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "toggle_username",
+		Description: "Show or hide one of your collectible usernames on your profile. The editable username cannot be hidden. Returns your active usernames.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"username": {"type": "string", "description": "One of your usernames, with or without @"},
+				"active": {"type": "boolean", "description": "true to show it, false to hide it"}
+			},
+			"required": ["username", "active"]
+		}`),
+		Handler: toggleUsernameTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "reorder_usernames",
+		Description: "Set the order your active usernames are shown in. Every active username must be listed exactly once. Returns your active usernames.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"order": {"type": "array", "items": {"type": "string"}, "description": "All your active usernames in the new order"}
+			},
+			"required": ["order"]
+		}`),
+		Handler: reorderUsernamesTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "get_service_notifications",
+		Description: "List the official Telegram service notifications, such as login alerts, seen on the update stream since the bridge started, newest first. Requires stream_updates = true under [bridge]; up to 50 are kept.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {}
+		}`),
+		Handler: getServiceNotificationsTool(stream),
+	})
+	s.RegisterTool(Tool{
+		Name:        "get_bio",
+		Description: "Get your account's bio with its length, the limit (70 characters, 140 with Telegram Premium) and the links and @mentions the apps highlight in it.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {}
+		}`),
+		Handler: getBioTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "set_bio",
+		Description: "Set your account's bio. Bios are plain text: links and @usernames are highlighted by the apps without markup, and custom emoji are not allowed. Limited to 70 characters, or 140 with Telegram Premium, counted in UTF-16 code units.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"about": {"type": "string", "description": "New bio; empty to clear it"}
+			},
+			"required": ["about"]
+		}`),
+		Handler: setBioTool(api, peers),
+	})
+	s.RegisterTool(Tool{
+		Name:        "get_recent_downloads",
+		Description: "List the files download_media, download_many, get_peer_stories and get_user_photos saved since the bridge started, newest first, with file name, path, size, MIME type and the peer they came from. Up to 200 are kept.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {}
+		}`),
+		Handler: getRecentDownloadsTool(downloads),
+	})
+	s.RegisterTool(Tool{
+		Name:        "speed_test",
+		Description: "Measure the connection to your Telegram DC: the round-trip latency, and the upload and download throughput of a 1 MiB file stored in Saved Messages without posting it. The local temporary file is removed afterwards.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {}
+		}`),
+		Handler: speedTestTool(api),
+	})
+	s.RegisterTool(Tool{
+		Name:        "download_many",
+		Description: "Download the photo or document of several messages of one chat into an existing directory, up to 4 at a time, as <message_id> with an extension for the file type. Returns the saved paths by message ID and, separately, why each other message was not saved, such as having no media.",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"peer": {"type": "string", "description": "@username or numeric peer ID"},
+				"message_ids": {"type": "array", "items": {"type": "integer"}, "description": "IDs of the messages to download, up to 100"},
+				"dest_dir": {"type": "string", "description": "Existing directory to download the files into"}
+			},
+			"required": ["peer", "message_ids", "dest_dir"]
+		}`),
+		Handler: downloadManyTool(api, peers, downloads),
+	})
+	s.RegisterTool(Tool{
+		Name:        "send_and_await_reply",
+		Description: "Send a text message and wait for the first reply: in a private chat the next incoming message, in a group or channel the next message replying to the one sent. Returns the sent message ID and the reply in the read_messages shape, or timed_out. Requires stream_updates = true under [bridge].",
+		InputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"peer": {"type": "string", "description": "@username or numeric peer ID"},
+				"text": {"type": "string", "description": "Message text"},
+				"timeout": {"type": "integer", "description": "Seconds to wait for the reply (default 60, max 300)"}
+			},
+			"required": ["peer", "text"]
+		}`),
+		Handler: sendAndAwaitReplyTool(api, peers, stream),
+	})
+}
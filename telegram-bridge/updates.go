@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gotd/td/telegram/updates"
+	"github.com/gotd/td/tg"
+	"golang.org/x/sync/errgroup"
+)
+
+// newMessageMethod is the MCP notification sent for each incoming message,
+// with the message in the read_messages shape including its peer_id
+const newMessageMethod = "telegram/new_message"
+
+// messageDedupSize is how many recently delivered messages are remembered to
+// drop repeats
+const messageDedupSize = 1000
+
+// updateStream is the update handler installed on the client when [bridge]
+// stream_updates is set, together with the state tools read from it
+type updateStream struct {
+	// gaps recovers updates missed while disconnected and must be started
+	// with serveWithUpdates once the user is known
+	gaps    *updates.Manager
+	members *membershipLog
+	seen    *recentMessages
+	filter  messageFilter
+	state   *updateStateStore
+	notices *serviceNotificationLog
+	replies *replyWaiters
+}
+
+// newUpdateStream wraps dispatcher in the update stream. New messages
+// passing filter are forwarded to server as notifications, and membership
+// changes of every chat and Telegram service notifications are logged.
+func newUpdateStream(dispatcher tg.UpdateDispatcher, server *MCPServer, filter messageFilter) *updateStream {
+	stream := &updateStream{
+		members: newMembershipLog(),
+		seen:    newRecentMessages(messageDedupSize),
+		filter:  filter,
+		state:   newUpdateStateStore(),
+		notices: newServiceNotificationLog(),
+		replies: newReplyWaiters(),
+	}
+	dispatcher.OnNewMessage(func(ctx context.Context, _ tg.Entities, u *tg.UpdateNewMessage) error {
+		stream.handleMessage(server, u.Message)
+		return nil
+	})
+	dispatcher.OnNewChannelMessage(func(ctx context.Context, _ tg.Entities, u *tg.UpdateNewChannelMessage) error {
+		stream.handleMessage(server, u.Message)
+		return nil
+	})
+	dispatcher.OnChatParticipants(func(ctx context.Context, _ tg.Entities, u *tg.UpdateChatParticipants) error {
+		stream.members.recordParticipants(u.Participants, time.Now())
+		return nil
+	})
+	dispatcher.OnServiceNotification(func(ctx context.Context, _ tg.Entities, u *tg.UpdateServiceNotification) error {
+		stream.notices.record(u, time.Now())
+		return nil
+	})
+	stream.gaps = updates.New(updates.Config{
+		Handler: stream.state.handler(dispatcher),
+		Storage: stream.state,
+	})
+	return stream
+}
+
+// handleMessage processes a new message once. Gap recovery can deliver a
+// message again after it arrived live, so repeats are dropped.
+func (s *updateStream) handleMessage(server *MCPServer, m tg.MessageClass) {
+	if key, ok := newMessageKey(m); ok && !s.seen.add(key) {
+		slog.Debug("Dropping repeated message update", "peer_id", key.peerID, "message_id", key.id)
+		return
+	}
+	s.members.recordMessage(m)
+	msg, ok := m.(*tg.Message)
+	if !ok {
+		return
+	}
+	s.replies.deliver(msg)
+	if s.filter.allows(msg) {
+		notifyNewMessage(server, msg)
+	}
+}
+
+// messageKey identifies a message; IDs are only unique within a channel, or
+// within the account for private chats and basic groups
+type messageKey struct {
+	peerID int64
+	id     int
+}
+
+func newMessageKey(m tg.MessageClass) (messageKey, bool) {
+	var peer tg.PeerClass
+	switch m := m.(type) {
+	case *tg.Message:
+		peer = m.PeerID
+	case *tg.MessageService:
+		peer = m.PeerID
+	default:
+		return messageKey{}, false
+	}
+	peerID, err := markedPeerID(peer)
+	if err != nil {
+		return messageKey{}, false
+	}
+	return messageKey{peerID: peerID, id: m.GetID()}, true
+}
+
+// recentMessages is a bounded set of the most recently added message keys
+type recentMessages struct {
+	mu    sync.Mutex
+	keys  map[messageKey]struct{}
+	order []messageKey // ring buffer of keys, overwritten oldest first
+	next  int
+}
+
+func newRecentMessages(size int) *recentMessages {
+	return &recentMessages{
+		keys:  make(map[messageKey]struct{}, size),
+		order: make([]messageKey, 0, size),
+	}
+}
+
+// add records key and reports whether it was new
+func (r *recentMessages) add(key messageKey) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.keys[key]; ok {
+		return false
+	}
+	if len(r.order) < cap(r.order) {
+		r.order = append(r.order, key)
+	} else {
+		delete(r.keys, r.order[r.next])
+		r.order[r.next] = key
+		r.next = (r.next + 1) % len(r.order)
+	}
+	r.keys[key] = struct{}{}
+	return true
+}
+
+// notifyNewMessage sends msg to the MCP client
+func notifyNewMessage(server *MCPServer, msg *tg.Message) {
+	info, err := newMessageInfo(msg)
+	if err == nil {
+		info.PeerID, err = markedPeerID(msg.PeerID)
+	}
+	if err != nil {
+		slog.Warn("Skipping new message", "message_id", msg.ID, "error", err)
+		return
+	}
+	if err := server.Notify(newMessageMethod, info); err != nil {
+		slog.Error("Failed to send notification", "method", newMessageMethod, "error", err)
+	}
+}
+
+// serveWithUpdates serves MCP on r while gaps processes updates for self.
+// Both stop when either fails; reaching EOF on r stops the update stream
+// and is not an error.
+func serveWithUpdates(ctx context.Context, server *MCPServer, r io.Reader, gaps *updates.Manager, api *tg.Client, self *tg.User) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		defer cancel()
+		return server.Serve(ctx, r)
+	})
+	g.Go(func() error {
+		err := gaps.Run(ctx, api, self.ID, updates.AuthOptions{
+			IsBot: self.Bot,
+			OnStart: func(ctx context.Context) {
+				slog.Info("Streaming incoming messages as MCP notifications")
+			},
+		})
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+		return err
+	})
+	return g.Wait()
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/gotd/td/tg"
+)
+
+func TestNotifyNewMessage(t *testing.T) {
+	var out bytes.Buffer
+	server := NewMCPServer(&out)
+
+	msg := &tg.Message{
+		ID:      2,
+		PeerID:  &tg.PeerChannel{ChannelID: 30},
+		Date:    100,
+		Message: "hello",
+	}
+	msg.SetFromID(&tg.PeerUser{UserID: 10})
+	notifyNewMessage(server, msg)
+
+	var n struct {
+		Method string                     `json:"method"`
+		Params map[string]json.RawMessage `json:"params"`
+	}
+	lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+	if len(lines) != 1 {
+		t.Fatalf("got %d notifications, want 1", len(lines))
+	}
+	if err := json.Unmarshal(lines[0], &n); err != nil {
+		t.Fatal(err)
+	}
+	if n.Method != newMessageMethod {
+		t.Errorf("method = %q", n.Method)
+	}
+	want := map[string]string{"id": "2", "peer_id": "-1000000000030", "sender_id": "10", "text": `"hello"`}
+	for key, value := range want {
+		if got := string(n.Params[key]); got != value {
+			t.Errorf("params[%s] = %s, want %s", key, got, value)
+		}
+	}
+}
+
+func TestUpdateStreamDropsRepeats(t *testing.T) {
+	var out bytes.Buffer
+	server := NewMCPServer(&out)
+	dispatcher := tg.NewUpdateDispatcher()
+	newUpdateStream(dispatcher, server, messageFilter{})
+
+	message := func(peer tg.PeerClass, id int) *tg.Message {
+		return &tg.Message{ID: id, PeerID: peer, Date: 100, Message: "hello"}
+	}
+	batch := &tg.Updates{Updates: []tg.UpdateClass{
+		&tg.UpdateNewMessage{Message: message(&tg.PeerUser{UserID: 10}, 1)},
+		&tg.UpdateNewChannelMessage{Message: message(&tg.PeerChannel{ChannelID: 30}, 1)},
+	}}
+	// The same batch again, as gap recovery may redeliver it
+	for i := 0; i < 2; i++ {
+		if err := dispatcher.Handle(context.Background(), batch); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("got %d notifications, want 2 (one per message):\n%s", len(lines), out.String())
+	}
+}
+
+func TestRecentMessagesBounded(t *testing.T) {
+	seen := newRecentMessages(2)
+	for _, id := range []int{1, 2, 3} {
+		if !seen.add(messageKey{peerID: 10, id: id}) {
+			t.Fatalf("message %d reported as repeated", id)
+		}
+	}
+	if seen.add(messageKey{peerID: 10, id: 3}) {
+		t.Error("repeated message 3 reported as new")
+	}
+	// Message 1 was evicted to stay within the bound
+	if !seen.add(messageKey{peerID: 10, id: 1}) {
+		t.Error("evicted message 1 reported as repeated")
+	}
+}
+
+func TestUpdateStreamFilters(t *testing.T) {
+	var out bytes.Buffer
+	server := NewMCPServer(&out)
+	dispatcher := tg.NewUpdateDispatcher()
+	newUpdateStream(dispatcher, server, messageFilter{ChatTypes: map[string]bool{chatTypePrivate: true}})
+
+	batch := &tg.Updates{Updates: []tg.UpdateClass{
+		&tg.UpdateNewMessage{Message: &tg.Message{ID: 1, PeerID: &tg.PeerUser{UserID: 10}, Message: "hi"}},
+		&tg.UpdateNewMessage{Message: &tg.MessageService{ID: 2, PeerID: &tg.PeerUser{UserID: 10}}},
+		&tg.UpdateNewMessage{Message: &tg.Message{ID: 3, PeerID: &tg.PeerChat{ChatID: 20}, Message: "all"}},
+	}}
+	if err := dispatcher.Handle(context.Background(), batch); err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+	if len(lines) != 1 || !bytes.Contains(lines[0], []byte(`"id":1`)) {
+		t.Fatalf("want only the private message, got:\n%s", out.String())
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/telegram/updates"
+	"github.com/gotd/td/tg"
+)
+
+// errNoUpdateState is returned by the state setters before the update
+// manager stored an initial state, as updates.StateStorage requires
+var errNoUpdateState = errors.New("update state not initialized")
+
+// updateStateStore holds the update sequence state the gap manager works
+// from, and the time it last handled an update. It lives in memory, so the
+// manager fetches a fresh state from Telegram after a restart.
+type updateStateStore struct {
+	mu         sync.Mutex
+	states     map[int64]updates.State
+	channels   map[int64]map[int64]int
+	lastUpdate time.Time
+}
+
+var _ updates.StateStorage = (*updateStateStore)(nil)
+
+func newUpdateStateStore() *updateStateStore {
+	return &updateStateStore{
+		states:   make(map[int64]updates.State),
+		channels: make(map[int64]map[int64]int),
+	}
+}
+
+// handler wraps next to record when updates were last handled
+func (s *updateStateStore) handler(next telegram.UpdateHandler) telegram.UpdateHandler {
+	return telegram.UpdateHandlerFunc(func(ctx context.Context, u tg.UpdatesClass) error {
+		s.mu.Lock()
+		s.lastUpdate = time.Now()
+		s.mu.Unlock()
+		return next.Handle(ctx, u)
+	})
+}
+
+func (s *updateStateStore) GetState(ctx context.Context, userID int64) (updates.State, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.states[userID]
+	return state, ok, nil
+}
+
+func (s *updateStateStore) SetState(ctx context.Context, userID int64, state updates.State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states[userID] = state
+	s.channels[userID] = make(map[int64]int)
+	return nil
+}
+
+// update applies f to the stored state of userID
+func (s *updateStateStore) update(userID int64, f func(*updates.State)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.states[userID]
+	if !ok {
+		return errNoUpdateState
+	}
+	f(&state)
+	s.states[userID] = state
+	return nil
+}
+
+func (s *updateStateStore) SetPts(ctx context.Context, userID int64, pts int) error {
+	return s.update(userID, func(st *updates.State) { st.Pts = pts })
+}
+
+func (s *updateStateStore) SetQts(ctx context.Context, userID int64, qts int) error {
+	return s.update(userID, func(st *updates.State) { st.Qts = qts })
+}
+
+func (s *updateStateStore) SetDate(ctx context.Context, userID int64, date int) error {
+	return s.update(userID, func(st *updates.State) { st.Date = date })
+}
+
+func (s *updateStateStore) SetSeq(ctx context.Context, userID int64, seq int) error {
+	return s.update(userID, func(st *updates.State) { st.Seq = seq })
+}
+
+func (s *updateStateStore) SetDateSeq(ctx context.Context, userID int64, date, seq int) error {
+	return s.update(userID, func(st *updates.State) { st.Date, st.Seq = date, seq })
+}
+
+func (s *updateStateStore) GetChannelPts(ctx context.Context, userID, channelID int64) (int, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pts, ok := s.channels[userID][channelID]
+	return pts, ok, nil
+}
+
+func (s *updateStateStore) SetChannelPts(ctx context.Context, userID, channelID int64, pts int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	channels, ok := s.channels[userID]
+	if !ok {
+		return errNoUpdateState
+	}
+	channels[channelID] = pts
+	return nil
+}
+
+func (s *updateStateStore) ForEachChannels(ctx context.Context, userID int64, f func(ctx context.Context, channelID int64, pts int) error) error {
+	s.mu.Lock()
+	channels := make(map[int64]int, len(s.channels[userID]))
+	for id, pts := range s.channels[userID] {
+		channels[id] = pts
+	}
+	s.mu.Unlock()
+	for id, pts := range channels {
+		if err := f(ctx, id, pts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateState is the update sequence the bridge has reached, for debugging
+// the update stream
+type UpdateState struct {
+	Pts  int `json:"pts"`
+	Qts  int `json:"qts"`
+	Date int `json:"date"`
+	Seq  int `json:"seq"`
+	// Channels is how many channels have their own pts tracked
+	Channels int `json:"channels"`
+	// LastUpdate is the Unix time an update was last handled, 0 if none was
+	LastUpdate int64 `json:"last_update"`
+}
+
+func getUpdateStateTool(stream *updateStream, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args struct{}
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if stream == nil {
+			return nil, fmt.Errorf("update state needs the update stream; set stream_updates = true under [bridge]")
+		}
+		return getUpdateState(ctx, stream.state, peers.selfID)
+	}
+}
+
+// getUpdateState returns the stored update state of userID. It fails until
+// the update stream fetched its initial state after startup.
+func getUpdateState(ctx context.Context, store *updateStateStore, userID int64) (UpdateState, error) {
+	state, ok, err := store.GetState(ctx, userID)
+	if err != nil {
+		return UpdateState{}, err
+	}
+	if !ok {
+		return UpdateState{}, fmt.Errorf("update stream has not fetched its state yet")
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	result := UpdateState{
+		Pts:      state.Pts,
+		Qts:      state.Qts,
+		Date:     state.Date,
+		Seq:      state.Seq,
+		Channels: len(store.channels[userID]),
+	}
+	if !store.lastUpdate.IsZero() {
+		result.LastUpdate = store.lastUpdate.Unix()
+	}
+	return result, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/telegram/updates"
+	"github.com/gotd/td/tg"
+)
+
+func TestUpdateStateStoreNeedsInitialState(t *testing.T) {
+	ctx := context.Background()
+	store := newUpdateStateStore()
+	if err := store.SetPts(ctx, 1, 10); !errors.Is(err, errNoUpdateState) {
+		t.Errorf("SetPts before SetState: err = %v, want errNoUpdateState", err)
+	}
+	if err := store.SetChannelPts(ctx, 1, 30, 5); !errors.Is(err, errNoUpdateState) {
+		t.Errorf("SetChannelPts before SetState: err = %v, want errNoUpdateState", err)
+	}
+	if _, err := getUpdateState(ctx, store, 1); err == nil {
+		t.Error("getUpdateState before the stream started: want error")
+	}
+}
+
+func TestGetUpdateState(t *testing.T) {
+	ctx := context.Background()
+	store := newUpdateStateStore()
+	if err := store.SetState(ctx, 1, updates.State{Pts: 10, Qts: 2, Date: 100, Seq: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetPts(ctx, 1, 12); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetDateSeq(ctx, 1, 150, 4); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetChannelPts(ctx, 1, 30, 7); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := getUpdateState(ctx, store, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := UpdateState{Pts: 12, Qts: 2, Date: 150, Seq: 4, Channels: 1}
+	if state != want {
+		t.Errorf("state = %+v, want %+v", state, want)
+	}
+
+	// Handling an update sets the last update time
+	before := time.Now().Unix()
+	h := store.handler(telegram.UpdateHandlerFunc(func(context.Context, tg.UpdatesClass) error { return nil }))
+	if err := h.Handle(ctx, &tg.UpdatesTooLong{}); err != nil {
+		t.Fatal(err)
+	}
+	state, err = getUpdateState(ctx, store, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.LastUpdate < before {
+		t.Errorf("last_update = %d, want at least %d", state.LastUpdate, before)
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+type peerUsernamesResult struct {
+	PeerID int64 `json:"peer_id"`
+	// Usernames are the active usernames, editable one first, each also
+	// reachable as https://t.me/<username>
+	Usernames []string `json:"usernames"`
+}
+
+func getPeerUsernamesTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args peerArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.Peer) == "" {
+			return nil, invalidParams("peer is required")
+		}
+		p, err := peers.resolve(ctx, args.Peer)
+		if err != nil {
+			return nil, err
+		}
+		usernames, err := getPeerUsernames(ctx, api, peers, args.Peer)
+		if err != nil {
+			return nil, err
+		}
+		return peerUsernamesResult{PeerID: p.MarkedID(), Usernames: usernames}, nil
+	}
+}
+
+// getPeerUsernames fetches the current entity of peer and returns its active
+// usernames, both the editable one and collectible ones bought on Fragment.
+// Basic groups have no usernames.
+func getPeerUsernames(ctx context.Context, api *tg.Client, peers *peerResolver, peer string) ([]string, error) {
+	p, err := peers.resolve(ctx, peer)
+	if err != nil {
+		return nil, err
+	}
+
+	switch p.Type {
+	case peerUser:
+		var users []tg.UserClass
+		err = withFloodRetry(ctx, func() (err error) {
+			users, err = api.UsersGetUsers(ctx, []tg.InputUserClass{&tg.InputUser{UserID: p.ID, AccessHash: p.AccessHash}})
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user: %w", err)
+		}
+		peers.remember(users, nil)
+		for _, u := range users {
+			if u, ok := u.(*tg.User); ok && u.ID == p.ID {
+				return activeUsernames(u.Username, u.Usernames), nil
+			}
+		}
+	case peerChannel:
+		var chats tg.MessagesChatsClass
+		err = withFloodRetry(ctx, func() (err error) {
+			chats, err = api.ChannelsGetChannels(ctx, []tg.InputChannelClass{&tg.InputChannel{ChannelID: p.ID, AccessHash: p.AccessHash}})
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get channel: %w", err)
+		}
+		peers.remember(nil, chats.GetChats())
+		for _, c := range chats.GetChats() {
+			if c, ok := c.(*tg.Channel); ok && c.ID == p.ID {
+				return activeUsernames(c.Username, c.Usernames), nil
+			}
+		}
+	}
+	return []string{}, nil
+}
+
+// activeUsernames returns the active entries of usernames, editable first.
+// Peers with a single username may only set username.
+func activeUsernames(username string, usernames []tg.Username) []string {
+	active := []string{}
+	if len(usernames) == 0 {
+		if username != "" {
+			active = append(active, username)
+		}
+		return active
+	}
+	for _, u := range usernames {
+		if u.Active && u.Editable {
+			active = append(active, u.Username)
+		}
+	}
+	for _, u := range usernames {
+		if u.Active && !u.Editable {
+			active = append(active, u.Username)
+		}
+	}
+	return active
+}
+
+type toggleUsernameArgs struct {
+	Username string `json:"username"`
+	Active   bool   `json:"active"`
+}
+
+type reorderUsernamesArgs struct {
+	Order []string `json:"order"`
+}
+
+func toggleUsernameTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args toggleUsernameArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		username := strings.TrimPrefix(strings.TrimSpace(args.Username), "@")
+		if username == "" {
+			return nil, invalidParams("username is required")
+		}
+		if err := toggleUsername(ctx, api, peers, username, args.Active); err != nil {
+			return nil, err
+		}
+		return ownUsernamesResult(ctx, api, peers)
+	}
+}
+
+func reorderUsernamesTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args reorderUsernamesArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if len(args.Order) == 0 {
+			return nil, invalidParams("order is required")
+		}
+		order := make([]string, len(args.Order))
+		for i, u := range args.Order {
+			order[i] = strings.TrimPrefix(strings.TrimSpace(u), "@")
+		}
+		if err := reorderUsernames(ctx, api, peers, order); err != nil {
+			return nil, err
+		}
+		return ownUsernamesResult(ctx, api, peers)
+	}
+}
+
+// ownUsernamesResult reports the account's active usernames after a change
+func ownUsernamesResult(ctx context.Context, api *tg.Client, peers *peerResolver) (peerUsernamesResult, error) {
+	owned, err := ownUsernames(ctx, api, peers)
+	if err != nil {
+		return peerUsernamesResult{}, err
+	}
+	return peerUsernamesResult{PeerID: peers.selfID, Usernames: activeUsernames("", owned)}, nil
+}
+
+// ownUsernames fetches the usernames of the logged-in account
+func ownUsernames(ctx context.Context, api *tg.Client, peers *peerResolver) ([]tg.Username, error) {
+	var users []tg.UserClass
+	err := withFloodRetry(ctx, func() (err error) {
+		users, err = api.UsersGetUsers(ctx, []tg.InputUserClass{&tg.InputUserSelf{}})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get own account: %w", err)
+	}
+	peers.remember(users, nil)
+	for _, u := range users {
+		if u, ok := u.(*tg.User); ok && u.Self {
+			return u.Usernames, nil
+		}
+	}
+	return nil, fmt.Errorf("own account missing from response")
+}
+
+// toggleUsername shows or hides one of the account's collectible usernames
+// on its profile. The editable username cannot be hidden this way.
+func toggleUsername(ctx context.Context, api *tg.Client, peers *peerResolver, username string, active bool) error {
+	owned, err := ownUsernames(ctx, api, peers)
+	if err != nil {
+		return err
+	}
+	var found *tg.Username
+	for i := range owned {
+		if strings.EqualFold(owned[i].Username, username) {
+			found = &owned[i]
+		}
+	}
+	switch {
+	case found == nil:
+		return invalidParams("@%s is not one of your usernames", username)
+	case found.Editable:
+		return invalidParams("@%s is your editable username; change it in the profile instead", username)
+	case found.Active == active:
+		return nil
+	}
+	err = withFloodRetry(ctx, func() error {
+		_, err := api.AccountToggleUsername(ctx, &tg.AccountToggleUsernameRequest{Username: found.Username, Active: active})
+		return err
+	})
+	switch {
+	case tgerr.Is(err, "USERNAMES_ACTIVE_TOO_MUCH"):
+		return fmt.Errorf("too many active usernames; hide another one first: %w", err)
+	case err != nil:
+		return fmt.Errorf("failed to toggle @%s: %w", username, err)
+	}
+	return nil
+}
+
+// reorderUsernames sets the order the account's active usernames are shown
+// in. order must name every active username exactly once.
+func reorderUsernames(ctx context.Context, api *tg.Client, peers *peerResolver, order []string) error {
+	owned, err := ownUsernames(ctx, api, peers)
+	if err != nil {
+		return err
+	}
+	active := make(map[string]string) // lower case to canonical
+	for _, u := range owned {
+		if u.Active {
+			active[strings.ToLower(u.Username)] = u.Username
+		}
+	}
+	if len(order) != len(active) {
+		return invalidParams("order names %d usernames, but you have %d active", len(order), len(active))
+	}
+	canonical := make([]string, 0, len(order))
+	seen := make(map[string]bool)
+	for _, u := range order {
+		key := strings.ToLower(u)
+		name, ok := active[key]
+		switch {
+		case !ok:
+			return invalidParams("@%s is not one of your active usernames", u)
+		case seen[key]:
+			return invalidParams("@%s is listed twice", u)
+		}
+		seen[key] = true
+		canonical = append(canonical, name)
+	}
+	err = withFloodRetry(ctx, func() error {
+		_, err := api.AccountReorderUsernames(ctx, canonical)
+		return err
+	})
+	if err != nil && !tgerr.Is(err, "USERNAME_NOT_MODIFIED") {
+		return fmt.Errorf("failed to reorder usernames: %w", err)
+	}
+	return nil
+}
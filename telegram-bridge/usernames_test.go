@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+)
+
+func TestActiveUsernames(t *testing.T) {
+	usernames := []tg.Username{
+		{Username: "collected", Active: true},
+		{Username: "hidden"},
+		{Username: "main", Editable: true, Active: true},
+		{Username: "rare", Active: true},
+	}
+	if got, want := activeUsernames("", usernames), []string{"main", "collected", "rare"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("activeUsernames = %v, want %v", got, want)
+	}
+	if got := activeUsernames("single", nil); !reflect.DeepEqual(got, []string{"single"}) {
+		t.Errorf("single username = %v", got)
+	}
+	if got := activeUsernames("", nil); got == nil || len(got) != 0 {
+		t.Errorf("no usernames = %#v, want empty list", got)
+	}
+}
+
+func TestGetPeerUsernames(t *testing.T) {
+	inv, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		switch input.(type) {
+		case *tg.UsersGetUsersRequest:
+			return &tg.UserClassVector{Elems: []tg.UserClass{&tg.User{ID: 10, AccessHash: 1, Usernames: []tg.Username{
+				{Username: "alice", Editable: true, Active: true},
+				{Username: "old"},
+			}}}}, nil
+		case *tg.ChannelsGetChannelsRequest:
+			return &tg.MessagesChats{Chats: []tg.ChatClass{&tg.Channel{ID: 30, AccessHash: 3, Photo: &tg.ChatPhotoEmpty{}, Username: "news"}}}, nil
+		}
+		return nil, nil
+	})
+	peers.storeUser(&tg.User{ID: 10, AccessHash: 1})
+	peers.storeChannel(&tg.Channel{ID: 30, AccessHash: 3})
+
+	got, err := getPeerUsernames(context.Background(), api, peers, "10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, []string{"alice"}) {
+		t.Errorf("user usernames = %v", got)
+	}
+	if got, err := getPeerUsernames(context.Background(), api, peers, "-1000000000030"); err != nil || !reflect.DeepEqual(got, []string{"news"}) {
+		t.Errorf("channel usernames = %v, %v", got, err)
+	}
+	if got, err := getPeerUsernames(context.Background(), api, peers, "-5"); err != nil || len(got) != 0 {
+		t.Errorf("basic group usernames = %v, %v", got, err)
+	}
+	if n := len(requests[*tg.UsersGetUsersRequest](inv)); n != 1 {
+		t.Errorf("fetched user %d times, want once", n)
+	}
+}
+
+func ownUsernamesClient() (*fakeInvoker, *tg.Client, *peerResolver) {
+	return newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		switch input.(type) {
+		case *tg.UsersGetUsersRequest:
+			return &tg.UserClassVector{Elems: []tg.UserClass{&tg.User{ID: 1, Self: true, Usernames: []tg.Username{
+				{Username: "me_main", Editable: true, Active: true},
+				{Username: "Rare", Active: true},
+				{Username: "stash"},
+			}}}}, nil
+		case *tg.AccountToggleUsernameRequest, *tg.AccountReorderUsernamesRequest:
+			return &tg.BoolTrue{}, nil
+		}
+		return nil, nil
+	})
+}
+
+func TestToggleUsername(t *testing.T) {
+	inv, api, peers := ownUsernamesClient()
+	if err := toggleUsername(context.Background(), api, peers, "stash", true); err != nil {
+		t.Fatal(err)
+	}
+	reqs := requests[*tg.AccountToggleUsernameRequest](inv)
+	if len(reqs) != 1 || reqs[0].Username != "stash" || !reqs[0].Active {
+		t.Errorf("requests = %+v, want stash activated", reqs)
+	}
+
+	// Already hidden, matched case-insensitively, so nothing is sent
+	if err := toggleUsername(context.Background(), api, peers, "STASH", false); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(requests[*tg.AccountToggleUsernameRequest](inv)); n != 1 {
+		t.Errorf("sent %d toggles, want 1", n)
+	}
+}
+
+func TestToggleUsernameNotOwned(t *testing.T) {
+	inv, api, peers := ownUsernamesClient()
+	for _, username := range []string{"someone_else", "me_main"} {
+		err := toggleUsername(context.Background(), api, peers, username, false)
+		var rpcErr *rpcError
+		if !errors.As(err, &rpcErr) || rpcErr.Code != rpcInvalidParams {
+			t.Errorf("%s: err = %v, want invalid params", username, err)
+		}
+	}
+	if n := len(requests[*tg.AccountToggleUsernameRequest](inv)); n != 0 {
+		t.Errorf("sent %d toggles for usernames that cannot be toggled", n)
+	}
+}
+
+func TestReorderUsernames(t *testing.T) {
+	inv, api, peers := ownUsernamesClient()
+	if err := reorderUsernames(context.Background(), api, peers, []string{"rare", "me_main"}); err != nil {
+		t.Fatal(err)
+	}
+	reqs := requests[*tg.AccountReorderUsernamesRequest](inv)
+	if len(reqs) != 1 || !reflect.DeepEqual(reqs[0].Order, []string{"Rare", "me_main"}) {
+		t.Errorf("requests = %+v, want order [Rare me_main]", reqs)
+	}
+
+	for _, order := range [][]string{{"rare"}, {"rare", "rare"}, {"rare", "stash"}} {
+		err := reorderUsernames(context.Background(), api, peers, order)
+		var rpcErr *rpcError
+		if !errors.As(err, &rpcErr) || rpcErr.Code != rpcInvalidParams {
+			t.Errorf("%v: err = %v, want invalid params", order, err)
+		}
+	}
+	if n := len(requests[*tg.AccountReorderUsernamesRequest](inv)); n != 1 {
+		t.Errorf("sent %d reorders, want 1", n)
+	}
+}
@@ -0,0 +1,476 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gotd/td/tg"
+)
+
+// Last-seen buckets reported by get_last_seen
+const (
+	seenOnline    = "online"
+	seenOffline   = "offline"
+	seenRecently  = "recently"
+	seenLastWeek  = "last_week"
+	seenLastMonth = "last_month"
+	seenHidden    = "hidden"
+)
+
+type userArgs struct {
+	User string `json:"user"`
+}
+
+type lastSeenResult struct {
+	Status   string `json:"status"`
+	LastSeen int64  `json:"last_seen,omitempty"`
+}
+
+func getLastSeenTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args userArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.User) == "" {
+			return nil, invalidParams("user is required")
+		}
+
+		seen, bucket, err := getLastSeen(ctx, api, peers, args.User)
+		if err != nil {
+			return nil, err
+		}
+		result := lastSeenResult{Status: bucket}
+		if !seen.IsZero() {
+			result.LastSeen = seen.Unix()
+		}
+		return result, nil
+	}
+}
+
+// getLastSeen returns when userPeer was last seen. The time is zero when the
+// user only exposes a coarse bucket or hides their status entirely.
+func getLastSeen(ctx context.Context, api *tg.Client, peers *peerResolver, userPeer string) (time.Time, string, error) {
+	user, err := getUser(ctx, api, peers, userPeer)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	seen, bucket := lastSeen(user.Status, time.Now())
+	return seen, bucket, nil
+}
+
+// lastSeen maps a user status to a concrete timestamp where one is exposed,
+// and the bucket describing it
+func lastSeen(status tg.UserStatusClass, now time.Time) (time.Time, string) {
+	switch s := status.(type) {
+	case *tg.UserStatusOnline:
+		return now, seenOnline
+	case *tg.UserStatusOffline:
+		return time.Unix(int64(s.WasOnline), 0), seenOffline
+	case *tg.UserStatusRecently:
+		return time.Time{}, seenRecently
+	case *tg.UserStatusLastWeek:
+		return time.Time{}, seenLastWeek
+	case *tg.UserStatusLastMonth:
+		return time.Time{}, seenLastMonth
+	default:
+		// userStatusEmpty or no status: hidden or never seen
+		return time.Time{}, seenHidden
+	}
+}
+
+// getUser fetches the full user object for peer
+func getUser(ctx context.Context, api *tg.Client, peers *peerResolver, peer string) (*tg.User, error) {
+	input, err := peers.ResolveUser(ctx, peer)
+	if err != nil {
+		return nil, err
+	}
+	users, err := api.UsersGetUsers(ctx, []tg.InputUserClass{input})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	peers.remember(users, nil)
+	for _, u := range users {
+		if u, ok := u.(*tg.User); ok {
+			return u, nil
+		}
+	}
+	return nil, fmt.Errorf("user %s not found", peer)
+}
+
+// suggestedPhotoScanLimit is how many recent messages of a private chat are
+// searched for a profile photo suggestion
+const suggestedPhotoScanLimit = 100
+
+// SuggestedPhoto is a profile photo a contact suggested in your private chat.
+// Suggested is false when no suggestion was found among recent messages.
+type SuggestedPhoto struct {
+	Suggested bool  `json:"suggested"`
+	MessageID int   `json:"message_id,omitempty"`
+	Date      int   `json:"date,omitempty"`
+	PhotoID   int64 `json:"photo_id,omitempty"`
+	// PersonalPhotoID is the photo you chose to show for this contact
+	// instead of their own, if any
+	PersonalPhotoID int64 `json:"personal_photo_id,omitempty"`
+
+	photo *tg.Photo
+}
+
+func getSuggestedProfilePhotoTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args userArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.User) == "" {
+			return nil, invalidParams("user is required")
+		}
+		return getSuggestedProfilePhoto(ctx, api, peers, args.User)
+	}
+}
+
+type acceptedPhotoResult struct {
+	PhotoID int64 `json:"photo_id"`
+}
+
+func acceptSuggestedPhotoTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args userArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.User) == "" {
+			return nil, invalidParams("user is required")
+		}
+
+		id, err := acceptSuggestedPhoto(ctx, api, peers, args.User)
+		if err != nil {
+			return nil, err
+		}
+		return acceptedPhotoResult{PhotoID: id}, nil
+	}
+}
+
+// getSuggestedProfilePhoto returns the latest profile photo userPeer
+// suggested to you, together with the personal photo from their full user
+func getSuggestedProfilePhoto(ctx context.Context, api *tg.Client, peers *peerResolver, userPeer string) (SuggestedPhoto, error) {
+	user, err := peers.ResolveUser(ctx, userPeer)
+	if err != nil {
+		return SuggestedPhoto{}, err
+	}
+	full, err := api.UsersGetFullUser(ctx, user)
+	if err != nil {
+		return SuggestedPhoto{}, fmt.Errorf("failed to get user: %w", err)
+	}
+	peers.remember(full.Users, full.Chats)
+
+	var history tg.MessagesMessagesClass
+	err = withFloodRetry(ctx, func() (err error) {
+		history, err = api.MessagesGetHistory(ctx, &tg.MessagesGetHistoryRequest{
+			Peer:  &tg.InputPeerUser{UserID: user.UserID, AccessHash: user.AccessHash},
+			Limit: suggestedPhotoScanLimit,
+		})
+		return err
+	})
+	if err != nil {
+		return SuggestedPhoto{}, fmt.Errorf("failed to read messages: %w", err)
+	}
+	modified, ok := history.AsModified()
+	if !ok {
+		return SuggestedPhoto{}, fmt.Errorf("unexpected messages response %T", history)
+	}
+
+	suggested := findSuggestedPhoto(modified.GetMessages())
+	if p, ok := full.FullUser.GetPersonalPhoto(); ok {
+		suggested.PersonalPhotoID = p.GetID()
+	}
+	return suggested, nil
+}
+
+// findSuggestedPhoto returns the newest photo suggestion received in msgs,
+// which are ordered newest first. Suggestions you sent are ignored.
+func findSuggestedPhoto(msgs []tg.MessageClass) SuggestedPhoto {
+	for _, m := range msgs {
+		msg, ok := m.(*tg.MessageService)
+		if !ok || msg.Out {
+			continue
+		}
+		action, ok := msg.Action.(*tg.MessageActionSuggestProfilePhoto)
+		if !ok {
+			continue
+		}
+		photo, ok := action.Photo.AsNotEmpty()
+		if !ok {
+			continue
+		}
+		return SuggestedPhoto{
+			Suggested: true,
+			MessageID: msg.ID,
+			Date:      msg.Date,
+			PhotoID:   photo.ID,
+			photo:     photo,
+		}
+	}
+	return SuggestedPhoto{}
+}
+
+// acceptSuggestedPhoto sets the photo userPeer last suggested as your own
+// profile photo. Suggestions are made with photos.uploadContactProfilePhoto;
+// accepting one reuses the already uploaded photo via
+// photos.updateProfilePhoto, as official clients do.
+func acceptSuggestedPhoto(ctx context.Context, api *tg.Client, peers *peerResolver, userPeer string) (int64, error) {
+	suggested, err := getSuggestedProfilePhoto(ctx, api, peers, userPeer)
+	if err != nil {
+		return 0, err
+	}
+	if !suggested.Suggested {
+		return 0, fmt.Errorf("%s has not suggested a profile photo in recent messages", userPeer)
+	}
+
+	res, err := api.PhotosUpdateProfilePhoto(ctx, &tg.PhotosUpdateProfilePhotoRequest{
+		ID: suggested.photo.AsInput(),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to set profile photo: %w", err)
+	}
+	return res.Photo.GetID(), nil
+}
+
+type contactPhotoArgs struct {
+	User string `json:"user"`
+	Path string `json:"path"`
+}
+
+type contactPhotoResult struct {
+	PhotoID int64 `json:"photo_id,omitempty"`
+}
+
+func setContactPhotoTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args contactPhotoArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.User) == "" {
+			return nil, invalidParams("user is required")
+		}
+		if strings.TrimSpace(args.Path) == "" {
+			return nil, invalidParams("path is required")
+		}
+
+		id, err := setContactPhoto(ctx, api, peers, args.User, args.Path)
+		if err != nil {
+			return nil, err
+		}
+		return contactPhotoResult{PhotoID: id}, nil
+	}
+}
+
+func resetContactPhotoTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args userArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.User) == "" {
+			return nil, invalidParams("user is required")
+		}
+
+		if _, err := setContactPhoto(ctx, api, peers, args.User, ""); err != nil {
+			return nil, err
+		}
+		return contactPhotoResult{}, nil
+	}
+}
+
+// setContactPhoto uploads the image at filePath and sets it as the personal
+// photo of a contact, shown only to you instead of the photo they chose. An
+// empty filePath removes the personal photo again.
+func setContactPhoto(ctx context.Context, api *tg.Client, peers *peerResolver, userPeer, filePath string) (int64, error) {
+	user, err := getUser(ctx, api, peers, userPeer)
+	if err != nil {
+		return 0, err
+	}
+	if !user.Contact {
+		return 0, fmt.Errorf("%s is not in your contacts", userPeer)
+	}
+
+	// Without a file, save removes the personal photo; it must never be
+	// combined with a new upload
+	req := &tg.PhotosUploadContactProfilePhotoRequest{
+		UserID: user.AsInput(),
+	}
+	if filePath == "" {
+		req.Save = true
+	} else {
+		file, err := uploadLocalFile(ctx, api, filePath)
+		if err != nil {
+			return 0, err
+		}
+		req.SetFile(file)
+	}
+
+	res, err := api.PhotosUploadContactProfilePhoto(ctx, req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to set contact photo: %w", err)
+	}
+	peers.remember(res.Users, nil)
+	return res.Photo.GetID(), nil
+}
+
+// Bounds for the number of photos get_user_photos returns; Telegram serves
+// at most 100 per request
+const (
+	defaultUserPhotoLimit = 20
+	maxUserPhotoLimit     = 500
+	userPhotosPageSize    = 100
+)
+
+type userPhotosArgs struct {
+	User  string `json:"user"`
+	Limit int    `json:"limit"`
+	// DestDir, when set, is where each photo is saved as <photo_id>.jpg
+	DestDir string `json:"dest_dir"`
+}
+
+// PhotoInfo describes one of a user's profile photos, newest first
+type PhotoInfo struct {
+	PhotoID int64 `json:"photo_id"`
+	Date    int   `json:"date"`
+	Width   int   `json:"width"`
+	Height  int   `json:"height"`
+	// Video is set for animated profile pictures
+	Video bool `json:"video"`
+	// Path is where the photo was saved, set only when downloading
+	Path string `json:"path,omitempty"`
+
+	photo *tg.Photo
+}
+
+func getUserPhotosTool(api *tg.Client, peers *peerResolver, downloads *downloadLog) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args userPhotosArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.User) == "" {
+			return nil, invalidParams("user is required")
+		}
+		if args.Limit < 0 {
+			return nil, invalidParams("limit must not be negative")
+		}
+
+		photos, err := getUserPhotos(ctx, api, peers, args.User, clampLimit(args.Limit, defaultUserPhotoLimit, maxUserPhotoLimit))
+		if err != nil {
+			return nil, err
+		}
+		if args.DestDir != "" {
+			p, err := peers.resolve(ctx, args.User)
+			if err != nil {
+				return nil, err
+			}
+			if err := downloadUserPhotos(ctx, api, photos, args.DestDir, p.MarkedID(), downloads); err != nil {
+				return nil, err
+			}
+		}
+		return photos, nil
+	}
+}
+
+// getUserPhotos pages through photos.getUserPhotos until limit photos are
+// collected or the user has no more
+func getUserPhotos(ctx context.Context, api *tg.Client, peers *peerResolver, userPeer string, limit int) ([]PhotoInfo, error) {
+	user, err := peers.ResolveUser(ctx, userPeer)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]PhotoInfo, 0, limit)
+	for len(result) < limit {
+		var res tg.PhotosPhotosClass
+		err := withFloodRetry(ctx, func() (err error) {
+			res, err = api.PhotosGetUserPhotos(ctx, &tg.PhotosGetUserPhotosRequest{
+				UserID: user,
+				Offset: len(result),
+				Limit:  min(limit-len(result), userPhotosPageSize),
+			})
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get photos of %s: %w", userPeer, err)
+		}
+
+		var (
+			page  []tg.PhotoClass
+			total int
+		)
+		switch res := res.(type) {
+		case *tg.PhotosPhotos:
+			// The complete list fits in one response
+			peers.remember(res.Users, nil)
+			page, total = res.Photos, len(res.Photos)
+		case *tg.PhotosPhotosSlice:
+			peers.remember(res.Users, nil)
+			page, total = res.Photos, res.Count
+		default:
+			return nil, fmt.Errorf("unexpected photos response %T", res)
+		}
+		if len(page) == 0 {
+			break
+		}
+		for _, p := range page {
+			if photo, ok := p.AsNotEmpty(); ok && len(result) < limit {
+				result = append(result, newPhotoInfo(photo))
+			}
+		}
+		if _, all := res.(*tg.PhotosPhotos); all || len(result) >= total {
+			break
+		}
+	}
+	return result, nil
+}
+
+func newPhotoInfo(photo *tg.Photo) PhotoInfo {
+	info := PhotoInfo{
+		PhotoID: photo.ID,
+		Date:    photo.Date,
+		Video:   len(photo.VideoSizes) > 0,
+		photo:   photo,
+	}
+	for _, s := range photo.Sizes {
+		var w, h int
+		switch s := s.(type) {
+		case *tg.PhotoSize:
+			w, h = s.W, s.H
+		case *tg.PhotoSizeProgressive:
+			w, h = s.W, s.H
+		}
+		if w*h > info.Width*info.Height {
+			info.Width, info.Height = w, h
+		}
+	}
+	return info
+}
+
+// downloadUserPhotos saves each photo of userID at its largest size into
+// dir, setting Path on success and logging it in downloads
+func downloadUserPhotos(ctx context.Context, api *tg.Client, photos []PhotoInfo, dir string, userID int64, downloads *downloadLog) error {
+	for i := range photos {
+		file, ok := photoFile(photos[i].photo)
+		if !ok {
+			continue
+		}
+		dest := filepath.Join(dir, fmt.Sprintf("%d.jpg", photos[i].PhotoID))
+		res, err := saveMediaFile(ctx, api, file, dest)
+		if err != nil {
+			return fmt.Errorf("failed to download photo %d: %w", photos[i].PhotoID, err)
+		}
+		photos[i].Path = dest
+		downloads.record(res, userID, downloadUserPhoto, photos[i].PhotoID)
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+)
+
+func TestSetContactPhoto(t *testing.T) {
+	photoPath := filepath.Join(t.TempDir(), "photo.jpg")
+	if err := os.WriteFile(photoPath, []byte("\xff\xd8\xff\xe0 not really a jpeg"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		contact  bool
+		wantSave bool
+		wantFile bool
+		wantErr  bool
+	}{
+		{name: "upload then set", path: photoPath, contact: true, wantFile: true},
+		{name: "reset", contact: true, wantSave: true},
+		{name: "not a contact", path: photoPath, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &tg.User{ID: 10, AccessHash: 99, Contact: tt.contact}
+			inv, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+				switch input.(type) {
+				case *tg.UsersGetUsersRequest:
+					return &tg.UserClassVector{Elems: []tg.UserClass{user}}, nil
+				case *tg.UploadSaveFilePartRequest:
+					return &tg.BoolTrue{}, nil
+				case *tg.PhotosUploadContactProfilePhotoRequest:
+					return &tg.PhotosPhoto{Photo: &tg.Photo{ID: 555}}, nil
+				}
+				return nil, nil
+			})
+			peers.storeUser(user)
+
+			id, err := setContactPhoto(context.Background(), api, peers, "10", tt.path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("setContactPhoto() succeeded, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("setContactPhoto() error = %v", err)
+			}
+			if id != 555 {
+				t.Errorf("photo id = %d, want 555", id)
+			}
+
+			parts := requests[*tg.UploadSaveFilePartRequest](inv)
+			if tt.wantFile && len(parts) == 0 {
+				t.Error("file was not uploaded")
+			}
+			if !tt.wantFile && len(parts) != 0 {
+				t.Error("reset uploaded a file")
+			}
+			sets := requests[*tg.PhotosUploadContactProfilePhotoRequest](inv)
+			if len(sets) != 1 {
+				t.Fatalf("got %d contact photo requests, want 1", len(sets))
+			}
+			_, hasFile := sets[0].GetFile()
+			if sets[0].Save != tt.wantSave || hasFile != tt.wantFile {
+				t.Errorf("request save = %v, file = %v; want %v, %v", sets[0].Save, hasFile, tt.wantSave, tt.wantFile)
+			}
+			if _, ok := inv.calls[len(inv.calls)-1].(*tg.PhotosUploadContactProfilePhotoRequest); !ok {
+				t.Errorf("contact photo was set before the upload finished")
+			}
+		})
+	}
+}
+
+func TestLastSeen(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		name       string
+		status     tg.UserStatusClass
+		wantTime   time.Time
+		wantBucket string
+	}{
+		{name: "online", status: &tg.UserStatusOnline{Expires: 1700000300}, wantTime: now, wantBucket: seenOnline},
+		{name: "offline", status: &tg.UserStatusOffline{WasOnline: 1690000000}, wantTime: time.Unix(1690000000, 0), wantBucket: seenOffline},
+		{name: "recently", status: &tg.UserStatusRecently{}, wantBucket: seenRecently},
+		{name: "last week", status: &tg.UserStatusLastWeek{}, wantBucket: seenLastWeek},
+		{name: "last month", status: &tg.UserStatusLastMonth{}, wantBucket: seenLastMonth},
+		{name: "empty", status: &tg.UserStatusEmpty{}, wantBucket: seenHidden},
+		{name: "no status", wantBucket: seenHidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotTime, gotBucket := lastSeen(tt.status, now)
+			if !gotTime.Equal(tt.wantTime) || gotBucket != tt.wantBucket {
+				t.Errorf("lastSeen() = %v, %q; want %v, %q", gotTime, gotBucket, tt.wantTime, tt.wantBucket)
+			}
+		})
+	}
+}
+
+func TestFindSuggestedPhoto(t *testing.T) {
+	suggestion := func(id int, photo tg.PhotoClass, out bool) *tg.MessageService {
+		return &tg.MessageService{ID: id, Date: 1000 + id, Out: out, PeerID: &tg.PeerUser{UserID: 10}, Action: &tg.MessageActionSuggestProfilePhoto{Photo: photo}}
+	}
+	tests := []struct {
+		name      string
+		msgs      []tg.MessageClass
+		wantMsg   int
+		wantPhoto int64
+	}{
+		{name: "no suggestion", msgs: []tg.MessageClass{&tg.Message{ID: 3}, &tg.MessageService{ID: 2, Action: &tg.MessageActionChatEditTitle{}}}},
+		{name: "newest suggestion wins", msgs: []tg.MessageClass{
+			&tg.Message{ID: 4},
+			suggestion(3, &tg.Photo{ID: 33}, false),
+			suggestion(1, &tg.Photo{ID: 11}, false),
+		}, wantMsg: 3, wantPhoto: 33},
+		{name: "own suggestions ignored", msgs: []tg.MessageClass{
+			suggestion(3, &tg.Photo{ID: 33}, true),
+			suggestion(1, &tg.Photo{ID: 11}, false),
+		}, wantMsg: 1, wantPhoto: 11},
+		{name: "empty photo ignored", msgs: []tg.MessageClass{suggestion(3, &tg.PhotoEmpty{ID: 33}, false)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := findSuggestedPhoto(tt.msgs)
+			if got.Suggested != (tt.wantMsg != 0) || got.MessageID != tt.wantMsg || got.PhotoID != tt.wantPhoto {
+				t.Errorf("findSuggestedPhoto() = %+v, want message %d photo %d", got, tt.wantMsg, tt.wantPhoto)
+			}
+			if got.Suggested && got.Date != 1000+tt.wantMsg {
+				t.Errorf("date = %d", got.Date)
+			}
+		})
+	}
+}
+
+func TestGetSuggestedProfilePhotoPersonal(t *testing.T) {
+	_, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		switch input.(type) {
+		case *tg.UsersGetFullUserRequest:
+			full := tg.UserFull{ID: 10}
+			full.SetPersonalPhoto(&tg.Photo{ID: 99})
+			return &tg.UsersUserFull{FullUser: full}, nil
+		case *tg.MessagesGetHistoryRequest:
+			return &tg.MessagesMessages{}, nil
+		}
+		return nil, nil
+	})
+	peers.storeUser(&tg.User{ID: 10, AccessHash: 1})
+
+	got, err := getSuggestedProfilePhoto(context.Background(), api, peers, "10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Suggested || got.PersonalPhotoID != 99 {
+		t.Errorf("getSuggestedProfilePhoto() = %+v, want no suggestion and personal photo 99", got)
+	}
+}
+
+func TestGetUserPhotos(t *testing.T) {
+	// The fake serves at most two photos per request out of five
+	var all []tg.PhotoClass
+	for i := int64(1); i <= 5; i++ {
+		all = append(all, &tg.Photo{
+			ID:   i,
+			Date: int(100 - i),
+			Sizes: []tg.PhotoSizeClass{
+				&tg.PhotoStrippedSize{Type: "i"},
+				&tg.PhotoSize{Type: "m", W: 320, H: 320},
+				&tg.PhotoSizeProgressive{Type: "y", W: 1280, H: 1280},
+			},
+		})
+	}
+	withVideo := *all[0].(*tg.Photo)
+	withVideo.SetVideoSizes([]tg.VideoSizeClass{&tg.VideoSize{Type: "u", W: 640, H: 640}})
+	all[0] = &withVideo
+
+	tests := []struct {
+		name        string
+		limit       int
+		want        int
+		wantOffsets []int
+	}{
+		{name: "all photos", limit: 10, want: 5, wantOffsets: []int{0, 2, 4}},
+		{name: "limited", limit: 3, want: 3, wantOffsets: []int{0, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inv, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+				req, ok := input.(*tg.PhotosGetUserPhotosRequest)
+				if !ok {
+					return nil, nil
+				}
+				end := min(req.Offset+min(req.Limit, 2), len(all))
+				return &tg.PhotosPhotosSlice{Count: len(all), Photos: all[req.Offset:end]}, nil
+			})
+			peers.storeUser(&tg.User{ID: 10, AccessHash: 1})
+
+			photos, err := getUserPhotos(context.Background(), api, peers, "10", tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(photos) != tt.want {
+				t.Fatalf("got %d photos, want %d", len(photos), tt.want)
+			}
+			var offsets []int
+			for _, req := range requests[*tg.PhotosGetUserPhotosRequest](inv) {
+				offsets = append(offsets, req.Offset)
+			}
+			if fmt.Sprint(offsets) != fmt.Sprint(tt.wantOffsets) {
+				t.Errorf("offsets = %v, want %v", offsets, tt.wantOffsets)
+			}
+
+			first := photos[0]
+			if first.PhotoID != 1 || first.Date != 99 || first.Width != 1280 || first.Height != 1280 || !first.Video {
+				t.Errorf("first photo = %+v", first)
+			}
+			if photos[1].Video {
+				t.Error("photo without video sizes reported as video")
+			}
+		})
+	}
+}
+
+func TestGetUserPhotosComplete(t *testing.T) {
+	inv, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		if _, ok := input.(*tg.PhotosGetUserPhotosRequest); ok {
+			return &tg.PhotosPhotos{Photos: []tg.PhotoClass{&tg.Photo{ID: 1}, &tg.PhotoEmpty{ID: 2}}}, nil
+		}
+		return nil, nil
+	})
+	peers.storeUser(&tg.User{ID: 10, AccessHash: 1})
+
+	photos, err := getUserPhotos(context.Background(), api, peers, "10", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(photos) != 1 || photos[0].PhotoID != 1 {
+		t.Errorf("photos = %+v, want only the non-empty one", photos)
+	}
+	if n := len(requests[*tg.PhotosGetUserPhotosRequest](inv)); n != 1 {
+		t.Errorf("made %d requests for a complete list, want 1", n)
+	}
+}
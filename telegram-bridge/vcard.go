@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/gotd/td/tg"
+)
+
+// vCardLineLimit is the line length in octets after which RFC 2425 folds a
+// content line
+const vCardLineLimit = 75
+
+type vCardArgs struct {
+	Path string `json:"path"`
+}
+
+type exportContactsResult struct {
+	Path     string `json:"path"`
+	Contacts int    `json:"contacts"`
+}
+
+func exportContactsVCardTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args vCardArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.Path) == "" {
+			return nil, invalidParams("path is required")
+		}
+		n, err := exportContactsVCard(ctx, api, peers, args.Path)
+		if err != nil {
+			return nil, err
+		}
+		return exportContactsResult{Path: args.Path, Contacts: n}, nil
+	}
+}
+
+// exportContactsVCard writes the whole contact list to outPath as a vCard
+// 3.0 file and returns the number of contacts written
+func exportContactsVCard(ctx context.Context, api *tg.Client, peers *peerResolver, outPath string) (int, error) {
+	contacts, err := getContacts(ctx, api, peers)
+	if err != nil {
+		return 0, err
+	}
+	var b strings.Builder
+	for _, c := range contacts {
+		writeVCard(&b, c)
+	}
+	if err := os.WriteFile(outPath, []byte(b.String()), 0600); err != nil {
+		return 0, fmt.Errorf("failed to write contacts '%s': %w", outPath, err)
+	}
+	return len(contacts), nil
+}
+
+// writeVCard appends c as one vCard. Contacts without a phone number get no
+// TEL line, and the username is kept as X-TELEGRAM.
+func writeVCard(b *strings.Builder, c Contact) {
+	name := strings.TrimSpace(c.FirstName + " " + c.LastName)
+	if name == "" {
+		// FN is required; fall back to what identifies the contact
+		switch {
+		case c.Username != "":
+			name = "@" + c.Username
+		case c.Phone != "":
+			name = c.Phone
+		default:
+			name = fmt.Sprintf("Telegram user %d", c.UserID)
+		}
+	}
+	writeVCardLine(b, "BEGIN:VCARD")
+	writeVCardLine(b, "VERSION:3.0")
+	writeVCardLine(b, "FN:"+escapeVCard(name))
+	writeVCardLine(b, "N:"+escapeVCard(c.LastName)+";"+escapeVCard(c.FirstName)+";;;")
+	if c.Phone != "" {
+		writeVCardLine(b, "TEL;TYPE=CELL:"+escapeVCard(c.Phone))
+	}
+	if c.Username != "" {
+		writeVCardLine(b, "X-TELEGRAM:"+escapeVCard(c.Username))
+	}
+	writeVCardLine(b, "END:VCARD")
+}
+
+// writeVCardLine appends a content line ending in CRLF, folding it into
+// continuation lines starting with a space once it exceeds the limit.
+// Folds never split a UTF-8 sequence.
+func writeVCardLine(b *strings.Builder, line string) {
+	limit := vCardLineLimit
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// The leading space counts toward the continuation line
+		limit = vCardLineLimit - 1
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+func isRuneStart(c byte) bool {
+	return c&0xC0 != 0x80
+}
+
+// escapeVCard escapes a text value as RFC 2426 requires
+func escapeVCard(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ",", `\,`, ";", `\;`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// importContactsChunk is how many contacts go into one contacts.importContacts
+const importContactsChunk = 100
+
+// vCardContact is a contact read from a vCard file
+type vCardContact struct {
+	FirstName string
+	LastName  string
+	Phone     string
+}
+
+type importContactsResult struct {
+	// Contacts is how many valid vCards the file had; Skipped counts the
+	// malformed ones left out
+	Contacts int `json:"contacts"`
+	Skipped  int `json:"skipped"`
+	// Imported is how many contacts matched a Telegram account
+	Imported int `json:"imported"`
+	// Retry is how many Telegram refused for now; import them again later
+	Retry int `json:"retry,omitempty"`
+}
+
+func importContactsVCardTool(api *tg.Client, peers *peerResolver) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args vCardArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(args.Path) == "" {
+			return nil, invalidParams("path is required")
+		}
+		return importContactsVCard(ctx, api, peers, args.Path)
+	}
+}
+
+// importContactsVCard adds the contacts of a vCard file to the account.
+// Cards without a phone number or an END line are skipped with a warning.
+func importContactsVCard(ctx context.Context, api *tg.Client, peers *peerResolver, path string) (importContactsResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return importContactsResult{}, fmt.Errorf("failed to read contacts: %w", err)
+	}
+	contacts, skipped := parseVCards(string(data))
+	result := importContactsResult{Contacts: len(contacts), Skipped: skipped}
+
+	for start := 0; start < len(contacts); start += importContactsChunk {
+		chunk := contacts[start:min(start+importContactsChunk, len(contacts))]
+		input := make([]tg.InputPhoneContact, 0, len(chunk))
+		for i, c := range chunk {
+			input = append(input, tg.InputPhoneContact{
+				ClientID:  int64(start + i),
+				Phone:     c.Phone,
+				FirstName: c.FirstName,
+				LastName:  c.LastName,
+			})
+		}
+		var imported *tg.ContactsImportedContacts
+		err := withFloodRetry(ctx, func() (err error) {
+			imported, err = api.ContactsImportContacts(ctx, input)
+			return err
+		})
+		if err != nil {
+			return result, fmt.Errorf("failed to import contacts %d-%d: %w", start+1, start+len(chunk), err)
+		}
+		peers.remember(imported.Users, nil)
+		result.Imported += len(imported.Imported)
+		result.Retry += len(imported.RetryContacts)
+	}
+	return result, nil
+}
+
+// parseVCards reads the vCards of data, returning the contacts and how many
+// malformed cards were skipped
+func parseVCards(data string) ([]vCardContact, int) {
+	// Unfold continuation lines before splitting into content lines
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	data = strings.NewReplacer("\n ", "", "\n\t", "").Replace(data)
+
+	var (
+		contacts []vCardContact
+		skipped  int
+		card     *vCardContact
+		fullName string
+	)
+	for n, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		name, params, value, ok := splitVCardLine(line)
+		if !ok {
+			continue
+		}
+		switch name {
+		case "BEGIN":
+			if card != nil {
+				slog.Warn("Skipping vCard without END", "line", n+1)
+				skipped++
+			}
+			card, fullName = &vCardContact{}, ""
+		case "END":
+			if card == nil {
+				continue
+			}
+			if card.FirstName == "" && card.LastName == "" {
+				card.FirstName = fullName
+			}
+			if card.Phone == "" {
+				slog.Warn("Skipping vCard without a phone number", "name", strings.TrimSpace(card.FirstName+" "+card.LastName))
+				skipped++
+			} else {
+				contacts = append(contacts, *card)
+			}
+			card = nil
+		case "FN":
+			fullName = unescapeVCard(value)
+		case "N":
+			if card != nil {
+				parts := splitVCardValue(value)
+				if len(parts) > 0 {
+					card.LastName = parts[0]
+				}
+				if len(parts) > 1 {
+					card.FirstName = parts[1]
+				}
+			}
+		case "TEL":
+			// Keep the first number, or a later one marked preferred
+			if card != nil && (card.Phone == "" || strings.Contains(strings.ToUpper(params), "PREF")) {
+				card.Phone = strings.TrimPrefix(unescapeVCard(value), "tel:")
+			}
+		}
+	}
+	if card != nil {
+		slog.Warn("Skipping vCard without END at end of file")
+		skipped++
+	}
+	return contacts, skipped
+}
+
+// splitVCardLine splits a content line into its upper-cased property name
+// without group, its parameters and its value
+func splitVCardLine(line string) (name, params, value string, ok bool) {
+	head, value, ok := strings.Cut(line, ":")
+	if !ok {
+		return "", "", "", false
+	}
+	name, params, _ = strings.Cut(head, ";")
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.ToUpper(name), params, value, true
+}
+
+// splitVCardValue splits a structured value on unescaped semicolons and
+// unescapes each component
+func splitVCardValue(value string) []string {
+	var parts []string
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch {
+		case value[i] == '\\' && i+1 < len(value):
+			b.WriteByte(value[i])
+			b.WriteByte(value[i+1])
+			i++
+		case value[i] == ';':
+			parts = append(parts, unescapeVCard(b.String()))
+			b.Reset()
+		default:
+			b.WriteByte(value[i])
+		}
+	}
+	return append(parts, unescapeVCard(b.String()))
+}
+
+// unescapeVCard reverses escapeVCard
+func unescapeVCard(s string) string {
+	return strings.NewReplacer(`\\`, `\`, `\,`, ",", `\;`, ";", `\n`, "\n", `\N`, "\n").Replace(s)
+}
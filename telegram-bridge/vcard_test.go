@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+)
+
+func TestExportContactsVCard(t *testing.T) {
+	_, api, peers := newFakeClient(contactsClient())
+	path := filepath.Join(t.TempDir(), "contacts.vcf")
+	n, err := exportContactsVCard(context.Background(), api, peers, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("exported %d contacts, want 2", n)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "BEGIN:VCARD\r\nVERSION:3.0\r\nFN:Alice Doe\\, Jr.\r\nN:Doe\\, Jr.;Alice;;;\r\nTEL;TYPE=CELL:+15551234567\r\nX-TELEGRAM:alice\r\nEND:VCARD\r\n" +
+		// No phone number, so no TEL line
+		"BEGIN:VCARD\r\nVERSION:3.0\r\nFN:Bob\r\nN:;Bob;;;\r\nEND:VCARD\r\n"
+	if string(data) != want {
+		t.Errorf("vCard =\n%q\nwant\n%q", data, want)
+	}
+}
+
+func TestWriteVCardFoldsLongLines(t *testing.T) {
+	var b strings.Builder
+	writeVCard(&b, Contact{UserID: 1, FirstName: strings.Repeat("é", 60)})
+	for _, line := range strings.Split(strings.TrimSuffix(b.String(), "\r\n"), "\r\n") {
+		if len(line) > vCardLineLimit {
+			t.Errorf("line of %d octets: %q", len(line), line)
+		}
+	}
+	unfolded := strings.ReplaceAll(b.String(), "\r\n ", "")
+	if !strings.Contains(unfolded, "FN:"+strings.Repeat("é", 60)+"\r\n") {
+		t.Errorf("unfolded vCard lost the name:\n%q", unfolded)
+	}
+}
+
+const sampleVCards = "BEGIN:VCARD\r\nVERSION:3.0\r\nFN:Alice Doe\\, Jr.\r\nN:Doe\\, Jr.;Alice;;;\r\nTEL;TYPE=CELL:+15551234567\r\nEND:VCARD\r\n" +
+	// Folded line, grouped properties and a preferred second number
+	"BEGIN:VCARD\r\nVERSION:3.0\r\nFN:Bob\r\nitem1.TEL;TYPE=HOME:+1555000\r\n 0001\r\nitem2.TEL;TYPE=CELL,PREF:+15550002\r\nEND:VCARD\r\n" +
+	// Malformed: no phone number
+	"BEGIN:VCARD\r\nVERSION:3.0\r\nFN:Carol\r\nEND:VCARD\r\n" +
+	// Malformed: never ended
+	"BEGIN:VCARD\r\nVERSION:3.0\r\nFN:Dave\r\nTEL:+15550003\r\n" +
+	"BEGIN:VCARD\r\nVERSION:2.1\r\nN:;Erin\r\nTEL:+15550004\r\nEND:VCARD\r\n"
+
+func TestParseVCards(t *testing.T) {
+	contacts, skipped := parseVCards(sampleVCards)
+	want := []vCardContact{
+		{FirstName: "Alice", LastName: "Doe, Jr.", Phone: "+15551234567"},
+		{FirstName: "Bob", Phone: "+15550002"},
+		{FirstName: "Erin", Phone: "+15550004"},
+	}
+	if len(contacts) != len(want) {
+		t.Fatalf("contacts = %+v, want %+v", contacts, want)
+	}
+	for i := range want {
+		if contacts[i] != want[i] {
+			t.Errorf("contact %d = %+v, want %+v", i, contacts[i], want[i])
+		}
+	}
+	if skipped != 2 {
+		t.Errorf("skipped = %d, want 2", skipped)
+	}
+}
+
+func TestImportContactsVCard(t *testing.T) {
+	inv, api, peers := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		r, ok := input.(*tg.ContactsImportContactsRequest)
+		if !ok {
+			return nil, nil
+		}
+		// Every other contact has a Telegram account
+		res := &tg.ContactsImportedContacts{}
+		for _, c := range r.Contacts {
+			if c.ClientID%2 == 0 {
+				res.Imported = append(res.Imported, tg.ImportedContact{UserID: 1000 + c.ClientID, ClientID: c.ClientID})
+				res.Users = append(res.Users, &tg.User{ID: 1000 + c.ClientID, AccessHash: 1})
+			}
+		}
+		return res, nil
+	})
+	var b strings.Builder
+	b.WriteString(sampleVCards)
+	for i := 0; i < 150; i++ {
+		writeVCard(&b, Contact{FirstName: "Bulk", Phone: "+1555100" + strconv.Itoa(1000+i)})
+	}
+	path := filepath.Join(t.TempDir(), "contacts.vcf")
+	if err := os.WriteFile(path, []byte(b.String()), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := importContactsVCard(context.Background(), api, peers, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := importContactsResult{Contacts: 153, Skipped: 2, Imported: 77}
+	if result != want {
+		t.Errorf("result = %+v, want %+v", result, want)
+	}
+	reqs := requests[*tg.ContactsImportContactsRequest](inv)
+	if len(reqs) != 2 || len(reqs[0].Contacts) != importContactsChunk || len(reqs[1].Contacts) != 53 {
+		t.Errorf("import requests of %d chunks, want 100 and 53 contacts", len(reqs))
+	}
+	if reqs[0].Contacts[0].FirstName != "Alice" || reqs[0].Contacts[0].Phone != "+15551234567" {
+		t.Errorf("first contact = %+v", reqs[0].Contacts[0])
+	}
+	if _, ok := peers.cached(1000); !ok {
+		t.Error("imported user not cached")
+	}
+}
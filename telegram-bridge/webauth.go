@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gotd/td/tg"
+)
+
+// WebAuth is a website the account logged in to with the Telegram Login
+// Widget
+type WebAuth struct {
+	// Hash identifies the authorization for reset_web_authorization
+	Hash        int64  `json:"hash"`
+	Domain      string `json:"domain"`
+	BotID       int64  `json:"bot_id"`
+	BotUsername string `json:"bot_username,omitempty"`
+	Browser     string `json:"browser"`
+	Platform    string `json:"platform"`
+	IP          string `json:"ip"`
+	Region      string `json:"region"`
+	DateCreated int    `json:"date_created"`
+	DateActive  int    `json:"date_active"`
+}
+
+func listWebAuthorizationsTool(api *tg.Client) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args struct{}
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		return listWebAuthorizations(ctx, api)
+	}
+}
+
+type resetWebAuthorizationArgs struct {
+	Hash int64 `json:"hash"`
+}
+
+type resetResult struct {
+	Reset bool `json:"reset"`
+}
+
+func resetWebAuthorizationTool(api *tg.Client) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args resetWebAuthorizationArgs
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if args.Hash == 0 {
+			return nil, invalidParams("hash is required")
+		}
+
+		ok, err := api.AccountResetWebAuthorization(ctx, args.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to reset web authorization: %w", err)
+		}
+		return resetResult{Reset: ok}, nil
+	}
+}
+
+func resetWebAuthorizationsTool(api *tg.Client) ToolHandler {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args struct{}
+		if err := decodeArgs(raw, &args); err != nil {
+			return nil, err
+		}
+
+		ok, err := api.AccountResetWebAuthorizations(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to reset web authorizations: %w", err)
+		}
+		return resetResult{Reset: ok}, nil
+	}
+}
+
+// listWebAuthorizations returns the account's website logins
+func listWebAuthorizations(ctx context.Context, api *tg.Client) ([]WebAuth, error) {
+	res, err := api.AccountGetWebAuthorizations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get web authorizations: %w", err)
+	}
+	return newWebAuths(res), nil
+}
+
+// newWebAuths converts a web authorizations response, naming each login's
+// bot from the users sent along with it
+func newWebAuths(res *tg.AccountWebAuthorizations) []WebAuth {
+	bots := make(map[int64]string)
+	for _, u := range res.Users {
+		if u, ok := u.(*tg.User); ok {
+			bots[u.ID] = u.Username
+		}
+	}
+
+	auths := make([]WebAuth, 0, len(res.Authorizations))
+	for _, a := range res.Authorizations {
+		auths = append(auths, WebAuth{
+			Hash:        a.Hash,
+			Domain:      a.Domain,
+			BotID:       a.BotID,
+			BotUsername: bots[a.BotID],
+			Browser:     a.Browser,
+			Platform:    a.Platform,
+			IP:          a.IP,
+			Region:      a.Region,
+			DateCreated: a.DateCreated,
+			DateActive:  a.DateActive,
+		})
+	}
+	return auths
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+)
+
+func TestNewWebAuths(t *testing.T) {
+	res := &tg.AccountWebAuthorizations{
+		Authorizations: []tg.WebAuthorization{
+			{Hash: 1, BotID: 100, Domain: "example.com", Browser: "Firefox", Platform: "Linux", IP: "192.0.2.1", Region: "NL", DateCreated: 10, DateActive: 20},
+			{Hash: 2, BotID: 200, Domain: "example.org"},
+		},
+		Users: []tg.UserClass{&tg.User{ID: 100, Bot: true, Username: "loginbot"}},
+	}
+
+	got := newWebAuths(res)
+	want := []WebAuth{
+		{Hash: 1, Domain: "example.com", BotID: 100, BotUsername: "loginbot", Browser: "Firefox", Platform: "Linux", IP: "192.0.2.1", Region: "NL", DateCreated: 10, DateActive: 20},
+		// The bot was not sent along, so it stays unnamed
+		{Hash: 2, Domain: "example.org", BotID: 200},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d authorizations, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("authorization %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	if empty := newWebAuths(&tg.AccountWebAuthorizations{}); empty == nil || len(empty) != 0 {
+		t.Errorf("no authorizations = %#v, want empty list", empty)
+	}
+}
+
+func TestResetWebAuthorizationTools(t *testing.T) {
+	inv, api, _ := newFakeClient(func(input bin.Encoder) (bin.Encoder, error) {
+		switch input.(type) {
+		case *tg.AccountResetWebAuthorizationRequest, *tg.AccountResetWebAuthorizationsRequest:
+			return &tg.BoolTrue{}, nil
+		}
+		return nil, nil
+	})
+	ctx := context.Background()
+
+	res, err := resetWebAuthorizationTool(api)(ctx, json.RawMessage(`{"hash":77}`))
+	if err != nil || res != (resetResult{Reset: true}) {
+		t.Fatalf("reset_web_authorization = %v, %v", res, err)
+	}
+	if reqs := requests[*tg.AccountResetWebAuthorizationRequest](inv); len(reqs) != 1 || reqs[0].Hash != 77 {
+		t.Errorf("reset requests = %+v", reqs)
+	}
+	if _, err := resetWebAuthorizationTool(api)(ctx, json.RawMessage(`{}`)); err == nil {
+		t.Error("reset without hash succeeded")
+	}
+
+	res, err = resetWebAuthorizationsTool(api)(ctx, json.RawMessage(`{}`))
+	if err != nil || res != (resetResult{Reset: true}) {
+		t.Fatalf("reset_web_authorizations = %v, %v", res, err)
+	}
+	if reqs := requests[*tg.AccountResetWebAuthorizationsRequest](inv); len(reqs) != 1 {
+		t.Errorf("got %d reset-all requests, want 1", len(reqs))
+	}
+}